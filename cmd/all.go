@@ -0,0 +1,89 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/streamfold/otel-loadgen/internal/telemetry"
+	"github.com/streamfold/otel-loadgen/internal/worker"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// allCmd represents the all command
+var allCmd = &cobra.Command{
+	Use:   "all",
+	Short: "Generate OTLP traces, metrics and logs together",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runAllCmd(); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var correlateSignals bool
+var exemplarsPerPoint int
+
+func init() {
+	genCmd.AddCommand(allCmd)
+
+	addTracesFlags(allCmd.Flags())
+	addMetricsFlags(allCmd.Flags())
+	addLogsFlags(allCmd.Flags())
+	allCmd.Flags().BoolVar(&correlateSignals, "correlate-signals", false, "Reference emitted spans from logs and metric exemplars of the same resource")
+	allCmd.Flags().IntVar(&exemplarsPerPoint, "exemplars-per-point", 1, "Number of exemplars on each metric data point with --correlate-signals, each referencing a different span")
+}
+
+func runAllCmd() error {
+	zl, err := zap.NewDevelopment(zap.IncreaseLevel(zapcore.InfoLevel))
+	if err != nil {
+		return err
+	}
+
+	exportCfg, err := newExportConfig()
+	if err != nil {
+		return err
+	}
+
+	tracesCfg, err := newTracesConfig(zl)
+	if err != nil {
+		return err
+	}
+
+	metricsCfg, err := newMetricsConfig()
+	if err != nil {
+		return err
+	}
+
+	logsCfg, err := newLogsConfig(zl)
+	if err != nil {
+		return err
+	}
+
+	if exemplarsPerPoint < 1 {
+		return fmt.Errorf("--exemplars-per-point must be > 0")
+	}
+
+	if correlateSignals {
+		correlator := telemetry.NewCorrelator()
+		tracesCfg.Correlator = correlator
+		metricsCfg.Correlator = correlator
+		metricsCfg.ExemplarsPerPoint = exemplarsPerPoint
+		logsCfg.Correlator = correlator
+	}
+
+	return runGenerator(zl, exportCfg, func(workers *worker.Workers) error {
+		if err := workers.Add("OTLP Traces", telemetry.NewTracesWorker(zl, exportCfg, tracesCfg)); err != nil {
+			return err
+		}
+		if err := workers.Add("OTLP Metrics", telemetry.NewMetricsWorker(zl, exportCfg, metricsCfg)); err != nil {
+			return err
+		}
+
+		return workers.Add("OTLP Logs", telemetry.NewLogsWorker(zl, exportCfg, logsCfg))
+	})
+}
@@ -0,0 +1,110 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// applyConfigFile loads the --config file, if any, into the flags of cmd
+func applyConfigFile(cmd *cobra.Command) error {
+	if configFile == "" {
+		return nil
+	}
+
+	unknown, err := loadConfigFile(configFile, cmd.Flags())
+	if err != nil {
+		return err
+	}
+
+	for _, key := range unknown {
+		// A suite may share one file across the gen subcommands, only keys
+		// that no subcommand accepts are likely mistakes
+		if isGenFlag(key) {
+			continue
+		}
+		log.Printf("WARNING: ignoring unknown key %q in config file %s", key, configFile)
+	}
+
+	return nil
+}
+
+// isGenFlag returns true if key is a flag of any gen subcommand
+func isGenFlag(key string) bool {
+	for _, sub := range genCmd.Commands() {
+		if sub.Flags().Lookup(key) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// loadConfigFile reads a YAML or JSON document of flag names to values and
+// applies it to the flags that weren't set on the command line, so flags
+// always override the file. Keys that don't match a flag are returned.
+func loadConfigFile(path string, flags *pflag.FlagSet) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	// JSON is a subset of YAML, so both are parsed the same way
+	values := make(map[string]any)
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	unknown := make([]string, 0)
+	for _, key := range keys {
+		flag := flags.Lookup(key)
+		if flag == nil || key == "config" {
+			unknown = append(unknown, key)
+			continue
+		}
+		if flag.Changed {
+			continue
+		}
+
+		if err := setFlagValue(flag, values[key]); err != nil {
+			return nil, fmt.Errorf("invalid value for %s in config file %s: %w", key, path, err)
+		}
+	}
+
+	return unknown, nil
+}
+
+func setFlagValue(flag *pflag.Flag, value any) error {
+	if list, ok := value.([]any); ok {
+		sv, ok := flag.Value.(pflag.SliceValue)
+		if !ok {
+			return fmt.Errorf("expected a single value, got a list")
+		}
+
+		items := make([]string, 0, len(list))
+		for _, item := range list {
+			items = append(items, fmt.Sprint(item))
+		}
+		return sv.Replace(items)
+	}
+
+	switch value.(type) {
+	case map[string]any, nil:
+		return fmt.Errorf("expected a scalar value")
+	}
+
+	return flag.Value.Set(fmt.Sprint(value))
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+type testFlags struct {
+	endpoint string
+	workers  int
+	interval time.Duration
+	insecure bool
+	headers  []string
+}
+
+func newTestFlagSet(f *testFlags) *pflag.FlagSet {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.StringVar(&f.endpoint, "otlp-endpoint", "localhost:4317", "")
+	flags.IntVar(&f.workers, "workers", 1, "")
+	flags.DurationVar(&f.interval, "push-interval", 50*time.Millisecond, "")
+	flags.BoolVar(&f.insecure, "tls-insecure-skip-verify", false, "")
+	flags.StringArrayVar(&f.headers, "otlp-header", []string{}, "")
+	return flags
+}
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigFile_YAML(t *testing.T) {
+	path := writeConfigFile(t, "bench.yaml", `
+otlp-endpoint: collector:4317
+workers: 8
+push-interval: 10ms
+tls-insecure-skip-verify: true
+otlp-header:
+  - authorization=Bearer ${API_KEY}
+  - x-tenant=bench
+spans-per-second: 100
+`)
+
+	var f testFlags
+	flags := newTestFlagSet(&f)
+	if err := flags.Parse([]string{"--workers", "2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	unknown, err := loadConfigFile(path, flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f.endpoint != "collector:4317" {
+		t.Errorf("Expected endpoint from file, got %s", f.endpoint)
+	}
+	if f.workers != 2 {
+		t.Errorf("Expected command line workers to override the file, got %d", f.workers)
+	}
+	if f.interval != 10*time.Millisecond {
+		t.Errorf("Expected push interval from file, got %s", f.interval)
+	}
+	if !f.insecure {
+		t.Error("Expected bool flag from file")
+	}
+	expectedHeaders := []string{"authorization=Bearer ${API_KEY}", "x-tenant=bench"}
+	if !reflect.DeepEqual(f.headers, expectedHeaders) {
+		t.Errorf("Expected headers %v, got %v", expectedHeaders, f.headers)
+	}
+	if !reflect.DeepEqual(unknown, []string{"spans-per-second"}) {
+		t.Errorf("Expected unknown key to be reported, got %v", unknown)
+	}
+}
+
+func TestLoadConfigFile_JSON(t *testing.T) {
+	path := writeConfigFile(t, "bench.json", `{"otlp-endpoint": "collector:4318", "workers": 4}`)
+
+	var f testFlags
+	flags := newTestFlagSet(&f)
+	unknown, err := loadConfigFile(path, flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f.endpoint != "collector:4318" || f.workers != 4 {
+		t.Errorf("Expected values from JSON file, got %s and %d", f.endpoint, f.workers)
+	}
+	if len(unknown) != 0 {
+		t.Errorf("Expected no unknown keys, got %v", unknown)
+	}
+}
+
+func TestLoadConfigFile_InvalidValue(t *testing.T) {
+	path := writeConfigFile(t, "bench.yaml", "workers: many\n")
+
+	var f testFlags
+	if _, err := loadConfigFile(path, newTestFlagSet(&f)); err == nil {
+		t.Error("Expected error for invalid value")
+	}
+}
@@ -5,62 +5,180 @@ package cmd
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"net/url"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/streamfold/otel-loadgen/internal/compression"
+	"github.com/streamfold/otel-loadgen/internal/control"
+	"github.com/streamfold/otel-loadgen/internal/otlp"
+	"github.com/streamfold/otel-loadgen/internal/stats"
+	"github.com/streamfold/otel-loadgen/internal/telemetry"
+	"github.com/streamfold/otel-loadgen/internal/util"
+	"github.com/streamfold/otel-loadgen/internal/worker"
+	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+	"go.uber.org/zap"
 )
 
 // genCmd represents the gen command
 var genCmd = &cobra.Command{
 	Use:   "gen",
 	Run: func(cmd *cobra.Command, args []string) {
-		log.Fatal("Choose a subcommand: traces")
+		log.Fatal("Choose a subcommand: traces, metrics, logs, all, retry-missing")
 	},
 }
 
+var configFile string
+
 var otlpEndpoint string
 var otlpResourcesPerBatch int
+var resourcesDistribution string
 
 var duration time.Duration
 var reportInterval time.Duration
 var pushInterval time.Duration
 
 var controlEndpoint string
+var controlRequired bool
+var controlOptional bool
+var drainTimeout time.Duration
+var controlBuffer int
+var controlOverflow string
+var controlBatchSize int
+var controlBatchTimeout time.Duration
+var controlTransport string
+var generatorIDPrefix string
+var elementGeneratorID bool
+var rangeSize uint
+var rampUp time.Duration
 
 var numWorkers int
+var buildQueueSize int
+var maxRetries int
 
 var customHeaders []string
+var otlpHeaders []string
+
+var useHTTP bool
+var httpEncoding string
+var compressionType string
+
+var baseTime string
+var resourceDetectors []string
+var runMeta []string
+var ciDetect bool
+var corpusMode string
+var corpusSeed int64
+var corpusFormat string
+var corpusStream bool
+var serviceName string
+var resourceAttrs []string
+var scopeName string
+var scopeVersion string
+var scopeAttrs []string
+
+var tlsCAFile string
+var tlsCertFile string
+var tlsKeyFile string
+var tlsInsecureSkipVerify bool
+var tlsServerName string
+var connMaxAge time.Duration
+var grpcWaitForReady bool
+var probeCompression bool
+var summaryFile string
+var statsCSV string
+var resourceReport bool
+var statsFormat string
 
 func init() {
 	rootCmd.AddCommand(genCmd)
+
+	// Set here, as applyConfigFile refers back to genCmd
+	genCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		return applyConfigFile(cmd)
+	}
+	genCmd.PersistentFlags().StringVar(&configFile, "config", "", "YAML or JSON file of flag values, flags given on the command line take precedence")
 	
 	genCmd.PersistentFlags().StringVar(&otlpEndpoint, "otlp-endpoint", "localhost:4317", "OTLP endpoint for exporting logs, metrics, and traces")
 	genCmd.PersistentFlags().IntVar(&otlpResourcesPerBatch, "otlp-resources-per-batch", 1, "OTLP number of resources per batch")
+	genCmd.PersistentFlags().StringVar(&resourcesDistribution, "resources-per-batch-distribution", "fixed", "How the number of resources varies between batches (fixed, uniform, skewed), the mean stays close to --otlp-resources-per-batch")
 	
 	genCmd.PersistentFlags().DurationVar(&duration, "duration", 0, "How long to run generator for, defaults to forever")
 	genCmd.PersistentFlags().DurationVar(&reportInterval, "report-interval", 3 * time.Second, "Interval to report statistics")
 	genCmd.PersistentFlags().DurationVar(&pushInterval, "push-interval", 50 * time.Millisecond, "Interval between push of batches")
 	
 	genCmd.PersistentFlags().IntVar(&numWorkers, "workers", 1, "How many concurrent workers to run")
+	genCmd.PersistentFlags().DurationVar(&rampUp, "ramp-up", 0, "Start workers gradually, linearly increasing the active workers over this window")
+	genCmd.PersistentFlags().IntVar(&maxRetries, "max-retries", 3, "How many times a failed export is retried, with exponential backoff, before the batch is dropped")
+	genCmd.PersistentFlags().IntVar(&buildQueueSize, "build-queue-size", 0, "Number of built batches that can wait for export per worker, 0 disables the queue")
 	
 	genCmd.PersistentFlags().StringVar(&controlEndpoint, "control-endpoint", "", "Endpoint of control server")
+	genCmd.PersistentFlags().BoolVar(&controlRequired, "control-required", false, "Fail at startup if the control server is unreachable")
+	genCmd.PersistentFlags().BoolVar(&controlOptional, "control-optional", false, "Disable message tracking if the control server is unreachable at startup")
+	genCmd.PersistentFlags().DurationVar(&drainTimeout, "drain-timeout", 10*time.Second, "How long to wait on shutdown for the final message ranges to reach the control server before dropping them, 0 waits until all are sent")
+	genCmd.PersistentFlags().DurationVar(&drainTimeout, "control-flush-timeout", 10*time.Second, "Alias of --drain-timeout")
+	_ = genCmd.PersistentFlags().MarkDeprecated("control-flush-timeout", "use --drain-timeout")
+	genCmd.PersistentFlags().IntVar(&controlBuffer, "control-buffer", control.DefaultBufferSize, "Number of message range notifications queued for the control server")
+	genCmd.PersistentFlags().StringVar(&controlOverflow, "control-overflow", "block", "What generators do when the control queue is full: block waits for room, drop drops new range notifications to keep the generator rate (block, drop)")
+	genCmd.PersistentFlags().StringVar(&controlTransport, "control-transport", "http", "Protocol to publish message ranges with, grpc streams them to the sink's --control-grpc-addr given as --control-endpoint (http, grpc)")
+	genCmd.PersistentFlags().IntVar(&controlBatchSize, "control-batch-size", 1, "Post up to this many message range notifications to the control server in one request, 1 posts each on its own")
+	genCmd.PersistentFlags().DurationVar(&controlBatchTimeout, "control-batch-timeout", 100*time.Millisecond, "Longest a message range notification waits for its batch to fill")
+	genCmd.PersistentFlags().StringVar(&generatorIDPrefix, "generator-id-prefix", "", "Name generators <prefix>-<index> instead of random UUIDs, so runs can be compared")
+	genCmd.PersistentFlags().BoolVar(&elementGeneratorID, "element-generator-id", false, "Also add the generator ID to every span, log record and data point, so delivery is tracked when a pipeline drops or rewrites resource attributes")
+	genCmd.PersistentFlags().UintVar(&rangeSize, "range-size", worker.ALLOC_SIZE, "Number of message IDs in each range reported to the control server, larger ranges send fewer controls but use more sink memory per range")
 
 	genCmd.PersistentFlags().StringSliceVar(&customHeaders, "header", []string{}, "Custom headers to send (format: 'Key=Value', can be repeated)")
+	genCmd.PersistentFlags().StringArrayVar(&otlpHeaders, "otlp-header", []string{}, "OTLP header or gRPC metadata to send, values support ${ENV_VAR} expansion (format: 'key=value', can be repeated)")
+
+	genCmd.PersistentFlags().BoolVar(&useHTTP, "http", false, "Use HTTP instead of gRPC for OTLP export")
+	genCmd.PersistentFlags().StringVar(&httpEncoding, "http-encoding", "protobuf", "Payload encoding for HTTP export (json, protobuf)")
+	genCmd.PersistentFlags().StringVar(&compressionType, "compression", "gzip", "Compression for exported payloads (gzip, zstd, none)")
+
+	genCmd.PersistentFlags().StringVar(&tlsCAFile, "tls-ca", "", "PEM CA bundle to trust for https endpoints, in addition to the system pool")
+	genCmd.PersistentFlags().StringVar(&tlsCertFile, "tls-cert", "", "PEM client certificate for mutual TLS (requires --tls-key)")
+	genCmd.PersistentFlags().StringVar(&tlsKeyFile, "tls-key", "", "PEM client private key for mutual TLS (requires --tls-cert)")
+	genCmd.PersistentFlags().BoolVar(&tlsInsecureSkipVerify, "tls-insecure-skip-verify", false, "Skip verification of the server certificate")
+	genCmd.PersistentFlags().StringVar(&tlsServerName, "server-name", "", "Override the server name used to verify the server certificate")
+	genCmd.PersistentFlags().DurationVar(&connMaxAge, "conn-max-age", 0, "Replace export connections older than this once idle so DNS is re-resolved and load re-spreads, 0 keeps connections open")
+	genCmd.PersistentFlags().BoolVar(&probeCompression, "probe-compression", true, "Send an empty export on startup and warn if the endpoint doesn't support the configured compression")
+	genCmd.PersistentFlags().BoolVar(&grpcWaitForReady, "grpc-wait-for-ready", false, "Queue gRPC exports until the connection is ready, bounded by the export timeout, instead of failing while the endpoint is down")
+
+	genCmd.PersistentFlags().StringVar(&statsFormat, "stats-format", "text", "Format of the periodic stats report (text, json), json prints a line per domain")
+	genCmd.PersistentFlags().StringVar(&statsCSV, "stats-csv", "", "Append every stats report to this CSV file, one row per stat")
+	genCmd.PersistentFlags().BoolVar(&resourceReport, "resource-report", false, "Log the generator's CPU time and allocated bytes per generated element every report interval")
+	genCmd.PersistentFlags().StringVar(&summaryFile, "summary-file", "", "Write a JSON summary of the run to this file on shutdown, see 'summary diff'")
+	genCmd.PersistentFlags().StringVar(&corpusMode, "corpus-mode", "round-robin", "Order gen_ai corpus entries are used in (round-robin, random)")
+	genCmd.PersistentFlags().Int64Var(&corpusSeed, "corpus-seed", 0, "Seed for random corpus sampling and the random values of gen_ai attributes, e.g. conversation IDs, models and temperatures, so runs are reproducible, 0 picks a random seed")
+	genCmd.PersistentFlags().StringVar(&corpusFormat, "corpus-format", "auto", "Format of gen_ai corpus files (auto, json, jsonl, sharegpt), auto picks jsonl for .jsonl files")
+	genCmd.PersistentFlags().BoolVar(&corpusStream, "corpus-stream", false, "Stream gen_ai corpora from disk instead of loading them into memory, entries are used in file order")
+	genCmd.PersistentFlags().StringVar(&serviceName, "service-name", otlp.DefaultServiceName, "service.name of the generated resources")
+	genCmd.PersistentFlags().StringArrayVar(&resourceAttrs, "resource-attr", []string{}, "Attribute added to every resource, replacing a built-in one with the same key (format: 'key=value' or 'key=value:type' with type string, int, double or bool, can be repeated)")
+	genCmd.PersistentFlags().StringVar(&scopeName, "scope-name", otlp.DefaultScopeName, "Instrumentation scope name of the generated telemetry")
+	genCmd.PersistentFlags().StringVar(&scopeVersion, "scope-version", otlp.DefaultScopeVersion, "Instrumentation scope version of the generated telemetry")
+	genCmd.PersistentFlags().StringArrayVar(&scopeAttrs, "scope-attr", []string{}, "Instrumentation scope attribute of the generated telemetry (format: 'key=value' or 'key=value:type', can be repeated)")
+	genCmd.PersistentFlags().StringArrayVar(&runMeta, "meta", []string{}, "Run metadata added as a resource attribute, e.g. the PR being tested (format: 'key=value', can be repeated)")
+	genCmd.PersistentFlags().BoolVar(&ciDetect, "ci-detect", true, "Add the build id, revision, branch and PR of a detected CI system (GitHub Actions, GitLab CI, CircleCI, Buildkite, Jenkins) as resource attributes")
+	genCmd.PersistentFlags().StringSliceVar(&resourceDetectors, "resource-detectors", []string{}, "Add detected resource attributes like a real SDK (host, os, process, sdk, all)")
+	genCmd.PersistentFlags().StringVar(&baseTime, "base-time", "", "Fixed base time for generated timestamps (RFC3339), defaults to the current time")
 }
 
 func defaultTransportDialContext(dialer *net.Dialer) func(context.Context, string, string) (net.Conn, error) {
 	return dialer.DialContext
 }
 
-func newClient() *http.Client {
+func newClient(tlsConfig *tls.Config) *http.Client {
 	client := &http.Client{
-		Transport: &http.Transport{
+		Transport: telemetry.ConnMaxAgeTransport(&http.Transport{
+			TLSClientConfig: tlsConfig,
 			DialContext: defaultTransportDialContext(&net.Dialer{
 				Timeout:   30 * time.Second,
 				KeepAlive: 30 * time.Second,
@@ -72,7 +190,7 @@ func newClient() *http.Client {
 			IdleConnTimeout:       90 * time.Second,
 			TLSHandshakeTimeout:   10 * time.Second,
 			ExpectContinueTimeout: 1 * time.Second,
-		},
+		}, connMaxAge),
 		Timeout: 3 * time.Second,
 	}
 
@@ -80,6 +198,13 @@ func newClient() *http.Client {
 }
 
 func parseOtlpEndpoint() (*url.URL, error) {
+	if util.IsUnixAddr(otlpEndpoint) {
+		if useHTTP {
+			return nil, fmt.Errorf("unix socket endpoints are only supported with gRPC export")
+		}
+		return url.Parse(otlpEndpoint)
+	}
+
 	if !strings.HasPrefix(otlpEndpoint, "http://") && !strings.HasPrefix(otlpEndpoint, "https://") {
 		otlpEndpoint = fmt.Sprintf("http://%s", otlpEndpoint)
 	}
@@ -88,13 +213,216 @@ func parseOtlpEndpoint() (*url.URL, error) {
 }
 
 func parseCustomHeaders() (map[string]string, error) {
-	headers := make(map[string]string)
-	for _, h := range customHeaders {
-		parts := strings.SplitN(h, "=", 2)
-		if len(parts) != 2 {
-			return nil, fmt.Errorf("invalid header format: %q (expected 'Key=Value')", h)
+	return util.ParseHeaders(append(append([]string{}, customHeaders...), otlpHeaders...))
+}
+
+func newExportConfig() (telemetry.ExportConfig, error) {
+	endpoint, err := parseOtlpEndpoint()
+	if err != nil {
+		return telemetry.ExportConfig{}, err
+	}
+
+	headers, err := parseCustomHeaders()
+	if err != nil {
+		return telemetry.ExportConfig{}, err
+	}
+
+	encoding, err := telemetry.ParseHTTPEncoding(httpEncoding)
+	if err != nil {
+		return telemetry.ExportConfig{}, err
+	}
+
+	comp, err := compression.Parse(compressionType)
+	if err != nil {
+		return telemetry.ExportConfig{}, err
+	}
+
+	tlsConfig, err := telemetry.TLSOptions{
+		CAFile:             tlsCAFile,
+		CertFile:           tlsCertFile,
+		KeyFile:            tlsKeyFile,
+		InsecureSkipVerify: tlsInsecureSkipVerify,
+		ServerName:         tlsServerName,
+	}.Config()
+	if err != nil {
+		return telemetry.ExportConfig{}, err
+	}
+
+	return telemetry.ExportConfig{
+		Endpoint:         endpoint,
+		UseGRPC:          !useHTTP,
+		HTTPEncoding:     encoding,
+		Compression:      comp,
+		CustomHeaders:    headers,
+		TLS:              tlsConfig,
+		MaxRetries:       maxRetries,
+		ConnMaxAge:       connMaxAge,
+		GRPCWaitForReady: grpcWaitForReady,
+		ProbeCompression: probeCompression,
+	}, nil
+}
+
+// parseBaseTime returns the --base-time value, or the zero time if unset
+func parseBaseTime() (time.Time, error) {
+	if baseTime == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, baseTime)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid base time: %w", err)
+	}
+	return t, nil
+}
+
+// checkBatchSize rejects batch sizes that would build empty batches
+func checkBatchSize(perResourceFlag string, perResource int) error {
+	if otlpResourcesPerBatch < 1 {
+		return fmt.Errorf("--otlp-resources-per-batch must be > 0")
+	}
+	if perResource < 1 {
+		return fmt.Errorf("--%s must be > 0", perResourceFlag)
+	}
+	return nil
+}
+
+// detectResourceAttrs returns the resource attributes of the --resource-detectors,
+// followed by the run metadata of the CI system and --meta
+func detectResourceAttrs() ([]*otlpCommon.KeyValue, error) {
+	detectors, err := otlp.ParseResourceDetectors(resourceDetectors)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := otlp.ParseMeta(runMeta)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := otlp.DetectResourceAttrs(detectors)
+	if ciDetect {
+		attrs = otlp.MergeAttrs(attrs, otlp.DetectCIAttrs(os.Getenv))
+	}
+	return otlp.MergeAttrs(attrs, meta), nil
+}
+
+// resourceConfig returns the service name and --resource-attr attributes
+// shared by all signals
+func resourceConfig() (otlp.ResourceConfig, error) {
+	attrs, err := otlp.ParseAttrs(resourceAttrs)
+	if err != nil {
+		return otlp.ResourceConfig{}, fmt.Errorf("--resource-attr: %w", err)
+	}
+	return otlp.ResourceConfig{ServiceName: serviceName, Attributes: attrs}, nil
+}
+
+// scopeConfig returns the instrumentation scope shared by all signals
+func scopeConfig() (otlp.ScopeConfig, error) {
+	attrs, err := otlp.ParseAttrs(scopeAttrs)
+	if err != nil {
+		return otlp.ScopeConfig{}, fmt.Errorf("--scope-attr: %w", err)
+	}
+	return otlp.ScopeConfig{Name: scopeName, Version: scopeVersion, Attributes: attrs}, nil
+}
+
+// runGenerator runs the workers added by addWorkers until the test duration
+// is reached or the process is signalled
+func runGenerator(zl *zap.Logger, exportCfg telemetry.ExportConfig, addWorkers func(workers *worker.Workers) error) error {
+	if controlRequired && controlOptional {
+		return fmt.Errorf("--control-required and --control-optional are mutually exclusive")
+	}
+	if rangeSize == 0 {
+		return fmt.Errorf("--range-size must be > 0")
+	}
+	if controlBuffer <= 0 {
+		return fmt.Errorf("--control-buffer must be > 0")
+	}
+	if controlBatchSize <= 0 {
+		return fmt.Errorf("--control-batch-size must be > 0")
+	}
+	overflow, err := control.ParseOverflow(controlOverflow)
+	if err != nil {
+		return err
+	}
+	transport, err := control.ParseTransport(controlTransport)
+	if err != nil {
+		return err
+	}
+
+	controlPolicy := worker.ControlPolicyWarn
+	if controlRequired {
+		controlPolicy = worker.ControlPolicyRequired
+	} else if controlOptional {
+		controlPolicy = worker.ControlPolicyOptional
+	}
+
+	format, err := stats.ParseFormat(statsFormat)
+	if err != nil {
+		return err
+	}
+
+	workerCfg := worker.Config{
+		NumWorkers:          numWorkers,
+		ReportInterval:      reportInterval,
+		PushInterval:        pushInterval,
+		ControlEndpoint:     controlEndpoint,
+		ControlPolicy:       controlPolicy,
+		DrainTimeout:        drainTimeout,
+		ControlBuffer:       controlBuffer,
+		ControlOverflow:     overflow,
+		ControlBatchSize:    controlBatchSize,
+		ControlBatchTimeout: controlBatchTimeout,
+		ControlTransport:    transport,
+		RampUp:              rampUp,
+		GeneratorIDPrefix:   generatorIDPrefix,
+		ElementGeneratorID:  elementGeneratorID,
+		RangeSize:           rangeSize,
+		StatsFormat:         format,
+		StatsCSV:            statsCSV,
+		ResourceReport:      resourceReport,
+	}
+
+	workers, err := worker.New(workerCfg, zl, newClient(exportCfg.TLS))
+	if err != nil {
+		return err
+	}
+
+	if err := addWorkers(workers); err != nil {
+		return err
+	}
+
+	zl.Info("Load generator has been started")
+	workers.Start()
+
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(
+		signalChan,
+		syscall.SIGHUP,  // kill -SIGHUP XXXX
+		syscall.SIGINT,  // kill -SIGINT XXXX or Ctrl+c
+		syscall.SIGQUIT, // kill -SIGQUIT XXXX
+	)
+
+	if duration.Milliseconds() != 0 {
+		t := time.NewTimer(duration)
+		select {
+		case <-t.C:
+			zl.Info("reached test duration", zap.Duration("duration", duration))
+		case sig := <-signalChan:
+			zl.Info("killed with signal", zap.String("signal", sig.String()))
 		}
-		headers[parts[0]] = parts[1]
+	} else {
+		sig := <-signalChan
+		zl.Info("killed with signal", zap.String("signal", sig.String()))
 	}
-	return headers, nil
-}
\ No newline at end of file
+	zl.Info("shutting down")
+
+	workers.Stop()
+
+	if summaryFile != "" {
+		if err := workers.Summary(time.Now()).Write(summaryFile); err != nil {
+			return err
+		}
+		zl.Info("wrote run summary", zap.String("path", summaryFile))
+	}
+
+	return nil
+}
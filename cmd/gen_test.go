@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+	"go.uber.org/zap"
+)
+
+func TestNewConfig_RejectsEmptyBatches(t *testing.T) {
+	defer func(res, spans, metrics, logs int) {
+		otlpResourcesPerBatch, spansPerResource, metricsPerResource, logsPerResource = res, spans, metrics, logs
+	}(otlpResourcesPerBatch, spansPerResource, metricsPerResource, logsPerResource)
+
+	newConfigs := map[string]func() error{
+		"spans-per-resource": func() error {
+			_, err := newTracesConfig(zap.NewNop())
+			return err
+		},
+		"metrics-per-resource": func() error {
+			_, err := newMetricsConfig()
+			return err
+		},
+		"logs-per-resource": func() error {
+			_, err := newLogsConfig(zap.NewNop())
+			return err
+		},
+	}
+
+	for flag, newConfig := range newConfigs {
+		otlpResourcesPerBatch, spansPerResource, metricsPerResource, logsPerResource = 0, 1, 1, 1
+		if err := newConfig(); err == nil || !strings.Contains(err.Error(), "--otlp-resources-per-batch") {
+			t.Errorf("%s: expected --otlp-resources-per-batch error, got %v", flag, err)
+		}
+
+		otlpResourcesPerBatch, spansPerResource, metricsPerResource, logsPerResource = 1, 0, 0, 0
+		if err := newConfig(); err == nil || !strings.Contains(err.Error(), "--"+flag) {
+			t.Errorf("%s: expected --%s error, got %v", flag, flag, err)
+		}
+	}
+}
+
+func TestNewConfig_RunMetadata(t *testing.T) {
+	defer func(meta []string, detect bool) {
+		runMeta, ciDetect = meta, detect
+	}(runMeta, ciDetect)
+
+	t.Setenv("GITHUB_ACTIONS", "true")
+	t.Setenv("GITHUB_RUN_ID", "42")
+	t.Setenv("GITHUB_SHA", "0123abcd")
+	runMeta, ciDetect = []string{"vcs.pull_request=1234", "ci.build.id=override"}, true
+
+	traces, err := newTracesConfig(zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics, err := newMetricsConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs, err := newLogsConfig(zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"ci.provider":      "github_actions",
+		"ci.build.id":      "override",
+		"vcs.revision":     "0123abcd",
+		"vcs.pull_request": "1234",
+	}
+	for signal, attrs := range map[string][]*otlpCommon.KeyValue{
+		"traces":  traces.ResourceAttrs,
+		"metrics": metrics.ResourceAttrs,
+		"logs":    logs.ResourceAttrs,
+	} {
+		got := make(map[string]string)
+		for _, kv := range attrs {
+			got[kv.Key] = kv.GetValue().GetStringValue()
+		}
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("%s: expected %s=%q, got %q", signal, k, v, got[k])
+			}
+		}
+	}
+
+	ciDetect = false
+	traces, err = newTracesConfig(zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, kv := range traces.ResourceAttrs {
+		if kv.Key == "ci.provider" {
+			t.Error("Expected no CI metadata with --ci-detect=false")
+		}
+	}
+}
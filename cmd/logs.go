@@ -0,0 +1,131 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/streamfold/otel-loadgen/internal/genai"
+	"github.com/streamfold/otel-loadgen/internal/telemetry"
+	"github.com/streamfold/otel-loadgen/internal/worker"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// logsCmd represents the logs command
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Generate OTLP log records",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runLogsCmd(); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var logsPerResource int
+var logsGenAICorpusPath string
+var logBody string
+var severityMix string
+var severitySeed int64
+
+func init() {
+	genCmd.AddCommand(logsCmd)
+
+	addLogsFlags(logsCmd.Flags())
+}
+
+func addLogsFlags(flags *pflag.FlagSet) {
+	flags.IntVar(&logsPerResource, "logs-per-resource", 100, "How many log records per resource to generate")
+	flags.StringVar(&logsGenAICorpusPath, "logs-genai-corpus", "", "Path to a gen_ai corpus, log records are generated as the gen_ai events of its conversations")
+	flags.StringVar(&logBody, "log-body", "string", "Shape of the log records: a plaintext message, a structured log as a nested kvlist body, or a message with request attributes (string, json, kvlist)")
+	flags.StringVar(&severityMix, "severity-mix", "", "Relative weights of log severities, by default records cycle through a fixed set of messages (format: 'trace:1,debug:10,info:70,warn:15,error:4,fatal:0')")
+	flags.Int64Var(&severitySeed, "severity-seed", 0, "Seed for assigning severities, the severity of each record index is fixed for a seed")
+}
+
+func runLogsCmd() error {
+	zl, err := zap.NewDevelopment(zap.IncreaseLevel(zapcore.InfoLevel))
+	if err != nil {
+		return err
+	}
+
+	exportCfg, err := newExportConfig()
+	if err != nil {
+		return err
+	}
+
+	logsCfg, err := newLogsConfig(zl)
+	if err != nil {
+		return err
+	}
+
+	return runGenerator(zl, exportCfg, func(workers *worker.Workers) error {
+		logsWorker := telemetry.NewLogsWorker(zl, exportCfg, logsCfg)
+
+		return workers.Add("OTLP Logs", logsWorker)
+	})
+}
+
+func newLogsConfig(zl *zap.Logger) (telemetry.LogsConfig, error) {
+	if err := checkBatchSize("logs-per-resource", logsPerResource); err != nil {
+		return telemetry.LogsConfig{}, err
+	}
+	resourceCounts, err := telemetry.ParseResourceCountDistribution(resourcesDistribution)
+	if err != nil {
+		return telemetry.LogsConfig{}, err
+	}
+	body, err := telemetry.ParseLogBody(logBody)
+	if err != nil {
+		return telemetry.LogsConfig{}, err
+	}
+	var severities *telemetry.SeverityMix
+	if severityMix != "" {
+		severities, err = telemetry.ParseSeverityMix(severityMix, severitySeed)
+		if err != nil {
+			return telemetry.LogsConfig{}, err
+		}
+	}
+
+	base, err := parseBaseTime()
+	if err != nil {
+		return telemetry.LogsConfig{}, err
+	}
+
+	resAttrs, err := detectResourceAttrs()
+	if err != nil {
+		return telemetry.LogsConfig{}, err
+	}
+	resCfg, err := resourceConfig()
+	if err != nil {
+		return telemetry.LogsConfig{}, err
+	}
+	scope, err := scopeConfig()
+	if err != nil {
+		return telemetry.LogsConfig{}, err
+	}
+
+	var corpus genai.EntrySource
+	if logsGenAICorpusPath != "" {
+		corpus, err = loadGenAICorpus(zl, logsGenAICorpusPath, genai.DefaultGenAIOptions())
+		if err != nil {
+			return telemetry.LogsConfig{}, err
+		}
+	}
+
+	return telemetry.LogsConfig{
+		ResourcesPerBatch: otlpResourcesPerBatch,
+		ResourceCounts:    resourceCounts,
+		LogsPerResource:   logsPerResource,
+		Body:              body,
+		Severities:        severities,
+		BaseTime:          base,
+		BuildQueueSize:    buildQueueSize,
+		ResourceAttrs:     resAttrs,
+		Scope:             scope,
+		Resource:          resCfg,
+		GenAICorpus:       corpus,
+	}, nil
+}
@@ -0,0 +1,154 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/streamfold/otel-loadgen/internal/telemetry"
+	"github.com/streamfold/otel-loadgen/internal/worker"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// metricsCmd represents the metrics command
+var metricsCmd = &cobra.Command{
+	Use:   "metrics",
+	Short: "Generate OTLP metric data points",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runMetricsCmd(); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var metricsPerResource int
+var metricType string
+var histogramBuckets int
+var metricAttrs string
+var stalenessRate float64
+var alertPattern string
+var alertPeriod time.Duration
+
+func init() {
+	genCmd.AddCommand(metricsCmd)
+
+	addMetricsFlags(metricsCmd.Flags())
+}
+
+func addMetricsFlags(flags *pflag.FlagSet) {
+	flags.IntVar(&metricsPerResource, "metrics-per-resource", 100, "How many metric data points per resource to generate")
+	flags.StringVar(&metricType, "metric-type", "gauge", "Type of metric to generate (gauge, sum, histogram, exp-histogram)")
+	flags.IntVar(&histogramBuckets, "histogram-buckets", 20, "Number of buckets for histogram metric types (max buckets for exp-histogram)")
+	flags.StringVar(&metricAttrs, "metric-attrs", "", "Data point attributes and the number of distinct values of each (format: 'key:cardinality,...')")
+	flags.StringVar(&alertPattern, "alert-pattern", "none", "Drive gauge values through a repeating shape to test alerting rules (none, spike, ramp, flap)")
+	flags.DurationVar(&alertPeriod, "alert-period", 5*time.Minute, "Length of one cycle of --alert-pattern")
+	flags.Float64Var(&stalenessRate, "staleness-rate", 0, "Fraction of data points emitted as NoRecordedValue staleness markers (0-1)")
+}
+
+func runMetricsCmd() error {
+	zl, err := zap.NewDevelopment(zap.IncreaseLevel(zapcore.InfoLevel))
+	if err != nil {
+		return err
+	}
+
+	exportCfg, err := newExportConfig()
+	if err != nil {
+		return err
+	}
+
+	metricsCfg, err := newMetricsConfig()
+	if err != nil {
+		return err
+	}
+
+	return runGenerator(zl, exportCfg, func(workers *worker.Workers) error {
+		metricsWorker := telemetry.NewMetricsWorker(zl, exportCfg, metricsCfg)
+
+		return workers.Add("OTLP Metrics", metricsWorker)
+	})
+}
+
+func newMetricsConfig() (telemetry.MetricsConfig, error) {
+	if err := checkBatchSize("metrics-per-resource", metricsPerResource); err != nil {
+		return telemetry.MetricsConfig{}, err
+	}
+	resourceCounts, err := telemetry.ParseResourceCountDistribution(resourcesDistribution)
+	if err != nil {
+		return telemetry.MetricsConfig{}, err
+	}
+
+	mt, err := telemetry.ParseMetricType(metricType)
+	if err != nil {
+		return telemetry.MetricsConfig{}, err
+	}
+
+	if histogramBuckets < 1 {
+		return telemetry.MetricsConfig{}, fmt.Errorf("--histogram-buckets must be > 0")
+	}
+	if mt == telemetry.MetricTypeExpHistogram && histogramBuckets < 2 {
+		return telemetry.MetricsConfig{}, fmt.Errorf("--histogram-buckets must be at least 2 for exp-histogram")
+	}
+
+	base, err := parseBaseTime()
+	if err != nil {
+		return telemetry.MetricsConfig{}, err
+	}
+
+	attrs, err := telemetry.ParseMetricAttrs(metricAttrs)
+	if err != nil {
+		return telemetry.MetricsConfig{}, err
+	}
+
+	if stalenessRate < 0 || stalenessRate > 1 {
+		return telemetry.MetricsConfig{}, fmt.Errorf("--staleness-rate must be between 0 and 1")
+	}
+
+	pattern, err := telemetry.ParseAlertPattern(alertPattern)
+	if err != nil {
+		return telemetry.MetricsConfig{}, err
+	}
+	if pattern != telemetry.AlertPatternNone {
+		if mt != telemetry.MetricTypeGauge {
+			return telemetry.MetricsConfig{}, fmt.Errorf("--alert-pattern requires --metric-type gauge")
+		}
+		if alertPeriod <= 0 {
+			return telemetry.MetricsConfig{}, fmt.Errorf("--alert-period must be > 0")
+		}
+	}
+
+	resAttrs, err := detectResourceAttrs()
+	if err != nil {
+		return telemetry.MetricsConfig{}, err
+	}
+	resCfg, err := resourceConfig()
+	if err != nil {
+		return telemetry.MetricsConfig{}, err
+	}
+	scope, err := scopeConfig()
+	if err != nil {
+		return telemetry.MetricsConfig{}, err
+	}
+
+	return telemetry.MetricsConfig{
+		ResourcesPerBatch:  otlpResourcesPerBatch,
+		ResourceCounts:     resourceCounts,
+		MetricsPerResource: metricsPerResource,
+		MetricType:         mt,
+		HistogramBuckets:   histogramBuckets,
+		BaseTime:           base,
+		BuildQueueSize:     buildQueueSize,
+		Attrs:              attrs,
+		StalenessRate:      stalenessRate,
+		ResourceAttrs:      resAttrs,
+		Scope:              scope,
+		Resource:           resCfg,
+		AlertPattern:       pattern,
+		AlertPeriod:        alertPeriod,
+	}, nil
+}
@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/streamfold/otel-loadgen/internal/control"
+	"github.com/streamfold/otel-loadgen/internal/telemetry"
+	"github.com/streamfold/otel-loadgen/internal/worker"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var retryMissingCmd = &cobra.Command{
+	Use:   "retry-missing",
+	Short: "Re-send the messages of a generator the sink hasn't acked",
+	Long: `Queries the control server for the unacked message IDs of a generator and
+sends an element of the generator's --signal for each with the same generator,
+range and message ID, so the sink acks them if they arrive. Exits non-zero if
+any replayed message is still unacked after --retry-wait, telling reproducible
+loss from transient loss.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runRetryMissing()
+	},
+}
+
+var retryGeneratorID string
+var retryLimit int
+var retryWait time.Duration
+var retrySignal string
+var retryControlTimeout time.Duration
+
+func init() {
+	genCmd.AddCommand(retryMissingCmd)
+
+	addTracesFlags(retryMissingCmd.Flags())
+	addMetricsFlags(retryMissingCmd.Flags())
+	addLogsFlags(retryMissingCmd.Flags())
+	retryMissingCmd.Flags().StringVar(&retryGeneratorID, "generator-id", "", "Generator whose unacked messages are re-sent (required)")
+	retryMissingCmd.Flags().IntVar(&retryLimit, "limit", 1000, "Maximum number of unacked messages to re-send")
+	retryMissingCmd.Flags().DurationVar(&retryWait, "retry-wait", 5*time.Second, "How long to wait for the re-sent messages to be acked")
+	retryMissingCmd.Flags().StringVar(&retrySignal, "signal", "traces", "Signal the generator sent, its messages are re-sent as the same signal (traces, metrics, logs)")
+	retryMissingCmd.Flags().DurationVar(&retryControlTimeout, "control-timeout", 10*time.Second, "Timeout of each control server request")
+}
+
+func runRetryMissing() error {
+	zl, err := zap.NewDevelopment(zap.IncreaseLevel(zapcore.InfoLevel))
+	if err != nil {
+		return err
+	}
+
+	if retryGeneratorID == "" {
+		return fmt.Errorf("--generator-id is required")
+	}
+	if controlEndpoint == "" {
+		return fmt.Errorf("--control-endpoint is required")
+	}
+
+	exportCfg, err := newExportConfig()
+	if err != nil {
+		return err
+	}
+
+	replay, err := newReplayFunc(zl, exportCfg, retrySignal)
+	if err != nil {
+		return err
+	}
+
+	result, err := retryMissing(zl, replay, controlEndpoint, retryGeneratorID, retryLimit, retryWait, retryControlTimeout)
+	if err != nil {
+		return err
+	}
+
+	zl.Info("re-sent unacked messages",
+		zap.String("generator_id", retryGeneratorID),
+		zap.Int("replayed", result.replayed),
+		zap.Int("still_unacked", len(result.stillUnacked)))
+	if len(result.stillUnacked) > 0 {
+		return fmt.Errorf("%d of %d re-sent messages are still unacked: %v", len(result.stillUnacked), result.replayed, result.stillUnacked)
+	}
+	return nil
+}
+
+// replayFunc exports count elements tagged by msgIdGen, returning the number
+// exported
+type replayFunc func(msgIdGen worker.MsgIdGenerator, count int) (int, error)
+
+// newReplayFunc replays messages as elements of signal, configured by the
+// flags of its gen command
+func newReplayFunc(zl *zap.Logger, exportCfg telemetry.ExportConfig, signal string) (replayFunc, error) {
+	client := newClient(exportCfg.TLS)
+
+	switch signal {
+	case "traces":
+		cfg, err := newTracesConfig(zl)
+		if err != nil {
+			return nil, err
+		}
+		return func(msgIdGen worker.MsgIdGenerator, count int) (int, error) {
+			return telemetry.ReplayTraces(zl, exportCfg, cfg, client, msgIdGen, count)
+		}, nil
+	case "metrics":
+		cfg, err := newMetricsConfig()
+		if err != nil {
+			return nil, err
+		}
+		return func(msgIdGen worker.MsgIdGenerator, count int) (int, error) {
+			return telemetry.ReplayMetrics(zl, exportCfg, cfg, client, msgIdGen, count)
+		}, nil
+	case "logs":
+		cfg, err := newLogsConfig(zl)
+		if err != nil {
+			return nil, err
+		}
+		return func(msgIdGen worker.MsgIdGenerator, count int) (int, error) {
+			return telemetry.ReplayLogs(zl, exportCfg, cfg, client, msgIdGen, count)
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid signal: %q (expected traces, metrics or logs)", signal)
+	}
+}
+
+// retryResult is the outcome of re-sending the unacked messages of a generator
+type retryResult struct {
+	replayed     int
+	stillUnacked []uint64
+}
+
+// retryMissing re-sends up to limit unacked messages of a generator with
+// replay and polls the control server until they're acked or wait has passed.
+// Each control server request is bounded by controlTimeout.
+func retryMissing(zl *zap.Logger, replay replayFunc, endpoint, generatorID string, limit int, wait, controlTimeout time.Duration) (retryResult, error) {
+	var result retryResult
+
+	client, err := control.NewClient(endpoint, control.ClientConfig{}, zl)
+	if err != nil {
+		return result, err
+	}
+
+	unacked, err := fetchUnacked(client, generatorID, limit, controlTimeout)
+	if err != nil {
+		return result, err
+	}
+	if len(unacked.IDs) == 0 {
+		return result, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), controlTimeout)
+	ranges, err := client.Ranges(ctx, generatorID)
+	cancel()
+	if err != nil {
+		return result, fmt.Errorf("failed to fetch message ranges: %w", err)
+	}
+
+	ids := worker.ReplayMsgIDs(unacked.IDs, ranges.Ranges)
+	msgIdGen := worker.NewReplayMsgIdGenerator(zl, generatorID, ids, elementGeneratorID)
+	result.replayed, err = replay(msgIdGen, len(ids))
+	if err != nil {
+		return result, err
+	}
+
+	replayed := make(map[uint64]bool, result.replayed)
+	for _, id := range ids[:result.replayed] {
+		replayed[id.ID] = true
+	}
+
+	// The replayed IDs were the lowest unacked IDs, so the same limit covers
+	// any of them still unacked
+	deadline := time.Now().Add(wait)
+	for {
+		unacked, err := fetchUnacked(client, generatorID, limit, controlTimeout)
+		if err != nil {
+			return result, err
+		}
+
+		result.stillUnacked = result.stillUnacked[:0]
+		for _, id := range unacked.IDs {
+			if replayed[id] {
+				result.stillUnacked = append(result.stillUnacked, id)
+			}
+		}
+		if len(result.stillUnacked) == 0 || time.Now().After(deadline) {
+			return result, nil
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func fetchUnacked(client *control.Client, generatorID string, limit int, timeout time.Duration) (control.UnackedReport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	unacked, err := client.Unacked(ctx, generatorID, limit)
+	if err != nil {
+		return unacked, fmt.Errorf("failed to fetch unacked messages: %w", err)
+	}
+	return unacked, nil
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/telemetry"
+	"github.com/streamfold/otel-loadgen/internal/testutil"
+	"github.com/streamfold/otel-loadgen/internal/worker"
+	"go.uber.org/zap"
+)
+
+func TestRetryMissing(t *testing.T) {
+	tests := []struct {
+		signal string
+		replay func(zl *zap.Logger, exportCfg telemetry.ExportConfig) replayFunc
+	}{
+		{"traces", func(zl *zap.Logger, exportCfg telemetry.ExportConfig) replayFunc {
+			cfg := telemetry.TracesConfig{ResourcesPerBatch: 1, SpansPerResource: 4}
+			return func(msgIdGen worker.MsgIdGenerator, count int) (int, error) {
+				return telemetry.ReplayTraces(zl, exportCfg, cfg, nil, msgIdGen, count)
+			}
+		}},
+		{"metrics", func(zl *zap.Logger, exportCfg telemetry.ExportConfig) replayFunc {
+			cfg := telemetry.MetricsConfig{ResourcesPerBatch: 1, MetricsPerResource: 4, MetricType: telemetry.MetricTypeSum}
+			return func(msgIdGen worker.MsgIdGenerator, count int) (int, error) {
+				return telemetry.ReplayMetrics(zl, exportCfg, cfg, nil, msgIdGen, count)
+			}
+		}},
+		{"logs", func(zl *zap.Logger, exportCfg telemetry.ExportConfig) replayFunc {
+			cfg := telemetry.LogsConfig{ResourcesPerBatch: 1, LogsPerResource: 4}
+			return func(msgIdGen worker.MsgIdGenerator, count int) (int, error) {
+				return telemetry.ReplayLogs(zl, exportCfg, cfg, nil, msgIdGen, count)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.signal, func(t *testing.T) {
+			s := testutil.StartInProcessSink(t)
+
+			// A run whose messages 10-19 and 95 never reached the sink
+			s.Tracker.AddRange("gen-a", 1, 100, time.Now())
+			for id := uint64(1); id <= 100; id++ {
+				if (id < 10 || id >= 20) && id != 95 {
+					s.Tracker.Ack("gen-a", 1, 100, id)
+				}
+			}
+
+			endpoint, err := url.Parse(s.Endpoint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			exportCfg := telemetry.ExportConfig{Endpoint: endpoint, UseGRPC: true}
+
+			result, err := retryMissing(zap.NewNop(), tt.replay(zap.NewNop(), exportCfg), s.ControlEndpoint, "gen-a", 1000, 5*time.Second, time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.replayed != 11 {
+				t.Errorf("Expected 11 messages to be replayed, got %d", result.replayed)
+			}
+			if len(result.stillUnacked) != 0 {
+				t.Errorf("Expected every replayed message to be acked, got %v unacked", result.stillUnacked)
+			}
+			if ids := s.Tracker.UnackedIDs("gen-a", 10); len(ids) != 0 {
+				t.Errorf("Expected no unacked messages left, got %v", ids)
+			}
+
+			report := s.Tracker.GeneratorReport(time.Now())["gen-a"]
+			if report.TotalAcked != 100 || report.TotalDuped != 0 {
+				t.Errorf("Expected 100 acked and no duplicates, got %+v", report)
+			}
+		})
+	}
+}
+
+func TestNewReplayFunc_InvalidSignal(t *testing.T) {
+	if _, err := newReplayFunc(zap.NewNop(), telemetry.ExportConfig{}, "profiles"); err == nil {
+		t.Error("Expected an error for an unknown signal")
+	}
+}
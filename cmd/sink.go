@@ -4,6 +4,7 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
@@ -31,15 +32,46 @@ var sinkCmd = &cobra.Command{
 
 var sinkAddr string
 var controlAddr string
+var sinkHTTPAddr string
 var sinkReportInterval time.Duration
+var maxGenerators int
+var generatorEviction string
+var ackTimeout time.Duration
+var compactAfter time.Duration
+var reapAfter time.Duration
+var maxEagerBitmapWords int
+var deliveryGuarantee string
+var logDuplicates bool
+var stateFile string
+var stateFlushInterval time.Duration
+var metricsAddr string
+var controlGRPCAddr string
+var sinkExpect uint64
+var sinkExpectTimeout time.Duration
 
 func init() {
 	rootCmd.AddCommand(sinkCmd)
 
-	sinkCmd.Flags().StringVar(&sinkAddr, "addr", "localhost:5317", "address to listen on")
+	sinkCmd.Flags().StringVar(&sinkAddr, "addr", "localhost:5317", "address to listen on, unix:///path listens on a unix socket")
 	sinkCmd.Flags().StringVar(&controlAddr, "control-addr", "localhost:5000", "control server address")
+	sinkCmd.Flags().StringVar(&controlGRPCAddr, "control-grpc-addr", "", "address to serve the gRPC control service on for generators using --control-transport grpc, disabled if empty")
+	sinkCmd.Flags().StringVar(&metricsAddr, "metrics-addr", "", "additional address to serve Prometheus /metrics on, it is always served on the control address")
+	sinkCmd.Flags().StringVar(&sinkHTTPAddr, "http-addr", "", "address to listen on for OTLP/HTTP, disabled if empty")
 	
 	sinkCmd.Flags().DurationVar(&sinkReportInterval, "report-interval", 3 * time.Second, "interval to report delivery statistics")
+
+	sinkCmd.Flags().IntVar(&maxGenerators, "max-generators", 0, "maximum number of generators to track, 0 is unlimited")
+	sinkCmd.Flags().StringVar(&generatorEviction, "generator-eviction", "reject", "policy when max generators is reached (reject, lru)")
+	sinkCmd.Flags().DurationVar(&ackTimeout, "ack-timeout", 0, "report unacked messages older than this as likely lost, 0 disables")
+	sinkCmd.Flags().StringVar(&stateFile, "state-file", "", "file to persist tracker state to, it is restored on startup so ack audits survive restarts")
+	sinkCmd.Flags().DurationVar(&stateFlushInterval, "state-flush-interval", 10*time.Second, "interval to write the tracker state to the state file")
+	sinkCmd.Flags().Uint64Var(&sinkExpect, "expect", 0, "exit once this many messages are acked across all generators, exiting non-zero if they aren't, 0 runs until killed")
+	sinkCmd.Flags().DurationVar(&sinkExpectTimeout, "expect-timeout", 0, "give up waiting for --expect messages after this long, 0 waits until killed")
+	sinkCmd.Flags().DurationVar(&compactAfter, "compact-after", 0, "compact tracked message ranges older than this to bound memory, 0 disables")
+	sinkCmd.Flags().DurationVar(&reapAfter, "reap-after", time.Minute, "age after which fully acked ranges release their bitmaps, their IDs are kept as runs of acked IDs so late duplicates are still detected, 0 disables")
+	sinkCmd.Flags().StringVar(&deliveryGuarantee, "delivery", "at-least-once", "delivery guarantee expected of generators (at-least-once, exactly-once), duplicates are counted as errors in exactly-once mode")
+	sinkCmd.Flags().BoolVar(&logDuplicates, "log-duplicates", false, "log the message ID of each duplicate counted as an error in exactly-once mode")
+	sinkCmd.Flags().IntVar(&maxEagerBitmapWords, "max-eager-bitmap-words", 0, "largest ack bitmap in 64 message words allocated when a range is added, larger bitmaps grow as acks arrive, 0 uses the default of 64, negative allocates every bitmap up front")
 }
 
 func runSink() error {
@@ -48,13 +80,55 @@ func runSink() error {
 		return err
 	}
 
-	mt := msg_tracker.NewTracker(zl)
+	evictionPolicy, err := msg_tracker.ParseEvictionPolicy(generatorEviction)
+	if err != nil {
+		return err
+	}
+
+	delivery, err := msg_tracker.ParseDeliveryGuarantee(deliveryGuarantee)
+	if err != nil {
+		return err
+	}
+
+	mt := msg_tracker.NewTrackerWithConfig(msg_tracker.Config{
+		MaxGenerators:  maxGenerators,
+		EvictionPolicy: evictionPolicy,
+		AckTimeout:     ackTimeout,
+		CompactAfter:   compactAfter,
+
+		MaxEagerBitmapWords: maxEagerBitmapWords,
+		Delivery:            delivery,
+		LogDuplicates:       logDuplicates,
+	}, zl)
+
+	if stateFile != "" {
+		err := mt.RestoreFile(stateFile)
+		switch {
+		case err == nil:
+			zl.Info("Restored tracker state", zap.String("path", stateFile))
+		case os.IsNotExist(err):
+			zl.Info("No tracker state to restore", zap.String("path", stateFile))
+		default:
+			return err
+		}
+	}
+
+	// The control server serves /metrics, the sink registers its counters with it
+	c := control.New(controlAddr, mt, sinkReportInterval, zl)
+	c.ReapAfter(reapAfter)
+	if metricsAddr != "" {
+		c.ServeMetricsOn(metricsAddr)
+	}
+	if controlGRPCAddr != "" {
+		c.ServeGRPCOn(controlGRPCAddr)
+	}
 
 	// Start the sink server
-	s, err := sink.New(sinkAddr, mt, zl)
+	s, err := sink.New(sinkAddr, sinkHTTPAddr, mt, zl)
 	if err != nil {
 		return err
 	}
+	s.SetMetrics(sink.NewMetrics(c.Registry()))
 
 	if err := s.Start(); err != nil {
 		return err
@@ -63,7 +137,6 @@ func runSink() error {
 	zl.Info("Sink server has been started", zap.String("addr", s.Addr()))
 	
 	// Start the control server
-	c := control.New(controlAddr, mt, sinkReportInterval, zl)
 	if err := c.Start(); err != nil {
 		s.Stop()
 		return err
@@ -71,6 +144,15 @@ func runSink() error {
 
 	zl.Info("Control server has been started", zap.String("addr", c.Addr()))
 
+	stateStop := make(chan bool)
+	stateDone := make(chan bool)
+	go func() {
+		defer close(stateDone)
+		if stateFile != "" {
+			flushState(zl, mt, stateStop)
+		}
+	}()
+
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(
 		signalChan,
@@ -79,15 +161,103 @@ func runSink() error {
 		syscall.SIGQUIT, // kill -SIGQUIT XXXX
 	)
 
+	expectStop := make(chan bool)
+	expectResult := make(chan bool, 1)
+	if sinkExpect > 0 {
+		go func() {
+			expectResult <- waitForAcked(mt, sinkExpect, sinkExpectTimeout, expectStop)
+		}()
+	}
+
 	select {
 	case sig := <-signalChan:
 		zl.Info("killed with signal", zap.String("signal", sig.String()))
+	case reached := <-expectResult:
+		if reached {
+			zl.Info("received expected messages", zap.Uint64("expect", sinkExpect))
+		} else {
+			zl.Warn("timed out waiting for expected messages", zap.Uint64("expect", sinkExpect),
+				zap.Uint64("acked", mt.TotalAcked()), zap.Duration("timeout", sinkExpectTimeout))
+		}
 	}
+	close(expectStop)
 	zl.Info("shutting down")
 
 	// Stop both servers
 	c.Stop()
 	s.Stop()
 
+	close(stateStop)
+	<-stateDone
+	if stateFile != "" {
+		if err := mt.SaveFile(stateFile); err != nil {
+			return err
+		}
+		zl.Info("Saved tracker state", zap.String("path", stateFile))
+	}
+
+	if sinkExpect > 0 {
+		// Final reconciliation of what was received
+		c.Report()
+		return checkExpected(mt, sinkExpect)
+	}
+
 	return nil
 }
+
+// expectPollInterval is how often the acked total is checked against --expect
+const expectPollInterval = 100 * time.Millisecond
+
+// waitForAcked waits until n messages are acked across all generators, it
+// returns false if timeout elapses or stop is closed first. A zero timeout
+// waits until stop is closed.
+func waitForAcked(mt *msg_tracker.Tracker, n uint64, timeout time.Duration, stop chan bool) bool {
+	var timeoutC <-chan time.Time
+	if timeout > 0 {
+		tm := time.NewTimer(timeout)
+		defer tm.Stop()
+		timeoutC = tm.C
+	}
+
+	tk := time.NewTicker(expectPollInterval)
+	defer tk.Stop()
+
+	for {
+		if mt.TotalAcked() >= n {
+			return true
+		}
+
+		select {
+		case <-tk.C:
+		case <-timeoutC:
+			return mt.TotalAcked() >= n
+		case <-stop:
+			return false
+		}
+	}
+}
+
+// checkExpected returns an error if fewer than n messages were acked
+func checkExpected(mt *msg_tracker.Tracker, n uint64) error {
+	if acked := mt.TotalAcked(); acked < n {
+		return fmt.Errorf("expected %d acked messages, received %d", n, acked)
+	}
+	return nil
+}
+
+// flushState periodically writes the tracker state to the state file until stop is closed
+func flushState(zl *zap.Logger, mt *msg_tracker.Tracker, stop chan bool) {
+	tm := time.NewTicker(stateFlushInterval)
+	defer tm.Stop()
+
+	for {
+		select {
+		case <-tm.C:
+			if err := mt.SaveFile(stateFile); err != nil {
+				zl.Error("failed to save tracker state", zap.Error(err), zap.String("path", stateFile))
+			}
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/msg_tracker"
+	"go.uber.org/zap"
+)
+
+func TestWaitForAcked_Reached(t *testing.T) {
+	mt := msg_tracker.NewTracker(zap.NewNop())
+	mt.AddRange("gen-a", 1, 10, time.Now())
+
+	go func() {
+		for id := uint64(1); id <= 10; id++ {
+			time.Sleep(10 * time.Millisecond)
+			mt.Ack("gen-a", 1, 10, id)
+		}
+	}()
+
+	if !waitForAcked(mt, 10, 5*time.Second, make(chan bool)) {
+		t.Fatal("Expected the expected messages to be received")
+	}
+	if err := checkExpected(mt, 10); err != nil {
+		t.Errorf("Expected success, got %v", err)
+	}
+}
+
+func TestWaitForAcked_Timeout(t *testing.T) {
+	mt := msg_tracker.NewTracker(zap.NewNop())
+	mt.AddRange("gen-a", 1, 10, time.Now())
+	for id := uint64(1); id <= 5; id++ {
+		mt.Ack("gen-a", 1, 10, id)
+	}
+
+	start := time.Now()
+	if waitForAcked(mt, 10, 200*time.Millisecond, make(chan bool)) {
+		t.Fatal("Expected a timeout")
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("Expected to wait for the timeout, returned after %s", elapsed)
+	}
+	if err := checkExpected(mt, 10); err == nil {
+		t.Error("Expected an error when fewer messages were acked")
+	}
+}
+
+func TestWaitForAcked_Stopped(t *testing.T) {
+	mt := msg_tracker.NewTracker(zap.NewNop())
+
+	stop := make(chan bool)
+	close(stop)
+	if waitForAcked(mt, 1, 0, stop) {
+		t.Error("Expected false once stopped")
+	}
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/streamfold/otel-loadgen/internal/summary"
+)
+
+// summaryCmd groups the commands working on run summary files
+var summaryCmd = &cobra.Command{
+	Use:   "summary",
+	Short: "Work with run summary files written by --summary-file",
+}
+
+var summaryDiffCmd = &cobra.Command{
+	Use:   "diff <base.json> <current.json>",
+	Short: "Compare two run summaries and flag regressions",
+	Long: `Prints the change in throughput, export error rate and latency percentiles
+from a base run to the current run. Exits non-zero if any value got worse by
+more than --threshold, so it can be used as a performance regression gate.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runSummaryDiff(args[0], args[1])
+	},
+}
+
+var regressionThreshold float64
+
+func init() {
+	rootCmd.AddCommand(summaryCmd)
+	summaryCmd.AddCommand(summaryDiffCmd)
+
+	summaryDiffCmd.Flags().Float64Var(&regressionThreshold, "threshold", 0.05, "Relative change beyond which a value is flagged as a regression (0.05 is 5%)")
+}
+
+func runSummaryDiff(basePath, currentPath string) error {
+	base, err := summary.Load(basePath)
+	if err != nil {
+		return err
+	}
+	current, err := summary.Load(currentPath)
+	if err != nil {
+		return err
+	}
+
+	deltas := summary.Diff(base, current, regressionThreshold)
+	if err := summary.WriteDiff(os.Stdout, deltas); err != nil {
+		return err
+	}
+
+	if n := summary.Regressions(deltas); n > 0 {
+		return fmt.Errorf("%d regressions beyond the %.2f%% threshold", n, regressionThreshold*100)
+	}
+	return nil
+}
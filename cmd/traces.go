@@ -4,15 +4,15 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"fmt"
 	"log"
-	"os"
-	"os/signal"
-	"syscall"
-	"time"
+	"regexp"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/streamfold/otel-loadgen/internal/genai"
 	"github.com/streamfold/otel-loadgen/internal/telemetry"
+	"github.com/streamfold/otel-loadgen/internal/util"
 	"github.com/streamfold/otel-loadgen/internal/worker"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -30,17 +30,73 @@ var tracesCmd = &cobra.Command{
 }
 
 var spansPerResource int
+var spansDistribution string
 var enableGenAI bool
 var genAICorpusPath string
-var useHTTP bool
+var tracesGenAICorpusPath string
+var genAIOperations string
+var genAIToolEmit string
+var genAIModelWeightsPath string
+var genAITokenizer string
+var genAIRefusalPattern string
+var validateBeforeSend bool
+var dropInvalidSpans bool
+var targetRate float64
+var partitionAttr bool
+var traceReuseRate float64
+var errorRate float64
+var errorSeed int64
+var linksPerSpan int
+var eventsPerSpan int
+var attrCardinality int
+var resourceAttrCardinality int
+var attrValues int
+var attrSeed int64
+var spanKinds string
+var idSeed int64
+var treeShape string
+var treeFanout int
+var spanKindSeed int64
+var spanSampling string
+var spanSamplingSeed int64
 
 func init() {
 	genCmd.AddCommand(tracesCmd)
 
-	tracesCmd.Flags().IntVar(&spansPerResource, "spans-per-resource", 100, "How many trace spans per resource to generate")
-	tracesCmd.Flags().BoolVar(&enableGenAI, "gen-ai", false, "Enable gen_ai span attributes using corpus data")
-	tracesCmd.Flags().StringVar(&genAICorpusPath, "gen-ai-corpus", "contrib/apigen-mt_5k.json.gz", "Path to the gen_ai corpus file (supports .gz)")
-	tracesCmd.Flags().BoolVar(&useHTTP, "http", false, "Use HTTP/JSON instead of gRPC for OTLP export")
+	addTracesFlags(tracesCmd.Flags())
+}
+
+func addTracesFlags(flags *pflag.FlagSet) {
+	flags.IntVar(&spansPerResource, "spans-per-resource", 100, "How many trace spans per resource to generate")
+	flags.StringVar(&spansDistribution, "spans-per-resource-distribution", "uniform", "How spans of a batch are split across resources (uniform, skewed, random)")
+	flags.BoolVar(&enableGenAI, "gen-ai", false, "Enable gen_ai span attributes using corpus data")
+	flags.StringVar(&genAICorpusPath, "gen-ai-corpus", "contrib/apigen-mt_5k.json.gz", "Path to the gen_ai corpus file (supports .gz)")
+	flags.StringVar(&tracesGenAICorpusPath, "traces-genai-corpus", "", "Path to the gen_ai corpus for spans, enables --gen-ai and overrides --gen-ai-corpus")
+	flags.StringVar(&genAIOperations, "gen-ai-operations", "chat:8,completion:1,embedding:1", "Relative weights of gen_ai operation names (format: 'name:weight,...')")
+	flags.StringVar(&genAIToolEmit, "gen-ai-tool-emit", "attrs", "How gen_ai tool calls are represented on spans (attrs, events)")
+	flags.StringVar(&genAIRefusalPattern, "gen-ai-refusal-pattern", genai.DefaultRefusalPattern, "Regexp matched against the final assistant reply of a corpus entry to detect refusals, which get an error status and a content_filter finish reason (empty only uses the entries' refusal flag)")
+	flags.StringVar(&genAITokenizer, "genai-tokenizer", "approx", "How gen_ai token usage is counted (approx: 4 bytes per token, cl100k, o200k: encoded with the encoding's BPE merge tables)")
+	flags.StringVar(&genAIModelWeightsPath, "genai-model-weights", "", "Path to a JSON file of gen_ai model weights per provider (format: '{\"provider\": {\"model\": weight}}')")
+	flags.BoolVar(&validateBeforeSend, "validate-before-send", false, "Validate generated spans before export and count invalid spans")
+	flags.BoolVar(&dropInvalidSpans, "drop-invalid-spans", false, "Drop spans that fail validation instead of sending them (requires --validate-before-send)")
+	flags.Float64Var(&targetRate, "target-rate", 0, "Target spans per second across all workers, replaces --push-interval when set")
+	flags.BoolVar(&partitionAttr, "partition-attr", false, "Add a loadgen.partition resource attribute with the worker index")
+	flags.Float64Var(&errorRate, "error-rate", 0, "Fraction (0-1) of spans given an error status and an exception event")
+	flags.Int64Var(&errorSeed, "error-seed", 0, "Seed for picking error spans so runs are reproducible, 0 picks a random seed")
+	flags.StringVar(&treeShape, "tree-shape", "chain", "How the spans of a trace are parented (chain, random, fanout)")
+	flags.IntVar(&treeFanout, "tree-fanout", 3, "Number of children of each span with --tree-shape fanout")
+	flags.Int64Var(&idSeed, "id-seed", 0, "Seed for trace and span ids to reproduce a run, 0 seeds them randomly")
+	flags.StringVar(&spanKinds, "span-kinds", "server:1", "Relative weights of span kinds (format: 'server:2,client:2,internal:5,producer:1,consumer:1')")
+	flags.Int64Var(&spanKindSeed, "span-kind-seed", 0, "Seed for assigning span kinds, the kind of each span index is fixed for a seed")
+	flags.StringVar(&spanSampling, "span-sampling", "", "Relative weights of sampling probabilities added as sampling.probability and sampling.priority span attributes (format: '1:1,0.1:4,0.01:5'), disabled if empty")
+	flags.Int64Var(&spanSamplingSeed, "span-sampling-seed", 0, "Seed for the sampling attributes, 0 picks a random seed")
+	flags.IntVar(&linksPerSpan, "links-per-span", 0, "Number of links per span to spans of previously generated traces")
+	flags.IntVar(&eventsPerSpan, "events-per-span", 1, "Number of events per span, with names and attributes from a pool of common events")
+	flags.IntVar(&attrCardinality, "attr-cardinality", 0, "Number of extra high cardinality attributes (user.id, request.id, ...) added to every span")
+	flags.IntVar(&resourceAttrCardinality, "resource-attr-cardinality", 0, "Number of extra high cardinality attributes added to every resource")
+	flags.IntVar(&attrValues, "attr-values", 0, "Number of distinct values of each extra attribute, 0 makes every value a random id")
+	flags.Int64Var(&attrSeed, "attr-seed", 0, "Seed for the extra attribute values, 0 picks a random seed")
+	flags.Float64Var(&traceReuseRate, "trace-reuse-rate", 0, "Probability (0-1) that a resource's spans extend a recent trace instead of starting a new one")
 }
 
 func runTracesCmd() error {
@@ -49,70 +105,228 @@ func runTracesCmd() error {
 		return err
 	}
 
-	endpoint, err := parseOtlpEndpoint()
+	exportCfg, err := newExportConfig()
 	if err != nil {
 		return err
 	}
 
-	headers, err := parseCustomHeaders()
+	tracesCfg, err := newTracesConfig(zl)
 	if err != nil {
 		return err
 	}
 
-	// Load gen_ai corpus if enabled
-	var corpus *genai.Corpus
-	if enableGenAI {
-		zl.Info("Loading gen_ai corpus", zap.String("path", genAICorpusPath))
-		corpus, err = genai.LoadCorpus(genAICorpusPath)
+	return runGenerator(zl, exportCfg, func(workers *worker.Workers) error {
+		traceWorker := telemetry.NewTracesWorker(zl, exportCfg, tracesCfg)
+
+		return workers.Add("OTLP Traces", traceWorker)
+	})
+}
+
+func newTracesConfig(zl *zap.Logger) (telemetry.TracesConfig, error) {
+	if err := checkBatchSize("spans-per-resource", spansPerResource); err != nil {
+		return telemetry.TracesConfig{}, err
+	}
+	resourceCounts, err := telemetry.ParseResourceCountDistribution(resourcesDistribution)
+	if err != nil {
+		return telemetry.TracesConfig{}, err
+	}
+
+	if dropInvalidSpans && !validateBeforeSend {
+		return telemetry.TracesConfig{}, fmt.Errorf("--drop-invalid-spans requires --validate-before-send")
+	}
+
+	if genAIModelWeightsPath != "" && !enableGenAI && tracesGenAICorpusPath == "" {
+		return telemetry.TracesConfig{}, fmt.Errorf("--genai-model-weights requires --gen-ai")
+	}
+
+	if targetRate < 0 {
+		return telemetry.TracesConfig{}, fmt.Errorf("--target-rate must be >= 0")
+	}
+
+	if errorRate < 0 || errorRate > 1 {
+		return telemetry.TracesConfig{}, fmt.Errorf("--error-rate must be between 0 and 1")
+	}
+
+	if linksPerSpan < 0 {
+		return telemetry.TracesConfig{}, fmt.Errorf("--links-per-span must be >= 0")
+	}
+
+	if eventsPerSpan < 0 {
+		return telemetry.TracesConfig{}, fmt.Errorf("--events-per-span must be >= 0")
+	}
+
+	spanAttrCard, err := telemetry.NewAttrCardinality(attrCardinality, attrValues, attrSeed)
+	if err != nil {
+		return telemetry.TracesConfig{}, fmt.Errorf("--attr-cardinality: %w", err)
+	}
+
+	resourceAttrCard, err := telemetry.NewAttrCardinality(resourceAttrCardinality, attrValues, attrSeed)
+	if err != nil {
+		return telemetry.TracesConfig{}, fmt.Errorf("--resource-attr-cardinality: %w", err)
+	}
+
+	if traceReuseRate < 0 || traceReuseRate > 1 {
+		return telemetry.TracesConfig{}, fmt.Errorf("--trace-reuse-rate must be between 0 and 1")
+	}
+
+	dist, err := telemetry.ParseDistribution(spansDistribution)
+	if err != nil {
+		return telemetry.TracesConfig{}, err
+	}
+
+	kinds, err := telemetry.ParseSpanKinds(spanKinds, spanKindSeed)
+	if err != nil {
+		return telemetry.TracesConfig{}, err
+	}
+
+	var sampling *telemetry.SpanSampling
+	if spanSampling != "" {
+		sampling, err = telemetry.ParseSpanSampling(spanSampling, spanSamplingSeed)
 		if err != nil {
-			return err
+			return telemetry.TracesConfig{}, err
 		}
-		zl.Info("Loaded gen_ai corpus", zap.Int("entries", corpus.Size()))
 	}
 
-	workerCfg := worker.Config{
-		NumWorkers:      numWorkers,
-		ReportInterval:  reportInterval,
-		PushInterval:    pushInterval,
-		ControlEndpoint: controlEndpoint,
+	shape, err := telemetry.ParseTreeShape(treeShape)
+	if err != nil {
+		return telemetry.TracesConfig{}, err
+	}
+	if treeFanout < 1 {
+		return telemetry.TracesConfig{}, fmt.Errorf("--tree-fanout must be > 0")
 	}
 
-	workers, err := worker.New(workerCfg, zl, newClient())
+	base, err := parseBaseTime()
 	if err != nil {
-		return err
+		return telemetry.TracesConfig{}, err
 	}
 
-	traceWorker := telemetry.NewTracesWorker(zl, endpoint, !useHTTP, otlpResourcesPerBatch, spansPerResource, corpus, headers)
+	resAttrs, err := detectResourceAttrs()
+	if err != nil {
+		return telemetry.TracesConfig{}, err
+	}
+	resCfg, err := resourceConfig()
+	if err != nil {
+		return telemetry.TracesConfig{}, err
+	}
+	scope, err := scopeConfig()
+	if err != nil {
+		return telemetry.TracesConfig{}, err
+	}
 
-	if err := workers.Add("OTLP Traces", traceWorker); err != nil {
-		return err
+	corpusPath := genAICorpusPath
+	if tracesGenAICorpusPath != "" {
+		corpusPath = tracesGenAICorpusPath
 	}
-	
-	zl.Info("Load generator has been started")
-	workers.Start()
-
-	signalChan := make(chan os.Signal, 1)
-	signal.Notify(
-		signalChan,
-		syscall.SIGHUP,  // kill -SIGHUP XXXX
-		syscall.SIGINT,  // kill -SIGINT XXXX or Ctrl+c
-		syscall.SIGQUIT, // kill -SIGQUIT XXXX
-	)
-	
-	if duration.Milliseconds() != 0 {
-		t := time.NewTimer(duration)
-		select {
-		case <- t.C:
-			zl.Info("reached test duration", zap.Duration("duration", duration))
-		case sig := <-signalChan:
-			zl.Info("killed with signal", zap.String("signal", sig.String()))
+
+	// Load gen_ai corpus if enabled
+	var corpus genai.EntrySource
+	if enableGenAI || tracesGenAICorpusPath != "" {
+		ops, weights, err := util.ParseWeights(genAIOperations)
+		if err != nil {
+			return telemetry.TracesConfig{}, err
 		}
-	} else {
-		sig := <-signalChan
-		zl.Info("killed with signal", zap.String("signal", sig.String()))
+		opts, err := genai.NewGenAIOptions(ops, weights)
+		if err != nil {
+			return telemetry.TracesConfig{}, err
+		}
+		toolEmit, err := genai.ParseToolEmit(genAIToolEmit)
+		if err != nil {
+			return telemetry.TracesConfig{}, err
+		}
+		opts.SetToolEmit(toolEmit)
+		tokenizer, err := genai.ParseTokenizer(genAITokenizer)
+		if err != nil {
+			return telemetry.TracesConfig{}, err
+		}
+		opts.SetTokenizer(tokenizer)
+		if genAIRefusalPattern != "" {
+			re, err := regexp.Compile(genAIRefusalPattern)
+			if err != nil {
+				return telemetry.TracesConfig{}, fmt.Errorf("invalid gen-ai-refusal-pattern: %w", err)
+			}
+			opts.SetRefusalPattern(re)
+		}
+		if genAIModelWeightsPath != "" {
+			models, err := genai.LoadModelWeights(genAIModelWeightsPath)
+			if err != nil {
+				return telemetry.TracesConfig{}, err
+			}
+			opts.SetModelWeights(models)
+		}
+
+		corpus, err = loadGenAICorpus(zl, corpusPath, opts)
+		if err != nil {
+			return telemetry.TracesConfig{}, err
+		}
+	}
+
+	return telemetry.TracesConfig{
+		ResourcesPerBatch:       otlpResourcesPerBatch,
+		ResourceCounts:          resourceCounts,
+		SpansPerResource:        spansPerResource,
+		SpansDistribution:       dist,
+		GenAICorpus:             corpus,
+		ValidateBeforeSend:      validateBeforeSend,
+		DropInvalidSpans:        dropInvalidSpans,
+		BaseTime:                base,
+		BuildQueueSize:          buildQueueSize,
+		TargetRate:              targetRate,
+		PartitionAttr:           partitionAttr,
+		ResourceAttrs:           resAttrs,
+		Scope:                   scope,
+		Resource:                resCfg,
+		TraceReuseRate:          traceReuseRate,
+		ErrorRate:               errorRate,
+		ErrorSeed:               errorSeed,
+		LinksPerSpan:            linksPerSpan,
+		EventsPerSpan:           eventsPerSpan,
+		SpanAttrCardinality:     spanAttrCard,
+		ResourceAttrCardinality: resourceAttrCard,
+		SpanKinds:               kinds,
+		Sampling:                sampling,
+		TreeShape:               shape,
+		TreeFanout:              treeFanout,
+		IDSeed:                  idSeed,
+	}, nil
+}
+
+// loadGenAICorpus loads a gen_ai corpus, or streams it with --corpus-stream.
+// Each corpus keeps its own cursor so signals loading separate corpora draw
+// from them independently.
+func loadGenAICorpus(zl *zap.Logger, path string, opts *genai.GenAIOptions) (genai.EntrySource, error) {
+	mode, err := genai.ParseSamplingMode(corpusMode)
+	if err != nil {
+		return nil, err
 	}
-	zl.Info("shutting down")
 
-	workers.Stop()
-	return nil
-}
\ No newline at end of file
+	format, err := genai.ParseCorpusFormat(corpusFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	if corpusStream {
+		if mode != genai.SamplingRoundRobin {
+			return nil, fmt.Errorf("--corpus-stream requires --corpus-mode round-robin")
+		}
+
+		zl.Info("Streaming gen_ai corpus", zap.String("path", path), zap.Stringer("format", format))
+		corpus, err := genai.NewStreamingCorpusFormat(path, format)
+		if err != nil {
+			return nil, err
+		}
+		corpus.SetOptions(opts)
+		corpus.SetSeed(corpusSeed)
+		return corpus, nil
+	}
+
+	zl.Info("Loading gen_ai corpus", zap.String("path", path), zap.Stringer("format", format))
+	corpus, err := genai.LoadCorpusFormat(path, format)
+	if err != nil {
+		return nil, err
+	}
+	zl.Info("Loaded gen_ai corpus", zap.Int("entries", corpus.Size()))
+
+	corpus.SetSampling(mode, corpusSeed)
+	corpus.SetOptions(opts)
+	return corpus, nil
+}
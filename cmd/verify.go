@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/streamfold/otel-loadgen/internal/control"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Wait until the sink has acked every published message",
+	Long: `Polls the control server's delivery report until no generator has unacked
+messages. Exits non-zero, listing each generator with unacked messages, if
+some remain after --timeout, so a CI job can fail when the pipeline under
+test dropped telemetry.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runVerify()
+	},
+}
+
+var verifyEndpoint string
+var verifyGeneratorIDs []string
+var verifyTimeout time.Duration
+var verifyPollInterval time.Duration
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+
+	verifyCmd.Flags().StringVar(&verifyEndpoint, "control-endpoint", "localhost:5000", "Endpoint of control server")
+	verifyCmd.Flags().StringSliceVar(&verifyGeneratorIDs, "generator-id", nil, "Only verify these generators, which must be tracked (can be repeated, default all)")
+	verifyCmd.Flags().DurationVar(&verifyTimeout, "timeout", 30*time.Second, "How long to wait for every message to be acked")
+	verifyCmd.Flags().DurationVar(&verifyPollInterval, "poll-interval", time.Second, "Interval between delivery report requests")
+}
+
+func runVerify() error {
+	zl, err := zap.NewDevelopment(zap.IncreaseLevel(zapcore.InfoLevel))
+	if err != nil {
+		return err
+	}
+
+	client, err := control.NewClient(verifyEndpoint, control.ClientConfig{}, zl)
+	if err != nil {
+		return err
+	}
+
+	result, err := verifyDelivery(zl, client, verifyGeneratorIDs, verifyTimeout, verifyPollInterval)
+	if err != nil {
+		return err
+	}
+
+	incomplete := result.incomplete()
+	for _, id := range incomplete {
+		if report, ok := result.generators[id]; ok {
+			fmt.Printf("Generator %s:\tUnacked: %d,\tTotal Acked: %d,\tLikely Lost: %d\n", id, report.Unacked, report.TotalAcked, report.LikelyLost)
+		} else {
+			fmt.Printf("Generator %s:\tnot tracked by the control server\n", id)
+		}
+	}
+	if len(incomplete) > 0 {
+		return fmt.Errorf("%d of %d generators still have unacked messages after %s", len(incomplete), result.total(), verifyTimeout)
+	}
+
+	var acked uint
+	for _, report := range result.generators {
+		acked += report.TotalAcked
+	}
+	fmt.Printf("All %d generators are complete, %d messages acked\n", len(result.generators), acked)
+	return nil
+}
+
+// verifyResult is the last delivery report polled by verifyDelivery
+type verifyResult struct {
+	generators map[string]control.GeneratorReport
+	// missing are the requested generators the control server doesn't track
+	missing []string
+}
+
+// incomplete returns the sorted IDs of the generators with unacked messages
+// or not tracked at all
+func (r verifyResult) incomplete() []string {
+	ids := slices.Clone(r.missing)
+	for id, report := range r.generators {
+		if report.Unacked > 0 {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func (r verifyResult) total() int {
+	return len(r.generators) + len(r.missing)
+}
+
+// verifyDelivery polls the delivery report of the control server until the
+// generators, all tracked ones if generatorIDs is empty, have no unacked
+// messages or the timeout elapses. Every range counts, however recent, since
+// the run is expected to be over. It fails if no report could be fetched or no
+// generators are tracked.
+func verifyDelivery(log *zap.Logger, client *control.Client, generatorIDs []string, timeout, pollInterval time.Duration) (verifyResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var result verifyResult
+	var lastErr error
+	fetched := false
+	for {
+		report, err := client.Report(ctx, 0)
+		if err == nil {
+			fetched = true
+			result = filterReport(report, generatorIDs)
+			if result.total() > 0 && len(result.incomplete()) == 0 {
+				return result, nil
+			}
+		} else {
+			lastErr = err
+			if ctx.Err() == nil {
+				log.Warn("failed to fetch delivery report", zap.Error(err))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if !fetched {
+				return result, fmt.Errorf("failed to fetch delivery report: %w", lastErr)
+			}
+			if result.total() == 0 {
+				return result, fmt.Errorf("no generators are tracked by the control server")
+			}
+			return result, nil
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+func filterReport(report control.Report, generatorIDs []string) verifyResult {
+	if len(generatorIDs) == 0 {
+		return verifyResult{generators: report.Generators}
+	}
+
+	result := verifyResult{generators: make(map[string]control.GeneratorReport, len(generatorIDs))}
+	for _, id := range generatorIDs {
+		if gr, ok := report.Generators[id]; ok {
+			result.generators[id] = gr
+		} else {
+			result.missing = append(result.missing, id)
+		}
+	}
+	return result
+}
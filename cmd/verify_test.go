@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/control"
+	"github.com/streamfold/otel-loadgen/internal/testutil"
+	"go.uber.org/zap"
+)
+
+func TestVerifyDelivery(t *testing.T) {
+	s := testutil.StartInProcessSink(t)
+	client, err := control.NewClient(s.ControlEndpoint, control.ClientConfig{}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := verifyDelivery(zap.NewNop(), client, nil, 50*time.Millisecond, 10*time.Millisecond); err == nil {
+		t.Error("Expected an error when no generators are tracked")
+	}
+
+	s.Tracker.AddRange("gen-a", 1, 10, time.Now())
+	s.Tracker.AddRange("gen-b", 1, 10, time.Now())
+	for id := uint64(1); id <= 10; id++ {
+		s.Tracker.Ack("gen-a", 1, 10, id)
+		if id <= 7 {
+			s.Tracker.Ack("gen-b", 1, 10, id)
+		}
+	}
+
+	result, err := verifyDelivery(zap.NewNop(), client, nil, 100*time.Millisecond, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.incomplete(); !slices.Equal(got, []string{"gen-b"}) {
+		t.Fatalf("Expected gen-b to be incomplete, got %v", got)
+	}
+	if unacked := result.generators["gen-b"].Unacked; unacked != 3 {
+		t.Errorf("Expected 3 unacked messages, got %d", unacked)
+	}
+
+	result, err = verifyDelivery(zap.NewNop(), client, []string{"gen-a", "gen-c"}, 100*time.Millisecond, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.incomplete(); !slices.Equal(got, []string{"gen-c"}) {
+		t.Errorf("Expected only the untracked gen-c to be incomplete, got %v", got)
+	}
+
+	// The last messages arrive while polling
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		for id := uint64(8); id <= 10; id++ {
+			s.Tracker.Ack("gen-b", 1, 10, id)
+		}
+	}()
+	result, err = verifyDelivery(zap.NewNop(), client, nil, 5*time.Second, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.incomplete(); len(got) != 0 {
+		t.Errorf("Expected every generator to be complete, got %v", got)
+	}
+}
@@ -0,0 +1,168 @@
+package compression
+
+import (
+	"bytes"
+	gzip2 "compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/gzip"
+)
+
+// Type is a compression codec for exported payloads
+type Type int
+
+const (
+	Gzip Type = iota
+	Zstd
+	None
+)
+
+func (t Type) String() string {
+	switch t {
+	case Gzip:
+		return "gzip"
+	case Zstd:
+		return "zstd"
+	case None:
+		return "none"
+	default:
+		return "unknown"
+	}
+}
+
+func Parse(s string) (Type, error) {
+	switch s {
+	case "gzip":
+		return Gzip, nil
+	case "zstd":
+		return Zstd, nil
+	case "none":
+		return None, nil
+	default:
+		return 0, fmt.Errorf("invalid compression: %q (expected gzip, zstd or none)", s)
+	}
+}
+
+// ContentEncoding returns the HTTP Content-Encoding value, empty for None
+func (t Type) ContentEncoding() string {
+	switch t {
+	case Gzip, Zstd:
+		return t.String()
+	default:
+		return ""
+	}
+}
+
+// GRPCCompressor returns the name of the registered gRPC compressor, empty for None
+func (t Type) GRPCCompressor() string {
+	switch t {
+	case Gzip:
+		return gzip.Name
+	case Zstd:
+		return zstdName
+	default:
+		return ""
+	}
+}
+
+// Compress returns buf compressed with the codec, None returns buf as is
+func (t Type) Compress(buf []byte) ([]byte, error) {
+	switch t {
+	case Gzip:
+		out := bytes.NewBuffer(nil)
+		gw := gzip2.NewWriter(out)
+		if _, err := gw.Write(buf); err != nil {
+			return nil, err
+		}
+		if err := gw.Close(); err != nil {
+			return nil, err
+		}
+		return out.Bytes(), nil
+	case Zstd:
+		return zstdEncoder.EncodeAll(buf, nil), nil
+	default:
+		return buf, nil
+	}
+}
+
+// ParseContentEncoding returns the codec for an HTTP Content-Encoding value,
+// an empty encoding or identity is None
+func ParseContentEncoding(s string) (Type, error) {
+	switch s {
+	case "", "identity":
+		return None, nil
+	default:
+		return Parse(s)
+	}
+}
+
+// Decompress returns buf decompressed with the codec, None returns buf as is
+func (t Type) Decompress(buf []byte) ([]byte, error) {
+	switch t {
+	case Gzip:
+		gr, err := gzip2.NewReader(bytes.NewReader(buf))
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		return io.ReadAll(gr)
+	case Zstd:
+		return zstdDecoder.DecodeAll(buf, nil)
+	default:
+		return buf, nil
+	}
+}
+
+const zstdName = "zstd"
+
+// The encoder and decoder are safe for concurrent use of EncodeAll and DecodeAll
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+func init() {
+	encoding.RegisterCompressor(zstdCompressor{})
+}
+
+// zstdCompressor implements the gRPC encoding.Compressor interface
+type zstdCompressor struct{}
+
+func (zstdCompressor) Name() string {
+	return zstdName
+}
+
+func (zstdCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	return &zstdWriter{w: w}, nil
+}
+
+func (zstdCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	compressed, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := zstdDecoder.DecodeAll(compressed, nil)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(out), nil
+}
+
+// zstdWriter buffers a gRPC message and compresses it as a single frame on Close
+type zstdWriter struct {
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+func (z *zstdWriter) Write(p []byte) (int, error) {
+	return z.buf.Write(p)
+}
+
+func (z *zstdWriter) Close() error {
+	_, err := z.w.Write(zstdEncoder.EncodeAll(z.buf.Bytes(), nil))
+	return err
+}
@@ -0,0 +1,118 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"google.golang.org/grpc/encoding"
+)
+
+func TestCompress(t *testing.T) {
+	payload := bytes.Repeat([]byte("otel-loadgen "), 1000)
+
+	gz, err := Gzip.Compress(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gr, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, _ := io.ReadAll(gr); !bytes.Equal(out, payload) {
+		t.Error("gzip round trip mismatch")
+	}
+
+	zs, err := Zstd.Compress(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zs) >= len(payload) {
+		t.Errorf("Expected zstd to compress the payload, got %d bytes", len(zs))
+	}
+	if out, err := zstdDecoder.DecodeAll(zs, nil); err != nil || !bytes.Equal(out, payload) {
+		t.Errorf("zstd round trip mismatch: %v", err)
+	}
+
+	none, _ := None.Compress(payload)
+	if !bytes.Equal(none, payload) {
+		t.Error("Expected none to return the payload unchanged")
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	payload := bytes.Repeat([]byte("otel-loadgen "), 1000)
+
+	for _, encoding := range []string{"", "identity", "gzip", "zstd"} {
+		typ, err := ParseContentEncoding(encoding)
+		if err != nil {
+			t.Fatalf("%q: %v", encoding, err)
+		}
+		compressed, err := typ.Compress(payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := typ.Decompress(compressed)
+		if err != nil {
+			t.Fatalf("%q: %v", encoding, err)
+		}
+		if !bytes.Equal(out, payload) {
+			t.Errorf("%q: round trip mismatch", encoding)
+		}
+	}
+
+	if _, err := ParseContentEncoding("br"); err == nil {
+		t.Error("Expected unsupported content encoding to fail")
+	}
+	if _, err := Gzip.Decompress([]byte("not gzip")); err == nil {
+		t.Error("Expected invalid gzip body to fail")
+	}
+}
+
+func TestGRPCZstdCompressor(t *testing.T) {
+	c := encoding.GetCompressor(Zstd.GRPCCompressor())
+	if c == nil {
+		t.Fatal("Expected zstd compressor to be registered")
+	}
+
+	payload := bytes.Repeat([]byte("span "), 500)
+
+	var compressed bytes.Buffer
+	wc, err := c.Compress(&compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = wc.Write(payload[:100])
+	_, _ = wc.Write(payload[100:])
+	if err := wc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := c.Decompress(&compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, _ := io.ReadAll(r); !bytes.Equal(out, payload) {
+		t.Error("gRPC zstd round trip mismatch")
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, s := range []string{"gzip", "zstd", "none"} {
+		c, err := Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.String() != s {
+			t.Errorf("Expected %s, got %s", s, c)
+		}
+	}
+
+	if _, err := Parse("lz4"); err == nil {
+		t.Error("Expected error for unsupported compression")
+	}
+	if None.ContentEncoding() != "" || None.GRPCCompressor() != "" {
+		t.Error("Expected no content encoding or compressor for none")
+	}
+}
@@ -7,23 +7,92 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/streamfold/otel-loadgen/internal/control/controlpb"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 )
 
+// DefaultBufferSize is the default number of controls queued for the control
+// server
+const DefaultBufferSize = 100
+
+// Overflow determines what Send does when the control queue is full
+type Overflow int
+
+const (
+	// OverflowBlock waits for room in the queue, so every range is accounted
+	// for but a slow control server throttles the generator
+	OverflowBlock Overflow = iota
+	// OverflowDrop drops new range notifications, so the generator keeps its
+	// rate but the sink only learns of a dropped range from its acks
+	OverflowDrop
+)
+
+func ParseOverflow(s string) (Overflow, error) {
+	switch s {
+	case "block":
+		return OverflowBlock, nil
+	case "drop":
+		return OverflowDrop, nil
+	default:
+		return 0, fmt.Errorf("invalid control overflow: %q (expected block or drop)", s)
+	}
+}
+
+// ClientConfig holds the control client settings
+type ClientConfig struct {
+	// FlushTimeout bounds how long queued controls are flushed on Stop or
+	// Drain, zero waits until all are sent
+	FlushTimeout time.Duration
+	// BufferSize is the number of controls queued for the control server,
+	// default DefaultBufferSize
+	BufferSize int
+	// Overflow determines what Send does when the queue is full
+	Overflow Overflow
+	// BatchSize is the most controls posted to /api/message_ranges in one
+	// request. 0 or 1 posts each control on its own.
+	BatchSize int
+	// BatchTimeout is the longest a control waits for a batch to fill
+	BatchTimeout time.Duration
+	// Transport is the protocol ranges are published with, the endpoint is
+	// the gRPC control service address with TransportGRPC
+	Transport Transport
+}
+
 // Client is a client for the control server
 type Client struct {
-	endpointUrl *url.URL
-	log         *zap.Logger
-	msgCh       chan Control
-	wg          sync.WaitGroup
-	client      *http.Client
+	endpointUrl  *url.URL
+	log          *zap.Logger
+	msgCh        chan Control
+	wg           sync.WaitGroup
+	client       *http.Client
+	flushTimeout time.Duration
+	overflow     Overflow
+	batchSize    int
+	batchTimeout time.Duration
+	transport    Transport
+	// grpcConn and stream publish ranges with TransportGRPC, the stream is
+	// only used by the processing goroutine
+	grpcConn *grpc.ClientConn
+	stream   controlpb.Control_PublishRangesClient
+	// ctx is cancelled when the flush timeout expires, aborting in-flight
+	// requests and dropping the remaining controls
+	ctx     context.Context
+	cancel  context.CancelFunc
+	dropped int
+	// overflowed counts the controls dropped by Send because the queue was
+	// full
+	overflowed atomic.Uint64
 }
 
 // NewClient creates a new control server client
-func NewClient(endpoint string, log *zap.Logger) (*Client, error) {
+func NewClient(endpoint string, cfg ClientConfig, log *zap.Logger) (*Client, error) {
 	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
 		endpoint = fmt.Sprintf("http://%s", endpoint)
 	}
@@ -33,19 +102,141 @@ func NewClient(endpoint string, log *zap.Logger) (*Client, error) {
 		return nil, err
 	}
 
+	var grpcConn *grpc.ClientConn
+	if cfg.Transport == TransportGRPC {
+		grpcConn, err = newGRPCConn(endpointUrl.Host)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	bufferSize := cfg.BufferSize
+	if bufferSize == 0 {
+		bufferSize = DefaultBufferSize
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
 	return &Client{
-		endpointUrl: endpointUrl,
-		log:         log,
-		msgCh:       make(chan Control, 100),
-		client:      &http.Client{},
+		endpointUrl:  endpointUrl,
+		log:          log,
+		msgCh:        make(chan Control, bufferSize),
+		client:       &http.Client{},
+		flushTimeout: cfg.FlushTimeout,
+		overflow:     cfg.Overflow,
+		batchSize:    cfg.BatchSize,
+		batchTimeout: cfg.BatchTimeout,
+		transport:    cfg.Transport,
+		grpcConn:     grpcConn,
+		ctx:          ctx,
+		cancel:       cancel,
 	}, nil
 }
 
+// Ping checks that the control server is reachable
+func (c *Client) Ping(ctx context.Context) error {
+	if c.transport == TransportGRPC {
+		return c.pingGRPC(ctx)
+	}
+
+	url := fmt.Sprintf("%s/api/health", c.endpointUrl.String())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach control server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// Unacked fetches up to limit unacked message IDs of a generator
+func (c *Client) Unacked(ctx context.Context, generatorID string, limit int) (UnackedReport, error) {
+	var report UnackedReport
+	query := url.Values{"generator_id": {generatorID}, "limit": {strconv.Itoa(limit)}}
+	err := c.getJSON(ctx, "/api/unacked", query, &report)
+	return report, err
+}
+
+// Report fetches the delivery report of every generator, counting the
+// messages of ranges older than olderThan as unacked
+func (c *Client) Report(ctx context.Context, olderThan time.Duration) (Report, error) {
+	var report Report
+	err := c.getJSON(ctx, "/api/report", url.Values{"older_than": {olderThan.String()}}, &report)
+	return report, err
+}
+
+// Ranges fetches the message ranges of a generator
+func (c *Client) Ranges(ctx context.Context, generatorID string) (RangesReport, error) {
+	var report RangesReport
+	err := c.getJSON(ctx, "/api/ranges", url.Values{"generator_id": {generatorID}}, &report)
+	return report, err
+}
+
+func (c *Client) getJSON(ctx context.Context, path string, query url.Values, out any) error {
+	url := fmt.Sprintf("%s%s?%s", c.endpointUrl.String(), path, query.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", path, err)
+	}
+	return nil
+}
+
 // MessageChannel returns the channel for sending message ranges
 func (c *Client) MessageChannel() chan<- Control {
 	return c.msgCh
 }
 
+// Send queues a control for the control server. With OverflowDrop, new
+// ranges are dropped rather than waiting for room in a full queue. Updates
+// are only sent as generators stop, under the flush timeout, and always wait
+// since a lost update leaves the sink waiting on IDs that were never sent.
+func (c *Client) Send(ctrl Control) {
+	if c.overflow == OverflowBlock || ctrl.Type == ControlTypeUpdate {
+		c.msgCh <- ctrl
+		return
+	}
+
+	select {
+	case c.msgCh <- ctrl:
+	default:
+		if c.overflowed.Add(1) == 1 {
+			c.log.Warn("control queue is full, dropping message range notifications",
+				zap.Int("buffer_size", cap(c.msgCh)))
+		}
+	}
+}
+
+// Overflowed returns the number of controls dropped because the queue was
+// full
+func (c *Client) Overflowed() uint64 {
+	return c.overflowed.Load()
+}
+
 // Start begins processing message ranges and sending them to the control server
 func (c *Client) Start() {
 	c.wg.Add(1)
@@ -53,20 +244,97 @@ func (c *Client) Start() {
 	c.log.Info("Control client started", zap.String("endpoint", c.endpointUrl.String()))
 }
 
-// Stop gracefully stops the client
+// Stop gracefully stops the client, flushing the queued controls best-effort
+// until the flush timeout expires
 func (c *Client) Stop() {
+	c.Drain(nil)
+}
+
+// Drain stops the client once every queued control is sent. stop, if set,
+// stops the producers of the message channel and must return only after they
+// queued their last control. The flush timeout covers stop as well, so a
+// producer blocked on a full channel can't hang shutdown: once it expires the
+// remaining controls are dropped.
+func (c *Client) Drain(stop func()) {
 	c.log.Info("Stopping control client")
+
+	var expired <-chan time.Time
+	if c.flushTimeout > 0 {
+		timer := time.NewTimer(c.flushTimeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	// Producers may still be sending, so the channel is closed only after
+	// they've stopped
+	if stop != nil {
+		stopped := make(chan struct{})
+		go func() {
+			stop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+		case <-expired:
+			c.cancel()
+			<-stopped
+		}
+	}
 	close(c.msgCh)
-	c.wg.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-expired:
+		c.cancel()
+		<-done
+	}
+	c.cancel()
+
+	if c.dropped > 0 {
+		c.log.Warn("control flush timed out, dropped remaining controls",
+			zap.Duration("flush_timeout", c.flushTimeout), zap.Int("dropped", c.dropped))
+	}
+	if c.grpcConn != nil {
+		_ = c.grpcConn.Close()
+	}
+	if n := c.overflowed.Load(); n > 0 {
+		c.log.Warn("dropped message range notifications while the control queue was full",
+			zap.Uint64("dropped", n), zap.Int("buffer_size", cap(c.msgCh)))
+	}
 	c.log.Info("Control client stopped")
 }
 
 func (c *Client) processMessages() {
 	defer c.wg.Done()
+	if c.transport == TransportGRPC {
+		defer c.finishStream()
+	}
+
+	if c.batchSize > 1 {
+		c.processBatches()
+		return
+	}
 
 	for ctrl := range c.msgCh {
+		if c.ctx.Err() != nil {
+			c.dropped++
+			continue
+		}
+
 		mr := ctrl.Range
-		if err := c.postMessageRange(ctrl.Type, mr); err != nil {
+		if err := c.publish(ctrl); err != nil {
+			if c.ctx.Err() != nil {
+				c.dropped++
+				continue
+			}
+
 			c.log.Error("failed to post message range",
 				zap.Error(err),
 				zap.String("generator_id", mr.GeneratorID),
@@ -84,6 +352,118 @@ func (c *Client) processMessages() {
 	}
 }
 
+// processBatches posts the queued controls in batches of up to batchSize, a
+// partial batch is posted once its first control has waited batchTimeout
+func (c *Client) processBatches() {
+	batch := make([]Control, 0, c.batchSize)
+	timer := time.NewTimer(c.batchTimeout)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case ctrl, ok := <-c.msgCh:
+			if !ok {
+				c.postBatch(batch)
+				return
+			}
+			if len(batch) == 0 {
+				timer.Reset(c.batchTimeout)
+			}
+			batch = append(batch, ctrl)
+			if len(batch) < c.batchSize {
+				continue
+			}
+			timer.Stop()
+		case <-timer.C:
+		}
+
+		c.postBatch(batch)
+		batch = batch[:0]
+	}
+}
+
+func (c *Client) postBatch(batch []Control) {
+	if len(batch) == 0 {
+		return
+	}
+	if c.ctx.Err() != nil {
+		c.dropped += len(batch)
+		return
+	}
+
+	if err := c.publishBatch(batch); err != nil {
+		if c.ctx.Err() != nil {
+			c.dropped += len(batch)
+			return
+		}
+
+		c.log.Error("failed to post message ranges",
+			zap.Error(err),
+			zap.Int("count", len(batch)),
+		)
+	} else {
+		c.log.Debug("posted message ranges", zap.Int("count", len(batch)))
+	}
+}
+
+// publish sends a single control with the configured transport
+func (c *Client) publish(ctrl Control) error {
+	if c.transport == TransportGRPC {
+		return c.streamRanges([]Control{ctrl})
+	}
+	return c.postMessageRange(ctrl.Type, ctrl.Range)
+}
+
+// publishBatch sends a batch of controls with the configured transport
+func (c *Client) publishBatch(batch []Control) error {
+	if c.transport == TransportGRPC {
+		return c.streamRanges(batch)
+	}
+	return c.postMessageRanges(batch)
+}
+
+// finishStream closes the gRPC stream once every control is published
+func (c *Client) finishStream() {
+	result, err := c.closeStream()
+	if err != nil {
+		if c.ctx.Err() == nil {
+			c.log.Error("failed to close control stream", zap.Error(err))
+		}
+		return
+	}
+	if result.Rejected > 0 {
+		c.log.Warn("control server rejected published ranges",
+			zap.Uint64("accepted", result.Accepted), zap.Uint64("rejected", result.Rejected))
+	}
+}
+
+func bulkMessages(batch []Control) []BulkControlMessage {
+	pubs := make([]BulkControlMessage, 0, len(batch))
+	for _, ctrl := range batch {
+		pubs = append(pubs, BulkControlMessage{
+			ControlMessage: ControlMessage{
+				GeneratorID: ctrl.Range.GeneratorID,
+				Timestamp:   ctrl.Range.Timestamp,
+				StartID:     ctrl.Range.StartID,
+				RangeLen:    ctrl.Range.RangeLen,
+			},
+			Update: ctrl.Type == ControlTypeUpdate,
+		})
+	}
+	return pubs
+}
+
+func (c *Client) postMessageRanges(batch []Control) error {
+	data, err := json.Marshal(bulkMessages(batch))
+	if err != nil {
+		return fmt.Errorf("failed to marshal published messages: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/message_ranges", c.endpointUrl.String())
+	return c.post(http.MethodPost, url, data)
+}
+
 func (c *Client) postMessageRange(msgType ControlType, mr MessageRange) error {
 	pub := ControlMessage{
 		GeneratorID: mr.GeneratorID,
@@ -103,7 +483,11 @@ func (c *Client) postMessageRange(msgType ControlType, mr MessageRange) error {
 		method = http.MethodPut
 	}
 
-	req, err := http.NewRequestWithContext(context.Background(), method, url, bytes.NewReader(data))
+	return c.post(method, url, data)
+}
+
+func (c *Client) post(method, url string, data []byte) error {
+	req, err := http.NewRequestWithContext(c.ctx, method, url, bytes.NewReader(data))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -0,0 +1,267 @@
+package control
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func testControl(startID uint64) Control {
+	return Control{
+		Type: ControlTypeNew,
+		Range: MessageRange{
+			GeneratorID: "gen-a",
+			StartID:     startID,
+			RangeLen:    10,
+			Timestamp:   time.Now(),
+		},
+	}
+}
+
+func TestClientStop_UnresponsiveServer(t *testing.T) {
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-block:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(block)
+
+	const flushTimeout = 100 * time.Millisecond
+	c, err := NewClient(srv.URL, ClientConfig{FlushTimeout: flushTimeout}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Start()
+
+	for i := uint64(0); i < 5; i++ {
+		c.MessageChannel() <- testControl(i * 10)
+	}
+
+	start := time.Now()
+	c.Stop()
+	if elapsed := time.Since(start); elapsed > flushTimeout+time.Second {
+		t.Fatalf("Expected Stop to return within the flush timeout, took %s", elapsed)
+	}
+
+	if c.dropped != 5 {
+		t.Errorf("Expected 5 dropped controls, got %d", c.dropped)
+	}
+}
+
+func TestClientStop_FlushesQueuedControls(t *testing.T) {
+	var received atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, ClientConfig{FlushTimeout: time.Second}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Start()
+
+	for i := uint64(0); i < 5; i++ {
+		c.MessageChannel() <- testControl(i * 10)
+	}
+	c.Stop()
+
+	if received.Load() != 5 {
+		t.Errorf("Expected 5 controls to be flushed, got %d", received.Load())
+	}
+	if c.dropped != 0 {
+		t.Errorf("Expected no dropped controls, got %d", c.dropped)
+	}
+}
+
+func TestClientDrain_BlockedProducer(t *testing.T) {
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-block:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(block)
+
+	const flushTimeout = 100 * time.Millisecond
+	c, err := NewClient(srv.URL, ClientConfig{FlushTimeout: flushTimeout}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Start()
+
+	// The producer fills the channel while the server hangs, so its last
+	// sends block until the flush timeout drops the queue
+	const sent = 200
+	start := time.Now()
+	c.Drain(func() {
+		for i := uint64(0); i < sent; i++ {
+			c.MessageChannel() <- testControl(i * 10)
+		}
+	})
+	if elapsed := time.Since(start); elapsed > flushTimeout+time.Second {
+		t.Fatalf("Expected Drain to return within the flush timeout, took %s", elapsed)
+	}
+
+	if c.dropped != sent {
+		t.Errorf("Expected %d dropped controls, got %d", sent, c.dropped)
+	}
+}
+
+func TestClientSend_OverflowDrop(t *testing.T) {
+	// The client isn't started, so nothing drains the queue
+	c, err := NewClient("localhost:0", ClientConfig{BufferSize: 2, Overflow: OverflowDrop}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := uint64(0); i < 5; i++ {
+		c.Send(testControl(i * 10))
+	}
+	if len(c.msgCh) != 2 || c.Overflowed() != 3 {
+		t.Errorf("Expected 2 queued and 3 dropped controls, got %d and %d", len(c.msgCh), c.Overflowed())
+	}
+
+	// Updates wait for room rather than being dropped
+	sent := make(chan struct{})
+	go func() {
+		update := testControl(0)
+		update.Type = ControlTypeUpdate
+		c.Send(update)
+		close(sent)
+	}()
+	<-c.msgCh
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the update to be queued once there was room")
+	}
+	if c.Overflowed() != 3 {
+		t.Errorf("Expected the update not to be dropped, got %d dropped", c.Overflowed())
+	}
+}
+
+func TestClientSend_OverflowBlock(t *testing.T) {
+	c, err := NewClient("localhost:0", ClientConfig{BufferSize: 2}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := make(chan struct{})
+	go func() {
+		for i := uint64(0); i < 5; i++ {
+			c.Send(testControl(i * 10))
+		}
+		close(sent)
+	}()
+
+	for i := 0; i < 5; i++ {
+		select {
+		case <-c.msgCh:
+		case <-time.After(time.Second):
+			t.Fatalf("Expected 5 queued controls, got %d", i)
+		}
+	}
+	<-sent
+
+	if c.Overflowed() != 0 {
+		t.Errorf("Expected no dropped controls, got %d", c.Overflowed())
+	}
+}
+
+func TestParseOverflow(t *testing.T) {
+	for s, want := range map[string]Overflow{"block": OverflowBlock, "drop": OverflowDrop} {
+		if got, err := ParseOverflow(s); err != nil || got != want {
+			t.Errorf("ParseOverflow(%q) = %d, %v, expected %d", s, got, err, want)
+		}
+	}
+	if _, err := ParseOverflow("wait"); err == nil {
+		t.Error("Expected an error for an invalid overflow")
+	}
+}
+
+func TestClient_Batching(t *testing.T) {
+	var mu sync.Mutex
+	var batches [][]BulkControlMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/message_ranges" {
+			t.Errorf("Expected batches to be posted to /api/message_ranges, got %s", r.URL.Path)
+		}
+		var batch []BulkControlMessage
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Errorf("Failed to decode batch: %v", err)
+		}
+		mu.Lock()
+		batches = append(batches, batch)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, ClientConfig{BatchSize: 4, BatchTimeout: time.Hour}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Start()
+
+	for i := uint64(0); i < 9; i++ {
+		c.Send(testControl(i * 10))
+	}
+	update := testControl(80)
+	update.Type = ControlTypeUpdate
+	c.Send(update)
+	c.Stop()
+
+	// Two full batches, then the rest are flushed on stop
+	sizes := make([]int, 0, len(batches))
+	for _, batch := range batches {
+		sizes = append(sizes, len(batch))
+	}
+	if !slices.Equal(sizes, []int{4, 4, 2}) {
+		t.Fatalf("Expected batches of 4, 4 and 2, got %v", sizes)
+	}
+	last := batches[2]
+	if last[0].StartID != 80 || last[0].Update || last[1].StartID != 80 || !last[1].Update {
+		t.Errorf("Expected the new and updated range 80 in order, got %+v", last)
+	}
+}
+
+func TestClient_BatchTimeout(t *testing.T) {
+	var received atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch []BulkControlMessage
+		json.NewDecoder(r.Body).Decode(&batch)
+		received.Add(int64(len(batch)))
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, ClientConfig{BatchSize: 100, BatchTimeout: 20 * time.Millisecond}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Start()
+	defer c.Stop()
+
+	c.Send(testControl(0))
+	c.Send(testControl(10))
+
+	deadline := time.Now().Add(time.Second)
+	for received.Load() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected a partial batch to be posted after the batch timeout, got %d", received.Load())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,5 @@
+// Package controlpb holds the messages and stubs of the gRPC control service
+// generated from control.proto
+package controlpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative control.proto
@@ -0,0 +1,198 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/control/controlpb"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// The gRPC control service is defined in controlpb/control.proto. A generator
+// keeps a single PublishRanges stream open, so a range costs one stream
+// message rather than an HTTP request.
+
+// Transport is the protocol generators use to publish message ranges
+type Transport int
+
+const (
+	// TransportHTTP posts ranges to the HTTP control server
+	TransportHTTP Transport = iota
+	// TransportGRPC streams ranges to the gRPC control service
+	TransportGRPC
+)
+
+func ParseTransport(s string) (Transport, error) {
+	switch s {
+	case "http":
+		return TransportHTTP, nil
+	case "grpc":
+		return TransportGRPC, nil
+	default:
+		return 0, fmt.Errorf("invalid control transport: %q (expected http or grpc)", s)
+	}
+}
+
+// grpcService implements the gRPC control service for the Server
+type grpcService struct {
+	controlpb.UnimplementedControlServer
+	s *Server
+}
+
+func (g *grpcService) Health(context.Context, *controlpb.HealthRequest) (*controlpb.HealthResponse, error) {
+	return &controlpb.HealthResponse{}, nil
+}
+
+func (g *grpcService) PublishRanges(stream controlpb.Control_PublishRangesServer) error {
+	return g.s.publishRanges(stream)
+}
+
+// ServeGRPCOn additionally serves the gRPC control service on addr, it must be
+// called before Start
+func (s *Server) ServeGRPCOn(addr string) {
+	s.grpcAddr = addr
+}
+
+// GRPCAddr returns the address the gRPC control service listens on once
+// started, if enabled
+func (s *Server) GRPCAddr() string {
+	return s.grpcAddr
+}
+
+func (s *Server) startGRPCServer() error {
+	lis, err := net.Listen("tcp", s.grpcAddr)
+	if err != nil {
+		return err
+	}
+	s.grpcAddr = lis.Addr().String()
+
+	s.grpcSrv = grpc.NewServer()
+	controlpb.RegisterControlServer(s.grpcSrv, &grpcService{s: s})
+
+	s.log.Info("Starting gRPC control server", zap.String("addr", s.grpcAddr))
+	go func() {
+		if err := s.grpcSrv.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			s.log.Error("gRPC control server error", zap.Error(err))
+		}
+	}()
+
+	return nil
+}
+
+func (s *Server) publishRanges(stream controlpb.Control_PublishRangesServer) error {
+	var result controlpb.PublishResult
+	for {
+		batch, err := stream.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return stream.SendAndClose(&result)
+			}
+			return err
+		}
+
+		ranges := rangesFromProto(batch.Ranges)
+		if err := validateRanges(ranges); err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		conflicts, err := s.applyRanges(ranges)
+		if conflicts > 0 {
+			s.log.Warn("rejected published ranges", zap.Int("rejected", conflicts), zap.Error(err))
+		}
+		result.Accepted += uint64(len(ranges) - conflicts)
+		result.Rejected += uint64(conflicts)
+	}
+}
+
+// rangesFromProto converts published ranges to the messages of the HTTP
+// endpoints
+func rangesFromProto(ranges []*controlpb.MessageRange) []BulkControlMessage {
+	msgs := make([]BulkControlMessage, 0, len(ranges))
+	for _, r := range ranges {
+		var ts time.Time
+		if r.TimeUnixNano != 0 {
+			ts = time.Unix(0, int64(r.TimeUnixNano))
+		}
+		msgs = append(msgs, BulkControlMessage{
+			ControlMessage: ControlMessage{
+				GeneratorID: r.GeneratorId,
+				Timestamp:   ts,
+				StartID:     r.StartId,
+				RangeLen:    uint(r.RangeLen),
+			},
+			Update: r.Update,
+		})
+	}
+	return msgs
+}
+
+// rangesToProto converts a batch of controls to published ranges
+func rangesToProto(batch []Control) []*controlpb.MessageRange {
+	ranges := make([]*controlpb.MessageRange, 0, len(batch))
+	for _, ctrl := range batch {
+		var ts uint64
+		if !ctrl.Range.Timestamp.IsZero() {
+			ts = uint64(ctrl.Range.Timestamp.UnixNano())
+		}
+		ranges = append(ranges, &controlpb.MessageRange{
+			GeneratorId:  ctrl.Range.GeneratorID,
+			TimeUnixNano: ts,
+			StartId:      ctrl.Range.StartID,
+			RangeLen:     uint64(ctrl.Range.RangeLen),
+			Update:       ctrl.Type == ControlTypeUpdate,
+		})
+	}
+	return ranges
+}
+
+func newGRPCConn(target string) (*grpc.ClientConn, error) {
+	return grpc.NewClient(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+}
+
+func (c *Client) pingGRPC(ctx context.Context) error {
+	if _, err := controlpb.NewControlClient(c.grpcConn).Health(ctx, &controlpb.HealthRequest{}); err != nil {
+		return fmt.Errorf("failed to reach control server: %w", err)
+	}
+	return nil
+}
+
+// streamRanges sends a batch on the PublishRanges stream, opening it first if
+// needed. A broken stream is closed so the next batch opens a new one.
+func (c *Client) streamRanges(batch []Control) error {
+	if c.stream == nil {
+		stream, err := controlpb.NewControlClient(c.grpcConn).PublishRanges(c.ctx)
+		if err != nil {
+			return fmt.Errorf("failed to open stream: %w", err)
+		}
+		c.stream = stream
+	}
+
+	if err := c.stream.Send(&controlpb.RangeBatch{Ranges: rangesToProto(batch)}); err != nil {
+		// The stream status is only returned by CloseAndRecv
+		if _, closeErr := c.closeStream(); closeErr != nil {
+			err = closeErr
+		}
+		return fmt.Errorf("failed to send ranges: %w", err)
+	}
+	return nil
+}
+
+// closeStream closes the PublishRanges stream, if open, and returns the
+// server's result
+func (c *Client) closeStream() (*controlpb.PublishResult, error) {
+	if c.stream == nil {
+		return &controlpb.PublishResult{}, nil
+	}
+
+	stream := c.stream
+	c.stream = nil
+	return stream.CloseAndRecv()
+}
@@ -0,0 +1,97 @@
+package control
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/msg_tracker"
+	"go.uber.org/zap"
+)
+
+func startGRPCServer(t *testing.T, mt *msg_tracker.Tracker) *Server {
+	t.Helper()
+
+	s := New("localhost:0", mt, time.Hour, zap.NewNop())
+	s.ServeGRPCOn("localhost:0")
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Stop() })
+	return s
+}
+
+func TestClient_GRPCTransport(t *testing.T) {
+	for _, batchSize := range []int{1, 4} {
+		mt := msg_tracker.NewTracker(zap.NewNop())
+		s := startGRPCServer(t, mt)
+
+		c, err := NewClient(s.GRPCAddr(), ClientConfig{Transport: TransportGRPC, BatchSize: batchSize, BatchTimeout: time.Hour}, zap.NewNop())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Ping(context.Background()); err != nil {
+			t.Fatalf("Expected the gRPC control service to be reachable: %v", err)
+		}
+		c.Start()
+
+		for i := uint64(0); i < 5; i++ {
+			c.Send(testControl(i * 10))
+		}
+		update := testControl(40)
+		update.Type = ControlTypeUpdate
+		update.Range.RangeLen = 3
+		c.Send(update)
+		c.Stop()
+
+		ranges := mt.Ranges("gen-a")
+		if len(ranges) != 5 {
+			t.Fatalf("batch size %d: expected 5 ranges, got %d", batchSize, len(ranges))
+		}
+		if last := ranges[4]; last.StartID != 40 || last.RangeLen != 3 {
+			t.Errorf("batch size %d: expected the last range to be updated to 40+3, got %d+%d", batchSize, last.StartID, last.RangeLen)
+		}
+	}
+}
+
+func TestClient_GRPCPingUnreachable(t *testing.T) {
+	c, err := NewClient("localhost:1", ClientConfig{Transport: TransportGRPC}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := c.Ping(ctx); err == nil {
+		t.Error("Expected an error pinging an unreachable control service")
+	}
+}
+
+func TestRangesProto(t *testing.T) {
+	added := testControl(10)
+	update := testControl(20)
+	update.Type = ControlTypeUpdate
+	update.Range.Timestamp = time.Time{}
+
+	msgs := rangesFromProto(rangesToProto([]Control{added, update}))
+	if len(msgs) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(msgs))
+	}
+	if got := msgs[0]; got.Update || got.GeneratorID != "gen-a" || got.StartID != 10 || got.RangeLen != 10 || !got.Timestamp.Equal(added.Range.Timestamp) {
+		t.Errorf("Expected the new range to be converted unchanged, got %+v", got)
+	}
+	if got := msgs[1]; !got.Update || !got.Timestamp.IsZero() {
+		t.Errorf("Expected an update without a timestamp, got %+v", got)
+	}
+}
+
+func TestParseTransport(t *testing.T) {
+	for s, want := range map[string]Transport{"http": TransportHTTP, "grpc": TransportGRPC} {
+		if got, err := ParseTransport(s); err != nil || got != want {
+			t.Errorf("ParseTransport(%q) = %d, %v, expected %d", s, got, err, want)
+		}
+	}
+	if _, err := ParseTransport("udp"); err == nil {
+		t.Error("Expected an error for an invalid transport")
+	}
+}
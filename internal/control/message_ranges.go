@@ -0,0 +1,75 @@
+package control
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// handleMessageRanges applies a batch of new and updated message ranges in
+// order, as if each was published to /api/message_range. The whole batch is
+// rejected if any element is invalid. Ranges overlapping an existing range
+// are skipped and reported with 409 Conflict once the rest are applied.
+func (s *Server) handleMessageRanges(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var batch []BulkControlMessage
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		s.log.Error("failed to decode published notifications", zap.Error(err))
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateRanges(batch); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.log.Debug("received published notifications", zap.Int("count", len(batch)))
+
+	conflicts, firstErr := s.applyRanges(batch)
+	if conflicts > 0 {
+		http.Error(w, fmt.Sprintf("%d of %d ranges rejected: %v", conflicts, len(batch), firstErr), http.StatusConflict)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+func validateRanges(batch []BulkControlMessage) error {
+	for i, pub := range batch {
+		if pub.GeneratorID == "" {
+			return fmt.Errorf("generator_id is required (element %d)", i)
+		}
+		if pub.RangeLen == 0 {
+			return fmt.Errorf("range_len is required (element %d)", i)
+		}
+	}
+	return nil
+}
+
+// applyRanges adds or updates each range in order, returning the number of
+// ranges rejected for overlapping an existing range and the first error
+func (s *Server) applyRanges(batch []BulkControlMessage) (int, error) {
+	var conflicts int
+	var firstErr error
+	for _, pub := range batch {
+		if pub.Update {
+			s.mt.UpdateRange(pub.GeneratorID, pub.StartID, pub.RangeLen)
+			continue
+		}
+		if err := s.mt.AddRange(pub.GeneratorID, pub.StartID, pub.RangeLen, pub.Timestamp); err != nil {
+			conflicts++
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return conflicts, firstErr
+}
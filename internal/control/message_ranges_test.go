@@ -0,0 +1,55 @@
+package control
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/msg_tracker"
+	"go.uber.org/zap"
+)
+
+func TestHandleMessageRanges(t *testing.T) {
+	mt := msg_tracker.NewTracker(zap.NewNop())
+	s := New("localhost:0", mt, time.Second, zap.NewNop())
+
+	post := func(batch []BulkControlMessage) int {
+		body, _ := json.Marshal(batch)
+		rec := httptest.NewRecorder()
+		s.handleMessageRanges(rec, httptest.NewRequest(http.MethodPost, "/api/message_ranges", bytes.NewReader(body)))
+		return rec.Code
+	}
+	msg := func(start uint64, rangeLen uint, update bool) BulkControlMessage {
+		return BulkControlMessage{
+			ControlMessage: ControlMessage{GeneratorID: "gen-a", StartID: start, RangeLen: rangeLen, Timestamp: time.Now()},
+			Update:         update,
+		}
+	}
+
+	if code := post([]BulkControlMessage{msg(0, 10, false), msg(10, 10, false), msg(10, 4, true)}); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	ranges := mt.Ranges("gen-a")
+	if len(ranges) != 2 || ranges[0].RangeLen != 10 || ranges[1].StartID != 10 || ranges[1].RangeLen != 4 {
+		t.Errorf("Expected ranges 0+10 and 10+4, got %+v", ranges)
+	}
+
+	// The overlapping range is skipped, the rest are still added
+	if code := post([]BulkControlMessage{msg(5, 10, false), msg(20, 10, false)}); code != http.StatusConflict {
+		t.Errorf("Expected 409 for an overlapping range, got %d", code)
+	}
+	if n := len(mt.Ranges("gen-a")); n != 3 {
+		t.Errorf("Expected the non-overlapping range to be added, got %d ranges", n)
+	}
+
+	// An invalid element rejects the whole batch
+	if code := post([]BulkControlMessage{msg(30, 10, false), msg(40, 0, false)}); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a range without a length, got %d", code)
+	}
+	if n := len(mt.Ranges("gen-a")); n != 3 {
+		t.Errorf("Expected no ranges from an invalid batch, got %d ranges", n)
+	}
+}
@@ -0,0 +1,75 @@
+package control
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/msg_tracker"
+)
+
+const openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// handleOpenMetrics renders the per-generator tracker report in the OpenMetrics
+// text exposition format
+func (s *Server) handleOpenMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	now := time.Now()
+	reports := s.mt.GeneratorReport(now.Add(-1 * s.reportInterval))
+
+	w.Header().Set("Content-Type", openMetricsContentType)
+	writeOpenMetrics(w, reports, now)
+}
+
+func writeOpenMetrics(w io.Writer, reports map[string]msg_tracker.GeneratorReport, now time.Time) {
+	genIDs := make([]string, 0, len(reports))
+	for genID := range reports {
+		genIDs = append(genIDs, genID)
+	}
+	sort.Strings(genIDs)
+
+	writeFamily := func(name, metricType, help, suffix string, value func(msg_tracker.GeneratorReport) (float64, bool)) {
+		fmt.Fprintf(w, "# TYPE %s %s\n", name, metricType)
+		fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+		for _, genID := range genIDs {
+			v, ok := value(reports[genID])
+			if !ok {
+				continue
+			}
+			fmt.Fprintf(w, "%s%s{generator_id=\"%s\"} %v\n", name, suffix, escapeLabelValue(genID), v)
+		}
+	}
+
+	writeFamily("loadgen_messages_acked", "counter", "Total number of messages acked by the sink.", "_total",
+		func(r msg_tracker.GeneratorReport) (float64, bool) { return float64(r.TotalAcked), true })
+	writeFamily("loadgen_messages_duplicated", "counter", "Total number of duplicate messages received by the sink.", "_total",
+		func(r msg_tracker.GeneratorReport) (float64, bool) { return float64(r.TotalDuped), true })
+	writeFamily("loadgen_messages_likely_lost", "counter", "Total number of messages not acked within the ack timeout.", "_total",
+		func(r msg_tracker.GeneratorReport) (float64, bool) { return float64(r.LikelyLost), true })
+	writeFamily("loadgen_messages_duplicate_errors", "counter", "Total number of duplicate messages received in exactly-once mode.", "_total",
+		func(r msg_tracker.GeneratorReport) (float64, bool) { return float64(r.DuplicateErrors), true })
+	writeFamily("loadgen_messages_unacked", "gauge", "Number of published messages not yet acked.", "",
+		func(r msg_tracker.GeneratorReport) (float64, bool) { return float64(r.Unacked), true })
+	writeFamily("loadgen_oldest_unacked_age_seconds", "gauge", "Age of the oldest unacked message range.", "",
+		func(r msg_tracker.GeneratorReport) (float64, bool) {
+			if r.Unacked == 0 {
+				return 0, false
+			}
+			return now.Sub(r.OldestUnackedAge).Seconds(), true
+		})
+
+	fmt.Fprint(w, "# EOF\n")
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(v string) string {
+	return labelValueEscaper.Replace(v)
+}
@@ -0,0 +1,117 @@
+package control
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/msg_tracker"
+	"go.uber.org/zap"
+)
+
+var (
+	sampleLine   = regexp.MustCompile(`^([a-zA-Z_:][a-zA-Z0-9_:]*)\{generator_id="((?:[^"\\]|\\.)*)"\} (\S+)$`)
+	metadataLine = regexp.MustCompile(`^# (TYPE|HELP) ([a-zA-Z_:][a-zA-Z0-9_:]*) (.+)$`)
+)
+
+func TestHandleOpenMetrics(t *testing.T) {
+	mt := msg_tracker.NewTracker(zap.NewNop())
+	published := time.Now().Add(-time.Minute)
+
+	mt.AddRange("gen-a", 1, 10, published)
+	for id := uint64(1); id <= 4; id++ {
+		mt.Ack("gen-a", 1, 10, id)
+	}
+	mt.Ack("gen-a", 1, 10, 1)
+
+	mt.AddRange(`gen-"b"`, 1, 5, published)
+	for id := uint64(1); id <= 5; id++ {
+		mt.Ack(`gen-"b"`, 1, 5, id)
+	}
+
+	s := New("localhost:0", mt, time.Second, zap.NewNop())
+
+	rec := httptest.NewRecorder()
+	s.handleOpenMetrics(rec, httptest.NewRequest(http.MethodGet, "/api/metrics.txt", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/openmetrics-text") {
+		t.Errorf("Unexpected content type: %s", ct)
+	}
+
+	samples := make(map[string]string)
+	families := make(map[string]string)
+	sawEOF := false
+
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if sawEOF {
+			t.Fatalf("Unexpected line after # EOF: %q", line)
+		}
+
+		switch {
+		case line == "# EOF":
+			sawEOF = true
+		case metadataLine.MatchString(line):
+			m := metadataLine.FindStringSubmatch(line)
+			if m[1] == "TYPE" {
+				families[m[2]] = m[3]
+			}
+		case sampleLine.MatchString(line):
+			m := sampleLine.FindStringSubmatch(line)
+			family := strings.TrimSuffix(m[1], "_total")
+			if _, ok := families[family]; !ok {
+				t.Errorf("Sample %q appears before its # TYPE line", line)
+			}
+			samples[m[1]+"/"+m[2]] = m[3]
+		default:
+			t.Errorf("Unparseable line: %q", line)
+		}
+	}
+
+	if !sawEOF {
+		t.Error("Expected output to end with # EOF")
+	}
+	if families["loadgen_messages_acked"] != "counter" {
+		t.Errorf("Expected loadgen_messages_acked to be a counter, got %q", families["loadgen_messages_acked"])
+	}
+
+	expected := map[string]string{
+		"loadgen_messages_acked_total/gen-a":          "4",
+		"loadgen_messages_duplicated_total/gen-a":     "1",
+		"loadgen_messages_unacked/gen-a":              "6",
+		`loadgen_messages_acked_total/gen-\"b\"`:      "5",
+		`loadgen_messages_unacked/gen-\"b\"`:          "0",
+		`loadgen_messages_duplicated_total/gen-\"b\"`: "0",
+	}
+	for key, value := range expected {
+		if samples[key] != value {
+			t.Errorf("Expected sample %s = %s, got %q", key, value, samples[key])
+		}
+	}
+
+	if _, ok := samples["loadgen_oldest_unacked_age_seconds/gen-a"]; !ok {
+		t.Error("Expected oldest unacked age for gen-a")
+	}
+	if _, ok := samples[`loadgen_oldest_unacked_age_seconds/gen-\"b\"`]; ok {
+		t.Error("Expected no oldest unacked age for a fully acked generator")
+	}
+}
+
+func TestHandleOpenMetrics_MethodNotAllowed(t *testing.T) {
+	s := New("localhost:0", msg_tracker.NewTracker(zap.NewNop()), time.Second, zap.NewNop())
+
+	rec := httptest.NewRecorder()
+	s.handleOpenMetrics(rec, httptest.NewRequest(http.MethodPost, "/api/metrics.txt", nil))
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,87 @@
+package control
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/streamfold/otel-loadgen/internal/msg_tracker"
+	"go.uber.org/zap"
+)
+
+// trackerCollector exposes the per-generator tracker report as Prometheus
+// metrics, named like those of the OpenMetrics endpoint. The report is taken
+// on every scrape.
+type trackerCollector struct {
+	mt        *msg_tracker.Tracker
+	olderThan time.Duration
+
+	acked   *prometheus.Desc
+	duped   *prometheus.Desc
+	unacked *prometheus.Desc
+}
+
+func newTrackerCollector(mt *msg_tracker.Tracker, olderThan time.Duration) *trackerCollector {
+	labels := []string{"generator_id"}
+	return &trackerCollector{
+		mt:        mt,
+		olderThan: olderThan,
+		acked:     prometheus.NewDesc("loadgen_messages_acked_total", "Total number of messages acked by the sink.", labels, nil),
+		duped:     prometheus.NewDesc("loadgen_messages_duplicated_total", "Total number of duplicate messages received by the sink.", labels, nil),
+		unacked:   prometheus.NewDesc("loadgen_messages_unacked", "Number of published messages not yet acked, older than the report interval.", labels, nil),
+	}
+}
+
+func (c *trackerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acked
+	ch <- c.duped
+	ch <- c.unacked
+}
+
+func (c *trackerCollector) Collect(ch chan<- prometheus.Metric) {
+	reports := c.mt.GeneratorReport(time.Now().Add(-1 * c.olderThan))
+	for genID, report := range reports {
+		ch <- prometheus.MustNewConstMetric(c.acked, prometheus.CounterValue, float64(report.TotalAcked), genID)
+		ch <- prometheus.MustNewConstMetric(c.duped, prometheus.CounterValue, float64(report.TotalDuped), genID)
+		ch <- prometheus.MustNewConstMetric(c.unacked, prometheus.GaugeValue, float64(report.Unacked), genID)
+	}
+}
+
+// Registry returns the Prometheus registry served on /metrics, so the sink can
+// register its own metrics
+func (s *Server) Registry() prometheus.Registerer {
+	return s.registry
+}
+
+// ServeMetricsOn additionally serves /metrics on a dedicated address, it must be
+// called before Start
+func (s *Server) ServeMetricsOn(addr string) {
+	s.metricsAddr = addr
+}
+
+func (s *Server) metricsHandler() http.Handler {
+	return promhttp.HandlerFor(s.registry, promhttp.HandlerOpts{})
+}
+
+func (s *Server) startMetricsServer() error {
+	lis, err := net.Listen("tcp", s.metricsAddr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", s.metricsHandler())
+	s.metricsSrv = &http.Server{Handler: mux}
+
+	s.log.Info("Starting metrics server", zap.String("addr", s.metricsAddr))
+	go func() {
+		if err := s.metricsSrv.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.log.Error("metrics server error", zap.Error(err))
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package control
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/msg_tracker"
+	"go.uber.org/zap"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	mt := msg_tracker.NewTracker(zap.NewNop())
+	mt.AddRange("gen-a", 1, 10, time.Now().Add(-time.Minute))
+	for id := uint64(1); id <= 4; id++ {
+		mt.Ack("gen-a", 1, 10, id)
+	}
+	mt.Ack("gen-a", 1, 10, 1)
+
+	s := New("localhost:0", mt, time.Second, zap.NewNop())
+
+	rec := httptest.NewRecorder()
+	s.srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+
+	body, _ := io.ReadAll(rec.Body)
+	for _, line := range []string{
+		"# TYPE loadgen_messages_acked_total counter",
+		`loadgen_messages_acked_total{generator_id="gen-a"} 4`,
+		"# TYPE loadgen_messages_duplicated_total counter",
+		`loadgen_messages_duplicated_total{generator_id="gen-a"} 1`,
+		"# TYPE loadgen_messages_unacked gauge",
+		`loadgen_messages_unacked{generator_id="gen-a"} 6`,
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Errorf("Expected %q in:\n%s", line, body)
+		}
+	}
+}
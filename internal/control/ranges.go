@@ -0,0 +1,42 @@
+package control
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// handleRanges dumps the message ranges of a generator as JSON, with the
+// lowest and highest acked ID of each so partial delivery can be located. The
+// generator_id query param is required.
+func (s *Server) handleRanges(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	genID := r.URL.Query().Get("generator_id")
+	if genID == "" {
+		http.Error(w, "Missing generator_id", http.StatusBadRequest)
+		return
+	}
+
+	ranges := make([]RangeDump, 0)
+	for _, info := range s.mt.Ranges(genID) {
+		dump := RangeDump{
+			StartID:    info.StartID,
+			RangeLen:   info.RangeLen,
+			Acked:      info.AckedCount,
+			Duplicates: info.DuplicateCount,
+		}
+		if info.AnyAcked {
+			minID, maxID := info.MinAckedID, info.MaxAckedID
+			dump.MinAckedID = &minID
+			dump.MaxAckedID = &maxID
+		}
+		ranges = append(ranges, dump)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(RangesReport{GeneratorID: genID, Ranges: ranges})
+}
@@ -0,0 +1,74 @@
+package control
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/msg_tracker"
+	"go.uber.org/zap"
+)
+
+func TestHandleRanges(t *testing.T) {
+	mt := msg_tracker.NewTracker(zap.NewNop())
+	mt.AddRange("gen-a", 1, 10, time.Now())
+	mt.AddRange("gen-a", 11, 10, time.Now())
+	for _, id := range []uint64{3, 5, 8} {
+		mt.Ack("gen-a", 1, 10, id)
+	}
+
+	s := New("localhost:0", mt, time.Second, zap.NewNop())
+
+	rec := httptest.NewRecorder()
+	s.handleRanges(rec, httptest.NewRequest(http.MethodGet, "/api/ranges?generator_id=gen-a", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+
+	var report RangesReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Ranges) != 2 {
+		t.Fatalf("Expected 2 ranges, got %+v", report.Ranges)
+	}
+
+	first := report.Ranges[0]
+	if first.StartID != 1 || first.Acked != 3 || first.MinAckedID == nil || *first.MinAckedID != 3 || *first.MaxAckedID != 8 {
+		t.Errorf("Unexpected first range: %+v", first)
+	}
+	if second := report.Ranges[1]; second.StartID != 11 || second.MinAckedID != nil || second.MaxAckedID != nil {
+		t.Errorf("Expected no acked bounds for the second range, got %+v", second)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleRanges(rec, httptest.NewRequest(http.MethodGet, "/api/ranges", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without generator_id, got %d", rec.Code)
+	}
+}
+
+func TestHandleMessageRange_Overlap(t *testing.T) {
+	mt := msg_tracker.NewTracker(zap.NewNop())
+	s := New("localhost:0", mt, time.Second, zap.NewNop())
+
+	post := func(start uint64, rangeLen uint) int {
+		body, _ := json.Marshal(ControlMessage{GeneratorID: "gen-a", StartID: start, RangeLen: rangeLen, Timestamp: time.Now()})
+		rec := httptest.NewRecorder()
+		s.handleMessageRange(rec, httptest.NewRequest(http.MethodPost, "/api/message_range", bytes.NewReader(body)))
+		return rec.Code
+	}
+
+	if code := post(0, 10); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if code := post(10, 10); code != http.StatusOK {
+		t.Errorf("Expected 200 for an adjacent range, got %d", code)
+	}
+	if code := post(5, 10); code != http.StatusConflict {
+		t.Errorf("Expected 409 for an overlapping range, got %d", code)
+	}
+}
@@ -0,0 +1,63 @@
+package control
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/msg_tracker"
+)
+
+// handleReport returns the per-generator tracker report as JSON. The optional
+// older_than query param sets how old a range must be before its messages are
+// counted as unacked, it defaults to the report interval.
+func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	olderThan := s.reportInterval
+	if param := r.URL.Query().Get("older_than"); param != "" {
+		d, err := time.ParseDuration(param)
+		if err != nil || d < 0 {
+			http.Error(w, fmt.Sprintf("Invalid older_than duration: %q", param), http.StatusBadRequest)
+			return
+		}
+		olderThan = d
+	}
+
+	now := time.Now()
+	reports := s.mt.GeneratorReport(now.Add(-1 * olderThan))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(newReport(reports, olderThan, now))
+}
+
+func newReport(reports map[string]msg_tracker.GeneratorReport, olderThan time.Duration, now time.Time) Report {
+	out := Report{
+		Timestamp:  now,
+		OlderThan:  olderThan.String(),
+		Generators: make(map[string]GeneratorReport, len(reports)),
+	}
+
+	for genID, report := range reports {
+		gr := GeneratorReport{
+			TotalAcked: report.TotalAcked,
+			TotalDuped: report.TotalDuped,
+			Unacked:    report.Unacked,
+			LikelyLost: report.LikelyLost,
+
+			DuplicateErrors: report.DuplicateErrors,
+		}
+		if report.Unacked > 0 && !report.OldestUnackedAge.IsZero() {
+			age := now.Sub(report.OldestUnackedAge).Seconds()
+			gr.OldestUnackedAgeSeconds = &age
+		}
+		out.Generators[genID] = gr
+	}
+
+	return out
+}
@@ -0,0 +1,92 @@
+package control
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/msg_tracker"
+	"go.uber.org/zap"
+)
+
+func TestHandleReport(t *testing.T) {
+	mt := msg_tracker.NewTracker(zap.NewNop())
+
+	mt.AddRange("gen-a", 1, 10, time.Now().Add(-time.Minute))
+	for id := uint64(1); id <= 4; id++ {
+		mt.Ack("gen-a", 1, 10, id)
+	}
+	mt.Ack("gen-a", 1, 10, 1)
+
+	mt.AddRange("gen-b", 1, 5, time.Now())
+	mt.Ack("gen-b", 1, 5, 1)
+
+	s := New("localhost:0", mt, time.Second, zap.NewNop())
+
+	get := func(target string) (int, Report) {
+		rec := httptest.NewRecorder()
+		s.handleReport(rec, httptest.NewRequest(http.MethodGet, target, nil))
+
+		var report Report
+		if rec.Code == http.StatusOK {
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Unexpected content type: %s", ct)
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec.Code, report
+	}
+
+	code, report := get("/api/report")
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", code)
+	}
+	if report.OlderThan != "1s" {
+		t.Errorf("Expected older_than to default to the report interval, got %s", report.OlderThan)
+	}
+
+	genA := report.Generators["gen-a"]
+	if genA.TotalAcked != 4 || genA.TotalDuped != 1 || genA.Unacked != 6 {
+		t.Errorf("Unexpected gen-a report: %+v", genA)
+	}
+	if genA.OldestUnackedAgeSeconds == nil || *genA.OldestUnackedAgeSeconds < 59 {
+		t.Errorf("Expected gen-a oldest unacked age of about a minute, got %v", genA.OldestUnackedAgeSeconds)
+	}
+
+	// gen-b's range is too recent to count as unacked
+	genB := report.Generators["gen-b"]
+	if genB.TotalAcked != 1 || genB.Unacked != 0 || genB.OldestUnackedAgeSeconds != nil {
+		t.Errorf("Unexpected gen-b report: %+v", genB)
+	}
+
+	// A zero older_than counts every range
+	_, report = get("/api/report?older_than=0s")
+	if report.Generators["gen-b"].Unacked != 4 {
+		t.Errorf("Expected gen-b to have 4 unacked, got %d", report.Generators["gen-b"].Unacked)
+	}
+
+	// A range younger than older_than isn't counted
+	_, report = get("/api/report?older_than=2m")
+	if report.Generators["gen-a"].Unacked != 0 {
+		t.Errorf("Expected gen-a to have 0 unacked, got %d", report.Generators["gen-a"].Unacked)
+	}
+
+	if code, _ := get("/api/report?older_than=soon"); code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid duration, got %d", code)
+	}
+}
+
+func TestHandleReport_MethodNotAllowed(t *testing.T) {
+	s := New("localhost:0", msg_tracker.NewTracker(zap.NewNop()), time.Second, zap.NewNop())
+
+	rec := httptest.NewRecorder()
+	s.handleReport(rec, httptest.NewRequest(http.MethodPost, "/api/report", nil))
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", rec.Code)
+	}
+}
@@ -3,14 +3,17 @@ package control
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/streamfold/otel-loadgen/internal/msg_tracker"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 )
 
 type Server struct {
@@ -21,18 +24,38 @@ type Server struct {
 	reportInterval time.Duration
 	reportStop     chan bool
 	reportWg       *sync.WaitGroup
+	registry       *prometheus.Registry
+	metricsAddr    string
+	metricsSrv     *http.Server
+	grpcAddr       string
+	grpcSrv        *grpc.Server
+	reapAfter      time.Duration
 }
 
+// defaultReapAfter is how long fully acked ranges keep their bitmaps before
+// they are reaped into runs of acked IDs
+const defaultReapAfter = time.Minute
+
 func New(addr string, mt *msg_tracker.Tracker, reportInterval time.Duration, log *zap.Logger) *Server {
 	s := &Server{
 		addr:           addr,
 		log:            log,
 		mt:             mt,
 		reportInterval: reportInterval,
+		registry:       prometheus.NewRegistry(),
+		reapAfter:      defaultReapAfter,
 	}
+	s.registry.MustRegister(newTrackerCollector(mt, reportInterval))
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/api/message_range", s.handleMessageRange)
+	mux.HandleFunc("/api/message_ranges", s.handleMessageRanges)
+	mux.HandleFunc("/api/metrics.txt", s.handleOpenMetrics)
+	mux.HandleFunc("/api/report", s.handleReport)
+	mux.HandleFunc("/api/unacked", s.handleUnacked)
+	mux.HandleFunc("/api/ranges", s.handleRanges)
+	mux.HandleFunc("/api/health", s.handleHealth)
+	mux.Handle("/metrics", s.metricsHandler())
 
 	s.srv = &http.Server{
 		Addr:    addr,
@@ -45,8 +68,35 @@ func New(addr string, mt *msg_tracker.Tracker, reportInterval time.Duration, log
 func (s *Server) Start() error {
 	s.log.Debug("Starting control server", zap.String("addr", s.addr))
 
+	if s.metricsAddr != "" {
+		if err := s.startMetricsServer(); err != nil {
+			return err
+		}
+	}
+
+	if s.grpcAddr != "" {
+		if err := s.startGRPCServer(); err != nil {
+			if s.metricsSrv != nil {
+				_ = s.metricsSrv.Close()
+			}
+			return err
+		}
+	}
+
+	lis, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		if s.metricsSrv != nil {
+			_ = s.metricsSrv.Close()
+		}
+		if s.grpcSrv != nil {
+			s.grpcSrv.Stop()
+		}
+		return err
+	}
+	s.addr = lis.Addr().String()
+
 	go func() {
-		if err := s.srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := s.srv.Serve(lis); err != nil && err != http.ErrServerClosed {
 			s.log.Error("control server error", zap.Error(err))
 		}
 	}()
@@ -73,7 +123,25 @@ func (s *Server) Start() error {
 	return nil
 }
 
+// ReapAfter sets the age after which fully acked ranges are reaped on each
+// report, 0 disables reaping. It must be called before Start.
+func (s *Server) ReapAfter(age time.Duration) {
+	s.reapAfter = age
+}
+
+// Report prints the delivery report of every generator, e.g. a final
+// reconciliation on shutdown
+func (s *Server) Report() {
+	s.report()
+}
+
 func (s *Server) report() {
+	s.mt.CheckAckTimeouts()
+	s.mt.Compact()
+	if s.reapAfter > 0 {
+		s.mt.Reap(time.Now().Add(-s.reapAfter))
+	}
+
 	reports := s.mt.GeneratorReport(time.Now().Add(-1 * s.reportInterval))
 	if len(reports) == 0 {
 		fmt.Printf("REPORT: No load generators running\n")
@@ -106,20 +174,45 @@ func (s *Server) reportGenerator(genID string, report msg_tracker.GeneratorRepor
 	if report.Unacked > 0 {
 		sb.WriteString(fmt.Sprintf(",\tUnacked: %d, Age: %s", report.Unacked, time.Since(report.OldestUnackedAge).String()))
 	}
+
+	if report.LikelyLost > 0 {
+		sb.WriteString(fmt.Sprintf(",\tLikely Lost: %d", report.LikelyLost))
+	}
+
+	if report.DuplicateErrors > 0 {
+		sb.WriteString(fmt.Sprintf(",\tDuplicate Errors: %d", report.DuplicateErrors))
+	}
 }
 
 func (s *Server) Stop() error {
 	s.log.Debug("Stopping control server")
 	err := s.srv.Close()
+	if s.metricsSrv != nil {
+		_ = s.metricsSrv.Close()
+	}
+	if s.grpcSrv != nil {
+		s.grpcSrv.Stop()
+	}
 	close(s.reportStop)
 	s.reportWg.Wait()
 	return err
 }
 
+// Addr returns the address listened on once started
 func (s *Server) Addr() string {
 	return s.addr
 }
 
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
 func (s *Server) handleMessageRange(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost && r.Method != http.MethodPut {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -151,7 +244,10 @@ func (s *Server) handleMessageRange(w http.ResponseWriter, r *http.Request) {
 	)
 	switch r.Method {
 	case http.MethodPost:
-		s.mt.AddRange(pub.GeneratorID, pub.StartID, pub.RangeLen, pub.Timestamp)
+		if err := s.mt.AddRange(pub.GeneratorID, pub.StartID, pub.RangeLen, pub.Timestamp); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 	case http.MethodPut:
 		// Currently only the range len is updated
 		s.mt.UpdateRange(pub.GeneratorID, pub.StartID, pub.RangeLen)
@@ -17,12 +17,33 @@ type ControlMessage struct {
 	RangeLen uint `json:"range_len"`
 }
 
+// BulkControlMessage is an element of the batch posted to /api/message_ranges
+type BulkControlMessage struct {
+	ControlMessage
+
+	// Update shortens an existing range rather than adding a new one, like a
+	// PUT to /api/message_range
+	Update bool `json:"update,omitempty"`
+}
+
 type ControlType int
 const (
 	ControlTypeNew ControlType = iota
 	ControlTypeUpdate
 )
 
+// Sender queues controls for the control server
+type Sender interface {
+	Send(ctrl Control)
+}
+
+// ChanSender sends controls on a channel, waiting while it's full
+type ChanSender chan<- Control
+
+func (s ChanSender) Send(ctrl Control) {
+	s <- ctrl
+}
+
 // Control represents a new or updated range
 type Control struct {
 	Type ControlType
@@ -36,3 +57,56 @@ type MessageRange struct {
 	RangeLen uint
 	Timestamp   time.Time
 }
+
+// Report is the JSON body returned by GET /api/report
+type Report struct {
+	// Timestamp is when the report was generated
+	Timestamp time.Time `json:"timestamp"`
+
+	// OlderThan is the age a range must reach before its messages count as unacked
+	OlderThan string `json:"older_than"`
+
+	// Generators holds the delivery report of each tracked generator
+	Generators map[string]GeneratorReport `json:"generators"`
+}
+
+// GeneratorReport is the delivery report of a single generator
+type GeneratorReport struct {
+	TotalAcked uint `json:"total_acked"`
+	TotalDuped uint `json:"total_duped"`
+	Unacked    uint `json:"unacked"`
+	LikelyLost uint `json:"likely_lost"`
+
+	// DuplicateErrors counts duplicates received in exactly-once mode
+	DuplicateErrors uint `json:"duplicate_errors"`
+
+	// OldestUnackedAgeSeconds is omitted when there are no unacked messages
+	OldestUnackedAgeSeconds *float64 `json:"oldest_unacked_age_seconds,omitempty"`
+}
+
+// UnackedReport is the JSON body returned by GET /api/unacked
+type UnackedReport struct {
+	GeneratorID string `json:"generator_id"`
+	Limit       int    `json:"limit"`
+
+	// IDs are the unacked message IDs in ascending order, at most Limit
+	IDs []uint64 `json:"ids"`
+}
+
+// RangesReport is the JSON body returned by GET /api/ranges
+type RangesReport struct {
+	GeneratorID string      `json:"generator_id"`
+	Ranges      []RangeDump `json:"ranges"`
+}
+
+// RangeDump is the ack state of a single message range
+type RangeDump struct {
+	StartID    uint64 `json:"start_id"`
+	RangeLen   uint   `json:"range_len"`
+	Acked      uint   `json:"acked"`
+	Duplicates uint   `json:"duplicates"`
+
+	// MinAckedID and MaxAckedID are omitted when nothing in the range is acked
+	MinAckedID *uint64 `json:"min_acked_id,omitempty"`
+	MaxAckedID *uint64 `json:"max_acked_id,omitempty"`
+}
@@ -0,0 +1,47 @@
+package control
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultUnackedLimit = 100
+	maxUnackedLimit     = 100000
+)
+
+// handleUnacked returns the unacked message IDs of a generator as JSON. The
+// generator_id query param is required, limit caps the number of IDs.
+func (s *Server) handleUnacked(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	genID := r.URL.Query().Get("generator_id")
+	if genID == "" {
+		http.Error(w, "Missing generator_id", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultUnackedLimit
+	if param := r.URL.Query().Get("limit"); param != "" {
+		n, err := strconv.Atoi(param)
+		if err != nil || n <= 0 || n > maxUnackedLimit {
+			http.Error(w, fmt.Sprintf("Invalid limit: %q (expected 1 to %d)", param, maxUnackedLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	ids := s.mt.UnackedIDs(genID, limit)
+	if ids == nil {
+		ids = []uint64{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(UnackedReport{GeneratorID: genID, Limit: limit, IDs: ids})
+}
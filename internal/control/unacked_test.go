@@ -0,0 +1,59 @@
+package control
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/msg_tracker"
+	"go.uber.org/zap"
+)
+
+func TestHandleUnacked(t *testing.T) {
+	mt := msg_tracker.NewTracker(zap.NewNop())
+	mt.AddRange("gen-a", 1, 10, time.Now())
+	for id := uint64(1); id <= 10; id++ {
+		if id != 3 && id != 7 && id != 8 {
+			mt.Ack("gen-a", 1, 10, id)
+		}
+	}
+
+	s := New("localhost:0", mt, time.Second, zap.NewNop())
+
+	get := func(target string) (int, UnackedReport) {
+		rec := httptest.NewRecorder()
+		s.handleUnacked(rec, httptest.NewRequest(http.MethodGet, target, nil))
+
+		var report UnackedReport
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec.Code, report
+	}
+
+	code, report := get("/api/unacked?generator_id=gen-a")
+	if code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if report.Limit != defaultUnackedLimit || !reflect.DeepEqual(report.IDs, []uint64{3, 7, 8}) {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+
+	if _, report := get("/api/unacked?generator_id=gen-a&limit=2"); !reflect.DeepEqual(report.IDs, []uint64{3, 7}) {
+		t.Errorf("Expected the limit to cap the IDs, got %v", report.IDs)
+	}
+	if _, report := get("/api/unacked?generator_id=gen-b"); report.IDs == nil || len(report.IDs) != 0 {
+		t.Errorf("Expected an empty list for an unknown generator, got %v", report.IDs)
+	}
+
+	for _, target := range []string{"/api/unacked", "/api/unacked?generator_id=gen-a&limit=0", "/api/unacked?generator_id=gen-a&limit=x"} {
+		if code, _ := get(target); code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", target, code)
+		}
+	}
+}
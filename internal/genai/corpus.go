@@ -1,15 +1,17 @@
 package genai
 
 import (
-	"compress/gzip"
 	"encoding/json"
 	"fmt"
-	"math/rand"
-	"os"
+	"regexp"
 	"strings"
 	"sync/atomic"
 
+	"github.com/streamfold/otel-loadgen/internal/otlp/anyvalue"
+	"github.com/streamfold/otel-loadgen/internal/util"
 	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+	otlpLogs "go.opentelemetry.io/proto/otlp/logs/v1"
+	otlpTraces "go.opentelemetry.io/proto/otlp/trace/v1"
 )
 
 // Conversation represents a single conversation turn from the corpus
@@ -23,6 +25,9 @@ type Entry struct {
 	Conversations []Conversation `json:"conversations"`
 	Tools         string         `json:"tools"`
 	System        string         `json:"system"`
+	// Refusal flags the final reply as a refusal, replies are also detected
+	// with the refusal pattern of the options
+	Refusal bool `json:"refusal,omitempty"`
 }
 
 // MessagePart represents a part of a GenAI message
@@ -37,8 +42,9 @@ type MessagePart struct {
 
 // Message represents a GenAI message in OTel format
 type Message struct {
-	Role  string        `json:"role"`
-	Parts []MessagePart `json:"parts"`
+	Role         string        `json:"role"`
+	Parts        []MessagePart `json:"parts"`
+	FinishReason string        `json:"finish_reason,omitempty"`
 }
 
 // ToolDefinition represents a tool definition in OTel format
@@ -60,25 +66,67 @@ type CorpusToolDefinition struct {
 type Corpus struct {
 	entries []Entry
 	idx     atomic.Uint64
+	opts    *GenAIOptions
+	mode    SamplingMode
+	rng     *lockedRand
 }
 
-// Provider names for simulated gen_ai spans
-var providerNames = []string{
-	"openai",
-	"anthropic",
-	"google",
-	"azure",
-	"bedrock",
+// SamplingMode controls the order corpus entries are used in
+type SamplingMode int
+
+const (
+	// SamplingRoundRobin cycles through the entries in order
+	SamplingRoundRobin SamplingMode = iota
+	// SamplingRandom picks entries uniformly at random
+	SamplingRandom
+)
+
+func (m SamplingMode) String() string {
+	switch m {
+	case SamplingRoundRobin:
+		return "round-robin"
+	case SamplingRandom:
+		return "random"
+	default:
+		return "unknown"
+	}
+}
+
+func ParseSamplingMode(s string) (SamplingMode, error) {
+	switch s {
+	case "round-robin":
+		return SamplingRoundRobin, nil
+	case "random":
+		return SamplingRandom, nil
+	default:
+		return 0, fmt.Errorf("invalid corpus mode: %q (expected round-robin or random)", s)
+	}
+}
+
+// GenAIOptions controls how gen_ai attributes are generated
+type GenAIOptions struct {
+	operations *util.WeightedChoice[string]
+	models     *ModelWeights
+	toolEmit   ToolEmit
+	refusal    *regexp.Regexp
+	tokenizer  Tokenizer
+}
+
+// Embedding model names and their output vector dimensions
+var embeddingModels = []struct {
+	name       string
+	dimensions int64
+}{
+	{"text-embedding-3-small", 1536},
+	{"text-embedding-3-large", 3072},
+	{"text-embedding-004", 768},
+	{"amazon.titan-embed-text-v2", 1024},
 }
 
-// Model names for simulated gen_ai spans
-var modelNames = []string{
-	"gpt-4o",
-	"gpt-4-turbo",
-	"claude-3-5-sonnet",
-	"claude-3-opus",
-	"gemini-1.5-pro",
-	"gemini-1.5-flash",
+// Encoding formats requested for embeddings
+var encodingFormats = []string{
+	"float",
+	"base64",
 }
 
 // Operation names for gen_ai spans
@@ -88,38 +136,116 @@ var operationNames = []string{
 	"embedding",
 }
 
-// LoadCorpus loads the APIGen corpus from the specified JSON file.
-// If the file has a .gz extension, it will be decompressed automatically.
-func LoadCorpus(path string) (*Corpus, error) {
-	file, err := os.Open(path)
+// Default relative weights of operationNames, real workloads are chat dominated
+var defaultOperationWeights = []float64{
+	8,
+	1,
+	1,
+}
+
+var defaultOptions = mustDefaultOptions()
+
+// NewGenAIOptions creates options that select operation names according to the given weights
+func NewGenAIOptions(operations []string, weights []float64) (*GenAIOptions, error) {
+	for _, op := range operations {
+		if !isKnownOperation(op) {
+			return nil, fmt.Errorf("unknown gen_ai operation: %q (expected one of: %s)", op, strings.Join(operationNames, ", "))
+		}
+	}
+
+	wc, err := util.NewWeightedChoice(operations, weights)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open corpus file: %w", err)
+		return nil, fmt.Errorf("invalid gen_ai operation weights: %w", err)
 	}
-	defer file.Close()
 
-	var decoder *json.Decoder
+	return &GenAIOptions{operations: wc, models: defaultModelWeights}, nil
+}
+
+// DefaultGenAIOptions returns the default, chat dominated, options
+func DefaultGenAIOptions() *GenAIOptions {
+	return defaultOptions
+}
 
-	if strings.HasSuffix(path, ".gz") {
-		gzReader, err := gzip.NewReader(file)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+func mustDefaultOptions() *GenAIOptions {
+	opts, err := NewGenAIOptions(operationNames, defaultOperationWeights)
+	if err != nil {
+		panic(err)
+	}
+	return opts
+}
+
+func isKnownOperation(op string) bool {
+	for _, name := range operationNames {
+		if name == op {
+			return true
 		}
-		defer gzReader.Close()
-		decoder = json.NewDecoder(gzReader)
-	} else {
-		decoder = json.NewDecoder(file)
 	}
+	return false
+}
+
+// SetToolEmit sets how tool calls are represented on spans, defaults to attributes
+func (o *GenAIOptions) SetToolEmit(toolEmit ToolEmit) {
+	o.toolEmit = toolEmit
+}
+
+// SetModelWeights sets the distribution of provider and model pairs, defaults
+// to equally likely providers
+func (o *GenAIOptions) SetModelWeights(models *ModelWeights) {
+	o.models = models
+}
+
+func (o *GenAIOptions) pickOperation(rng *lockedRand) string {
+	return o.operations.Pick(rng.Float64())
+}
+
+// LoadCorpus loads the APIGen corpus from the specified JSON or JSONL file,
+// picking the format from the extension. If the file has a .gz extension, it
+// will be decompressed automatically.
+func LoadCorpus(path string) (*Corpus, error) {
+	return LoadCorpusFormat(path, CorpusFormatAuto)
+}
+
+// LoadCorpusFormat loads a corpus in the given format from the specified file.
+// If the file has a .gz extension, it will be decompressed automatically.
+func LoadCorpusFormat(path string, format CorpusFormat) (*Corpus, error) {
+	r, err := openCorpus(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
 
 	var entries []Entry
-	if err := decoder.Decode(&entries); err != nil {
-		return nil, fmt.Errorf("failed to parse corpus JSON: %w", err)
+	err = decodeEntries(r, path, format, func(entry Entry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &Corpus{
 		entries: entries,
+		opts:    defaultOptions,
+		rng:     newLockedRand(0),
 	}, nil
 }
 
+// SetSampling sets the order entries are used in by SampleEntry. A non-zero
+// seed makes random sampling and the generated attributes reproducible. Must
+// be called before the corpus is used.
+func (c *Corpus) SetSampling(mode SamplingMode, seed int64) {
+	c.mode = mode
+	if seed != 0 {
+		c.rng = newLockedRand(seed)
+	}
+}
+
+// SetOptions sets the options used when generating attributes, must be
+// called before the corpus is used
+func (c *Corpus) SetOptions(opts *GenAIOptions) {
+	c.opts = opts
+}
+
 // Size returns the number of entries in the corpus
 func (c *Corpus) Size() int {
 	return len(c.entries)
@@ -136,56 +262,100 @@ func (c *Corpus) NextEntry() *Entry {
 	return c.GetEntry(int(idx))
 }
 
+// RandomEntry returns an entry picked uniformly at random
+func (c *Corpus) RandomEntry() *Entry {
+	return &c.entries[c.rng.Intn(len(c.entries))]
+}
+
+// SampleEntry returns the next entry of the sampling mode
+func (c *Corpus) SampleEntry() *Entry {
+	if c.mode == SamplingRandom {
+		return c.RandomEntry()
+	}
+	return c.NextEntry()
+}
+
 // GenAIAttributes generates gen_ai span attributes from a corpus entry
 func (c *Corpus) GenAIAttributes() []*otlpCommon.KeyValue {
-	entry := c.NextEntry()
-	return GenAIAttributesFromEntry(entry)
+	attrs, _ := c.GenAISpan()
+	return attrs
+}
+
+// GenAISpan generates gen_ai span attributes and, when tool calls are emitted as
+// events, the tool call span events from a corpus entry
+func (c *Corpus) GenAISpan() ([]*otlpCommon.KeyValue, []*otlpTraces.Span_Event) {
+	entry := c.SampleEntry()
+	return genAISpan(entry, c.opts, c.rng)
+}
+
+// LogRecords converts the next entry to a stream of GenAI log events
+func (c *Corpus) LogRecords() []*otlpLogs.LogRecord {
+	return entryToLogRecords(c.SampleEntry(), c.rng)
 }
 
 // GenAIAttributesFromEntry generates gen_ai span attributes from a specific entry
 func GenAIAttributesFromEntry(entry *Entry) []*otlpCommon.KeyValue {
+	return GenAIAttributesFromEntryWithOptions(entry, defaultOptions)
+}
+
+// GenAIAttributesFromEntryWithOptions generates gen_ai span attributes from a specific
+// entry, using opts to select the operation
+func GenAIAttributesFromEntryWithOptions(entry *Entry, opts *GenAIOptions) []*otlpCommon.KeyValue {
+	attrs, _ := GenAISpanFromEntry(entry, opts)
+	return attrs
+}
+
+// GenAISpanFromEntry generates gen_ai span attributes from a specific entry, using
+// opts to select the operation. With ToolEmitEvents the tool calls are returned as
+// span events rather than included in the input messages.
+func GenAISpanFromEntry(entry *Entry, opts *GenAIOptions) ([]*otlpCommon.KeyValue, []*otlpTraces.Span_Event) {
+	return genAISpan(entry, opts, defaultRand)
+}
+
+// genAISpan generates the attributes and events of GenAISpanFromEntry, drawing
+// random values from rng
+func genAISpan(entry *Entry, opts *GenAIOptions, rng *lockedRand) ([]*otlpCommon.KeyValue, []*otlpTraces.Span_Event) {
 	attrs := make([]*otlpCommon.KeyValue, 0, 15)
 
 	// Generate conversation ID
-	conversationID := fmt.Sprintf("conv-%d", rand.Int63())
+	conversationID := fmt.Sprintf("conv-%d", rng.Int63())
 	attrs = append(attrs, stringAttr("gen_ai.conversation.id", conversationID))
 
 	// Operation name
-	opName := operationNames[rand.Intn(len(operationNames))]
+	opName := opts.pickOperation(rng)
 	attrs = append(attrs, stringAttr("gen_ai.operation.name", opName))
 
-	// Provider name
-	providerName := providerNames[rand.Intn(len(providerNames))]
+	// Provider and model, the model is only used for chat and completion
+	providerName, modelName := opts.models.pick(rng)
 	attrs = append(attrs, stringAttr("gen_ai.provider.name", providerName))
 
-	// Model names
-	modelName := modelNames[rand.Intn(len(modelNames))]
+	if opName == "embedding" {
+		return append(attrs, embeddingAttributes(entry, opts.tokenizer, rng)...), nil
+	}
+
 	attrs = append(attrs, stringAttr("gen_ai.request.model", modelName))
 	attrs = append(attrs, stringAttr("gen_ai.response.model", modelName))
 
 	// Convert conversations to OTel format
-	inputMessages, outputMessages := convertConversationsToOTelFormat(entry.Conversations)
-
-	// Calculate token counts based on total message content length
-	inputLen := 0
-	outputLen := 0
-	for _, msg := range inputMessages {
-		for _, part := range msg.Parts {
-			inputLen += len(part.Content) + len(part.Result) + len(part.Arguments)
-		}
+	inputMessages, outputMessages := convertConversationsToOTelFormat(entry.Conversations, rng)
+
+	refused := opts.isRefusal(entry) && len(outputMessages) > 0
+	if refused {
+		outputMessages[len(outputMessages)-1].FinishReason = FinishReasonContentFilter
 	}
-	for _, msg := range outputMessages {
-		for _, part := range msg.Parts {
-			outputLen += len(part.Content) + len(part.Result) + len(part.Arguments)
-		}
+
+	var events []*otlpTraces.Span_Event
+	if opts.toolEmit == ToolEmitEvents {
+		events = ToolCallEvents(toolCalls(inputMessages))
+		inputMessages = withoutToolMessages(inputMessages)
 	}
 
-	// Simulate token counts (rough approximation: ~4 chars per token)
-	inputTokens := inputLen / 4
+	// Count tokens of the message content
+	inputTokens := opts.tokenizer.countMessages(inputMessages)
 	if inputTokens < 10 {
 		inputTokens = 10
 	}
-	outputTokens := outputLen / 4
+	outputTokens := opts.tokenizer.countMessages(outputMessages)
 	if outputTokens < 10 {
 		outputTokens = 10
 	}
@@ -194,41 +364,77 @@ func GenAIAttributesFromEntry(entry *Entry) []*otlpCommon.KeyValue {
 	attrs = append(attrs, intAttr("gen_ai.usage.output_tokens", int64(outputTokens)))
 
 	// Temperature (0.0 - 1.0)
-	temperature := rand.Float64()
+	temperature := rng.Float64()
 	attrs = append(attrs, floatAttr("gen_ai.request.temperature", temperature))
 
 	// Max tokens (256 - 4096)
-	maxTokens := 256 + rand.Intn(3840)
+	maxTokens := 256 + rng.Intn(3840)
 	attrs = append(attrs, intAttr("gen_ai.request.max_tokens", int64(maxTokens)))
 
 	// Response ID
-	responseID := fmt.Sprintf("resp-%d", rand.Int63())
+	responseID := fmt.Sprintf("resp-%d", rng.Int63())
 	attrs = append(attrs, stringAttr("gen_ai.response.id", responseID))
 
+	if refused {
+		attrs = append(attrs, anyvalue.KV("gen_ai.response.finish_reasons", anyvalue.Array(anyvalue.String(FinishReasonContentFilter))))
+	}
+
 	// Input messages as native OTel array of KeyValueList
 	if len(inputMessages) > 0 {
-		attrs = append(attrs, otelKV("gen_ai.input.messages", MessagesToOTel(inputMessages)))
+		attrs = append(attrs, anyvalue.KV("gen_ai.input.messages", MessagesToOTel(inputMessages)))
 	}
 
 	// Output messages as native OTel array of KeyValueList
 	if len(outputMessages) > 0 {
-		attrs = append(attrs, otelKV("gen_ai.output.messages", MessagesToOTel(outputMessages)))
+		attrs = append(attrs, anyvalue.KV("gen_ai.output.messages", MessagesToOTel(outputMessages)))
 	}
 
 	// System instructions as native OTel array of TextParts
 	if entry.System != "" {
 		systemPart := MessagePart{Type: "text", Content: entry.System}
-		attrs = append(attrs, otelKV("gen_ai.system_instructions", otelArray(systemPart.ToOTel())))
+		attrs = append(attrs, anyvalue.KV("gen_ai.system_instructions", anyvalue.Array(systemPart.ToOTel())))
 	}
 
 	// Tool definitions as native OTel array of KeyValueList
 	if entry.Tools != "" {
 		toolDefs := parseToolDefinitions(entry.Tools)
 		if len(toolDefs) > 0 {
-			attrs = append(attrs, otelKV("gen_ai.tool.definitions", ToolDefinitionsToOTel(toolDefs)))
+			attrs = append(attrs, anyvalue.KV("gen_ai.tool.definitions", ToolDefinitionsToOTel(toolDefs)))
+		}
+	}
+
+	return attrs, events
+}
+
+// embeddingAttributes generates the attributes of an embeddings operation. Embeddings
+// produce a vector rather than a conversation, so there are no chat messages, output
+// usage or sampling parameters.
+func embeddingAttributes(entry *Entry, tokenizer Tokenizer, rng *lockedRand) []*otlpCommon.KeyValue {
+	attrs := make([]*otlpCommon.KeyValue, 0, 6)
+
+	model := embeddingModels[rng.Intn(len(embeddingModels))]
+	attrs = append(attrs, stringAttr("gen_ai.request.model", model.name))
+	attrs = append(attrs, stringAttr("gen_ai.response.model", model.name))
+
+	// A single document is embedded, the first user turn
+	var document string
+	for _, conv := range entry.Conversations {
+		if conv.From == "human" {
+			document = conv.Value
+			break
 		}
 	}
 
+	inputTokens := tokenizer.count(document)
+	if inputTokens < 1 {
+		inputTokens = 1
+	}
+	attrs = append(attrs, intAttr("gen_ai.usage.input_tokens", int64(inputTokens)))
+
+	format := encodingFormats[rng.Intn(len(encodingFormats))]
+	attrs = append(attrs, anyvalue.KV("gen_ai.request.encoding_formats", anyvalue.Array(anyvalue.String(format))))
+	attrs = append(attrs, intAttr("gen_ai.embeddings.dimension.count", model.dimensions))
+
 	return attrs
 }
 
@@ -252,8 +458,9 @@ func parseToolDefinitions(toolsJSON string) []ToolDefinition {
 }
 
 // convertConversationsToOTelFormat converts corpus conversations to proper GenAI message format
-func convertConversationsToOTelFormat(conversations []Conversation) (inputMessages []Message, outputMessages []Message) {
+func convertConversationsToOTelFormat(conversations []Conversation, rng *lockedRand) (inputMessages []Message, outputMessages []Message) {
 	lastToolCallID := ""
+	lastToolName := ""
 
 	for i, conv := range conversations {
 		switch conv.From {
@@ -286,6 +493,7 @@ func convertConversationsToOTelFormat(conversations []Conversation) (inputMessag
 
 			// Check if this is the last message in the conversation
 			if i == len(conversations)-1 {
+				msg.FinishReason = "stop"
 				outputMessages = append(outputMessages, msg)
 			} else {
 				inputMessages = append(inputMessages, msg)
@@ -293,15 +501,17 @@ func convertConversationsToOTelFormat(conversations []Conversation) (inputMessag
 
 		case "function_call":
 			// Function calls become assistant tool_call messages
-			lastToolCallID = fmt.Sprintf("call_%d", rand.Int63())
+			lastToolCallID = fmt.Sprintf("call_%d", rng.Int63())
+			name, arguments := parseFunctionCall(conv.Value)
+			lastToolName = name
 			msg := Message{
 				Role: "assistant",
 				Parts: []MessagePart{
 					{
 						Type:      "tool_call",
 						ID:        lastToolCallID,
-						Name:      "function_name", // Would parse from conv.Value in real implementation
-						Arguments: conv.Value,
+						Name:      name,
+						Arguments: arguments,
 					},
 				},
 			}
@@ -315,6 +525,7 @@ func convertConversationsToOTelFormat(conversations []Conversation) (inputMessag
 					{
 						Type:   "tool_call_response",
 						ID:     lastToolCallID,
+						Name:   lastToolName,
 						Result: conv.Value,
 					},
 				},
@@ -327,127 +538,98 @@ func convertConversationsToOTelFormat(conversations []Conversation) (inputMessag
 }
 
 func stringAttr(key, value string) *otlpCommon.KeyValue {
-	return &otlpCommon.KeyValue{
-		Key:   key,
-		Value: &otlpCommon.AnyValue{Value: &otlpCommon.AnyValue_StringValue{StringValue: value}},
-	}
+	return anyvalue.KV(key, anyvalue.String(value))
 }
 
 func intAttr(key string, value int64) *otlpCommon.KeyValue {
-	return &otlpCommon.KeyValue{
-		Key:   key,
-		Value: &otlpCommon.AnyValue{Value: &otlpCommon.AnyValue_IntValue{IntValue: value}},
-	}
+	return anyvalue.KV(key, anyvalue.Int(value))
 }
 
 func floatAttr(key string, value float64) *otlpCommon.KeyValue {
-	return &otlpCommon.KeyValue{
-		Key:   key,
-		Value: &otlpCommon.AnyValue{Value: &otlpCommon.AnyValue_DoubleValue{DoubleValue: value}},
-	}
-}
-
-// OTel value constructors for idiomatic usage
-
-func otelString(s string) *otlpCommon.AnyValue {
-	return &otlpCommon.AnyValue{Value: &otlpCommon.AnyValue_StringValue{StringValue: s}}
-}
-
-func otelArray(values ...*otlpCommon.AnyValue) *otlpCommon.AnyValue {
-	return &otlpCommon.AnyValue{
-		Value: &otlpCommon.AnyValue_ArrayValue{
-			ArrayValue: &otlpCommon.ArrayValue{Values: values},
-		},
-	}
-}
-
-func otelKVList(kvs ...*otlpCommon.KeyValue) *otlpCommon.AnyValue {
-	return &otlpCommon.AnyValue{
-		Value: &otlpCommon.AnyValue_KvlistValue{
-			KvlistValue: &otlpCommon.KeyValueList{Values: kvs},
-		},
-	}
-}
-
-func otelKV(key string, value *otlpCommon.AnyValue) *otlpCommon.KeyValue {
-	return &otlpCommon.KeyValue{Key: key, Value: value}
+	return anyvalue.KV(key, anyvalue.Double(value))
 }
 
 // ToOTel converts a MessagePart to a native OTel KeyValueList.
 // Supports: TextPart, ToolCallPart, ToolCallResponsePart, ThinkingPart
 func (p MessagePart) ToOTel() *otlpCommon.AnyValue {
-	kvs := []*otlpCommon.KeyValue{otelKV("type", otelString(p.Type))}
+	kvs := []*otlpCommon.KeyValue{anyvalue.KV("type", anyvalue.String(p.Type))}
 
 	switch p.Type {
 	case "text", "thinking":
 		if p.Content != "" {
-			kvs = append(kvs, otelKV("content", otelString(p.Content)))
+			kvs = append(kvs, anyvalue.KV("content", anyvalue.String(p.Content)))
 		}
 
 	case "tool_call":
 		if p.ID != "" {
-			kvs = append(kvs, otelKV("id", otelString(p.ID)))
+			kvs = append(kvs, anyvalue.KV("id", anyvalue.String(p.ID)))
 		}
 		if p.Name != "" {
-			kvs = append(kvs, otelKV("name", otelString(p.Name)))
+			kvs = append(kvs, anyvalue.KV("name", anyvalue.String(p.Name)))
 		}
 		if p.Arguments != "" {
-			kvs = append(kvs, otelKV("arguments", otelString(p.Arguments)))
+			kvs = append(kvs, anyvalue.KV("arguments", anyvalue.String(p.Arguments)))
 		}
 
 	case "tool_call_response":
 		if p.ID != "" {
-			kvs = append(kvs, otelKV("id", otelString(p.ID)))
+			kvs = append(kvs, anyvalue.KV("id", anyvalue.String(p.ID)))
 		}
 		if p.Name != "" {
-			kvs = append(kvs, otelKV("name", otelString(p.Name)))
+			kvs = append(kvs, anyvalue.KV("name", anyvalue.String(p.Name)))
 		}
 		if p.Result != "" {
-			kvs = append(kvs, otelKV("result", otelString(p.Result)))
+			kvs = append(kvs, anyvalue.KV("result", anyvalue.String(p.Result)))
 		}
 
 	default:
 		// For any other type, include all non-empty fields
 		if p.Content != "" {
-			kvs = append(kvs, otelKV("content", otelString(p.Content)))
+			kvs = append(kvs, anyvalue.KV("content", anyvalue.String(p.Content)))
 		}
 		if p.ID != "" {
-			kvs = append(kvs, otelKV("id", otelString(p.ID)))
+			kvs = append(kvs, anyvalue.KV("id", anyvalue.String(p.ID)))
 		}
 		if p.Name != "" {
-			kvs = append(kvs, otelKV("name", otelString(p.Name)))
+			kvs = append(kvs, anyvalue.KV("name", anyvalue.String(p.Name)))
 		}
 	}
 
-	return otelKVList(kvs...)
+	return anyvalue.KVList(kvs...)
 }
 
-// ToOTel converts a Message to a native OTel KeyValueList with "role" and "parts" keys.
+// ToOTel converts a Message to a native OTel KeyValueList with "role" and "parts" keys,
+// output messages also carry a "finish_reason" key.
 func (m Message) ToOTel() *otlpCommon.AnyValue {
 	partsValues := make([]*otlpCommon.AnyValue, 0, len(m.Parts))
 	for _, part := range m.Parts {
 		partsValues = append(partsValues, part.ToOTel())
 	}
 
-	return otelKVList(
-		otelKV("role", otelString(m.Role)),
-		otelKV("parts", otelArray(partsValues...)),
-	)
+	kvs := []*otlpCommon.KeyValue{
+		anyvalue.KV("role", anyvalue.String(m.Role)),
+		anyvalue.KV("parts", anyvalue.Array(partsValues...)),
+	}
+	if m.FinishReason != "" {
+		kvs = append(kvs, anyvalue.KV("finish_reason", anyvalue.String(m.FinishReason)))
+	}
+
+	return anyvalue.KVList(kvs...)
 }
 
 // ToOTel converts a ToolDefinition to a native OTel KeyValueList.
 func (t ToolDefinition) ToOTel() *otlpCommon.AnyValue {
 	kvs := []*otlpCommon.KeyValue{
-		otelKV("type", otelString(t.Type)),
-		otelKV("name", otelString(t.Name)),
-		otelKV("description", otelString(t.Description)),
+		anyvalue.KV("type", anyvalue.String(t.Type)),
+		anyvalue.KV("name", anyvalue.String(t.Name)),
+		anyvalue.KV("description", anyvalue.String(t.Description)),
 	}
 
 	if len(t.Parameters) > 0 {
-		kvs = append(kvs, otelKV("parameters", otelString(string(t.Parameters))))
+		kvs = append(kvs, anyvalue.KV("parameters", anyvalue.String(string(t.Parameters))))
 	}
 
-	return otelKVList(kvs...)
+	return anyvalue.KVList(kvs...)
 }
 
 // MessagesToOTel converts a slice of Messages to a native OTel ArrayValue.
@@ -456,7 +638,7 @@ func MessagesToOTel(messages []Message) *otlpCommon.AnyValue {
 	for _, msg := range messages {
 		values = append(values, msg.ToOTel())
 	}
-	return otelArray(values...)
+	return anyvalue.Array(values...)
 }
 
 // ToolDefinitionsToOTel converts a slice of ToolDefinitions to a native OTel ArrayValue.
@@ -465,5 +647,5 @@ func ToolDefinitionsToOTel(toolDefs []ToolDefinition) *otlpCommon.AnyValue {
 	for _, tool := range toolDefs {
 		values = append(values, tool.ToOTel())
 	}
-	return otelArray(values...)
+	return anyvalue.Array(values...)
 }
@@ -2,9 +2,12 @@ package genai
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 
+	"github.com/streamfold/otel-loadgen/internal/otlp/anyvalue"
 	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestLoadCorpus(t *testing.T) {
@@ -26,6 +29,8 @@ func TestGenAIAttributes(t *testing.T) {
 		t.Fatalf("Failed to load corpus: %v", err)
 	}
 
+	corpus.SetOptions(chatOnlyOptions(t))
+
 	attrs := corpus.GenAIAttributes()
 	if len(attrs) == 0 {
 		t.Fatal("No attributes generated")
@@ -76,6 +81,250 @@ func TestNextEntryRoundRobin(t *testing.T) {
 	}
 }
 
+func TestRandomEntry_Seeded(t *testing.T) {
+	a, err := LoadCorpus("../../contrib/apigen-mt_5k.json.gz")
+	if err != nil {
+		t.Fatalf("Failed to load corpus: %v", err)
+	}
+	b, err := LoadCorpus("../../contrib/apigen-mt_5k.json.gz")
+	if err != nil {
+		t.Fatalf("Failed to load corpus: %v", err)
+	}
+	a.SetSampling(SamplingRandom, 42)
+	b.SetSampling(SamplingRandom, 42)
+
+	seen := make(map[*Entry]bool)
+	inOrder := true
+	for i := 0; i < 100; i++ {
+		ea, eb := a.SampleEntry(), b.SampleEntry()
+		if ea.System != eb.System || ea.Tools != eb.Tools {
+			t.Fatalf("Expected the same seed to sample the same entries, differ at %d", i)
+		}
+		if ea != a.GetEntry(i) {
+			inOrder = false
+		}
+		seen[ea] = true
+	}
+
+	if inOrder {
+		t.Error("Expected random sampling not to follow the corpus order")
+	}
+	if len(seen) < 90 {
+		t.Errorf("Expected mostly distinct entries from a 5k corpus, got %d of 100", len(seen))
+	}
+}
+
+func TestGenAISpan_Seeded(t *testing.T) {
+	newCorpus := func(seed int64) *Corpus {
+		c, err := LoadCorpus("../../contrib/apigen-mt_5k.json.gz")
+		if err != nil {
+			t.Fatalf("Failed to load corpus: %v", err)
+		}
+		c.SetSampling(SamplingRoundRobin, seed)
+		return c
+	}
+
+	a, b, other := newCorpus(42), newCorpus(42), newCorpus(7)
+	differs := false
+	for i := 0; i < 50; i++ {
+		attrsA, _ := a.GenAISpan()
+		attrsB, _ := b.GenAISpan()
+		attrsOther, _ := other.GenAISpan()
+
+		if !proto.Equal(&otlpCommon.KeyValueList{Values: attrsA}, &otlpCommon.KeyValueList{Values: attrsB}) {
+			t.Fatalf("Expected the same seed to generate the same attributes, differ at %d", i)
+		}
+		if !proto.Equal(&otlpCommon.KeyValueList{Values: attrsA}, &otlpCommon.KeyValueList{Values: attrsOther}) {
+			differs = true
+		}
+
+		logsA, logsB := a.LogRecords(), b.LogRecords()
+		if len(logsA) != len(logsB) {
+			t.Fatalf("Expected the same seed to generate the same log events, differ at %d", i)
+		}
+		for j := range logsA {
+			if !proto.Equal(logsA[j], logsB[j]) {
+				t.Fatalf("Expected the same seed to generate the same log events, differ at %d", i)
+			}
+		}
+	}
+
+	if !differs {
+		t.Error("Expected different seeds to generate different attributes")
+	}
+}
+
+func TestParseSamplingMode(t *testing.T) {
+	for _, mode := range []SamplingMode{SamplingRoundRobin, SamplingRandom} {
+		parsed, err := ParseSamplingMode(mode.String())
+		if err != nil || parsed != mode {
+			t.Errorf("Expected %s to parse, got %v, %v", mode, parsed, err)
+		}
+	}
+	if _, err := ParseSamplingMode("shuffle"); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+}
+
+// Helper to build options that only generate chat operations
+func chatOnlyOptions(t *testing.T) *GenAIOptions {
+	opts, err := NewGenAIOptions([]string{"chat"}, []float64{1})
+	if err != nil {
+		t.Fatalf("Failed to create options: %v", err)
+	}
+	return opts
+}
+
+// Helper to find an attribute by key
+func findAttr(attrs []*otlpCommon.KeyValue, key string) *otlpCommon.AnyValue {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return attr.Value
+		}
+	}
+	return nil
+}
+
+func TestEmbeddingOmitsOutputMessages(t *testing.T) {
+	opts, err := NewGenAIOptions([]string{"embedding"}, []float64{1})
+	if err != nil {
+		t.Fatalf("Failed to create options: %v", err)
+	}
+
+	entry := &Entry{
+		Conversations: []Conversation{
+			{From: "human", Value: "Embed this document"},
+			{From: "gpt", Value: "Done"},
+		},
+	}
+
+	attrs := GenAIAttributesFromEntryWithOptions(entry, opts)
+
+	if op := findAttr(attrs, "gen_ai.operation.name"); getStringValue(op) != "embedding" {
+		t.Fatalf("Expected operation 'embedding', got %v", op)
+	}
+
+	for _, key := range []string{"gen_ai.output.messages", "gen_ai.usage.output_tokens", "gen_ai.request.temperature", "gen_ai.request.max_tokens"} {
+		if findAttr(attrs, key) != nil {
+			t.Errorf("Expected %s to be omitted for embedding operation", key)
+		}
+	}
+
+	if findAttr(attrs, "gen_ai.usage.input_tokens") == nil {
+		t.Error("Expected gen_ai.usage.input_tokens for embedding operation")
+	}
+}
+
+func TestEmbeddingAttributes(t *testing.T) {
+	opts, err := NewGenAIOptions([]string{"embedding"}, []float64{1})
+	if err != nil {
+		t.Fatalf("Failed to create options: %v", err)
+	}
+
+	entry := &Entry{
+		Conversations: []Conversation{
+			{From: "human", Value: "The quick brown fox jumps over the lazy dog"},
+			{From: "function_call", Value: `{"name": "lookup"}`},
+			{From: "observation", Value: `{"result": "ok"}`},
+			{From: "gpt", Value: "Done"},
+		},
+		System: "You are helpful.",
+		Tools:  `[{"name": "lookup", "description": "Lookup"}]`,
+	}
+
+	attrs := GenAIAttributesFromEntryWithOptions(entry, opts)
+
+	formats := findAttr(attrs, "gen_ai.request.encoding_formats")
+	if formats == nil {
+		t.Fatal("Expected gen_ai.request.encoding_formats")
+	}
+	arr := getArray(formats)
+	if len(arr) != 1 {
+		t.Fatalf("Expected a single encoding format, got %d", len(arr))
+	}
+	if f := getStringValue(arr[0]); f != "float" && f != "base64" {
+		t.Errorf("Unexpected encoding format %q", f)
+	}
+
+	dims := findAttr(attrs, "gen_ai.embeddings.dimension.count")
+	if dims == nil || dims.GetIntValue() <= 0 {
+		t.Errorf("Expected positive gen_ai.embeddings.dimension.count, got %v", dims)
+	}
+
+	for _, key := range []string{"gen_ai.input.messages", "gen_ai.output.messages", "gen_ai.system_instructions", "gen_ai.tool.definitions"} {
+		if findAttr(attrs, key) != nil {
+			t.Errorf("Expected %s to be omitted for embedding operation", key)
+		}
+	}
+
+	// Only the human turn counts as embedded input
+	if tokens := findAttr(attrs, "gen_ai.usage.input_tokens").GetIntValue(); tokens != int64(len(entry.Conversations[0].Value)/4) {
+		t.Errorf("Expected input tokens %d, got %d", len(entry.Conversations[0].Value)/4, tokens)
+	}
+}
+
+func TestEmbeddingSingleDocument(t *testing.T) {
+	opts, err := NewGenAIOptions([]string{"embedding"}, []float64{1})
+	if err != nil {
+		t.Fatalf("Failed to create options: %v", err)
+	}
+
+	document := strings.Repeat("embed me ", 20)
+	entry := &Entry{
+		Conversations: []Conversation{
+			{From: "human", Value: document},
+			{From: "gpt", Value: "Sure"},
+			{From: "human", Value: strings.Repeat("a follow up question ", 50)},
+		},
+	}
+
+	attrs := GenAIAttributesFromEntryWithOptions(entry, opts)
+	if tokens := findAttr(attrs, "gen_ai.usage.input_tokens").GetIntValue(); tokens != int64(len(document)/4) {
+		t.Errorf("Expected only the first user turn to be embedded, %d input tokens, got %d", len(document)/4, tokens)
+	}
+	if findAttr(attrs, "gen_ai.usage.output_tokens") != nil {
+		t.Error("Expected no output tokens for an embedding")
+	}
+}
+
+func TestOperationWeightsDistribution(t *testing.T) {
+	opts, err := NewGenAIOptions([]string{"chat", "completion", "embedding"}, []float64{7, 2, 1})
+	if err != nil {
+		t.Fatalf("Failed to create options: %v", err)
+	}
+
+	entry := &Entry{
+		Conversations: []Conversation{
+			{From: "human", Value: "Hello"},
+			{From: "gpt", Value: "Hi!"},
+		},
+	}
+
+	const samples = 20000
+	counts := make(map[string]int)
+	for i := 0; i < samples; i++ {
+		attrs := GenAIAttributesFromEntryWithOptions(entry, opts)
+		counts[getStringValue(findAttr(attrs, "gen_ai.operation.name"))]++
+	}
+
+	expected := map[string]float64{"chat": 0.7, "completion": 0.2, "embedding": 0.1}
+	for op, want := range expected {
+		got := float64(counts[op]) / samples
+		if got < want-0.02 || got > want+0.02 {
+			t.Errorf("Expected %s ratio ~%.2f, got %.3f", op, want, got)
+		}
+	}
+}
+
+func TestNewGenAIOptions_Invalid(t *testing.T) {
+	if _, err := NewGenAIOptions([]string{"summarize"}, []float64{1}); err == nil {
+		t.Error("Expected error for unknown operation")
+	}
+	if _, err := NewGenAIOptions([]string{"chat"}, []float64{0}); err == nil {
+		t.Error("Expected error for all-zero weights")
+	}
+}
+
 // Helper to extract string value from AnyValue
 func getStringValue(av *otlpCommon.AnyValue) string {
 	if sv := av.GetStringValue(); sv != "" {
@@ -290,7 +539,7 @@ func TestConvertConversationsToOTelFormat(t *testing.T) {
 		{From: "gpt", Value: "The weather in Paris is 22°C and sunny."},
 	}
 
-	inputMsgs, outputMsgs := convertConversationsToOTelFormat(conversations)
+	inputMsgs, outputMsgs := convertConversationsToOTelFormat(conversations, defaultRand)
 
 	// Should have 3 input messages (human, function_call, observation)
 	if len(inputMsgs) != 3 {
@@ -327,6 +576,9 @@ func TestConvertConversationsToOTelFormat(t *testing.T) {
 	if outputMsgs[0].Role != "assistant" {
 		t.Errorf("Expected output role='assistant', got '%s'", outputMsgs[0].Role)
 	}
+	if outputMsgs[0].FinishReason != "stop" {
+		t.Errorf("Expected output finish_reason='stop', got '%s'", outputMsgs[0].FinishReason)
+	}
 }
 
 func TestGenAIAttributesStructuredFormat(t *testing.T) {
@@ -612,7 +864,7 @@ func TestGenAIAttributesFromEntry_OTelTypeStructure(t *testing.T) {
 		Tools:  `[{"name": "test", "description": "A test"}]`,
 	}
 
-	attrs := GenAIAttributesFromEntry(entry)
+	attrs := GenAIAttributesFromEntryWithOptions(entry, chatOnlyOptions(t))
 
 	// Build a map for easier lookup
 	attrMap := make(map[string]*otlpCommon.AnyValue)
@@ -703,7 +955,7 @@ func TestGenAIAttributesFromEntry_OTelTypeStructure(t *testing.T) {
 func TestOTelHelperFunctions(t *testing.T) {
 	// Test otelString
 	t.Run("otelString", func(t *testing.T) {
-		av := otelString("test")
+		av := anyvalue.String("test")
 		if av.GetStringValue() != "test" {
 			t.Errorf("otelString: expected 'test', got %v", av.GetStringValue())
 		}
@@ -711,7 +963,7 @@ func TestOTelHelperFunctions(t *testing.T) {
 
 	// Test otelArray
 	t.Run("otelArray", func(t *testing.T) {
-		av := otelArray(otelString("a"), otelString("b"))
+		av := anyvalue.Array(anyvalue.String("a"), anyvalue.String("b"))
 		arr := av.GetArrayValue()
 		if arr == nil || len(arr.Values) != 2 {
 			t.Errorf("otelArray: expected array of 2 elements")
@@ -720,9 +972,9 @@ func TestOTelHelperFunctions(t *testing.T) {
 
 	// Test otelKVList
 	t.Run("otelKVList", func(t *testing.T) {
-		av := otelKVList(
-			otelKV("key1", otelString("val1")),
-			otelKV("key2", otelString("val2")),
+		av := anyvalue.KVList(
+			anyvalue.KV("key1", anyvalue.String("val1")),
+			anyvalue.KV("key2", anyvalue.String("val2")),
 		)
 		kvlist := av.GetKvlistValue()
 		if kvlist == nil || len(kvlist.Values) != 2 {
@@ -732,7 +984,7 @@ func TestOTelHelperFunctions(t *testing.T) {
 
 	// Test otelKV
 	t.Run("otelKV", func(t *testing.T) {
-		kv := otelKV("mykey", otelString("myval"))
+		kv := anyvalue.KV("mykey", anyvalue.String("myval"))
 		if kv.Key != "mykey" {
 			t.Errorf("otelKV: expected key='mykey', got '%s'", kv.Key)
 		}
@@ -0,0 +1,86 @@
+package genai
+
+import (
+	"github.com/streamfold/otel-loadgen/internal/otlp/anyvalue"
+	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+	otlpLogs "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+// GenAI event names, see the OTel GenAI semantic conventions for events
+const (
+	EventSystemMessage    = "gen_ai.system.message"
+	EventUserMessage      = "gen_ai.user.message"
+	EventAssistantMessage = "gen_ai.assistant.message"
+	EventToolMessage      = "gen_ai.tool.message"
+	EventChoice           = "gen_ai.choice"
+)
+
+// EntryToLogRecords converts a corpus entry to a stream of GenAI log events, one per
+// input message followed by a gen_ai.choice event for each output message. Timestamps
+// and trace context are left unset for the caller to fill in.
+func EntryToLogRecords(entry *Entry) []*otlpLogs.LogRecord {
+	return entryToLogRecords(entry, defaultRand)
+}
+
+// entryToLogRecords converts an entry like EntryToLogRecords, drawing tool call
+// IDs from rng
+func entryToLogRecords(entry *Entry, rng *lockedRand) []*otlpLogs.LogRecord {
+	inputMessages, outputMessages := convertConversationsToOTelFormat(entry.Conversations, rng)
+
+	records := make([]*otlpLogs.LogRecord, 0, len(inputMessages)+len(outputMessages)+1)
+
+	if entry.System != "" {
+		system := Message{
+			Role:  "system",
+			Parts: []MessagePart{{Type: "text", Content: entry.System}},
+		}
+		records = append(records, newEventRecord(EventSystemMessage, system.ToOTel()))
+	}
+
+	for _, msg := range inputMessages {
+		records = append(records, newEventRecord(messageEventName(msg.Role), msg.ToOTel()))
+	}
+
+	for i, msg := range outputMessages {
+		records = append(records, newEventRecord(EventChoice, choiceBody(i, msg)))
+	}
+
+	return records
+}
+
+func messageEventName(role string) string {
+	switch role {
+	case "system":
+		return EventSystemMessage
+	case "assistant":
+		return EventAssistantMessage
+	case "tool":
+		return EventToolMessage
+	default:
+		return EventUserMessage
+	}
+}
+
+// choiceBody builds the gen_ai.choice body: the choice index, its finish reason
+// and the generated message
+func choiceBody(index int, msg Message) *otlpCommon.AnyValue {
+	finishReason := msg.FinishReason
+	if finishReason == "" {
+		finishReason = "stop"
+	}
+
+	return anyvalue.KVList(
+		anyvalue.KV("index", anyvalue.Int(int64(index))),
+		anyvalue.KV("finish_reason", anyvalue.String(finishReason)),
+		anyvalue.KV("message", msg.ToOTel()),
+	)
+}
+
+func newEventRecord(name string, body *otlpCommon.AnyValue) *otlpLogs.LogRecord {
+	return &otlpLogs.LogRecord{
+		EventName:      name,
+		SeverityNumber: otlpLogs.SeverityNumber_SEVERITY_NUMBER_INFO,
+		SeverityText:   "INFO",
+		Body:           body,
+	}
+}
@@ -0,0 +1,87 @@
+package genai
+
+import (
+	"testing"
+)
+
+func TestEntryToLogRecords(t *testing.T) {
+	entry := &Entry{
+		System: "You are a helpful assistant.",
+		Conversations: []Conversation{
+			{From: "human", Value: "What's the weather in Paris?"},
+			{From: "function_call", Value: `{"name": "get_weather", "arguments": {"location": "Paris"}}`},
+			{From: "observation", Value: `{"temp": "22C"}`},
+			{From: "gpt", Value: "It is 22°C in Paris."},
+		},
+	}
+
+	records := EntryToLogRecords(entry)
+
+	expected := []string{
+		EventSystemMessage,
+		EventUserMessage,
+		EventAssistantMessage,
+		EventToolMessage,
+		EventChoice,
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("Expected %d records, got %d", len(expected), len(records))
+	}
+	for i, name := range expected {
+		if records[i].EventName != name {
+			t.Errorf("Expected record %d to be %s, got %s", i, name, records[i].EventName)
+		}
+		if records[i].Body.GetKvlistValue() == nil {
+			t.Errorf("Expected record %d body to be a KvlistValue, got %T", i, records[i].Body.Value)
+		}
+	}
+
+	userKvs := getKvlist(records[1].Body)
+	if role := getStringValue(findInKvlist(userKvs, "role")); role != "user" {
+		t.Errorf("Expected user message role='user', got '%s'", role)
+	}
+}
+
+func TestEntryToLogRecords_ChoiceFinishReason(t *testing.T) {
+	entry := &Entry{
+		Conversations: []Conversation{
+			{From: "human", Value: "Hello"},
+			{From: "gpt", Value: "Hi there!"},
+		},
+	}
+
+	records := EntryToLogRecords(entry)
+	choice := records[len(records)-1]
+	if choice.EventName != EventChoice {
+		t.Fatalf("Expected last record to be %s, got %s", EventChoice, choice.EventName)
+	}
+
+	kvs := getKvlist(choice.Body)
+	if reason := getStringValue(findInKvlist(kvs, "finish_reason")); reason != "stop" {
+		t.Errorf("Expected finish_reason='stop', got '%s'", reason)
+	}
+	if idx := findInKvlist(kvs, "index"); idx == nil || idx.GetIntValue() != 0 {
+		t.Errorf("Expected index=0, got %v", idx)
+	}
+
+	msgKvs := getKvlist(findInKvlist(kvs, "message"))
+	if role := getStringValue(findInKvlist(msgKvs, "role")); role != "assistant" {
+		t.Errorf("Expected choice message role='assistant', got '%s'", role)
+	}
+	if reason := getStringValue(findInKvlist(msgKvs, "finish_reason")); reason != "stop" {
+		t.Errorf("Expected choice message finish_reason='stop', got '%s'", reason)
+	}
+}
+
+func TestEntryToLogRecords_NoOutput(t *testing.T) {
+	entry := &Entry{
+		Conversations: []Conversation{
+			{From: "human", Value: "Hello"},
+		},
+	}
+
+	records := EntryToLogRecords(entry)
+	if len(records) != 1 || records[0].EventName != EventUserMessage {
+		t.Fatalf("Expected a single %s record, got %v", EventUserMessage, records)
+	}
+}
@@ -0,0 +1,183 @@
+package genai
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// CorpusFormat is the file format of a corpus
+type CorpusFormat int
+
+const (
+	// CorpusFormatAuto picks CorpusFormatJSONL for .jsonl and .jsonl.gz files
+	// and CorpusFormatJSON otherwise
+	CorpusFormatAuto CorpusFormat = iota
+	// CorpusFormatJSON is a single JSON array of APIGen entries
+	CorpusFormatJSON
+	// CorpusFormatJSONL is newline delimited APIGen entries
+	CorpusFormatJSONL
+	// CorpusFormatShareGPT is ShareGPT conversations, either as a JSON array
+	// or newline delimited for .jsonl and .jsonl.gz files
+	CorpusFormatShareGPT
+)
+
+func (f CorpusFormat) String() string {
+	switch f {
+	case CorpusFormatAuto:
+		return "auto"
+	case CorpusFormatJSON:
+		return "json"
+	case CorpusFormatJSONL:
+		return "jsonl"
+	case CorpusFormatShareGPT:
+		return "sharegpt"
+	default:
+		return "unknown"
+	}
+}
+
+func ParseCorpusFormat(s string) (CorpusFormat, error) {
+	switch s {
+	case "auto":
+		return CorpusFormatAuto, nil
+	case "json":
+		return CorpusFormatJSON, nil
+	case "jsonl":
+		return CorpusFormatJSONL, nil
+	case "sharegpt":
+		return CorpusFormatShareGPT, nil
+	default:
+		return 0, fmt.Errorf("invalid corpus format: %q (expected auto, json, jsonl or sharegpt)", s)
+	}
+}
+
+// shareGPTEntry is a conversation in the ShareGPT schema
+type shareGPTEntry struct {
+	Conversations []Conversation `json:"conversations"`
+}
+
+// toEntry maps a ShareGPT conversation to an entry, system turns become the
+// system instructions and role aliases are mapped to human and gpt
+func (s *shareGPTEntry) toEntry() Entry {
+	var entry Entry
+	for _, conv := range s.Conversations {
+		switch conv.From {
+		case "system":
+			if entry.System != "" {
+				entry.System += "\n"
+			}
+			entry.System += conv.Value
+			continue
+		case "user":
+			conv.From = "human"
+		case "assistant", "chatgpt", "bard":
+			conv.From = "gpt"
+		}
+		entry.Conversations = append(entry.Conversations, conv)
+	}
+	return entry
+}
+
+// openCorpus opens a corpus file, decompressing it if it has a .gz extension
+func openCorpus(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open corpus file: %w", err)
+	}
+
+	if !strings.HasSuffix(path, ".gz") {
+		return file, nil
+	}
+
+	gzReader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	return &gzipFile{Reader: gzReader, file: file}, nil
+}
+
+// gzipFile closes both the gzip reader and the underlying file
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g *gzipFile) Close() error {
+	return errors.Join(g.Reader.Close(), g.file.Close())
+}
+
+// isJSONL reports whether path has a newline delimited JSON extension
+func isJSONL(path string) bool {
+	return strings.HasSuffix(strings.TrimSuffix(path, ".gz"), ".jsonl")
+}
+
+// decodeEntries reads the entries of a corpus in format from r, calling fn
+// with each one as it is decoded
+func decodeEntries(r io.Reader, path string, format CorpusFormat, fn func(Entry) error) error {
+	if format == CorpusFormatAuto {
+		format = CorpusFormatJSON
+		if isJSONL(path) {
+			format = CorpusFormatJSONL
+		}
+	}
+
+	decode := func(dec *json.Decoder) (Entry, error) {
+		var entry Entry
+		err := dec.Decode(&entry)
+		return entry, err
+	}
+	if format == CorpusFormatShareGPT {
+		decode = func(dec *json.Decoder) (Entry, error) {
+			var entry shareGPTEntry
+			err := dec.Decode(&entry)
+			return entry.toEntry(), err
+		}
+	}
+
+	dec := json.NewDecoder(r)
+	delimited := format == CorpusFormatJSONL || (format == CorpusFormatShareGPT && isJSONL(path))
+
+	if delimited {
+		for n := 1; ; n++ {
+			entry, err := decode(dec)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to parse corpus entry %d: %w", n, err)
+			}
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Walk the array rather than decoding it whole so entries can be handled
+	// as they are read
+	if tok, err := dec.Token(); err != nil {
+		return fmt.Errorf("failed to parse corpus JSON: %w", err)
+	} else if tok != json.Delim('[') {
+		return fmt.Errorf("failed to parse corpus JSON: expected an array of entries")
+	}
+
+	for n := 1; dec.More(); n++ {
+		entry, err := decode(dec)
+		if err != nil {
+			return fmt.Errorf("failed to parse corpus entry %d: %w", n, err)
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("failed to parse corpus JSON: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package genai
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeCorpusFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if filepath.Ext(name) != ".gz" {
+		if _, err := f.WriteString(content); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	gz := gzip.NewWriter(f)
+	if _, err := gz.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const testJSONLCorpus = `{"conversations": [{"from": "human", "value": "Hi"}, {"from": "gpt", "value": "Hello!"}], "system": "Be brief"}
+
+{"conversations": [{"from": "human", "value": "Bye"}]}
+`
+
+func TestLoadCorpusFormat_JSONL(t *testing.T) {
+	for _, name := range []string{"corpus.jsonl", "corpus.jsonl.gz"} {
+		t.Run(name, func(t *testing.T) {
+			corpus, err := LoadCorpus(writeCorpusFile(t, name, testJSONLCorpus))
+			if err != nil {
+				t.Fatalf("Failed to load corpus: %v", err)
+			}
+			if corpus.Size() != 2 {
+				t.Fatalf("Expected 2 entries, got %d", corpus.Size())
+			}
+			if corpus.entries[0].System != "Be brief" || corpus.entries[1].Conversations[0].Value != "Bye" {
+				t.Errorf("Unexpected entries: %+v", corpus.entries)
+			}
+		})
+	}
+}
+
+func TestLoadCorpusFormat_ShareGPT(t *testing.T) {
+	expected := Entry{
+		System: "You are helpful",
+		Conversations: []Conversation{
+			{From: "human", Value: "Hi"},
+			{From: "gpt", Value: "Hello!"},
+			{From: "human", Value: "Bye"},
+		},
+	}
+
+	files := map[string]string{
+		"sharegpt.json": `[{"id": "a1", "conversations": [
+			{"from": "system", "value": "You are helpful"},
+			{"from": "human", "value": "Hi"},
+			{"from": "gpt", "value": "Hello!"},
+			{"from": "user", "value": "Bye"}]}]`,
+		"sharegpt.jsonl": `{"id": "a1", "conversations": [{"from": "system", "value": "You are helpful"}, {"from": "user", "value": "Hi"}, {"from": "assistant", "value": "Hello!"}, {"from": "human", "value": "Bye"}]}` + "\n",
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			corpus, err := LoadCorpusFormat(writeCorpusFile(t, name, content), CorpusFormatShareGPT)
+			if err != nil {
+				t.Fatalf("Failed to load corpus: %v", err)
+			}
+			if corpus.Size() != 1 || !reflect.DeepEqual(corpus.entries[0], expected) {
+				t.Errorf("Unexpected entries: %+v", corpus.entries)
+			}
+		})
+	}
+}
+
+func TestLoadCorpusFormat_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		format  CorpusFormat
+	}{
+		{"corpus.json", `{"conversations": []}`, CorpusFormatAuto},
+		{"corpus.json", `[{"conversations": "x"}]`, CorpusFormatJSON},
+		{"corpus.jsonl", "{\"conversations\": []}\nnot json\n", CorpusFormatAuto},
+		{"corpus.json", `[{"conversations": []}`, CorpusFormatJSON},
+	}
+
+	for _, tt := range tests {
+		if _, err := LoadCorpusFormat(writeCorpusFile(t, tt.name, tt.content), tt.format); err == nil {
+			t.Errorf("Expected error loading %q as %s", tt.content, tt.format)
+		}
+	}
+}
+
+func TestParseCorpusFormat(t *testing.T) {
+	for _, format := range []CorpusFormat{CorpusFormatAuto, CorpusFormatJSON, CorpusFormatJSONL, CorpusFormatShareGPT} {
+		parsed, err := ParseCorpusFormat(format.String())
+		if err != nil || parsed != format {
+			t.Errorf("Expected %s to round trip, got %v, %v", format, parsed, err)
+		}
+	}
+
+	if _, err := ParseCorpusFormat("csv"); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
@@ -0,0 +1,100 @@
+package genai
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/streamfold/otel-loadgen/internal/util"
+)
+
+// modelPair is a model and the provider serving it
+type modelPair struct {
+	provider string
+	model    string
+}
+
+// ModelWeights picks provider and model pairs with a probability proportional
+// to their weight
+type ModelWeights struct {
+	pairs *util.WeightedChoice[modelPair]
+}
+
+// Default provider and model pairs, each provider is equally likely
+var defaultModelWeights = mustModelWeights(map[string]map[string]float64{
+	"openai":    {"gpt-4o": 1, "gpt-4-turbo": 1},
+	"anthropic": {"claude-3-5-sonnet": 1, "claude-3-opus": 1},
+	"google":    {"gemini-1.5-pro": 1, "gemini-1.5-flash": 1},
+	"azure":     {"gpt-4o": 1, "gpt-4-turbo": 1},
+	"bedrock":   {"claude-3-5-sonnet": 1, "claude-3-opus": 1},
+})
+
+// NewModelWeights creates a distribution from weights of the form
+// provider -> model -> weight
+func NewModelWeights(weights map[string]map[string]float64) (*ModelWeights, error) {
+	providers := make([]string, 0, len(weights))
+	for provider := range weights {
+		providers = append(providers, provider)
+	}
+	// Sort so the same weights always give the same distribution
+	sort.Strings(providers)
+
+	pairs := make([]modelPair, 0)
+	values := make([]float64, 0)
+	for _, provider := range providers {
+		if provider == "" {
+			return nil, fmt.Errorf("empty gen_ai provider name")
+		}
+
+		models := make([]string, 0, len(weights[provider]))
+		for model := range weights[provider] {
+			models = append(models, model)
+		}
+		sort.Strings(models)
+
+		for _, model := range models {
+			if model == "" {
+				return nil, fmt.Errorf("empty gen_ai model name for provider %q", provider)
+			}
+			pairs = append(pairs, modelPair{provider: provider, model: model})
+			values = append(values, weights[provider][model])
+		}
+	}
+
+	wc, err := util.NewWeightedChoice(pairs, values)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gen_ai model weights: %w", err)
+	}
+
+	return &ModelWeights{pairs: wc}, nil
+}
+
+// LoadModelWeights loads model weights from a JSON file mapping providers to
+// the weights of their models, e.g. {"openai": {"gpt-4o": 6, "gpt-4-turbo": 1}}
+func LoadModelWeights(path string) (*ModelWeights, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model weights file: %w", err)
+	}
+
+	var weights map[string]map[string]float64
+	if err := json.Unmarshal(data, &weights); err != nil {
+		return nil, fmt.Errorf("failed to parse model weights JSON: %w", err)
+	}
+
+	return NewModelWeights(weights)
+}
+
+func mustModelWeights(weights map[string]map[string]float64) *ModelWeights {
+	mw, err := NewModelWeights(weights)
+	if err != nil {
+		panic(err)
+	}
+	return mw
+}
+
+func (m *ModelWeights) pick(rng *lockedRand) (provider string, model string) {
+	pair := m.pairs.Pick(rng.Float64())
+	return pair.provider, pair.model
+}
@@ -0,0 +1,106 @@
+package genai
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestModelWeightsDistribution(t *testing.T) {
+	models, err := NewModelWeights(map[string]map[string]float64{
+		"openai":    {"gpt-4o": 6, "gpt-4-turbo": 1},
+		"anthropic": {"claude-3-5-sonnet": 3},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create model weights: %v", err)
+	}
+
+	opts, err := NewGenAIOptions([]string{"chat"}, []float64{1})
+	if err != nil {
+		t.Fatalf("Failed to create options: %v", err)
+	}
+	opts.SetModelWeights(models)
+
+	entry := &Entry{
+		Conversations: []Conversation{
+			{From: "human", Value: "Hello"},
+			{From: "gpt", Value: "Hi!"},
+		},
+	}
+
+	providers := map[string]string{
+		"gpt-4o":            "openai",
+		"gpt-4-turbo":       "openai",
+		"claude-3-5-sonnet": "anthropic",
+	}
+
+	const samples = 20000
+	counts := make(map[string]int)
+	for i := 0; i < samples; i++ {
+		attrs := GenAIAttributesFromEntryWithOptions(entry, opts)
+		provider := getStringValue(findAttr(attrs, "gen_ai.provider.name"))
+		requestModel := getStringValue(findAttr(attrs, "gen_ai.request.model"))
+		responseModel := getStringValue(findAttr(attrs, "gen_ai.response.model"))
+
+		if requestModel != responseModel {
+			t.Fatalf("Expected matching request and response models, got %q and %q", requestModel, responseModel)
+		}
+		if providers[requestModel] != provider {
+			t.Fatalf("Model %q doesn't belong to provider %q", requestModel, provider)
+		}
+		counts[requestModel]++
+	}
+
+	expected := map[string]float64{"gpt-4o": 0.6, "gpt-4-turbo": 0.1, "claude-3-5-sonnet": 0.3}
+	for model, want := range expected {
+		got := float64(counts[model]) / samples
+		if got < want-0.02 || got > want+0.02 {
+			t.Errorf("Expected %s ratio ~%.2f, got %.3f", model, want, got)
+		}
+	}
+}
+
+func TestNewModelWeights_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights map[string]map[string]float64
+	}{
+		{"empty", map[string]map[string]float64{}},
+		{"all zero", map[string]map[string]float64{"openai": {"gpt-4o": 0}}},
+		{"negative", map[string]map[string]float64{"openai": {"gpt-4o": -1}}},
+		{"empty provider", map[string]map[string]float64{"": {"gpt-4o": 1}}},
+		{"empty model", map[string]map[string]float64{"openai": {"": 1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewModelWeights(tt.weights); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestLoadModelWeights(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "models.json")
+	if err := os.WriteFile(path, []byte(`{"anthropic": {"claude-3-opus": 1}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	models, err := LoadModelWeights(path)
+	if err != nil {
+		t.Fatalf("Failed to load model weights: %v", err)
+	}
+
+	provider, model := models.pick(defaultRand)
+	if provider != "anthropic" || model != "claude-3-opus" {
+		t.Errorf("Expected anthropic/claude-3-opus, got %s/%s", provider, model)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"anthropic": ["claude-3-opus"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadModelWeights(path); err == nil {
+		t.Error("Expected error for invalid JSON")
+	}
+}
@@ -0,0 +1,44 @@
+package genai
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// lockedRand is a random source safe for concurrent use. Each corpus owns one
+// so a seed reproduces the generated attributes, and pushers don't contend on
+// the global source.
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+// newLockedRand returns a source seeded with seed, 0 picks a random seed
+func newLockedRand(seed int64) *lockedRand {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &lockedRand{r: rand.New(rand.NewSource(seed))}
+}
+
+// defaultRand is used when generating from an entry outside of a corpus
+var defaultRand = newLockedRand(0)
+
+func (l *lockedRand) Int63() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Int63()
+}
+
+func (l *lockedRand) Intn(n int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Intn(n)
+}
+
+func (l *lockedRand) Float64() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Float64()
+}
@@ -0,0 +1,49 @@
+package genai
+
+import (
+	"regexp"
+
+	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+)
+
+// FinishReasonContentFilter is the finish reason of a refused response
+const FinishReasonContentFilter = "content_filter"
+
+// DefaultRefusalPattern matches the usual openings of a model declining to answer
+const DefaultRefusalPattern = `(?i)^\s*(I'm sorry|I am sorry|I apologize|I cannot|I can't|I can not|I won't|I'm not able to|I am not able to|As an AI)`
+
+// SetRefusalPattern sets the pattern matched against the final assistant
+// reply to detect refusals, nil only treats entries flagged as refusals as such
+func (o *GenAIOptions) SetRefusalPattern(re *regexp.Regexp) {
+	o.refusal = re
+}
+
+// isRefusal returns true if the entry is flagged as a refusal, or its final
+// assistant reply matches the refusal pattern
+func (o *GenAIOptions) isRefusal(entry *Entry) bool {
+	if entry.Refusal {
+		return true
+	}
+	if o.refusal == nil || len(entry.Conversations) == 0 {
+		return false
+	}
+
+	last := entry.Conversations[len(entry.Conversations)-1]
+	return last.From == "gpt" && o.refusal.MatchString(last.Value)
+}
+
+// IsRefusal returns true if gen_ai span attributes describe a refused
+// response, so the span can be given an error status
+func IsRefusal(attrs []*otlpCommon.KeyValue) bool {
+	for _, attr := range attrs {
+		if attr.Key != "gen_ai.response.finish_reasons" {
+			continue
+		}
+		for _, v := range attr.GetValue().GetArrayValue().GetValues() {
+			if v.GetStringValue() == FinishReasonContentFilter {
+				return true
+			}
+		}
+	}
+	return false
+}
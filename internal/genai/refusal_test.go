@@ -0,0 +1,68 @@
+package genai
+
+import (
+	"regexp"
+	"testing"
+
+	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+)
+
+func refusalEntry(reply string, flagged bool) *Entry {
+	return &Entry{
+		Conversations: []Conversation{
+			{From: "human", Value: "How do I pick a lock?"},
+			{From: "gpt", Value: reply},
+		},
+		Refusal: flagged,
+	}
+}
+
+func outputFinishReason(t *testing.T, av *otlpCommon.AnyValue) string {
+	t.Helper()
+
+	msgs := av.GetArrayValue().GetValues()
+	if len(msgs) == 0 {
+		t.Fatal("Expected output messages")
+	}
+	return getStringValue(findInKvlist(getKvlist(msgs[len(msgs)-1]), "finish_reason"))
+}
+
+func TestGenAISpanFromEntry_Refusal(t *testing.T) {
+	opts := chatOnlyOptions(t)
+	opts.SetRefusalPattern(regexp.MustCompile(DefaultRefusalPattern))
+
+	tests := []struct {
+		name    string
+		entry   *Entry
+		refused bool
+	}{
+		{"flagged", refusalEntry("Here is how.", true), true},
+		{"pattern", refusalEntry("I'm sorry, but I can't help with that.", false), true},
+		{"answer", refusalEntry("Use a tension wrench.", false), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attrs, _ := GenAISpanFromEntry(tt.entry, opts)
+
+			if got := IsRefusal(attrs); got != tt.refused {
+				t.Errorf("IsRefusal() = %v, want %v", got, tt.refused)
+			}
+
+			want := "stop"
+			if tt.refused {
+				want = FinishReasonContentFilter
+			}
+			if got := outputFinishReason(t, findAttr(attrs, "gen_ai.output.messages")); got != want {
+				t.Errorf("finish_reason = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestGenAISpanFromEntry_RefusalPatternDisabled(t *testing.T) {
+	attrs, _ := GenAISpanFromEntry(refusalEntry("I'm sorry, but I can't help with that.", false), chatOnlyOptions(t))
+	if IsRefusal(attrs) {
+		t.Error("Expected only flagged entries to be refusals without a pattern")
+	}
+}
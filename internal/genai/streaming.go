@@ -0,0 +1,208 @@
+package genai
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+	otlpLogs "go.opentelemetry.io/proto/otlp/logs/v1"
+	otlpTraces "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// EntrySource supplies corpus entries to the workers, it is implemented by
+// Corpus and StreamingCorpus
+type EntrySource interface {
+	// SampleEntry returns the next entry to use
+	SampleEntry() *Entry
+	// GenAISpan generates gen_ai span attributes and tool call span events
+	// from the next entry
+	GenAISpan() ([]*otlpCommon.KeyValue, []*otlpTraces.Span_Event)
+	// LogRecords converts the next entry to a stream of GenAI log events
+	LogRecords() []*otlpLogs.LogRecord
+}
+
+var (
+	_ EntrySource = (*Corpus)(nil)
+	_ EntrySource = (*StreamingCorpus)(nil)
+)
+
+// streamBufferSize is the number of entries a StreamingCorpus reads ahead
+const streamBufferSize = 1024
+
+var errStreamStopped = errors.New("corpus stream stopped")
+
+// StreamingCorpus serves corpus entries in file order without loading the
+// whole corpus. A background reader keeps a bounded buffer of entries filled
+// and wraps to the start of the file at EOF, so memory use doesn't depend on
+// the size of the corpus.
+type StreamingCorpus struct {
+	path   string
+	format CorpusFormat
+	opts   *GenAIOptions
+	rng    *lockedRand
+
+	entries chan *Entry
+	// last is served once the reader has stopped
+	last atomic.Pointer[Entry]
+
+	errMu sync.Mutex
+	err   error
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewStreamingCorpus streams the corpus at path, picking the format from the
+// extension
+func NewStreamingCorpus(path string) (*StreamingCorpus, error) {
+	return NewStreamingCorpusFormat(path, CorpusFormatAuto)
+}
+
+// NewStreamingCorpusFormat streams a corpus in the given format from path. It
+// returns once the first entry is read, or with an error if the file can't be
+// read or has no entries.
+func NewStreamingCorpusFormat(path string, format CorpusFormat) (*StreamingCorpus, error) {
+	c := &StreamingCorpus{
+		path:    path,
+		format:  format,
+		opts:    defaultOptions,
+		rng:     newLockedRand(0),
+		entries: make(chan *Entry, streamBufferSize),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	ready := make(chan error, 1)
+	go c.run(ready)
+
+	if err := <-ready; err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// SetOptions sets the options used when generating attributes, must be
+// called before the corpus is used
+func (c *StreamingCorpus) SetOptions(opts *GenAIOptions) {
+	c.opts = opts
+}
+
+// SetSeed seeds the random values of the generated attributes, a non-zero seed
+// makes them reproducible. Must be called before the corpus is used.
+func (c *StreamingCorpus) SetSeed(seed int64) {
+	if seed != 0 {
+		c.rng = newLockedRand(seed)
+	}
+}
+
+// NextEntry returns the next entry of the stream, waiting for the reader if
+// the buffer is empty
+func (c *StreamingCorpus) NextEntry() *Entry {
+	select {
+	case entry := <-c.entries:
+		c.last.Store(entry)
+		return entry
+	case <-c.done:
+		return c.last.Load()
+	}
+}
+
+// SampleEntry returns the next entry of the stream, entries are always used
+// in file order
+func (c *StreamingCorpus) SampleEntry() *Entry {
+	return c.NextEntry()
+}
+
+// GenAISpan generates gen_ai span attributes and, when tool calls are emitted as
+// events, the tool call span events from the next entry
+func (c *StreamingCorpus) GenAISpan() ([]*otlpCommon.KeyValue, []*otlpTraces.Span_Event) {
+	return genAISpan(c.NextEntry(), c.opts, c.rng)
+}
+
+// LogRecords converts the next entry to a stream of GenAI log events
+func (c *StreamingCorpus) LogRecords() []*otlpLogs.LogRecord {
+	return entryToLogRecords(c.NextEntry(), c.rng)
+}
+
+// Err returns the last error of the background reader. Entries read before a
+// decode error keep being served.
+func (c *StreamingCorpus) Err() error {
+	c.errMu.Lock()
+	defer c.errMu.Unlock()
+
+	return c.err
+}
+
+// Close stops the background reader
+func (c *StreamingCorpus) Close() {
+	c.stopOnce.Do(func() { close(c.stop) })
+	<-c.done
+}
+
+func (c *StreamingCorpus) run(ready chan<- error) {
+	defer close(c.done)
+
+	signal := func(err error) {
+		if ready != nil {
+			ready <- err
+			ready = nil
+		}
+	}
+
+	for {
+		n, err := c.readPass(func() { signal(nil) })
+		if errors.Is(err, errStreamStopped) {
+			return
+		}
+
+		if n == 0 {
+			// Nothing to wrap around to
+			if err == nil {
+				err = fmt.Errorf("corpus %s has no entries", c.path)
+			}
+			c.setErr(err)
+			signal(err)
+			return
+		}
+		if err != nil {
+			c.setErr(err)
+		}
+	}
+}
+
+// readPass reads the file once, pushing each entry into the buffer. It
+// returns the number of entries read and calls onEntry after each.
+func (c *StreamingCorpus) readPass(onEntry func()) (int, error) {
+	r, err := openCorpus(c.path)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	n := 0
+	err = decodeEntries(r, c.path, c.format, func(entry Entry) error {
+		c.last.CompareAndSwap(nil, &entry)
+
+		select {
+		case c.entries <- &entry:
+		case <-c.stop:
+			return errStreamStopped
+		}
+
+		n++
+		onEntry()
+		return nil
+	})
+	return n, err
+}
+
+func (c *StreamingCorpus) setErr(err error) {
+	c.errMu.Lock()
+	defer c.errMu.Unlock()
+
+	c.err = err
+}
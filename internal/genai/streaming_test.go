@@ -0,0 +1,100 @@
+package genai
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func writeJSONLCorpus(t *testing.T, name string, n int) string {
+	t.Helper()
+
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, `{"conversations": [{"from": "human", "value": "question %d"}]}`+"\n", i)
+	}
+	return writeCorpusFile(t, name, sb.String())
+}
+
+func TestStreamingCorpus_WrapsInOrder(t *testing.T) {
+	// Larger than the buffer so the reader has to refill it
+	const n = streamBufferSize*2 + 10
+
+	for _, name := range []string{"corpus.jsonl", "corpus.jsonl.gz"} {
+		t.Run(name, func(t *testing.T) {
+			corpus, err := NewStreamingCorpus(writeJSONLCorpus(t, name, n))
+			if err != nil {
+				t.Fatalf("Failed to stream corpus: %v", err)
+			}
+			defer corpus.Close()
+
+			for i := 0; i < n*2+5; i++ {
+				expected := fmt.Sprintf("question %d", i%n)
+				if got := corpus.NextEntry().Conversations[0].Value; got != expected {
+					t.Fatalf("Entry %d: expected %q, got %q", i, expected, got)
+				}
+			}
+			if err := corpus.Err(); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestStreamingCorpus_JSONArray(t *testing.T) {
+	path := writeCorpusFile(t, "corpus.json", `[{"conversations": [{"from": "human", "value": "a"}]},
+		{"conversations": [{"from": "human", "value": "b"}]}]`)
+
+	corpus, err := NewStreamingCorpus(path)
+	if err != nil {
+		t.Fatalf("Failed to stream corpus: %v", err)
+	}
+	defer corpus.Close()
+
+	var got []string
+	for i := 0; i < 5; i++ {
+		got = append(got, corpus.SampleEntry().Conversations[0].Value)
+	}
+	if strings.Join(got, "") != "ababa" {
+		t.Errorf("Expected entries in file order, got %v", got)
+	}
+
+	attrs, _ := corpus.GenAISpan()
+	if findAttr(attrs, "gen_ai.operation.name") == nil {
+		t.Error("Expected gen_ai attributes from the streamed entry")
+	}
+}
+
+func TestStreamingCorpus_DecodeError(t *testing.T) {
+	path := writeCorpusFile(t, "corpus.jsonl", `{"conversations": [{"from": "human", "value": "a"}]}`+"\nnot json\n")
+
+	corpus, err := NewStreamingCorpus(path)
+	if err != nil {
+		t.Fatalf("Failed to stream corpus: %v", err)
+	}
+	defer corpus.Close()
+
+	// Entries before the error keep being served
+	for i := 0; i < 3; i++ {
+		if got := corpus.NextEntry().Conversations[0].Value; got != "a" {
+			t.Fatalf("Expected entry a, got %q", got)
+		}
+	}
+	if corpus.Err() == nil {
+		t.Error("Expected the decode error to be reported")
+	}
+}
+
+func TestNewStreamingCorpus_Invalid(t *testing.T) {
+	paths := map[string]string{
+		"missing": "does-not-exist.jsonl",
+		"empty":   writeCorpusFile(t, "empty.jsonl", ""),
+		"invalid": writeCorpusFile(t, "invalid.jsonl", "not json\n"),
+	}
+
+	for name, path := range paths {
+		if _, err := NewStreamingCorpus(path); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
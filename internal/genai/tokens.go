@@ -0,0 +1,102 @@
+package genai
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/tiktoken-go/tokenizer/codec"
+)
+
+// Tokenizer determines how gen_ai.usage token counts are derived from the
+// message content
+type Tokenizer int
+
+const (
+	// TokenizerApprox counts a token per 4 bytes of content
+	TokenizerApprox Tokenizer = iota
+	// TokenizerCL100K counts tokens of the cl100k_base encoding (GPT-4,
+	// GPT-3.5)
+	TokenizerCL100K
+	// TokenizerO200K counts tokens of the o200k_base encoding (GPT-4o)
+	TokenizerO200K
+)
+
+func (t Tokenizer) String() string {
+	switch t {
+	case TokenizerApprox:
+		return "approx"
+	case TokenizerCL100K:
+		return "cl100k"
+	case TokenizerO200K:
+		return "o200k"
+	default:
+		return "unknown"
+	}
+}
+
+func ParseTokenizer(s string) (Tokenizer, error) {
+	switch s {
+	case "approx":
+		return TokenizerApprox, nil
+	case "cl100k":
+		return TokenizerCL100K, nil
+	case "o200k":
+		return TokenizerO200K, nil
+	default:
+		return 0, fmt.Errorf("invalid tokenizer: %q (expected approx, cl100k or o200k)", s)
+	}
+}
+
+// SetTokenizer sets how token usage is counted, defaults to TokenizerApprox
+func (o *GenAIOptions) SetTokenizer(tokenizer Tokenizer) {
+	o.tokenizer = tokenizer
+}
+
+// The encodings load their merge tables on first use, which takes tens of
+// megabytes, so runs using approx never pay for them
+var (
+	cl100kCodec = sync.OnceValue(codec.NewCl100kBase)
+	o200kCodec  = sync.OnceValue(codec.NewO200kBase)
+)
+
+// codec returns the BPE encoding of the tokenizer, nil for approx
+func (t Tokenizer) codec() *codec.Codec {
+	switch t {
+	case TokenizerCL100K:
+		return cl100kCodec()
+	case TokenizerO200K:
+		return o200kCodec()
+	default:
+		return nil
+	}
+}
+
+// count returns the number of tokens of texts
+func (t Tokenizer) count(texts ...string) int {
+	c := t.codec()
+
+	n, bytes := 0, 0
+	for _, s := range texts {
+		if c != nil {
+			if tokens, err := c.Count(s); err == nil {
+				n += tokens
+				continue
+			}
+		}
+		// Without an encoding, or if s can't be encoded, a token is 4 bytes
+		bytes += len(s)
+	}
+	return n + bytes/4
+}
+
+// countMessages returns the number of tokens of the content, tool results and
+// tool arguments of msgs
+func (t Tokenizer) countMessages(msgs []Message) int {
+	texts := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		for _, part := range msg.Parts {
+			texts = append(texts, part.Content, part.Result, part.Arguments)
+		}
+	}
+	return t.count(texts...)
+}
@@ -0,0 +1,79 @@
+package genai
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTokenizer_CL100K(t *testing.T) {
+	tests := []struct {
+		text   string
+		tokens int
+	}{
+		{"Hello, world!", 4},
+		{"The quick brown fox jumps over the lazy dog.", 10},
+		{`func main() { fmt.Println("hi") }`, 10},
+		{"12345678", 3},
+		{"", 0},
+	}
+
+	for _, tt := range tests {
+		if got := TokenizerCL100K.count(tt.text); got != tt.tokens {
+			t.Errorf("count(%q) = %d, want %d", tt.text, got, tt.tokens)
+		}
+	}
+}
+
+func TestTokenizer_Approx(t *testing.T) {
+	if got := TokenizerApprox.count("0123456789", "ab"); got != 3 {
+		t.Errorf("Expected a token per 4 bytes across texts, got %d", got)
+	}
+}
+
+func TestTokenizer_NonEnglish(t *testing.T) {
+	// cl100k takes more than a token per Han character, more than the 3
+	// UTF-8 bytes per character divided by 4, and o200k is more compact
+	text := strings.Repeat("你好世界", 10)
+	cl100k, o200k := TokenizerCL100K.count(text), TokenizerO200K.count(text)
+	if cl100k != 50 {
+		t.Errorf("Expected 50 cl100k tokens for 40 Han characters, got %d", cl100k)
+	}
+	if o200k >= cl100k {
+		t.Errorf("Expected fewer o200k tokens than cl100k, got %d and %d", o200k, cl100k)
+	}
+	if approx := TokenizerApprox.count(text); approx == cl100k {
+		t.Errorf("Expected the byte approximation to differ, got %d", approx)
+	}
+}
+
+func TestGenAISpanFromEntry_Tokenizer(t *testing.T) {
+	sentence := "The quick brown fox jumps over the lazy dog."
+	entry := &Entry{Conversations: []Conversation{
+		{From: "human", Value: sentence + " " + sentence},
+		{From: "gpt", Value: strings.Repeat(sentence+" ", 3)},
+	}}
+
+	opts := chatOnlyOptions(t)
+	opts.SetTokenizer(TokenizerCL100K)
+	attrs, _ := GenAISpanFromEntry(entry, opts)
+
+	if got := findAttr(attrs, "gen_ai.usage.input_tokens").GetIntValue(); got != 20 {
+		t.Errorf("Expected 20 input tokens, got %d", got)
+	}
+	// The trailing space is a token of its own
+	if got := findAttr(attrs, "gen_ai.usage.output_tokens").GetIntValue(); got != 31 {
+		t.Errorf("Expected 31 output tokens, got %d", got)
+	}
+}
+
+func TestParseTokenizer(t *testing.T) {
+	for _, tok := range []Tokenizer{TokenizerApprox, TokenizerCL100K, TokenizerO200K} {
+		got, err := ParseTokenizer(tok.String())
+		if err != nil || got != tok {
+			t.Errorf("ParseTokenizer(%q) = %v, %v", tok.String(), got, err)
+		}
+	}
+	if _, err := ParseTokenizer("p50k"); err == nil {
+		t.Error("Expected an error for an unknown tokenizer")
+	}
+}
@@ -0,0 +1,128 @@
+package genai
+
+import (
+	"encoding/json"
+	"fmt"
+
+	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+	otlpTraces "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// ToolEmit selects how the tool calls of a conversation are represented on gen_ai spans
+type ToolEmit int
+
+const (
+	// ToolEmitAttrs keeps tool calls and their results in the gen_ai.input.messages attribute
+	ToolEmitAttrs ToolEmit = iota
+	// ToolEmitEvents moves tool calls out of the message attributes into span events
+	ToolEmitEvents
+)
+
+func (t ToolEmit) String() string {
+	switch t {
+	case ToolEmitAttrs:
+		return "attrs"
+	case ToolEmitEvents:
+		return "events"
+	default:
+		return "unknown"
+	}
+}
+
+func ParseToolEmit(s string) (ToolEmit, error) {
+	switch s {
+	case "attrs":
+		return ToolEmitAttrs, nil
+	case "events":
+		return ToolEmitEvents, nil
+	default:
+		return 0, fmt.Errorf("invalid gen_ai tool emit mode: %q (expected attrs or events)", s)
+	}
+}
+
+// ToolCall is a tool execution of a conversation: the call and its result
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments string
+	Result    string
+}
+
+// toolCalls pairs the tool calls of the messages with their responses
+func toolCalls(messages []Message) []ToolCall {
+	calls := make([]ToolCall, 0)
+	byID := make(map[string]int)
+
+	for _, msg := range messages {
+		for _, part := range msg.Parts {
+			switch part.Type {
+			case "tool_call":
+				byID[part.ID] = len(calls)
+				calls = append(calls, ToolCall{ID: part.ID, Name: part.Name, Arguments: part.Arguments})
+			case "tool_call_response":
+				if i, ok := byID[part.ID]; ok {
+					calls[i].Result = part.Result
+				}
+			}
+		}
+	}
+
+	return calls
+}
+
+// withoutToolMessages returns the messages without tool calls and tool responses
+func withoutToolMessages(messages []Message) []Message {
+	result := make([]Message, 0, len(messages))
+	for _, msg := range messages {
+		parts := make([]MessagePart, 0, len(msg.Parts))
+		for _, part := range msg.Parts {
+			if part.Type != "tool_call" && part.Type != "tool_call_response" {
+				parts = append(parts, part)
+			}
+		}
+		if len(parts) > 0 {
+			msg.Parts = parts
+			result = append(result, msg)
+		}
+	}
+	return result
+}
+
+// ToolCallEvents converts tool calls to gen_ai.tool.message span events. Timestamps
+// are left unset for the caller to fill in.
+func ToolCallEvents(calls []ToolCall) []*otlpTraces.Span_Event {
+	events := make([]*otlpTraces.Span_Event, 0, len(calls))
+	for _, call := range calls {
+		attrs := []*otlpCommon.KeyValue{
+			stringAttr("gen_ai.tool.call.id", call.ID),
+			stringAttr("gen_ai.tool.name", call.Name),
+			stringAttr("gen_ai.tool.type", "function"),
+		}
+		if call.Arguments != "" {
+			attrs = append(attrs, stringAttr("gen_ai.tool.call.arguments", call.Arguments))
+		}
+		if call.Result != "" {
+			attrs = append(attrs, stringAttr("gen_ai.tool.call.result", call.Result))
+		}
+
+		events = append(events, &otlpTraces.Span_Event{
+			Name:       EventToolMessage,
+			Attributes: attrs,
+		})
+	}
+	return events
+}
+
+// parseFunctionCall extracts the tool name and arguments of a corpus function
+// call, which is formatted as {"name": ..., "arguments": {...}}
+func parseFunctionCall(value string) (name string, arguments string) {
+	var call struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal([]byte(value), &call); err != nil || call.Name == "" {
+		return "function_name", value
+	}
+
+	return call.Name, string(call.Arguments)
+}
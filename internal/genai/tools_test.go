@@ -0,0 +1,136 @@
+package genai
+
+import (
+	"testing"
+
+	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func toolCallEntry() *Entry {
+	return &Entry{
+		Conversations: []Conversation{
+			{From: "human", Value: "What's the weather in Paris and Rome?"},
+			{From: "function_call", Value: `{"name": "get_weather", "arguments": {"location": "Paris"}}`},
+			{From: "observation", Value: `{"temp": "22C"}`},
+			{From: "function_call", Value: `{"name": "get_forecast", "arguments": {"location": "Rome"}}`},
+			{From: "observation", Value: `{"temp": "25C"}`},
+			{From: "gpt", Value: "It is 22°C in Paris and 25°C in Rome."},
+		},
+	}
+}
+
+func TestGenAISpanFromEntry_ToolEvents(t *testing.T) {
+	opts := chatOnlyOptions(t)
+	opts.SetToolEmit(ToolEmitEvents)
+
+	attrs, events := GenAISpanFromEntry(toolCallEntry(), opts)
+
+	expected := []struct {
+		name      string
+		arguments string
+		result    string
+	}{
+		{"get_weather", `{"location": "Paris"}`, `{"temp": "22C"}`},
+		{"get_forecast", `{"location": "Rome"}`, `{"temp": "25C"}`},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d tool events, got %d", len(expected), len(events))
+	}
+	for i, exp := range expected {
+		event := events[i]
+		if event.Name != EventToolMessage {
+			t.Errorf("Expected event name %s, got %s", EventToolMessage, event.Name)
+		}
+		if name := getStringValue(findAttr(event.Attributes, "gen_ai.tool.name")); name != exp.name {
+			t.Errorf("Expected tool name %q, got %q", exp.name, name)
+		}
+		if args := getStringValue(findAttr(event.Attributes, "gen_ai.tool.call.arguments")); args != exp.arguments {
+			t.Errorf("Expected tool arguments %q, got %q", exp.arguments, args)
+		}
+		if result := getStringValue(findAttr(event.Attributes, "gen_ai.tool.call.result")); result != exp.result {
+			t.Errorf("Expected tool result %q, got %q", exp.result, result)
+		}
+		if id := getStringValue(findAttr(event.Attributes, "gen_ai.tool.call.id")); id == "" {
+			t.Error("Expected tool call id to be set")
+		}
+	}
+
+	// Tool calls are emitted instead of being part of the input messages
+	input := findAttr(attrs, "gen_ai.input.messages").GetArrayValue().GetValues()
+	if len(input) != 1 {
+		t.Fatalf("Expected only the user message in the input messages, got %d", len(input))
+	}
+	if role := getStringValue(findInKvlist(getKvlist(input[0]), "role")); role != "user" {
+		t.Errorf("Expected user message, got role %q", role)
+	}
+}
+
+func TestGenAISpanFromEntry_ToolAttrs(t *testing.T) {
+	attrs, events := GenAISpanFromEntry(toolCallEntry(), chatOnlyOptions(t))
+
+	if len(events) != 0 {
+		t.Errorf("Expected no tool events, got %d", len(events))
+	}
+
+	input := findAttr(attrs, "gen_ai.input.messages").GetArrayValue().GetValues()
+	if len(input) != 5 {
+		t.Fatalf("Expected tool calls in the input messages, got %d messages", len(input))
+	}
+	parts := findInKvlist(getKvlist(input[1]), "parts").GetArrayValue().GetValues()
+	if name := getStringValue(findInKvlist(getKvlist(parts[0]), "name")); name != "get_weather" {
+		t.Errorf("Expected tool call name 'get_weather', got %q", name)
+	}
+}
+
+func TestParseToolEmit(t *testing.T) {
+	for _, emit := range []ToolEmit{ToolEmitAttrs, ToolEmitEvents} {
+		got, err := ParseToolEmit(emit.String())
+		if err != nil || got != emit {
+			t.Errorf("Expected %s to round trip, got %s (%v)", emit, got, err)
+		}
+	}
+
+	if _, err := ParseToolEmit("spans"); err == nil {
+		t.Error("Expected error for unknown mode")
+	}
+}
+
+func TestToolJSONToOTel_Deterministic(t *testing.T) {
+	params := `{"type":"object","properties":{"unit":{"type":"string"},"location":{"type":"string"},"days":{"type":"integer"}}}`
+	tools := parseToolDefinitions(`[{"name":"get_forecast","description":"Forecast","parameters":` + params + `}]`)
+	if len(tools) != 1 {
+		t.Fatalf("Expected 1 tool definition, got %d", len(tools))
+	}
+	inputs, _ := convertConversationsToOTelFormat([]Conversation{
+		{From: "human", Value: "Forecast for Rome?"},
+		{From: "function_call", Value: `{"name":"get_forecast","arguments":{"unit":"c","location":"Rome","days":3}}`},
+	}, defaultRand)
+
+	// Tool JSON is carried as the raw text, never decoded into a map, so
+	// repeated conversions keep the key order of the input
+	first := []*otlpCommon.AnyValue{tools[0].ToOTel(), MessagesToOTel(inputs)}
+	for i := 0; i < 20; i++ {
+		again := []*otlpCommon.AnyValue{parseToolDefinitions(`[{"name":"get_forecast","description":"Forecast","parameters":` + params + `}]`)[0].ToOTel(), MessagesToOTel(inputs)}
+		for j := range first {
+			if !proto.Equal(first[j], again[j]) {
+				t.Fatalf("Expected identical output across conversions, got %v and %v", first[j], again[j])
+			}
+		}
+	}
+
+	if got := getStringValue(findInKvlist(getKvlist(first[0]), "parameters")); got != params {
+		t.Errorf("Expected parameters %s as given, got %s", params, got)
+	}
+	var arguments string
+	for _, msg := range inputs {
+		for _, part := range msg.Parts {
+			if part.Type == "tool_call" {
+				arguments = part.Arguments
+			}
+		}
+	}
+	if want := `{"unit":"c","location":"Rome","days":3}`; arguments != want {
+		t.Errorf("Expected arguments %s as given, got %s", want, arguments)
+	}
+}
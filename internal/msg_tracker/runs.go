@@ -0,0 +1,58 @@
+package msg_tracker
+
+import "sort"
+
+// ackedRun is an interval [start, end) of message IDs that are all acked
+type ackedRun struct {
+	start, end uint64
+}
+
+// ackedRuns is a run-length encoding of the reaped ranges of a generator,
+// sorted by start ID. Late acks to them are duplicates. Adjacent runs are
+// merged, so a generator whose messages all arrive needs a single run however
+// long it runs.
+type ackedRuns []ackedRun
+
+// add marks the IDs from start up to end as acked
+func (runs *ackedRuns) add(start, end uint64) {
+	rs := *runs
+
+	// The first run that ends at or after start can be merged with the new run
+	i := sort.Search(len(rs), func(i int) bool { return rs[i].end >= start })
+	j := i
+	for j < len(rs) && rs[j].start <= end {
+		start = min(start, rs[j].start)
+		end = max(end, rs[j].end)
+		j++
+	}
+
+	merged := append(rs[:i:i], ackedRun{start: start, end: end})
+	*runs = append(merged, rs[j:]...)
+}
+
+// find returns the index of the run containing id
+func (runs ackedRuns) find(id uint64) (int, bool) {
+	i := sort.Search(len(runs), func(i int) bool { return runs[i].end > id })
+	return i, i < len(runs) && runs[i].start <= id
+}
+
+// contains reports whether id is acked
+func (runs ackedRuns) contains(id uint64) bool {
+	_, ok := runs.find(id)
+	return ok
+}
+
+// covers reports whether every ID from start up to end is acked
+func (runs ackedRuns) covers(start, end uint64) bool {
+	i, ok := runs.find(start)
+	return ok && runs[i].end >= end
+}
+
+// intersecting returns the first run holding any ID from start up to end
+func (runs ackedRuns) intersecting(start, end uint64) (ackedRun, bool) {
+	i := sort.Search(len(runs), func(i int) bool { return runs[i].end > start })
+	if i < len(runs) && runs[i].start < end {
+		return runs[i], true
+	}
+	return ackedRun{}, false
+}
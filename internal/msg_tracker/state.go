@@ -0,0 +1,329 @@
+package msg_tracker
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The state format is the magic and version followed by each generator. All
+// integers are uvarints except timestamps, which are varint Unix nanos with 0
+// for unset, and bitmap words, which are little endian uint64s.
+//
+//	generator: id, total acked, total duped, likely lost, last active,
+//	           range count, ranges, merged count, (from, into) pairs,
+//	           run count, (start, end) pairs
+//	range:     start id, range len, timestamp, acked, duplicates, overdue,
+//	           dropped, bitmap word count, bitmap words
+const (
+	stateMagic   = "OLGT"
+	stateVersion = 1
+)
+
+// Snapshot writes the state of every generator to w, so it can be restored
+// after a restart. No ranges are added while the snapshot is taken and each
+// range is copied under its lock.
+func (t *Tracker) Snapshot(w io.Writer) error {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	enc := &stateEncoder{w: bufio.NewWriter(w)}
+	enc.bytes([]byte(stateMagic))
+	enc.uvarint(stateVersion)
+	enc.uvarint(uint64(len(t.generators)))
+
+	for id, gt := range t.generators {
+		gt.mu.RLock()
+		enc.string(id)
+		enc.uvarint(gt.totalAcked.Load())
+		enc.uvarint(gt.totalDuped.Load())
+		enc.uvarint(gt.likelyLost.Load())
+		enc.varint(gt.lastActive.Load())
+
+		enc.uvarint(uint64(len(gt.ranges)))
+		for start, r := range gt.ranges {
+			enc.uvarint(start)
+			r.encode(enc)
+		}
+
+		enc.uvarint(uint64(len(gt.merged)))
+		for from, into := range gt.merged {
+			enc.uvarint(from)
+			enc.uvarint(into)
+		}
+
+		enc.uvarint(uint64(len(gt.runs)))
+		for _, run := range gt.runs {
+			enc.uvarint(run.start)
+			enc.uvarint(run.end)
+		}
+		gt.mu.RUnlock()
+	}
+
+	if enc.err != nil {
+		return enc.err
+	}
+	return enc.w.Flush()
+}
+
+func (mr *MessageRange) encode(enc *stateEncoder) {
+	mr.RLock()
+	defer mr.RUnlock()
+
+	enc.uvarint(mr.StartID)
+	enc.uvarint(uint64(mr.RangeLen))
+	enc.time(mr.Timestamp)
+	enc.uvarint(uint64(mr.AckedCount))
+	enc.uvarint(uint64(mr.DuplicateCount))
+	enc.bool(mr.overdue)
+	enc.uvarint(mr.dropped)
+	enc.uvarint(uint64(len(mr.bitmap)))
+	for _, word := range mr.bitmap {
+		enc.uint64(word)
+	}
+}
+
+// Restore replaces the state of the tracker with a snapshot read from r
+func (t *Tracker) Restore(r io.Reader) error {
+	dec := &stateDecoder{r: bufio.NewReader(r)}
+
+	magic := dec.bytes(len(stateMagic))
+	if dec.err == nil && string(magic) != stateMagic {
+		return fmt.Errorf("not a tracker state file")
+	}
+	if version := dec.uvarint(); dec.err == nil && version != stateVersion {
+		return fmt.Errorf("unsupported tracker state version %d", version)
+	}
+
+	numGenerators := dec.uvarint()
+	generators := make(map[string]*generatorTracker)
+	for i := uint64(0); i < numGenerators && dec.err == nil; i++ {
+		id := dec.string()
+		gt := &generatorTracker{
+			ranges:        make(map[uint64]*MessageRange),
+			merged:        make(map[uint64]uint64),
+			maxEagerWords: t.cfg.maxEagerBitmapWords(),
+		}
+		gt.totalAcked.Store(dec.uvarint())
+		gt.totalDuped.Store(dec.uvarint())
+		gt.likelyLost.Store(dec.uvarint())
+		gt.lastActive.Store(dec.varint())
+
+		numRanges := dec.uvarint()
+		for j := uint64(0); j < numRanges && dec.err == nil; j++ {
+			// The key is the start ID the range also holds
+			dec.uvarint()
+			gt.putRange(decodeRange(dec))
+		}
+
+		numMerged := dec.uvarint()
+		for j := uint64(0); j < numMerged && dec.err == nil; j++ {
+			from := dec.uvarint()
+			gt.merged[from] = dec.uvarint()
+		}
+
+		numRuns := dec.uvarint()
+		for j := uint64(0); j < numRuns && dec.err == nil; j++ {
+			start, end := dec.uvarint(), dec.uvarint()
+			if end <= start {
+				dec.fail(fmt.Errorf("invalid acked run %d to %d", start, end))
+				break
+			}
+			gt.runs.add(start, end)
+		}
+
+		generators[id] = gt
+	}
+
+	if dec.err != nil {
+		return fmt.Errorf("failed to read tracker state: %w", dec.err)
+	}
+
+	t.mu.Lock()
+	t.generators = generators
+	t.mu.Unlock()
+
+	return nil
+}
+
+func decodeRange(dec *stateDecoder) *MessageRange {
+	mr := &MessageRange{
+		StartID:        dec.uvarint(),
+		RangeLen:       uint(dec.uvarint()),
+		Timestamp:      dec.time(),
+		AckedCount:     uint(dec.uvarint()),
+		DuplicateCount: uint(dec.uvarint()),
+		overdue:        dec.bool(),
+		dropped:        dec.uvarint(),
+	}
+
+	// The bitmap isn't resized when a range is shortened and large bitmaps are
+	// allocated lazily, so it may be larger or smaller than the range. Grow it
+	// as words are read rather than trusting the count.
+	words := dec.uvarint()
+	mr.bitmap = make([]uint64, 0, min(words, 1024))
+	for i := uint64(0); i < words && dec.err == nil; i++ {
+		mr.bitmap = append(mr.bitmap, dec.uint64())
+	}
+	return mr
+}
+
+// SaveFile writes a snapshot to path, replacing it atomically
+func (t *Tracker) SaveFile(path string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := t.Snapshot(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// RestoreFile restores a snapshot written by SaveFile, it returns an error
+// satisfying os.IsNotExist if there's no snapshot at path
+func (t *Tracker) RestoreFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return t.Restore(f)
+}
+
+// stateEncoder writes the state format, keeping the first error
+type stateEncoder struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func (e *stateEncoder) bytes(b []byte) {
+	if e.err == nil {
+		_, e.err = e.w.Write(b)
+	}
+}
+
+func (e *stateEncoder) uvarint(v uint64) {
+	e.bytes(e.buf[:binary.PutUvarint(e.buf[:], v)])
+}
+
+func (e *stateEncoder) varint(v int64) {
+	e.bytes(e.buf[:binary.PutVarint(e.buf[:], v)])
+}
+
+func (e *stateEncoder) uint64(v uint64) {
+	e.bytes(binary.LittleEndian.AppendUint64(e.buf[:0], v))
+}
+
+func (e *stateEncoder) string(s string) {
+	e.uvarint(uint64(len(s)))
+	e.bytes([]byte(s))
+}
+
+func (e *stateEncoder) bool(b bool) {
+	if b {
+		e.uvarint(1)
+	} else {
+		e.uvarint(0)
+	}
+}
+
+func (e *stateEncoder) time(ts time.Time) {
+	if ts.IsZero() {
+		e.varint(0)
+		return
+	}
+	e.varint(ts.UnixNano())
+}
+
+// stateDecoder reads the state format, keeping the first error. Values read
+// after an error are zero.
+type stateDecoder struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (d *stateDecoder) bytes(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		d.fail(err)
+		return nil
+	}
+	return b
+}
+
+func (d *stateDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(d.r)
+	d.fail(err)
+	return v
+}
+
+func (d *stateDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(d.r)
+	d.fail(err)
+	return v
+}
+
+func (d *stateDecoder) uint64() uint64 {
+	b := d.bytes(8)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+// maxStateString bounds generator IDs read from a state file
+const maxStateString = 1 << 16
+
+func (d *stateDecoder) string() string {
+	n := d.uvarint()
+	if n > maxStateString {
+		d.fail(fmt.Errorf("string of %d bytes is too long", n))
+		return ""
+	}
+	return string(d.bytes(int(n)))
+}
+
+func (d *stateDecoder) bool() bool {
+	return d.uvarint() != 0
+}
+
+func (d *stateDecoder) time() time.Time {
+	nanos := d.varint()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+func (d *stateDecoder) fail(err error) {
+	if err == nil || d.err != nil {
+		return
+	}
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	d.err = err
+}
@@ -0,0 +1,134 @@
+package msg_tracker
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestTracker_SnapshotRestore(t *testing.T) {
+	tracker := NewTrackerWithConfig(Config{CompactAfter: time.Minute}, zap.NewNop())
+	now := time.Now().Round(0)
+	tracker.now = func() time.Time { return now }
+
+	for start := uint64(0); start < 1024; start += 256 {
+		tracker.AddRange("gen1", start, 256, now.Add(-time.Hour))
+		for id := start; id < start+256; id++ {
+			if id != 300 && id != 900 {
+				tracker.Ack("gen1", start, 256, id)
+			}
+		}
+	}
+	tracker.Ack("gen1", 0, 256, 5)
+	tracker.AddRange("gen2", 0, 100, now)
+	tracker.Ack("gen2", 0, 100, 42)
+	tracker.Ack("gen2", 500, 10, 505) // range without a timestamp
+
+	// Compaction merges the ranges of gen1, the start IDs must survive a restore
+	if merged, _ := tracker.Compact(); merged != 3 {
+		t.Fatalf("Expected 3 merged ranges, got %d", merged)
+	}
+
+	var buf bytes.Buffer
+	if err := tracker.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewTracker(zap.NewNop())
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, gen := range []string{"gen1", "gen2"} {
+		before := tracker.GeneratorReport(now)[gen]
+		after := restored.GeneratorReport(now)[gen]
+		if before != after {
+			t.Errorf("%s: expected report %+v after restore, got %+v", gen, before, after)
+		}
+	}
+	for id := uint64(0); id < 1024; id++ {
+		start := id / 256 * 256
+		if restored.isAcked("gen1", start, 256, id) != tracker.isAcked("gen1", start, 256, id) {
+			t.Errorf("Expected isAcked(%d) to match after restore", id)
+		}
+	}
+	if !restored.isAcked("gen2", 500, 10, 505) || restored.isAcked("gen2", 0, 100, 41) {
+		t.Error("Expected gen2 acks to be restored")
+	}
+
+	// Acks continue where the snapshot left off
+	restored.Ack("gen1", 768, 256, 900)
+	restored.Ack("gen1", 256, 256, 256)
+	report := restored.GeneratorReport(now)["gen1"]
+	if report.Unacked != 1 || report.TotalDuped != 2 {
+		t.Errorf("Expected 1 unacked and 2 duplicates after acking, got %+v", report)
+	}
+}
+
+func TestTracker_SnapshotRestoreRuns(t *testing.T) {
+	tracker := NewTracker(zap.NewNop())
+	old := time.Now().Add(-time.Hour)
+	for start := uint64(0); start < 30; start += 10 {
+		tracker.AddRange("gen1", start, 10, old)
+		for id := start; id < start+10; id++ {
+			tracker.Ack("gen1", start, 10, id)
+		}
+	}
+	tracker.Reap(time.Now())
+
+	var buf bytes.Buffer
+	if err := tracker.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewTracker(zap.NewNop())
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	runs := tracker.generators["gen1"].runs
+	if restoredRuns := restored.generators["gen1"].runs; len(runs) == 0 || !reflect.DeepEqual(restoredRuns, runs) {
+		t.Errorf("Expected acked runs %v to be restored, got %v", runs, restoredRuns)
+	}
+	if !restored.isAcked("gen1", 10, 10, 15) {
+		t.Error("Expected reaped messages to be acked after restore")
+	}
+}
+
+func TestTracker_SaveRestoreFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	tracker := NewTracker(zap.NewNop())
+	tracker.AddRange("gen1", 0, 10, time.Now())
+	tracker.Ack("gen1", 0, 10, 3)
+
+	if err := tracker.SaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewTracker(zap.NewNop())
+	if err := restored.RestoreFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if !restored.isAcked("gen1", 0, 10, 3) {
+		t.Error("Expected ack to be restored from the file")
+	}
+}
+
+func TestTracker_RestoreInvalid(t *testing.T) {
+	tracker := NewTracker(zap.NewNop())
+	tracker.AddRange("gen1", 0, 10, time.Now())
+
+	var buf bytes.Buffer
+	if err := tracker.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewTracker(zap.NewNop()).Restore(bytes.NewReader(buf.Bytes()[:buf.Len()-3])); err == nil {
+		t.Error("Expected a truncated snapshot to fail")
+	}
+	if err := NewTracker(zap.NewNop()).Restore(bytes.NewReader([]byte("not a snapshot"))); err == nil {
+		t.Error("Expected an invalid snapshot to fail")
+	}
+}
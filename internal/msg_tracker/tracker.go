@@ -1,7 +1,10 @@
 package msg_tracker
 
 import (
+	"errors"
 	"fmt"
+	"math/bits"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -9,6 +12,10 @@ import (
 	"go.uber.org/zap"
 )
 
+// ErrOverlappingRange is returned when a range intersects the IDs of another
+// range from the same generator, such as a restarted generator reusing IDs
+var ErrOverlappingRange = errors.New("range overlaps an existing range")
+
 // MessageRange represents a range of message IDs with a bitmask for tracking acknowledgments
 type MessageRange struct {
 	sync.RWMutex
@@ -18,6 +25,13 @@ type MessageRange struct {
 	AckedCount     uint     // Number of unique messages acked
 	DuplicateCount uint     // Number of duplicate acks received
 	bitmap         []uint64 // Each uint64 holds 64 bits
+	overdue        bool     // Unacked messages outlived the ack timeout, reported once
+	// dropped is the number of leading messages released from the bitmap by
+	// compaction, they are all acked. It is always a multiple of 64.
+	dropped uint64
+	// mergedInto is set once compaction merged this range into the preceding
+	// range, acks are forwarded to it
+	mergedInto *MessageRange
 }
 
 // GeneratorReport contains statistics for a single generator
@@ -26,15 +40,33 @@ type GeneratorReport struct {
 	TotalAcked       uint
 	TotalDuped       uint
 	OldestUnackedAge time.Time
+	// LikelyLost counts messages still unacked when their range exceeded the ack timeout
+	LikelyLost uint
+	// DuplicateErrors counts duplicates received in exactly-once mode
+	DuplicateErrors uint
 }
 
+// defaultMaxEagerBitmapWords is the largest bitmap allocated up front when
+// Config.MaxEagerBitmapWords is unset, covering ranges of 4096 messages
+const defaultMaxEagerBitmapWords = 64
+
 // NewMessageRange creates a new message range
 func NewMessageRange(startID uint64, rangeLen uint) *MessageRange {
+	return newMessageRange(startID, rangeLen, defaultMaxEagerBitmapWords)
+}
+
+// newMessageRange creates a new message range, allocating the bitmap up front
+// if it needs at most maxEagerWords words. Larger bitmaps grow as higher
+// offsets are acked, so huge ranges that are only acked at the start stay small.
+func newMessageRange(startID uint64, rangeLen uint, maxEagerWords int) *MessageRange {
 	if rangeLen == 0 {
 		panic("range length must be > 0")
 	}
 
 	bitmapSize := (rangeLen + 63) / 64 // Round up to nearest 64
+	if maxEagerWords >= 0 && bitmapSize > uint(maxEagerWords) {
+		bitmapSize = 0
+	}
 
 	return &MessageRange{
 		StartID:  startID,
@@ -55,6 +87,10 @@ func (mr *MessageRange) Ack(msgID uint64) (AckedResult, bool) {
 	var result AckedResult
 
 	mr.Lock()
+	if into := mr.mergedInto; into != nil {
+		mr.Unlock()
+		return into.Ack(msgID)
+	}
 	defer mr.Unlock()
 
 	if !mr.contains(msgID) {
@@ -63,14 +99,14 @@ func (mr *MessageRange) Ack(msgID uint64) (AckedResult, bool) {
 	}
 
 	offset := msgID - mr.StartID
-	idx := offset / 64
-	bit := offset % 64
 
 	// Check if already acked
-	wasAlreadyAcked := (mr.bitmap[idx] & (1 << bit)) != 0
+	wasAlreadyAcked := mr.isSet(offset)
 
 	// Set the bit
-	mr.bitmap[idx] |= (1 << bit)
+	if !wasAlreadyAcked {
+		mr.set(offset)
+	}
 
 	// Update counters
 	if wasAlreadyAcked {
@@ -87,17 +123,60 @@ func (mr *MessageRange) Ack(msgID uint64) (AckedResult, bool) {
 // IsAcked checks if a message ID has been acknowledged
 func (mr *MessageRange) IsAcked(msgID uint64) bool {
 	mr.RLock()
+	if into := mr.mergedInto; into != nil {
+		mr.RUnlock()
+		return into.IsAcked(msgID)
+	}
 	defer mr.RUnlock()
 
 	if !mr.contains(msgID) {
 		return false
 	}
 
-	offset := msgID - mr.StartID
+	return mr.isSet(msgID - mr.StartID)
+}
+
+// isSet reports whether the message at offset from StartID is acked, must be
+// called with the lock held
+func (mr *MessageRange) isSet(offset uint64) bool {
+	if offset < mr.dropped {
+		return true
+	}
+	offset -= mr.dropped
+
+	idx := offset / 64
+	if idx >= uint64(len(mr.bitmap)) {
+		return false
+	}
+	return (mr.bitmap[idx] & (1 << (offset % 64))) != 0
+}
+
+// set marks the message at offset from StartID as acked, growing the bitmap
+// if it isn't allocated that far yet. Must be called with the write lock held.
+func (mr *MessageRange) set(offset uint64) {
+	if offset < mr.dropped {
+		return
+	}
+	offset -= mr.dropped
+
 	idx := offset / 64
-	bit := offset % 64
+	if idx >= uint64(len(mr.bitmap)) {
+		mr.grow(idx + 1)
+	}
+	mr.bitmap[idx] |= 1 << (offset % 64)
+}
+
+// grow extends the bitmap to at least words words, doubling it to amortize
+// the copies but never past the end of the range
+func (mr *MessageRange) grow(words uint64) {
+	size := max(words, 2*uint64(len(mr.bitmap)))
+	if limit := (uint64(mr.RangeLen) - mr.dropped + 63) / 64; size > limit {
+		size = max(limit, words)
+	}
 
-	return (mr.bitmap[idx] & (1 << bit)) != 0
+	bitmap := make([]uint64, size)
+	copy(bitmap, mr.bitmap)
+	mr.bitmap = bitmap
 }
 
 // contains checks if the range contains the given message ID (internal helper)
@@ -151,6 +230,235 @@ func (mr *MessageRange) UpdateRangeLen(rangeLen uint) {
 	mr.RangeLen = rangeLen
 }
 
+// markOverdue flags the range as overdue if it is older than timestamp and
+// has unacked messages, returning the unacked count. A range is only flagged once.
+func (mr *MessageRange) markOverdue(timestamp time.Time) (uint, bool) {
+	mr.Lock()
+	defer mr.Unlock()
+
+	if mr.overdue || mr.Timestamp.IsZero() || !mr.Timestamp.Before(timestamp) {
+		return 0, false
+	}
+
+	unacked := mr.RangeLen - min(mr.AckedCount, mr.RangeLen)
+	if unacked == 0 {
+		return 0, false
+	}
+
+	mr.overdue = true
+	return unacked, true
+}
+
+// dropAckedPrefix releases the leading bitmap words whose messages are all
+// acked, returning the number of words released
+func (mr *MessageRange) dropAckedPrefix() int {
+	mr.Lock()
+	defer mr.Unlock()
+
+	n := 0
+	for n < len(mr.bitmap) {
+		start := mr.dropped + uint64(n)*64
+		if start >= uint64(mr.RangeLen) {
+			break
+		}
+
+		full := ^uint64(0)
+		if remaining := uint64(mr.RangeLen) - start; remaining < 64 {
+			full = 1<<remaining - 1
+		}
+		if mr.bitmap[n] != full {
+			break
+		}
+		n++
+	}
+
+	if n == 0 {
+		return 0
+	}
+
+	// Copy so the released words can be collected
+	mr.bitmap = append([]uint64(nil), mr.bitmap[n:]...)
+	mr.dropped += uint64(n) * 64
+	return n
+}
+
+// canAbsorb reports whether next directly follows the range and both together
+// are mostly acked
+func (mr *MessageRange) canAbsorb(next *MessageRange) bool {
+	mr.RLock()
+	end := mr.StartID + uint64(mr.RangeLen)
+	total := mr.RangeLen
+	acked := min(mr.AckedCount, mr.RangeLen)
+	overdue := mr.overdue
+	mr.RUnlock()
+
+	next.RLock()
+	defer next.RUnlock()
+
+	if next.StartID != end || next.overdue != overdue {
+		return false
+	}
+	total += next.RangeLen
+	acked += min(next.AckedCount, next.RangeLen)
+
+	return (total-acked)*100 <= total*mergeMaxUnackedPercent
+}
+
+// absorb merges next, which must directly follow the range, into the range.
+// Acks to next are forwarded afterwards.
+func (mr *MessageRange) absorb(next *MessageRange) {
+	mr.Lock()
+	defer mr.Unlock()
+	next.Lock()
+	defer next.Unlock()
+
+	base := uint64(mr.RangeLen)
+	mr.RangeLen += next.RangeLen
+
+	// The bitmap grows as the acks of next are copied
+	for offset := uint64(0); offset < uint64(next.RangeLen); offset++ {
+		if next.isSet(offset) {
+			mr.set(base + offset)
+		}
+	}
+
+	mr.AckedCount += next.AckedCount
+	mr.DuplicateCount += next.DuplicateCount
+	// Keep the newer timestamp so the absorbed messages aren't reported as
+	// unacked early
+	if next.Timestamp.After(mr.Timestamp) {
+		mr.Timestamp = next.Timestamp
+	}
+
+	next.mergedInto = mr
+	next.bitmap = nil
+}
+
+// ForEachUnacked calls fn with each unacked message ID of the range in
+// ascending order. The bitmap is scanned a word at a time, so acked runs are
+// skipped without testing each ID. fn is called with the read lock held and
+// must not call back into the range.
+func (mr *MessageRange) ForEachUnacked(fn func(msgID uint64)) {
+	mr.forEachUnacked(func(msgID uint64) bool {
+		fn(msgID)
+		return true
+	})
+}
+
+// forEachUnacked is ForEachUnacked, stopping once fn returns false
+func (mr *MessageRange) forEachUnacked(fn func(msgID uint64) bool) {
+	mr.RLock()
+	if into := mr.mergedInto; into != nil {
+		start, end := mr.StartID, mr.StartID+uint64(mr.RangeLen)
+		mr.RUnlock()
+
+		// The range's IDs are part of the range it was merged into
+		into.forEachUnacked(func(msgID uint64) bool {
+			if msgID >= end {
+				return false
+			}
+			return msgID < start || fn(msgID)
+		})
+		return
+	}
+	defer mr.RUnlock()
+
+	rangeLen := uint64(mr.RangeLen)
+	for i, base := 0, mr.dropped; base < rangeLen; i, base = i+1, base+64 {
+		// Unallocated words have no acks
+		unset := ^uint64(0)
+		if i < len(mr.bitmap) {
+			unset = ^mr.bitmap[i]
+		}
+		if remaining := rangeLen - base; remaining < 64 {
+			unset &= 1<<remaining - 1
+		}
+
+		for unset != 0 {
+			if !fn(mr.StartID + base + uint64(bits.TrailingZeros64(unset))) {
+				return
+			}
+			unset &= unset - 1
+		}
+	}
+}
+
+// unackedIDs appends up to limit unacked message IDs of the range to ids
+func (mr *MessageRange) unackedIDs(ids []uint64, limit int) []uint64 {
+	if len(ids) >= limit {
+		return ids
+	}
+
+	mr.forEachUnacked(func(msgID uint64) bool {
+		ids = append(ids, msgID)
+		return len(ids) < limit
+	})
+	return ids
+}
+
+// AckedBounds returns the lowest and highest acked message ID of the range,
+// any is false if no message has been acked
+func (mr *MessageRange) AckedBounds() (min, max uint64, any bool) {
+	mr.RLock()
+	if into := mr.mergedInto; into != nil {
+		mr.RUnlock()
+		return into.AckedBounds()
+	}
+	defer mr.RUnlock()
+
+	// Only bits within the range count, the bitmap isn't resized when the
+	// range is shortened
+	var limit uint64
+	if uint64(mr.RangeLen) > mr.dropped {
+		limit = uint64(mr.RangeLen) - mr.dropped
+	}
+	words := (limit + 63) / 64
+	if words > uint64(len(mr.bitmap)) {
+		words = uint64(len(mr.bitmap))
+	}
+
+	first, last := -1, -1
+	for i := uint64(0); i < words; i++ {
+		if mr.bitmap[i]&wordMask(i, limit) != 0 {
+			if first < 0 {
+				first = int(i)
+			}
+			last = int(i)
+		}
+	}
+
+	// The dropped prefix is all acked
+	switch {
+	case first < 0 && mr.dropped == 0:
+		return 0, 0, false
+	case first < 0:
+		return mr.StartID, mr.StartID + mr.dropped - 1, true
+	}
+
+	lastWord := mr.bitmap[last] & wordMask(uint64(last), limit)
+	max = mr.StartID + mr.dropped + uint64(last)*64 + uint64(63-bits.LeadingZeros64(lastWord))
+	if mr.dropped > 0 {
+		return mr.StartID, max, true
+	}
+	return mr.StartID + uint64(first)*64 + uint64(bits.TrailingZeros64(mr.bitmap[first])), max, true
+}
+
+// wordMask masks the bits of bitmap word i that are below limit
+func wordMask(i, limit uint64) uint64 {
+	if remaining := limit - i*64; remaining < 64 {
+		return 1<<remaining - 1
+	}
+	return ^uint64(0)
+}
+
+// bitmapLen returns the number of bitmap words held by the range
+func (mr *MessageRange) bitmapLen() int {
+	mr.RLock()
+	defer mr.RUnlock()
+
+	return len(mr.bitmap)
+}
+
 func (mr *MessageRange) OlderThan(timestamp time.Time) bool {
 	mr.RLock()
 	defer mr.RUnlock()
@@ -158,48 +466,157 @@ func (mr *MessageRange) OlderThan(timestamp time.Time) bool {
 	return !mr.Timestamp.IsZero() && mr.Timestamp.Before(timestamp)
 }
 
+// mergeMaxUnackedPercent is the share of unacked messages up to which
+// contiguous ranges are merged by compaction
+const mergeMaxUnackedPercent = 10
+
 // generatorTracker holds all ranges for a specific generator ID
 type generatorTracker struct {
 	mu         sync.RWMutex
 	totalAcked atomic.Uint64
 	totalDuped atomic.Uint64
+	likelyLost atomic.Uint64
+	dupErrors  atomic.Uint64            // Duplicates received in exactly-once mode, not persisted
+	lastActive atomic.Int64             // Unix nanos of the last ack or range update
 	ranges     map[uint64]*MessageRange // Key is startID, we assume ranges are unique
+	starts     []uint64                 // Start IDs of ranges in ascending order
+	merged     map[uint64]uint64        // Start IDs of ranges merged by compaction to the start ID they were merged into
+	runs       ackedRuns                // Reaped ranges, late acks to them are duplicates
+	// maxEagerWords is the largest bitmap allocated up front for new ranges
+	maxEagerWords int
+}
+
+func newGeneratorTracker(now time.Time, maxEagerWords int) *generatorTracker {
+	gt := &generatorTracker{
+		ranges:        make(map[uint64]*MessageRange),
+		merged:        make(map[uint64]uint64),
+		maxEagerWords: maxEagerWords,
+	}
+	gt.touch(now)
+	return gt
+}
+
+func (gt *generatorTracker) touch(now time.Time) {
+	gt.lastActive.Store(now.UnixNano())
+}
+
+// findRange finds the range with the given start ID, or the range it was
+// merged into by compaction
+func (gt *generatorTracker) findRange(startRangeID uint64) (*MessageRange, bool) {
+	for {
+		into, merged := gt.merged[startRangeID]
+		if !merged {
+			break
+		}
+		startRangeID = into
+	}
+	r, exists := gt.ranges[startRangeID]
+	return r, exists
 }
 
-func newGeneratorTracker() *generatorTracker {
-	return &generatorTracker{
-		ranges: make(map[uint64]*MessageRange),
+// putRange adds r to the ranges, keeping the start IDs sorted
+func (gt *generatorTracker) putRange(r *MessageRange) {
+	i := sort.Search(len(gt.starts), func(i int) bool { return gt.starts[i] >= r.StartID })
+	gt.starts = append(gt.starts, 0)
+	copy(gt.starts[i+1:], gt.starts[i:])
+	gt.starts[i] = r.StartID
+	gt.ranges[r.StartID] = r
+}
+
+// deleteRange removes the range with the given start ID
+func (gt *generatorTracker) deleteRange(startID uint64) {
+	i := sort.Search(len(gt.starts), func(i int) bool { return gt.starts[i] >= startID })
+	if i < len(gt.starts) && gt.starts[i] == startID {
+		gt.starts = append(gt.starts[:i], gt.starts[i+1:]...)
 	}
+	delete(gt.ranges, startID)
 }
 
-// findRange finds the range containing the given message ID
-func (gt *generatorTracker) findRange(startRangeID uint64) *MessageRange {
-	return gt.ranges[startRangeID]
+// overlapping returns a range that intersects the IDs of a new range. A range
+// with the same start ID and length is the same range, not an overlap, while
+// adjacent ranges never intersect.
+func (gt *generatorTracker) overlapping(startID uint64, rangeLen uint) *MessageRange {
+	// Tracked ranges never intersect each other, so only the last range
+	// starting at or before startID and the range after it can intersect
+	i := sort.Search(len(gt.starts), func(i int) bool { return gt.starts[i] > startID })
+	for _, j := range []int{i - 1, i} {
+		if j < 0 || j >= len(gt.starts) {
+			continue
+		}
+
+		r := gt.ranges[gt.starts[j]]
+		r.RLock()
+		rStart, rLen := r.StartID, r.RangeLen
+		r.RUnlock()
+
+		if rStart == startID && rLen == rangeLen {
+			continue
+		}
+		if startID < rStart+uint64(rLen) && rStart < startID+uint64(rangeLen) {
+			return r
+		}
+	}
+	return nil
 }
 
 // addRange adds a new range or returns the existing range if it already exists
-func (gt *generatorTracker) addRange(startID uint64, rangeLen uint) *MessageRange {
-	if r, exists := gt.ranges[startID]; exists {
-		return r
+func (gt *generatorTracker) addRange(startID uint64, rangeLen uint) (*MessageRange, error) {
+	if r, exists := gt.findRange(startID); exists {
+		return r, nil
+	}
+	if r := gt.overlapping(startID, rangeLen); r != nil {
+		return nil, overlapError(r)
+	}
+	if run, ok := gt.runs.intersecting(startID, startID+uint64(rangeLen)); ok {
+		return nil, run.overlapError()
 	}
 
-	r := NewMessageRange(startID, rangeLen)
-	gt.ranges[startID] = r
-	return r
+	r := newMessageRange(startID, rangeLen, gt.maxEagerWords)
+	gt.putRange(r)
+	return r, nil
 }
 
 // addRangeWithTimestamp adds a new range with timestamp, or updates timestamp if range exists
-func (gt *generatorTracker) addRangeWithTimestamp(startID uint64, rangeLen uint, timestamp time.Time) *MessageRange {
+func (gt *generatorTracker) addRangeWithTimestamp(startID uint64, rangeLen uint, timestamp time.Time) (*MessageRange, error) {
+	// A merged range already has the timestamp of the newest range it holds
+	if _, merged := gt.merged[startID]; merged {
+		r, _ := gt.findRange(startID)
+		return r, nil
+	}
+
+	if r := gt.overlapping(startID, rangeLen); r != nil {
+		return nil, overlapError(r)
+	}
+
 	if r, exists := gt.ranges[startID]; exists {
 		r.UpdateTimestamp(timestamp)
-		r.Timestamp = timestamp
-		return r
+		return r, nil
+	}
+
+	// A reaped range was fully acked, there's nothing left to track
+	end := startID + uint64(rangeLen)
+	if gt.runs.covers(startID, end) {
+		return nil, nil
+	}
+	if run, ok := gt.runs.intersecting(startID, end); ok {
+		return nil, run.overlapError()
 	}
 
-	r := NewMessageRange(startID, rangeLen)
+	r := newMessageRange(startID, rangeLen, gt.maxEagerWords)
 	r.Timestamp = timestamp
-	gt.ranges[startID] = r
-	return r
+	gt.putRange(r)
+	return r, nil
+}
+
+func overlapError(r *MessageRange) error {
+	r.RLock()
+	defer r.RUnlock()
+
+	return fmt.Errorf("%w: start_id %d, range_len %d", ErrOverlappingRange, r.StartID, r.RangeLen)
+}
+
+func (run ackedRun) overlapError() error {
+	return fmt.Errorf("%w: reaped acked IDs %d to %d", ErrOverlappingRange, run.start, run.end-1)
 }
 
 // unackedOlderThan returns the total number of unacked messages in ranges older than the given timestamp and the oldest timestamp
@@ -223,69 +640,324 @@ func (gt *generatorTracker) unackedOlderThan(timestamp time.Time) (uint, time.Ti
 	return total, oldestTime
 }
 
+// compact merges contiguous mostly-acked ranges older than timestamp and drops
+// the acked prefixes of their bitmaps. Returns the number of merged ranges and
+// released bitmap words. Must be called with the write lock held.
+func (gt *generatorTracker) compact(timestamp time.Time) (int, int) {
+	// Ranges are deleted while iterating
+	starts := append([]uint64(nil), gt.starts...)
+
+	before := 0
+	for _, r := range gt.ranges {
+		before += r.bitmapLen()
+	}
+
+	merged := 0
+	var prev *MessageRange
+	var prevStart uint64
+	for _, start := range starts {
+		r := gt.ranges[start]
+		if !r.OlderThan(timestamp) {
+			prev = nil
+			continue
+		}
+
+		if prev != nil && prev.canAbsorb(r) {
+			prev.absorb(r)
+			gt.deleteRange(start)
+			gt.merged[start] = prevStart
+			merged++
+			continue
+		}
+		prev, prevStart = r, start
+	}
+
+	after := 0
+	for _, r := range gt.ranges {
+		if r.OlderThan(timestamp) {
+			r.dropAckedPrefix()
+		}
+		after += r.bitmapLen()
+	}
+
+	return merged, before - after
+}
+
+// reap drops the fully acked ranges older than timestamp and adds their IDs to
+// the acked runs, returning the number dropped. Must be called with the write
+// lock held.
+func (gt *generatorTracker) reap(timestamp time.Time) int {
+	reaped := make(map[uint64]struct{})
+	for start, r := range gt.ranges {
+		if r.OlderThan(timestamp) && r.UnackedCount() == 0 {
+			reaped[start] = struct{}{}
+		}
+	}
+	if len(reaped) == 0 {
+		return 0
+	}
+
+	// Drop the start IDs of ranges merged into the reaped ranges as well
+	for from := range gt.merged {
+		into := from
+		for {
+			next, merged := gt.merged[into]
+			if !merged {
+				break
+			}
+			into = next
+		}
+		if _, ok := reaped[into]; ok {
+			delete(gt.merged, from)
+		}
+	}
+
+	for start := range reaped {
+		r := gt.ranges[start]
+		r.RLock()
+		gt.runs.add(r.StartID, r.StartID+uint64(r.RangeLen))
+		r.RUnlock()
+		gt.deleteRange(start)
+	}
+	return len(reaped)
+}
+
 func (gt *generatorTracker) ackedCount() uint {
 	var total uint
 	for _, r := range gt.ranges {
 		total += r.TotalAckedCount()
 	}
+	for _, run := range gt.runs {
+		total += uint(run.end - run.start)
+	}
 	return total
 }
 
+// EvictionPolicy determines what happens when a new generator would exceed
+// the maximum number of tracked generators
+type EvictionPolicy int
+
+const (
+	// EvictionReject ignores messages and ranges from new generators
+	EvictionReject EvictionPolicy = iota
+	// EvictionLRU drops the least-recently-active generator to make room
+	EvictionLRU
+)
+
+func ParseEvictionPolicy(s string) (EvictionPolicy, error) {
+	switch s {
+	case "reject":
+		return EvictionReject, nil
+	case "lru":
+		return EvictionLRU, nil
+	default:
+		return 0, fmt.Errorf("invalid eviction policy: %q (expected reject or lru)", s)
+	}
+}
+
+// DeliveryGuarantee determines how duplicate messages are accounted
+type DeliveryGuarantee int
+
+const (
+	// DeliveryAtLeastOnce expects duplicates, they are only counted
+	DeliveryAtLeastOnce DeliveryGuarantee = iota
+	// DeliveryExactlyOnce treats every duplicate as an error
+	DeliveryExactlyOnce
+)
+
+func ParseDeliveryGuarantee(s string) (DeliveryGuarantee, error) {
+	switch s {
+	case "at-least-once":
+		return DeliveryAtLeastOnce, nil
+	case "exactly-once":
+		return DeliveryExactlyOnce, nil
+	default:
+		return 0, fmt.Errorf("invalid delivery guarantee: %q (expected at-least-once or exactly-once)", s)
+	}
+}
+
+// Config holds the tracker settings
+type Config struct {
+	// MaxGenerators limits the number of tracked generators, 0 is unlimited
+	MaxGenerators  int
+	EvictionPolicy EvictionPolicy
+	// AckTimeout is how long a range may have unacked messages before they are
+	// reported as likely lost, 0 disables the check
+	AckTimeout time.Duration
+	// CompactAfter is the age after which ranges are compacted: contiguous
+	// mostly-acked ranges are merged and acked prefixes released, bounding the
+	// memory of generators that never fully ack. 0 disables compaction.
+	CompactAfter time.Duration
+	// MaxEagerBitmapWords is the largest ack bitmap, in 64 message words,
+	// allocated when a range is added. Bitmaps of larger ranges grow as acks
+	// arrive. 0 uses the default of 64 words, negative allocates every bitmap
+	// up front.
+	MaxEagerBitmapWords int
+	// Delivery is the guarantee expected of every generator. In exactly-once
+	// mode duplicates are also counted as errors.
+	Delivery DeliveryGuarantee
+	// LogDuplicates logs the ID of each duplicate counted as an error
+	LogDuplicates bool
+}
+
+// maxEagerBitmapWords returns the largest bitmap allocated up front, negative
+// for no limit
+func (c Config) maxEagerBitmapWords() int {
+	if c.MaxEagerBitmapWords == 0 {
+		return defaultMaxEagerBitmapWords
+	}
+	return c.MaxEagerBitmapWords
+}
+
 // Tracker is the main message tracking service
 type Tracker struct {
 	mu         sync.RWMutex
 	log        *zap.Logger
+	cfg        Config
 	generators map[string]*generatorTracker
+	rejected   map[string]struct{} // Generators rejected due to MaxGenerators, warned once
+	now        func() time.Time
 }
 
 // NewTracker creates a new message tracker
 func NewTracker(log *zap.Logger) *Tracker {
+	return NewTrackerWithConfig(Config{}, log)
+}
+
+// NewTrackerWithConfig creates a new message tracker with the given settings
+func NewTrackerWithConfig(cfg Config, log *zap.Logger) *Tracker {
 	return &Tracker{
 		log:        log,
+		cfg:        cfg,
 		generators: make(map[string]*generatorTracker),
+		rejected:   make(map[string]struct{}),
+		now:        time.Now,
 	}
 }
 
-// Ack acknowledges a message ID within a specific range for a generator
-func (t *Tracker) Ack(generatorID string, startRangeID uint64, rangeLen uint, msgID uint64) bool {
+// getOrCreateGenerator returns the tracker for generatorID, creating it if needed.
+// Returns nil if the generator was rejected because the tracker is at capacity.
+func (t *Tracker) getOrCreateGenerator(generatorID string) *generatorTracker {
+	now := t.now()
+
 	// Fast path: read lock to check if generator exists
 	t.mu.RLock()
 	gt, exists := t.generators[generatorID]
 	t.mu.RUnlock()
 
-	if !exists {
-		// Need to create generator tracker
-		t.mu.Lock()
-		// Double-check after acquiring write lock
-		gt, exists = t.generators[generatorID]
-		if !exists {
-			gt = newGeneratorTracker()
-			t.generators[generatorID] = gt
+	if exists {
+		gt.touch(now)
+		return gt
+	}
+
+	// Need to create generator tracker
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Double-check after acquiring write lock
+	gt, exists = t.generators[generatorID]
+	if exists {
+		gt.touch(now)
+		return gt
+	}
+
+	if t.cfg.MaxGenerators > 0 && len(t.generators) >= t.cfg.MaxGenerators {
+		switch t.cfg.EvictionPolicy {
+		case EvictionReject:
+			if _, warned := t.rejected[generatorID]; !warned {
+				t.rejected[generatorID] = struct{}{}
+				t.log.Warn("rejecting generator, maximum number of generators reached",
+					zap.String("generator_id", generatorID),
+					zap.Int("max_generators", t.cfg.MaxGenerators))
+			}
+			return nil
+		case EvictionLRU:
+			t.evictLeastRecentlyActive()
+		}
+	}
+
+	gt = newGeneratorTracker(now, t.cfg.maxEagerBitmapWords())
+	t.generators[generatorID] = gt
+	return gt
+}
+
+// evictLeastRecentlyActive drops the generator with the oldest activity, must
+// be called with the write lock held
+func (t *Tracker) evictLeastRecentlyActive() {
+	var oldestID string
+	var oldestActive int64
+	for id, gt := range t.generators {
+		active := gt.lastActive.Load()
+		if oldestID == "" || active < oldestActive {
+			oldestID = id
+			oldestActive = active
 		}
-		t.mu.Unlock()
+	}
+
+	if oldestID == "" {
+		return
+	}
+
+	delete(t.generators, oldestID)
+	t.log.Warn("evicted least recently active generator, maximum number of generators reached",
+		zap.String("generator_id", oldestID),
+		zap.Time("last_active", time.Unix(0, oldestActive)),
+		zap.Int("max_generators", t.cfg.MaxGenerators))
+}
+
+// Ack acknowledges a message ID within a specific range for a generator
+func (t *Tracker) Ack(generatorID string, startRangeID uint64, rangeLen uint, msgID uint64) bool {
+	gt := t.getOrCreateGenerator(generatorID)
+	if gt == nil {
+		return false
 	}
 
 	// Lock the generator tracker
 	gt.mu.RLock()
 
 	// Find or create the range
-	r, exists := gt.ranges[startRangeID]
+	r, exists := gt.findRange(startRangeID)
+	reaped := false
 	if !exists {
 		// Upgrade to write lock
 		gt.mu.RUnlock()
 
+		var err error
 		gt.mu.Lock()
-		r = gt.addRange(startRangeID, rangeLen)
+		if gt.runs.contains(msgID) {
+			reaped = true
+		} else {
+			r, err = gt.addRange(startRangeID, rangeLen)
+		}
 		gt.mu.Unlock()
+		if err != nil {
+			t.log.Debug("rejected ack for overlapping range",
+				zap.String("generator_id", generatorID),
+				zap.Uint64("start_id", startRangeID),
+				zap.Uint("range_len", rangeLen),
+				zap.Error(err))
+			return false
+		}
 	} else {
 		gt.mu.RUnlock()
 	}
 
-	// Ack the message
-	result, success := r.Ack(msgID)
+	// Ack the message, a message of a reaped range was already acked
+	result, success := AckedResult{Dup: true}, true
+	if !reaped {
+		result, success = r.Ack(msgID)
+	}
 	if success {
 		if result.Dup {
 			gt.totalDuped.Add(1)
+			if t.cfg.Delivery == DeliveryExactlyOnce {
+				gt.dupErrors.Add(1)
+				if t.cfg.LogDuplicates {
+					t.log.Warn("duplicate message in exactly-once mode",
+						zap.String("generator_id", generatorID),
+						zap.Uint64("msg_id", msgID))
+				}
+			}
 		} else if result.Acked {
 			gt.totalAcked.Add(1)
 		}
@@ -296,22 +968,11 @@ func (t *Tracker) Ack(generatorID string, startRangeID uint64, rangeLen uint, ms
 
 // AddRange adds a message range for a generator without acking any messages
 // The timestamp is recorded for the range. If the range already exists, the timestamp is updated.
-func (t *Tracker) AddRange(generatorID string, startRangeID uint64, rangeLen uint, timestamp time.Time) {
-	// Fast path: read lock to check if generator exists
-	t.mu.RLock()
-	gt, exists := t.generators[generatorID]
-	t.mu.RUnlock()
-
-	if !exists {
-		// Need to create generator tracker
-		t.mu.Lock()
-		// Double-check after acquiring write lock
-		gt, exists = t.generators[generatorID]
-		if !exists {
-			gt = newGeneratorTracker()
-			t.generators[generatorID] = gt
-		}
-		t.mu.Unlock()
+// A range that overlaps another range of the generator is rejected with ErrOverlappingRange.
+func (t *Tracker) AddRange(generatorID string, startRangeID uint64, rangeLen uint, timestamp time.Time) error {
+	gt := t.getOrCreateGenerator(generatorID)
+	if gt == nil {
+		return nil
 	}
 
 	// Lock the generator tracker
@@ -319,7 +980,24 @@ func (t *Tracker) AddRange(generatorID string, startRangeID uint64, rangeLen uin
 	defer gt.mu.Unlock()
 
 	// Add the range with timestamp (or update timestamp if it exists)
-	gt.addRangeWithTimestamp(startRangeID, rangeLen, timestamp)
+	r, err := gt.addRangeWithTimestamp(startRangeID, rangeLen, timestamp)
+	if err != nil {
+		t.log.Warn("rejected overlapping range",
+			zap.String("generator_id", generatorID),
+			zap.Uint64("start_id", startRangeID),
+			zap.Uint("range_len", rangeLen),
+			zap.Error(err))
+		return err
+	}
+	if r == nil {
+		// Messages of a generator restarted with the same ID and message IDs
+		// would count as duplicates of the reaped range
+		t.log.Warn("ignored range that was already acked and reaped, generator may have restarted",
+			zap.String("generator_id", generatorID),
+			zap.Uint64("start_id", startRangeID),
+			zap.Uint("range_len", rangeLen))
+	}
+	return nil
 }
 
 // UpdateRange updates the length of an existing message range. This is typically called when
@@ -335,17 +1013,23 @@ func (t *Tracker) UpdateRange(generatorID string, startRangeID uint64, rangeLen
 		t.log.Warn("attempt to update a range for unknown generator ID")
 		return
 	}
+	gt.touch(t.now())
 
 	gt.mu.RLock()
-	r, exists := gt.ranges[startRangeID]
+	r, exists := gt.findRange(startRangeID)
+	reaped := gt.runs.contains(startRangeID)
 	gt.mu.RUnlock()
 
 	if !exists {
-		t.log.Warn("attempt to update a range that does not exist")
+		// A reaped range was fully acked, shortening it changes nothing
+		if !reaped {
+			t.log.Warn("attempt to update a range that does not exist")
+		}
 		return
 	}
 
-	r.UpdateRangeLen(rangeLen)
+	// A range merged by compaction is truncated from its own start
+	r.UpdateRangeLen(uint(startRangeID-r.StartID) + rangeLen)
 }
 
 // isAcked checks if a message ID has been acknowledged
@@ -362,14 +1046,26 @@ func (t *Tracker) isAcked(generatorID string, startRangeID uint64, rangeLen uint
 	gt.mu.RLock()
 	defer gt.mu.RUnlock()
 
-	r, exists := gt.ranges[startRangeID]
+	r, exists := gt.findRange(startRangeID)
 	if !exists {
-		return false
+		return gt.runs.contains(msgID)
 	}
 
 	return r.IsAcked(msgID)
 }
 
+// TotalAcked returns the number of unique messages acked across all generators
+func (t *Tracker) TotalAcked() uint64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var total uint64
+	for _, gt := range t.generators {
+		total += gt.totalAcked.Load()
+	}
+	return total
+}
+
 // only called from tests
 func (t *Tracker) ackedCount() map[string]uint {
 	result := make(map[string]uint)
@@ -409,8 +1105,181 @@ func (t *Tracker) GeneratorReport(timestamp time.Time) map[string]GeneratorRepor
 			TotalAcked:       uint(gt.totalAcked.Load()),
 			TotalDuped:       uint(gt.totalDuped.Load()),
 			OldestUnackedAge: oldestTime,
+			LikelyLost:       uint(gt.likelyLost.Load()),
+			DuplicateErrors:  uint(gt.dupErrors.Load()),
 		}
 	}
 
 	return result
 }
+
+// CheckAckTimeouts warns about ranges whose unacked messages have exceeded the
+// ack timeout and counts those messages as likely lost. Each range is reported
+// at most once, even if its messages are acked later. Returns the number of
+// messages newly counted as likely lost.
+func (t *Tracker) CheckAckTimeouts() uint {
+	if t.cfg.AckTimeout <= 0 {
+		return 0
+	}
+	deadline := t.now().Add(-t.cfg.AckTimeout)
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var total uint
+	for generatorID, gt := range t.generators {
+		gt.mu.RLock()
+		for startID, r := range gt.ranges {
+			unacked, overdue := r.markOverdue(deadline)
+			if !overdue {
+				continue
+			}
+
+			t.log.Warn("messages not acked within the ack timeout, likely lost",
+				zap.String("generator_id", generatorID),
+				zap.Uint64("start_id", startID),
+				zap.Uint("range_len", r.TotalMessages()),
+				zap.Uint("unacked", unacked),
+				zap.Duration("ack_timeout", t.cfg.AckTimeout))
+
+			gt.likelyLost.Add(uint64(unacked))
+			total += unacked
+		}
+		gt.mu.RUnlock()
+	}
+
+	return total
+}
+
+// Compact compacts the ranges older than the CompactAfter age of every
+// generator. Returns the number of merged ranges and released bitmap bytes.
+func (t *Tracker) Compact() (int, int) {
+	if t.cfg.CompactAfter <= 0 {
+		return 0, 0
+	}
+	olderThan := t.now().Add(-t.cfg.CompactAfter)
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var merged, freedWords int
+	for _, gt := range t.generators {
+		gt.mu.Lock()
+		m, w := gt.compact(olderThan)
+		gt.mu.Unlock()
+
+		merged += m
+		freedWords += w
+	}
+
+	if merged > 0 || freedWords > 0 {
+		t.log.Debug("compacted message ranges",
+			zap.Int("merged", merged),
+			zap.Int("freed_bytes", freedWords*8))
+	}
+
+	return merged, freedWords * 8
+}
+
+// Reap drops the ranges of every generator whose messages are all acked and
+// whose timestamp is older than olderThan, releasing their bitmaps. Their IDs
+// are kept as runs of acked IDs, contiguous ranges sharing a run, so late acks
+// are still counted as duplicates rather than recreating the range. Returns the
+// number of ranges dropped.
+func (t *Tracker) Reap(olderThan time.Time) int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	total := 0
+	for _, gt := range t.generators {
+		gt.mu.Lock()
+		total += gt.reap(olderThan)
+		gt.mu.Unlock()
+	}
+
+	if total > 0 {
+		t.log.Debug("reaped fully acked message ranges", zap.Int("ranges", total))
+	}
+	return total
+}
+
+// UnackedIDs returns up to limit message IDs of the generator that haven't
+// been acked, in ascending order. Recent ranges are included, so their
+// messages may still be in flight.
+func (t *Tracker) UnackedIDs(generatorID string, limit int) []uint64 {
+	t.mu.RLock()
+	gt, exists := t.generators[generatorID]
+	t.mu.RUnlock()
+
+	if !exists || limit <= 0 {
+		return nil
+	}
+
+	gt.mu.RLock()
+	defer gt.mu.RUnlock()
+
+	var ids []uint64
+	for _, start := range gt.starts {
+		if len(ids) >= limit {
+			break
+		}
+		ids = gt.ranges[start].unackedIDs(ids, limit)
+	}
+	return ids
+}
+
+// RangeInfo describes the ack state of a single message range
+type RangeInfo struct {
+	StartID        uint64
+	RangeLen       uint
+	AckedCount     uint
+	DuplicateCount uint
+	// MinAckedID and MaxAckedID are only set when AnyAcked is true
+	MinAckedID uint64
+	MaxAckedID uint64
+	AnyAcked   bool
+}
+
+// Ranges returns the ranges of a generator ordered by start ID, for debugging
+func (t *Tracker) Ranges(generatorID string) []RangeInfo {
+	t.mu.RLock()
+	gt, exists := t.generators[generatorID]
+	t.mu.RUnlock()
+
+	if !exists {
+		return nil
+	}
+
+	gt.mu.RLock()
+	defer gt.mu.RUnlock()
+
+	infos := make([]RangeInfo, 0, len(gt.ranges))
+	for _, r := range gt.ranges {
+		minID, maxID, anyAcked := r.AckedBounds()
+
+		r.RLock()
+		infos = append(infos, RangeInfo{
+			StartID:        r.StartID,
+			RangeLen:       r.RangeLen,
+			AckedCount:     r.AckedCount,
+			DuplicateCount: r.DuplicateCount,
+			MinAckedID:     minID,
+			MaxAckedID:     maxID,
+			AnyAcked:       anyAcked,
+		})
+		r.RUnlock()
+	}
+	// Reaped runs are reported as fully acked ranges
+	for _, run := range gt.runs {
+		infos = append(infos, RangeInfo{
+			StartID:    run.start,
+			RangeLen:   uint(run.end - run.start),
+			AckedCount: uint(run.end - run.start),
+			MinAckedID: run.start,
+			MaxAckedID: run.end - 1,
+			AnyAcked:   true,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].StartID < infos[j].StartID })
+	return infos
+}
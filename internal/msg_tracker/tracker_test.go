@@ -1,11 +1,15 @@
 package msg_tracker
 
 import (
+	"errors"
+	"reflect"
 	"sync"
 	"testing"
 	"time"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestMessageRange_NewAndAck(t *testing.T) {
@@ -182,6 +186,119 @@ func TestTracker_AddRange(t *testing.T) {
 	}
 }
 
+func TestTracker_AddRangeOverlap(t *testing.T) {
+	tracker := NewTracker(zap.NewNop())
+	now := time.Now()
+
+	if err := tracker.AddRange("gen1", 100, 100, now); err != nil {
+		t.Fatalf("Expected first range to be added, got %v", err)
+	}
+
+	// Adjacent ranges and the same range again are accepted
+	for _, r := range []struct{ start, len uint64 }{{0, 100}, {200, 50}, {100, 100}} {
+		if err := tracker.AddRange("gen1", r.start, uint(r.len), now); err != nil {
+			t.Errorf("Expected range %d+%d to be accepted, got %v", r.start, r.len, err)
+		}
+	}
+
+	// Ranges intersecting an existing range are rejected
+	for _, r := range []struct{ start, len uint64 }{{100, 50}, {150, 100}, {50, 60}, {120, 10}, {0, 300}} {
+		err := tracker.AddRange("gen1", r.start, uint(r.len), now)
+		if !errors.Is(err, ErrOverlappingRange) {
+			t.Errorf("Expected range %d+%d to overlap, got %v", r.start, r.len, err)
+		}
+	}
+
+	// Other generators have their own IDs
+	if err := tracker.AddRange("gen2", 100, 50, now); err != nil {
+		t.Errorf("Expected range for another generator to be accepted, got %v", err)
+	}
+
+	// Acks can't create an overlapping range either
+	if tracker.Ack("gen1", 150, 100, 160) {
+		t.Error("Expected ack for an overlapping range to be rejected")
+	}
+	if ranges := tracker.Ranges("gen1"); len(ranges) != 3 {
+		t.Errorf("Expected 3 ranges, got %+v", ranges)
+	}
+}
+
+// Overlaps are found from the neighbours of the sorted start IDs, which must
+// follow ranges merged by compaction and dropped by reaping
+func TestTracker_AddRangeOverlapSortedStarts(t *testing.T) {
+	tracker := NewTrackerWithConfig(Config{CompactAfter: time.Minute}, zap.NewNop())
+	now := time.Now()
+	tracker.now = func() time.Time { return now }
+
+	// Ranges are added out of order, the first three are mostly acked and
+	// merged by compaction, the last is fully acked and reaped
+	for _, start := range []uint64{300, 0, 200, 100, 500} {
+		tracker.AddRange("gen1", start, 100, now.Add(-time.Hour))
+	}
+	for id := uint64(0); id < 300; id++ {
+		if id != 150 {
+			tracker.Ack("gen1", id/100*100, 100, id)
+		}
+	}
+	for id := uint64(500); id < 600; id++ {
+		tracker.Ack("gen1", 500, 100, id)
+	}
+	tracker.Compact()
+	tracker.Reap(now.Add(-time.Minute))
+
+	gt := tracker.generators["gen1"]
+	if want := []uint64{0, 300}; !reflect.DeepEqual(gt.starts, want) {
+		t.Fatalf("Expected start IDs %v, got %v", want, gt.starts)
+	}
+
+	// The merged range now spans 0 to 300, the predecessor of a new range
+	// starting inside it is found. A range sticking out of the reaped IDs
+	// overlaps them too.
+	for _, r := range []struct{ start, len uint64 }{{250, 10}, {290, 20}, {350, 10}, {590, 20}} {
+		if err := tracker.AddRange("gen1", r.start, uint(r.len), now); !errors.Is(err, ErrOverlappingRange) {
+			t.Errorf("Expected range %d+%d to overlap, got %v", r.start, r.len, err)
+		}
+	}
+	if err := tracker.AddRange("gen1", 400, 100, now); err != nil {
+		t.Errorf("Expected the gap between ranges to be accepted, got %v", err)
+	}
+	if want := []uint64{0, 300, 400}; !reflect.DeepEqual(gt.starts, want) {
+		t.Errorf("Expected start IDs %v, got %v", want, gt.starts)
+	}
+}
+
+func TestTracker_DeliveryGuarantee(t *testing.T) {
+	for _, tc := range []struct {
+		delivery   DeliveryGuarantee
+		wantErrors uint
+	}{
+		{DeliveryAtLeastOnce, 0},
+		{DeliveryExactlyOnce, 2},
+	} {
+		core, logs := observer.New(zapcore.WarnLevel)
+		tracker := NewTrackerWithConfig(Config{Delivery: tc.delivery, LogDuplicates: true}, zap.New(core))
+		tracker.AddRange("gen1", 0, 10, time.Now())
+		for _, id := range []uint64{1, 2, 1, 1} {
+			tracker.Ack("gen1", 0, 10, id)
+		}
+
+		report := tracker.GeneratorReport(time.Now())["gen1"]
+		if report.TotalDuped != 2 || report.TotalAcked != 2 {
+			t.Errorf("Expected 2 acked and 2 duped with delivery %d, got %+v", tc.delivery, report)
+		}
+		if report.DuplicateErrors != tc.wantErrors {
+			t.Errorf("Expected %d duplicate errors with delivery %d, got %d", tc.wantErrors, tc.delivery, report.DuplicateErrors)
+		}
+		if logged := logs.FilterField(zap.Uint64("msg_id", 1)).Len(); logged != int(tc.wantErrors) {
+			t.Errorf("Expected %d duplicates logged with delivery %d, got %d", tc.wantErrors, tc.delivery, logged)
+		}
+	}
+
+	if _, err := ParseDeliveryGuarantee("at-most-once"); err == nil {
+		t.Error("Expected an invalid delivery guarantee to be rejected")
+	}
+}
+
 func TestTracker_Concurrency(t *testing.T) {
 	tracker := NewTracker(zap.NewNop())
 
@@ -245,6 +362,54 @@ func TestTracker_ConcurrentGenerators(t *testing.T) {
 	}
 }
 
+// Run with -race, the range's timestamp must only be written under its lock
+func TestTracker_ConcurrentTimestampUpdates(t *testing.T) {
+	tracker := NewTracker(zap.NewNop())
+	start := time.Now()
+	tracker.AddRange("gen1", 0, 1000, start)
+
+	gt := tracker.generators["gen1"]
+	r := gt.ranges[0]
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			tracker.AddRange("gen1", 0, 1000, start.Add(time.Duration(i)*time.Millisecond))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		// Only the range lock is held while reading
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				r.GetTimestamp()
+				r.OlderThan(start)
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for id := uint64(0); id < 1000; id++ {
+			tracker.Ack("gen1", 0, 1000, id)
+		}
+	}()
+	wg.Wait()
+
+	if got := r.GetTimestamp(); !got.Equal(start.Add(999 * time.Millisecond)) {
+		t.Errorf("Expected the last timestamp to win, got %v", got)
+	}
+	if r.UnackedCount() != 0 {
+		t.Errorf("Expected all messages to be acked, got %d unacked", r.UnackedCount())
+	}
+}
+
 func TestTracker_LargeRange(t *testing.T) {
 	tracker := NewTracker(zap.NewNop())
 
@@ -811,3 +976,653 @@ func TestTracker_UpdateRange_AffectsUnackedCount(t *testing.T) {
 		t.Errorf("Expected TotalAcked to remain 100, got %d", reports["gen1"].TotalAcked)
 	}
 }
+
+func TestTracker_MaxGenerators_Reject(t *testing.T) {
+	tracker := NewTrackerWithConfig(Config{MaxGenerators: 2, EvictionPolicy: EvictionReject}, zap.NewNop())
+
+	tracker.AddRange("gen1", 0, 100, time.Now())
+	tracker.AddRange("gen2", 0, 100, time.Now())
+
+	if !tracker.Ack("gen1", 0, 100, 1) {
+		t.Error("Expected ack for existing gen1 to succeed")
+	}
+
+	// Third generator should be rejected
+	tracker.AddRange("gen3", 0, 100, time.Now())
+	if tracker.Ack("gen3", 0, 100, 1) {
+		t.Error("Expected ack for gen3 to be rejected")
+	}
+
+	reports := tracker.GeneratorReport(time.Now().Add(time.Hour))
+	if len(reports) != 2 {
+		t.Fatalf("Expected 2 generators, got %d", len(reports))
+	}
+	if _, exists := reports["gen3"]; exists {
+		t.Error("Expected gen3 to not be tracked")
+	}
+
+	// Existing generators keep their state
+	if reports["gen1"].TotalAcked != 1 {
+		t.Errorf("Expected gen1 TotalAcked 1, got %d", reports["gen1"].TotalAcked)
+	}
+	if reports["gen2"].Unacked != 100 {
+		t.Errorf("Expected gen2 Unacked 100, got %d", reports["gen2"].Unacked)
+	}
+	if !tracker.Ack("gen2", 0, 100, 5) {
+		t.Error("Expected ack for existing gen2 to succeed")
+	}
+}
+
+func TestTracker_MaxGenerators_LRU(t *testing.T) {
+	tracker := NewTrackerWithConfig(Config{MaxGenerators: 2, EvictionPolicy: EvictionLRU}, zap.NewNop())
+
+	now := time.Now()
+	tracker.now = func() time.Time { return now }
+
+	tracker.AddRange("gen1", 0, 100, now)
+	now = now.Add(time.Second)
+	tracker.AddRange("gen2", 0, 100, now)
+
+	// gen1 becomes the most recently active
+	now = now.Add(time.Second)
+	tracker.Ack("gen1", 0, 100, 10)
+
+	// gen3 should evict gen2, the least recently active
+	now = now.Add(time.Second)
+	if !tracker.Ack("gen3", 0, 100, 1) {
+		t.Error("Expected ack for gen3 to succeed")
+	}
+
+	reports := tracker.GeneratorReport(now.Add(time.Hour))
+	if len(reports) != 2 {
+		t.Fatalf("Expected 2 generators, got %d", len(reports))
+	}
+	if _, exists := reports["gen2"]; exists {
+		t.Error("Expected gen2 to be evicted")
+	}
+
+	// gen1's state is preserved across the eviction
+	if reports["gen1"].TotalAcked != 1 {
+		t.Errorf("Expected gen1 TotalAcked 1, got %d", reports["gen1"].TotalAcked)
+	}
+	if reports["gen1"].Unacked != 99 {
+		t.Errorf("Expected gen1 Unacked 99, got %d", reports["gen1"].Unacked)
+	}
+	if !tracker.isAcked("gen1", 0, 100, 10) {
+		t.Error("Expected gen1 message 10 to remain acked")
+	}
+	if reports["gen3"].TotalAcked != 1 {
+		t.Errorf("Expected gen3 TotalAcked 1, got %d", reports["gen3"].TotalAcked)
+	}
+}
+
+func TestParseEvictionPolicy(t *testing.T) {
+	if p, err := ParseEvictionPolicy("lru"); err != nil || p != EvictionLRU {
+		t.Errorf("Expected lru policy, got %v, %v", p, err)
+	}
+	if p, err := ParseEvictionPolicy("reject"); err != nil || p != EvictionReject {
+		t.Errorf("Expected reject policy, got %v, %v", p, err)
+	}
+	if _, err := ParseEvictionPolicy("fifo"); err == nil {
+		t.Error("Expected error for unknown policy")
+	}
+}
+
+func TestTracker_CheckAckTimeouts(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	tracker := NewTrackerWithConfig(Config{AckTimeout: 30 * time.Second}, zap.New(core))
+
+	now := time.Now()
+	tracker.now = func() time.Time { return now }
+
+	tracker.AddRange("gen1", 0, 10, now)
+	tracker.AddRange("gen1", 10, 10, now)
+	for i := uint64(0); i < 6; i++ {
+		tracker.Ack("gen1", 0, 10, i)
+	}
+	for i := uint64(10); i < 20; i++ {
+		tracker.Ack("gen1", 10, 10, i)
+	}
+
+	if lost := tracker.CheckAckTimeouts(); lost != 0 {
+		t.Errorf("Expected no likely lost messages before the timeout, got %d", lost)
+	}
+
+	now = now.Add(time.Minute)
+	if lost := tracker.CheckAckTimeouts(); lost != 4 {
+		t.Errorf("Expected 4 likely lost messages, got %d", lost)
+	}
+
+	warnings := logs.FilterMessageSnippet("likely lost").All()
+	if len(warnings) != 1 {
+		t.Fatalf("Expected 1 warning, got %d", len(warnings))
+	}
+	fields := warnings[0].ContextMap()
+	if fields["generator_id"] != "gen1" || fields["start_id"] != uint64(0) || fields["unacked"] != uint64(4) {
+		t.Errorf("Unexpected warning fields: %v", fields)
+	}
+
+	if report := tracker.GeneratorReport(now)["gen1"]; report.LikelyLost != 4 {
+		t.Errorf("Expected LikelyLost of 4, got %d", report.LikelyLost)
+	}
+
+	// A range is only reported once
+	now = now.Add(time.Minute)
+	if lost := tracker.CheckAckTimeouts(); lost != 0 {
+		t.Errorf("Expected overdue range not to be counted again, got %d", lost)
+	}
+	if logs.FilterMessageSnippet("likely lost").Len() != 1 {
+		t.Errorf("Expected no additional warnings")
+	}
+}
+
+func TestTracker_CheckAckTimeouts_Disabled(t *testing.T) {
+	tracker := NewTracker(zap.NewNop())
+	tracker.AddRange("gen1", 0, 10, time.Now().Add(-time.Hour))
+
+	if lost := tracker.CheckAckTimeouts(); lost != 0 {
+		t.Errorf("Expected ack timeout check to be disabled, got %d", lost)
+	}
+}
+
+func TestMessageRange_LazyBitmap(t *testing.T) {
+	const rangeLen = 1 << 24
+	mr := NewMessageRange(1000, rangeLen)
+	if mr.bitmapLen() != 0 {
+		t.Fatalf("Expected a huge range to start without a bitmap, got %d words", mr.bitmapLen())
+	}
+
+	for id := uint64(1000); id < 1100; id++ {
+		mr.Ack(id)
+	}
+	if mr.bitmapLen() > 2 {
+		t.Errorf("Expected acking a prefix to allocate at most 2 words, got %d", mr.bitmapLen())
+	}
+	if !mr.IsAcked(1099) || mr.IsAcked(1100) || mr.IsAcked(1000+rangeLen-1) {
+		t.Error("Expected only the acked prefix to be acked")
+	}
+	if mr.UnackedCount() != rangeLen-100 {
+		t.Errorf("Expected %d unacked, got %d", rangeLen-100, mr.UnackedCount())
+	}
+	if ids := mr.unackedIDs(nil, 2); !reflect.DeepEqual(ids, []uint64{1100, 1101}) {
+		t.Errorf("Expected the unallocated tail to be unacked, got %v", ids)
+	}
+
+	// Acking the last message grows the bitmap to the end of the range
+	last := uint64(1000 + rangeLen - 1)
+	if result, ok := mr.Ack(last); !ok || !result.Acked {
+		t.Fatalf("Expected last message to be acked, got %+v", result)
+	}
+	if mr.bitmapLen() != rangeLen/64 {
+		t.Errorf("Expected %d bitmap words, got %d", rangeLen/64, mr.bitmapLen())
+	}
+	if !mr.IsAcked(last) || !mr.IsAcked(1050) || mr.IsAcked(last-1) {
+		t.Error("Expected acks to survive growing the bitmap")
+	}
+	if min, max, any := mr.AckedBounds(); !any || min != 1000 || max != last {
+		t.Errorf("Expected acked bounds 1000-%d, got %d-%d", last, min, max)
+	}
+
+	// Small ranges keep the dense bitmap
+	if small := NewMessageRange(0, 100); small.bitmapLen() != 2 {
+		t.Errorf("Expected a small range to allocate 2 words, got %d", small.bitmapLen())
+	}
+	if eager := newMessageRange(0, rangeLen, -1); eager.bitmapLen() != rangeLen/64 {
+		t.Errorf("Expected an unlimited eager bitmap of %d words, got %d", rangeLen/64, eager.bitmapLen())
+	}
+}
+
+func TestMessageRange_ForEachUnacked(t *testing.T) {
+	mr := NewMessageRange(500, 1000)
+	for id := uint64(500); id < 700; id++ {
+		mr.Ack(id)
+	}
+	for id := uint64(700); id < 1500; id += 3 {
+		mr.Ack(id)
+	}
+	mr.dropAckedPrefix()
+
+	var want []uint64
+	for id := uint64(500); id < 1500; id++ {
+		if !mr.IsAcked(id) {
+			want = append(want, id)
+		}
+	}
+
+	var got []uint64
+	mr.ForEachUnacked(func(msgID uint64) { got = append(got, msgID) })
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %d unacked IDs matching IsAcked, got %d", len(want), len(got))
+	}
+	if uint(len(got)) != mr.UnackedCount() {
+		t.Errorf("Expected %d unacked IDs, got %d", mr.UnackedCount(), len(got))
+	}
+
+	// Unallocated bitmap words are unacked
+	lazy := NewMessageRange(0, 1<<20)
+	lazy.Ack(1)
+	n := 0
+	lazy.ForEachUnacked(func(msgID uint64) {
+		if msgID == 1 {
+			t.Error("Expected acked message to be skipped")
+		}
+		n++
+	})
+	if n != 1<<20-1 {
+		t.Errorf("Expected %d unacked IDs, got %d", 1<<20-1, n)
+	}
+}
+
+func BenchmarkMessageRange_ForEachUnacked(b *testing.B) {
+	mr := benchmarkRange()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		n := 0
+		mr.ForEachUnacked(func(uint64) { n++ })
+	}
+}
+
+func BenchmarkMessageRange_IsAckedScan(b *testing.B) {
+	mr := benchmarkRange()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		n := 0
+		for id := uint64(0); id < 1_000_000; id++ {
+			if !mr.IsAcked(id) {
+				n++
+			}
+		}
+	}
+}
+
+// benchmarkRange returns a 1M message range with every 1000th message unacked
+func benchmarkRange() *MessageRange {
+	mr := NewMessageRange(0, 1_000_000)
+	for id := uint64(0); id < 1_000_000; id++ {
+		if id%1000 != 0 {
+			mr.Ack(id)
+		}
+	}
+	return mr
+}
+
+func TestMessageRange_DropAckedPrefix(t *testing.T) {
+	mr := NewMessageRange(1000, 1000)
+	for id := uint64(1000); id < 1640; id++ {
+		mr.Ack(id)
+	}
+	mr.Ack(1700)
+	mr.Ack(1999)
+
+	if n := mr.dropAckedPrefix(); n != 10 {
+		t.Fatalf("Expected 10 words released, got %d", n)
+	}
+	if mr.bitmapLen() != 6 {
+		t.Errorf("Expected 6 bitmap words left, got %d", mr.bitmapLen())
+	}
+
+	for id := uint64(1000); id < 2000; id++ {
+		expected := id < 1640 || id == 1700 || id == 1999
+		if mr.IsAcked(id) != expected {
+			t.Errorf("Expected IsAcked(%d) = %v after compaction", id, expected)
+		}
+	}
+
+	// Acks in the released prefix are duplicates
+	result, ok := mr.Ack(1001)
+	if !ok || !result.Dup {
+		t.Errorf("Expected ack in released prefix to be a duplicate, got %+v", result)
+	}
+	if mr.UnackedCount() != 1000-642 {
+		t.Errorf("Expected %d unacked, got %d", 1000-642, mr.UnackedCount())
+	}
+
+	// A fully acked range releases its whole bitmap
+	full := NewMessageRange(0, 100)
+	for id := uint64(0); id < 100; id++ {
+		full.Ack(id)
+	}
+	full.dropAckedPrefix()
+	if full.bitmapLen() != 0 || !full.IsAcked(99) {
+		t.Errorf("Expected fully acked range to release its bitmap, %d words left", full.bitmapLen())
+	}
+}
+
+func TestMessageRange_AckedBounds(t *testing.T) {
+	tests := []struct {
+		name     string
+		rangeLen uint
+		acks     []uint64
+		min, max uint64
+		any      bool
+	}{
+		{"none", 200, nil, 0, 0, false},
+		{"single", 200, []uint64{1077}, 1077, 1077, true},
+		{"head and tail", 200, []uint64{1000, 1199}, 1000, 1199, true},
+		{"scattered", 200, []uint64{1130, 1064, 1063, 1150}, 1063, 1150, true},
+		{"word boundaries", 256, []uint64{1128, 1191, 1192}, 1128, 1192, true},
+		{"last bit", 64, []uint64{1063}, 1063, 1063, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr := NewMessageRange(1000, tt.rangeLen)
+			for _, id := range tt.acks {
+				mr.Ack(id)
+			}
+
+			min, max, any := mr.AckedBounds()
+			if min != tt.min || max != tt.max || any != tt.any {
+				t.Errorf("Expected (%d, %d, %v), got (%d, %d, %v)", tt.min, tt.max, tt.any, min, max, any)
+			}
+		})
+	}
+}
+
+func TestMessageRange_AckedBounds_Compacted(t *testing.T) {
+	mr := NewMessageRange(0, 300)
+	for id := uint64(0); id < 128; id++ {
+		mr.Ack(id)
+	}
+	mr.Ack(250)
+	mr.dropAckedPrefix()
+
+	if min, max, any := mr.AckedBounds(); min != 0 || max != 250 || !any {
+		t.Errorf("Expected (0, 250, true), got (%d, %d, %v)", min, max, any)
+	}
+
+	// Only the dropped prefix is acked
+	prefix := NewMessageRange(0, 300)
+	for id := uint64(0); id < 64; id++ {
+		prefix.Ack(id)
+	}
+	prefix.dropAckedPrefix()
+
+	if min, max, any := prefix.AckedBounds(); min != 0 || max != 63 || !any {
+		t.Errorf("Expected (0, 63, true), got (%d, %d, %v)", min, max, any)
+	}
+
+	// Acks beyond a shortened range are ignored
+	shortened := NewMessageRange(0, 300)
+	shortened.Ack(10)
+	shortened.Ack(200)
+	shortened.UpdateRangeLen(100)
+
+	if min, max, any := shortened.AckedBounds(); min != 10 || max != 10 || !any {
+		t.Errorf("Expected (10, 10, true), got (%d, %d, %v)", min, max, any)
+	}
+}
+
+func TestTracker_Compact(t *testing.T) {
+	tracker := NewTrackerWithConfig(Config{CompactAfter: time.Minute}, zap.NewNop())
+	now := time.Now()
+	tracker.now = func() time.Time { return now }
+
+	// Four contiguous ranges of 512, each missing a few acks, and a lagging
+	// range with most messages unacked
+	unacked := map[uint64]bool{700: true, 1100: true, 1535: true, 1600: true}
+	for start := uint64(0); start < 2048; start += 512 {
+		tracker.AddRange("gen1", start, 512, now)
+		for id := start; id < start+512; id++ {
+			if !unacked[id] {
+				tracker.Ack("gen1", start, 512, id)
+			}
+		}
+	}
+	tracker.AddRange("gen1", 2048, 512, now)
+	for id := uint64(2048); id < 2100; id++ {
+		tracker.Ack("gen1", 2048, 512, id)
+	}
+
+	gt := tracker.generators["gen1"]
+	bitmapWords := func() int {
+		n := 0
+		for _, r := range gt.ranges {
+			n += r.bitmapLen()
+		}
+		return n
+	}
+	before := bitmapWords()
+	reportBefore := tracker.GeneratorReport(now.Add(time.Hour))["gen1"]
+
+	// Ranges aren't compacted before they reach the age
+	if merged, freed := tracker.Compact(); merged != 0 || freed != 0 {
+		t.Fatalf("Expected no compaction of recent ranges, got %d merged, %d bytes", merged, freed)
+	}
+
+	now = now.Add(2 * time.Minute)
+	merged, freed := tracker.Compact()
+	if merged != 3 {
+		t.Errorf("Expected 3 merged ranges, got %d", merged)
+	}
+	if len(gt.ranges) != 2 {
+		t.Errorf("Expected 2 ranges left, got %d", len(gt.ranges))
+	}
+	if after := bitmapWords(); after >= before || freed != (before-after)*8 {
+		t.Errorf("Expected bitmap to shrink from %d words, got %d with %d bytes freed", before, after, freed)
+	}
+
+	for start := uint64(0); start < 2560; start += 512 {
+		for id := start; id < start+512; id++ {
+			expected := id < 2048 && !unacked[id] || id >= 2048 && id < 2100
+			if tracker.isAcked("gen1", start, 512, id) != expected {
+				t.Errorf("Expected isAcked(%d) = %v after compaction", id, expected)
+			}
+		}
+	}
+	if report := tracker.GeneratorReport(now.Add(time.Hour))["gen1"]; report.Unacked != reportBefore.Unacked {
+		t.Errorf("Expected %d unacked after compaction, got %d", reportBefore.Unacked, report.Unacked)
+	}
+
+	// Acks for merged ranges still land by their original start ID
+	if !tracker.Ack("gen1", 1024, 512, 1100) || !tracker.isAcked("gen1", 1024, 512, 1100) {
+		t.Error("Expected ack of merged range to succeed")
+	}
+	tracker.Ack("gen1", 512, 512, 600)
+	report := tracker.GeneratorReport(now.Add(time.Hour))["gen1"]
+	if report.Unacked != reportBefore.Unacked-1 || report.TotalDuped != 1 {
+		t.Errorf("Expected %d unacked and 1 duplicate, got %+v", reportBefore.Unacked-1, report)
+	}
+}
+
+func TestTracker_Reap(t *testing.T) {
+	tracker := NewTracker(zap.NewNop())
+	now := time.Now()
+	old := now.Add(-time.Hour)
+
+	// Fully acked old range, partially acked old range and fully acked recent range
+	tracker.AddRange("gen1", 0, 10, old)
+	tracker.AddRange("gen1", 10, 10, old)
+	tracker.AddRange("gen1", 20, 10, now)
+	for i := uint64(0); i < 10; i++ {
+		tracker.Ack("gen1", 0, 10, i)
+		tracker.Ack("gen1", 20, 10, 20+i)
+	}
+	for i := uint64(10); i < 15; i++ {
+		tracker.Ack("gen1", 10, 10, i)
+	}
+	tracker.Ack("gen1", 0, 10, 3)
+
+	before := tracker.GeneratorReport(now)["gen1"]
+
+	if reaped := tracker.Reap(now.Add(-time.Minute)); reaped != 1 {
+		t.Fatalf("Expected 1 range reaped, got %d", reaped)
+	}
+
+	gt := tracker.generators["gen1"]
+	if _, exists := gt.ranges[0]; exists {
+		t.Error("Expected the fully acked old range to be reaped")
+	}
+	if len(gt.ranges) != 2 {
+		t.Errorf("Expected 2 ranges left, got %d", len(gt.ranges))
+	}
+
+	after := tracker.GeneratorReport(now)["gen1"]
+	if after.TotalAcked != before.TotalAcked || after.TotalDuped != before.TotalDuped || after.Unacked != before.Unacked {
+		t.Errorf("Expected totals to be preserved, before %+v, after %+v", before, after)
+	}
+	if after.TotalAcked != 25 || after.TotalDuped != 1 || after.Unacked != 5 {
+		t.Errorf("Unexpected report after reaping: %+v", after)
+	}
+}
+
+// A late duplicate of a reaped range must not recreate the range
+func TestTracker_ReapLateDuplicate(t *testing.T) {
+	tracker := NewTracker(zap.NewNop())
+	now := time.Now()
+
+	tracker.AddRange("gen1", 0, 4, now.Add(-time.Hour))
+	for id := uint64(0); id < 4; id++ {
+		tracker.Ack("gen1", 0, 4, id)
+	}
+	if reaped := tracker.Reap(now.Add(-time.Minute)); reaped != 1 {
+		t.Fatalf("Expected 1 range reaped, got %d", reaped)
+	}
+
+	if !tracker.Ack("gen1", 0, 4, 1) {
+		t.Error("Expected the late ack to succeed")
+	}
+	if !tracker.isAcked("gen1", 0, 4, 3) {
+		t.Error("Expected messages of the reaped range to be acked")
+	}
+
+	report := tracker.GeneratorReport(now)["gen1"]
+	if report.TotalAcked != 4 || report.TotalDuped != 1 || report.Unacked != 0 {
+		t.Errorf("Expected 4 acked and 1 duplicate, got %+v", report)
+	}
+	if acked := tracker.ackedCount()["gen1"]; acked != 4 {
+		t.Errorf("Expected 4 acked messages, got %d", acked)
+	}
+
+	// Republishing the range is a no-op
+	tracker.AddRange("gen1", 0, 4, now)
+	if gt := tracker.generators["gen1"]; len(gt.ranges) != 0 {
+		t.Errorf("Expected no range to be recreated, got %d ranges", len(gt.ranges))
+	}
+}
+
+func TestTracker_UnackedIDs(t *testing.T) {
+	tracker := NewTrackerWithConfig(Config{CompactAfter: time.Minute}, zap.NewNop())
+	now := time.Now()
+	tracker.now = func() time.Time { return now }
+
+	unacked := []uint64{70, 130, 1000, 1001, 1500}
+	missing := make(map[uint64]bool)
+	for _, id := range unacked {
+		missing[id] = true
+	}
+	// Ranges are added out of order, IDs are returned in ascending order
+	for _, start := range []uint64{1024, 0, 512} {
+		tracker.AddRange("gen1", start, 512, now.Add(-time.Hour))
+		for id := start; id < start+512; id++ {
+			if !missing[id] {
+				tracker.Ack("gen1", start, 512, id)
+			}
+		}
+	}
+
+	if ids := tracker.UnackedIDs("gen1", 100); !reflect.DeepEqual(ids, unacked) {
+		t.Errorf("Expected unacked IDs %v, got %v", unacked, ids)
+	}
+	if ids := tracker.UnackedIDs("gen1", 3); !reflect.DeepEqual(ids, unacked[:3]) {
+		t.Errorf("Expected the first 3 unacked IDs, got %v", ids)
+	}
+
+	// Compacted ranges return the same IDs
+	tracker.Compact()
+	if ids := tracker.UnackedIDs("gen1", 100); !reflect.DeepEqual(ids, unacked) {
+		t.Errorf("Expected unacked IDs %v after compaction, got %v", unacked, ids)
+	}
+
+	if ids := tracker.UnackedIDs("unknown", 100); len(ids) != 0 {
+		t.Errorf("Expected no IDs for an unknown generator, got %v", ids)
+	}
+}
+
+func TestTracker_ReapCoalescesRuns(t *testing.T) {
+	tracker := NewTracker(zap.NewNop())
+	now := time.Now()
+	old := now.Add(-time.Hour)
+
+	// An hour of fully acked 1000 message ranges and a recent range
+	for start := uint64(1); start < 100_001; start += 1000 {
+		tracker.AddRange("gen1", start, 1000, old)
+		for id := start; id < start+1000; id++ {
+			tracker.Ack("gen1", start, 1000, id)
+		}
+	}
+	tracker.AddRange("gen1", 100_001, 1000, now)
+	tracker.Ack("gen1", 100_001, 1000, 100_001)
+
+	before := tracker.GeneratorReport(now)["gen1"]
+	if reaped := tracker.Reap(now.Add(-time.Minute)); reaped != 100 {
+		t.Fatalf("Expected 100 ranges reaped, got %d", reaped)
+	}
+
+	gt := tracker.generators["gen1"]
+	if len(gt.ranges) != 1 || len(gt.runs) != 1 {
+		t.Fatalf("Expected 1 range and 1 run left, got %d ranges and runs %v", len(gt.ranges), gt.runs)
+	}
+	if after := tracker.GeneratorReport(now)["gen1"]; after != before {
+		t.Errorf("Expected report %+v after coalescing, got %+v", before, after)
+	}
+
+	// Acks to coalesced ranges are still duplicates
+	for _, id := range []uint64{1, 50_000, 100_000} {
+		if !tracker.isAcked("gen1", (id-1)/1000*1000+1, 1000, id) {
+			t.Errorf("Expected %d to be acked after coalescing", id)
+		}
+	}
+	if !tracker.Ack("gen1", 50_001, 1000, 50_500) {
+		t.Error("Expected ack of a coalesced range to succeed")
+	}
+	if report := tracker.GeneratorReport(now)["gen1"]; report.TotalDuped != 1 || report.TotalAcked != before.TotalAcked {
+		t.Errorf("Expected the late ack to be a duplicate, got %+v", report)
+	}
+	if len(gt.ranges) != 1 {
+		t.Errorf("Expected no range to be recreated, got %d ranges", len(gt.ranges))
+	}
+
+	// Republishing a coalesced range is a no-op, one sticking out of the run
+	// is rejected
+	if err := tracker.AddRange("gen1", 2001, 1000, now); err != nil || len(gt.ranges) != 1 {
+		t.Errorf("Expected republishing a coalesced range to be ignored, got %v with %d ranges", err, len(gt.ranges))
+	}
+	if err := tracker.AddRange("gen1", 0, 10, now); !errors.Is(err, ErrOverlappingRange) {
+		t.Errorf("Expected a range overlapping a run to be rejected, got %v", err)
+	}
+
+	ranges := tracker.Ranges("gen1")
+	if len(ranges) != 2 || ranges[0].StartID != 1 || ranges[0].RangeLen != 100_000 || ranges[0].AckedCount != 100_000 {
+		t.Errorf("Expected the run to be dumped as a fully acked range, got %+v", ranges)
+	}
+}
+
+func TestAckedRuns(t *testing.T) {
+	var runs ackedRuns
+	runs.add(10, 20)
+	runs.add(30, 40)
+	runs.add(50, 60)
+	runs.add(20, 30) // joins the first two
+	runs.add(45, 55) // overlaps the last
+
+	want := ackedRuns{{10, 40}, {45, 60}}
+	if !reflect.DeepEqual(runs, want) {
+		t.Fatalf("Expected runs %v, got %v", want, runs)
+	}
+
+	for id, acked := range map[uint64]bool{9: false, 10: true, 39: true, 40: false, 44: false, 45: true, 59: true, 60: false} {
+		if runs.contains(id) != acked {
+			t.Errorf("Expected contains(%d) = %v", id, acked)
+		}
+	}
+	if !runs.covers(10, 40) || runs.covers(10, 41) || runs.covers(40, 45) {
+		t.Error("Unexpected covers result")
+	}
+	if _, ok := runs.intersecting(40, 45); ok {
+		t.Error("Expected the gap between runs not to intersect")
+	}
+	if run, ok := runs.intersecting(35, 50); !ok || run.start != 10 {
+		t.Errorf("Expected the first run to intersect, got %v", run)
+	}
+}
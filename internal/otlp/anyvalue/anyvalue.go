@@ -0,0 +1,92 @@
+// Package anyvalue builds OTLP AnyValues and KeyValues
+package anyvalue
+
+import (
+	"fmt"
+	"slices"
+
+	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+)
+
+func String(s string) *otlpCommon.AnyValue {
+	return &otlpCommon.AnyValue{Value: &otlpCommon.AnyValue_StringValue{StringValue: s}}
+}
+
+func Int(i int64) *otlpCommon.AnyValue {
+	return &otlpCommon.AnyValue{Value: &otlpCommon.AnyValue_IntValue{IntValue: i}}
+}
+
+func Double(f float64) *otlpCommon.AnyValue {
+	return &otlpCommon.AnyValue{Value: &otlpCommon.AnyValue_DoubleValue{DoubleValue: f}}
+}
+
+func Bool(b bool) *otlpCommon.AnyValue {
+	return &otlpCommon.AnyValue{Value: &otlpCommon.AnyValue_BoolValue{BoolValue: b}}
+}
+
+func Bytes(b []byte) *otlpCommon.AnyValue {
+	return &otlpCommon.AnyValue{Value: &otlpCommon.AnyValue_BytesValue{BytesValue: b}}
+}
+
+func Array(values ...*otlpCommon.AnyValue) *otlpCommon.AnyValue {
+	return &otlpCommon.AnyValue{
+		Value: &otlpCommon.AnyValue_ArrayValue{
+			ArrayValue: &otlpCommon.ArrayValue{Values: values},
+		},
+	}
+}
+
+func KVList(kvs ...*otlpCommon.KeyValue) *otlpCommon.AnyValue {
+	return &otlpCommon.AnyValue{
+		Value: &otlpCommon.AnyValue_KvlistValue{
+			KvlistValue: &otlpCommon.KeyValueList{Values: kvs},
+		},
+	}
+}
+
+// KV returns a key value pair, for attributes and KVList
+func KV(key string, value *otlpCommon.AnyValue) *otlpCommon.KeyValue {
+	return &otlpCommon.KeyValue{Key: key, Value: value}
+}
+
+// FromJSON converts a Go value, such as decoded JSON, to an OTLP value. Maps
+// become kvlists with sorted keys and slices become arrays. Other types are
+// formatted as strings.
+func FromJSON(v any) *otlpCommon.AnyValue {
+	switch v := v.(type) {
+	case nil:
+		return &otlpCommon.AnyValue{}
+	case string:
+		return String(v)
+	case bool:
+		return Bool(v)
+	case int:
+		return Int(int64(v))
+	case int64:
+		return Int(v)
+	case float64:
+		return Double(v)
+	case []byte:
+		return Bytes(v)
+	case []any:
+		values := make([]*otlpCommon.AnyValue, 0, len(v))
+		for _, elem := range v {
+			values = append(values, FromJSON(elem))
+		}
+		return Array(values...)
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+
+		kvs := make([]*otlpCommon.KeyValue, 0, len(v))
+		for _, key := range keys {
+			kvs = append(kvs, KV(key, FromJSON(v[key])))
+		}
+		return KVList(kvs...)
+	default:
+		return String(fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,50 @@
+package anyvalue
+
+import (
+	"encoding/json"
+	"testing"
+
+	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestFromJSON(t *testing.T) {
+	var v any
+	if err := json.Unmarshal([]byte(`{"msg": "ok", "http": {"status": 200, "ok": true}, "tags": ["a", null]}`), &v); err != nil {
+		t.Fatal(err)
+	}
+
+	want := KVList(
+		KV("http", KVList(
+			KV("ok", Bool(true)),
+			// JSON numbers decode as float64
+			KV("status", Double(200)),
+		)),
+		KV("msg", String("ok")),
+		KV("tags", Array(String("a"), &otlpCommon.AnyValue{})),
+	)
+
+	if got := FromJSON(v); !proto.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if got := FromJSON(int64(7)).GetIntValue(); got != 7 {
+		t.Errorf("Expected int 7, got %d", got)
+	}
+}
+
+func TestConstructors(t *testing.T) {
+	want := &otlpCommon.AnyValue{Value: &otlpCommon.AnyValue_KvlistValue{KvlistValue: &otlpCommon.KeyValueList{
+		Values: []*otlpCommon.KeyValue{
+			{Key: "s", Value: &otlpCommon.AnyValue{Value: &otlpCommon.AnyValue_StringValue{StringValue: "v"}}},
+			{Key: "i", Value: &otlpCommon.AnyValue{Value: &otlpCommon.AnyValue_IntValue{IntValue: 1}}},
+			{Key: "a", Value: &otlpCommon.AnyValue{Value: &otlpCommon.AnyValue_ArrayValue{ArrayValue: &otlpCommon.ArrayValue{
+				Values: []*otlpCommon.AnyValue{{Value: &otlpCommon.AnyValue_BoolValue{BoolValue: true}}},
+			}}}},
+		},
+	}}}
+
+	got := KVList(KV("s", String("v")), KV("i", Int(1)), KV("a", Array(Bool(true))))
+	if !proto.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
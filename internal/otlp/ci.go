@@ -0,0 +1,169 @@
+package otlp
+
+import (
+	"fmt"
+	"strings"
+
+	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+)
+
+// Resource attribute keys of the run metadata, so load test telemetry can be
+// sliced by build and PR in the backend
+const (
+	AttrCIProvider     = "ci.provider"
+	AttrCIBuildID      = "ci.build.id"
+	AttrVCSRevision    = "vcs.revision"
+	AttrVCSBranch      = "vcs.branch"
+	AttrVCSPullRequest = "vcs.pull_request"
+	AttrVCSRepository  = "vcs.repository"
+)
+
+// ciProvider maps the environment variables of a CI system to the metadata
+// attributes, a provider is detected if its marker variable is set
+type ciProvider struct {
+	name   string
+	marker string
+	vars   []ciVar
+}
+
+type ciVar struct {
+	key string
+	env string
+	// value transforms the raw value, an empty result skips the attribute
+	value func(string) string
+}
+
+var ciProviders = []ciProvider{
+	{
+		name:   "github_actions",
+		marker: "GITHUB_ACTIONS",
+		vars: []ciVar{
+			{key: AttrCIBuildID, env: "GITHUB_RUN_ID"},
+			{key: AttrVCSRevision, env: "GITHUB_SHA"},
+			{key: AttrVCSBranch, env: "GITHUB_HEAD_REF"},
+			{key: AttrVCSBranch, env: "GITHUB_REF_NAME"},
+			{key: AttrVCSPullRequest, env: "GITHUB_REF", value: githubPullRequest},
+			{key: AttrVCSRepository, env: "GITHUB_REPOSITORY"},
+		},
+	},
+	{
+		name:   "gitlab_ci",
+		marker: "GITLAB_CI",
+		vars: []ciVar{
+			{key: AttrCIBuildID, env: "CI_PIPELINE_ID"},
+			{key: AttrVCSRevision, env: "CI_COMMIT_SHA"},
+			{key: AttrVCSBranch, env: "CI_COMMIT_REF_NAME"},
+			{key: AttrVCSPullRequest, env: "CI_MERGE_REQUEST_IID"},
+			{key: AttrVCSRepository, env: "CI_PROJECT_PATH"},
+		},
+	},
+	{
+		name:   "circleci",
+		marker: "CIRCLECI",
+		vars: []ciVar{
+			{key: AttrCIBuildID, env: "CIRCLE_BUILD_NUM"},
+			{key: AttrVCSRevision, env: "CIRCLE_SHA1"},
+			{key: AttrVCSBranch, env: "CIRCLE_BRANCH"},
+			{key: AttrVCSPullRequest, env: "CIRCLE_PR_NUMBER"},
+			{key: AttrVCSRepository, env: "CIRCLE_REPOSITORY_URL"},
+		},
+	},
+	{
+		name:   "buildkite",
+		marker: "BUILDKITE",
+		vars: []ciVar{
+			{key: AttrCIBuildID, env: "BUILDKITE_BUILD_ID"},
+			{key: AttrVCSRevision, env: "BUILDKITE_COMMIT"},
+			{key: AttrVCSBranch, env: "BUILDKITE_BRANCH"},
+			{key: AttrVCSPullRequest, env: "BUILDKITE_PULL_REQUEST", value: func(s string) string {
+				if s == "false" {
+					return ""
+				}
+				return s
+			}},
+			{key: AttrVCSRepository, env: "BUILDKITE_REPO"},
+		},
+	},
+	{
+		name:   "jenkins",
+		marker: "JENKINS_URL",
+		vars: []ciVar{
+			{key: AttrCIBuildID, env: "BUILD_ID"},
+			{key: AttrVCSRevision, env: "GIT_COMMIT"},
+			{key: AttrVCSBranch, env: "GIT_BRANCH"},
+			{key: AttrVCSPullRequest, env: "CHANGE_ID"},
+			{key: AttrVCSRepository, env: "GIT_URL"},
+		},
+	},
+}
+
+// githubPullRequest extracts the PR number from a refs/pull/<n>/merge ref
+func githubPullRequest(ref string) string {
+	if rest, ok := strings.CutPrefix(ref, "refs/pull/"); ok {
+		number, _, _ := strings.Cut(rest, "/")
+		return number
+	}
+	return ""
+}
+
+// DetectCIAttrs returns the run metadata of the first CI system detected
+// through getenv, or nil outside of CI. When several variables map to the
+// same attribute the first one set wins.
+func DetectCIAttrs(getenv func(string) string) []*otlpCommon.KeyValue {
+	for _, p := range ciProviders {
+		if getenv(p.marker) == "" {
+			continue
+		}
+
+		attrs := []*otlpCommon.KeyValue{stringAttr(AttrCIProvider, p.name)}
+		seen := make(map[string]bool)
+		for _, v := range p.vars {
+			if seen[v.key] {
+				continue
+			}
+			value := getenv(v.env)
+			if v.value != nil {
+				value = v.value(value)
+			}
+			if value == "" {
+				continue
+			}
+			seen[v.key] = true
+			attrs = append(attrs, stringAttr(v.key, value))
+		}
+		return attrs
+	}
+	return nil
+}
+
+// ParseMeta parses run metadata in the format 'key=value' into string
+// attributes
+func ParseMeta(values []string) ([]*otlpCommon.KeyValue, error) {
+	attrs := make([]*otlpCommon.KeyValue, 0, len(values))
+	for _, m := range values {
+		key, value, ok := strings.Cut(m, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid meta format: %q (expected 'key=value')", m)
+		}
+		// A repeated key takes the last value
+		attrs = MergeAttrs(attrs, []*otlpCommon.KeyValue{stringAttr(key, value)})
+	}
+	return attrs, nil
+}
+
+// MergeAttrs returns base with overrides appended, replacing any attribute
+// of base with the same key
+func MergeAttrs(base []*otlpCommon.KeyValue, overrides []*otlpCommon.KeyValue) []*otlpCommon.KeyValue {
+	override := make(map[string]bool, len(overrides))
+	for _, kv := range overrides {
+		override[kv.Key] = true
+	}
+
+	merged := make([]*otlpCommon.KeyValue, 0, len(base)+len(overrides))
+	for _, kv := range base {
+		if !override[kv.Key] {
+			merged = append(merged, kv)
+		}
+	}
+	return append(merged, overrides...)
+}
@@ -0,0 +1,94 @@
+package otlp
+
+import (
+	"testing"
+
+	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+)
+
+func attrMap(attrs []*otlpCommon.KeyValue) map[string]string {
+	m := make(map[string]string, len(attrs))
+	for _, kv := range attrs {
+		m[kv.Key] = kv.GetValue().GetStringValue()
+	}
+	return m
+}
+
+func TestDetectCIAttrs_GitHubActions(t *testing.T) {
+	env := map[string]string{
+		"GITHUB_ACTIONS":    "true",
+		"GITHUB_RUN_ID":     "987654",
+		"GITHUB_SHA":        "0123abcd",
+		"GITHUB_HEAD_REF":   "feature/x",
+		"GITHUB_REF_NAME":   "1234/merge",
+		"GITHUB_REF":        "refs/pull/1234/merge",
+		"GITHUB_REPOSITORY": "streamfold/otel-loadgen",
+	}
+	got := attrMap(DetectCIAttrs(func(k string) string { return env[k] }))
+
+	want := map[string]string{
+		AttrCIProvider:     "github_actions",
+		AttrCIBuildID:      "987654",
+		AttrVCSRevision:    "0123abcd",
+		AttrVCSBranch:      "feature/x",
+		AttrVCSPullRequest: "1234",
+		AttrVCSRepository:  "streamfold/otel-loadgen",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("Expected %s=%q, got %q", k, v, got[k])
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("Expected %d attributes, got %v", len(want), got)
+	}
+}
+
+func TestDetectCIAttrs_SkipsUnset(t *testing.T) {
+	env := map[string]string{
+		"BUILDKITE":              "true",
+		"BUILDKITE_BUILD_ID":     "b-1",
+		"BUILDKITE_PULL_REQUEST": "false",
+	}
+	got := attrMap(DetectCIAttrs(func(k string) string { return env[k] }))
+	if got[AttrCIProvider] != "buildkite" || got[AttrCIBuildID] != "b-1" {
+		t.Errorf("Expected buildkite metadata, got %v", got)
+	}
+	if _, ok := got[AttrVCSPullRequest]; ok {
+		t.Error("Expected no pull request outside of a PR build")
+	}
+	if _, ok := got[AttrVCSRevision]; ok {
+		t.Error("Expected unset variables to be skipped")
+	}
+
+	if attrs := DetectCIAttrs(func(string) string { return "" }); attrs != nil {
+		t.Errorf("Expected no attributes outside of CI, got %v", attrs)
+	}
+}
+
+func TestParseMeta(t *testing.T) {
+	attrs, err := ParseMeta([]string{"team=core", "vcs.revision=abc=def", "team=infra"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := attrMap(attrs)
+	if len(attrs) != 2 || got["team"] != "infra" || got["vcs.revision"] != "abc=def" {
+		t.Errorf("Unexpected meta attributes %v", got)
+	}
+
+	for _, m := range []string{"team", "=core"} {
+		if _, err := ParseMeta([]string{m}); err == nil {
+			t.Errorf("Expected error parsing %q", m)
+		}
+	}
+}
+
+func TestMergeAttrs(t *testing.T) {
+	base := []*otlpCommon.KeyValue{stringAttr("a", "1"), stringAttr("b", "2")}
+	merged := MergeAttrs(base, []*otlpCommon.KeyValue{stringAttr("b", "3"), stringAttr("c", "4")})
+
+	got := attrMap(merged)
+	if len(merged) != 3 || got["a"] != "1" || got["b"] != "3" || got["c"] != "4" {
+		t.Errorf("Unexpected merged attributes %v", got)
+	}
+}
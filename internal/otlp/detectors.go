@@ -0,0 +1,134 @@
+package otlp
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/streamfold/otel-loadgen/internal/otlp/anyvalue"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+)
+
+// ResourceDetector adds a group of the standard resource attributes emitted by
+// the resource detectors of the OpenTelemetry SDKs
+type ResourceDetector string
+
+const (
+	DetectorHost    ResourceDetector = "host"
+	DetectorOS      ResourceDetector = "os"
+	DetectorProcess ResourceDetector = "process"
+	DetectorSDK     ResourceDetector = "sdk"
+)
+
+var allDetectors = []ResourceDetector{DetectorHost, DetectorOS, DetectorProcess, DetectorSDK}
+
+// ParseResourceDetectors parses detector names, "all" enables every detector
+func ParseResourceDetectors(names []string) ([]ResourceDetector, error) {
+	detectors := make([]ResourceDetector, 0, len(names))
+	for _, name := range names {
+		switch d := ResourceDetector(name); d {
+		case DetectorHost, DetectorOS, DetectorProcess, DetectorSDK:
+			detectors = append(detectors, d)
+		case "all":
+			detectors = append(detectors, allDetectors...)
+		default:
+			return nil, fmt.Errorf("invalid resource detector: %q (expected host, os, process, sdk or all)", name)
+		}
+	}
+	return detectors, nil
+}
+
+// DetectResourceAttrs returns the resource attributes of the detectors, read
+// from the running process and host
+func DetectResourceAttrs(detectors []ResourceDetector) []*otlpCommon.KeyValue {
+	var attrs []*otlpCommon.KeyValue
+	seen := make(map[ResourceDetector]bool)
+
+	for _, d := range detectors {
+		if seen[d] {
+			continue
+		}
+		seen[d] = true
+
+		switch d {
+		case DetectorHost:
+			attrs = append(attrs, stringAttr(string(semconv.HostArchKey), hostArch()))
+		case DetectorOS:
+			attrs = append(attrs,
+				stringAttr(string(semconv.OSTypeKey), runtime.GOOS),
+				stringAttr(string(semconv.OSDescriptionKey), fmt.Sprintf("%s %s", runtime.GOOS, runtime.GOARCH)),
+			)
+		case DetectorProcess:
+			attrs = append(attrs, processAttrs()...)
+		case DetectorSDK:
+			attrs = append(attrs,
+				stringAttr(string(semconv.TelemetrySDKNameKey), "opentelemetry"),
+				stringAttr(string(semconv.TelemetrySDKLanguageKey), semconv.TelemetrySDKLanguageGo.Value.AsString()),
+				stringAttr(string(semconv.TelemetrySDKVersionKey), sdkVersion()),
+			)
+		}
+	}
+
+	return attrs
+}
+
+func processAttrs() []*otlpCommon.KeyValue {
+	attrs := []*otlpCommon.KeyValue{
+		{
+			Key:   string(semconv.ProcessPIDKey),
+			Value: anyvalue.Int(int64(os.Getpid())),
+		},
+		stringAttr(string(semconv.ProcessRuntimeNameKey), "go"),
+		stringAttr(string(semconv.ProcessRuntimeVersionKey), runtime.Version()),
+		stringAttr(string(semconv.ProcessRuntimeDescriptionKey), "go version "+runtime.Version()+" "+runtime.GOOS+"/"+runtime.GOARCH),
+	}
+
+	if exe, err := os.Executable(); err == nil {
+		attrs = append(attrs,
+			stringAttr(string(semconv.ProcessExecutableNameKey), filepath.Base(exe)),
+			stringAttr(string(semconv.ProcessExecutablePathKey), exe),
+		)
+	}
+	if u, err := user.Current(); err == nil {
+		attrs = append(attrs, stringAttr(string(semconv.ProcessOwnerKey), u.Username))
+	}
+
+	return attrs
+}
+
+// sdkVersion returns the version of the OpenTelemetry Go module the binary was
+// built with
+func sdkVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path == "go.opentelemetry.io/otel" {
+				return strings.TrimPrefix(dep.Version, "v")
+			}
+		}
+	}
+	return "unknown"
+}
+
+// hostArch maps GOARCH to the semantic convention host.arch values
+func hostArch() string {
+	switch runtime.GOARCH {
+	case "386":
+		return "x86"
+	case "ppc64le", "ppc64":
+		return "ppc64"
+	default:
+		return runtime.GOARCH
+	}
+}
+
+func stringAttr(key, value string) *otlpCommon.KeyValue {
+	return &otlpCommon.KeyValue{
+		Key:   key,
+		Value: anyvalue.String(value),
+	}
+}
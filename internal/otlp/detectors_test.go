@@ -0,0 +1,37 @@
+package otlp
+
+import "testing"
+
+func TestDetectResourceAttrs(t *testing.T) {
+	detectors, err := ParseResourceDetectors([]string{"all"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := make(map[string]bool)
+	for _, kv := range DetectResourceAttrs(detectors) {
+		if keys[kv.Key] {
+			t.Errorf("Duplicate attribute %s", kv.Key)
+		}
+		keys[kv.Key] = true
+	}
+
+	expected := []string{
+		"host.arch",
+		"os.type", "os.description",
+		"process.pid", "process.executable.name", "process.executable.path",
+		"process.runtime.name", "process.runtime.version", "process.runtime.description",
+		"telemetry.sdk.name", "telemetry.sdk.language", "telemetry.sdk.version",
+	}
+	for _, k := range expected {
+		if !keys[k] {
+			t.Errorf("Missing resource attribute %s", k)
+		}
+	}
+}
+
+func TestParseResourceDetectors_Invalid(t *testing.T) {
+	if _, err := ParseResourceDetectors([]string{"host", "container"}); err == nil {
+		t.Error("Expected an error for an unknown detector")
+	}
+}
@@ -3,13 +3,32 @@ package otlp
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 
+	"github.com/streamfold/otel-loadgen/internal/otlp/anyvalue"
 	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
 	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
 	otlpRes "go.opentelemetry.io/proto/otlp/resource/v1"
 )
 
-func NewResource(idx uint64, i int) *otlpRes.Resource {
+// DefaultServiceName is the service.name of the generated resources
+const DefaultServiceName = "loadtest"
+
+// ResourceConfig customizes the generated resources, empty fields use the
+// defaults
+type ResourceConfig struct {
+	ServiceName string
+	// Attributes are merged into every resource, replacing the built-in
+	// attributes with the same key
+	Attributes []*otlpCommon.KeyValue
+}
+
+func NewResource(cfg ResourceConfig, idx uint64, i int) *otlpRes.Resource {
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = DefaultServiceName
+	}
+
 	r := &otlpRes.Resource{
 		Attributes:             nil,
 		DroppedAttributesCount: 0,
@@ -22,39 +41,118 @@ func NewResource(idx uint64, i int) *otlpRes.Resource {
 
 	r.Attributes = append(r.Attributes, &otlpCommon.KeyValue{
 		Key:   string(semconv.ServiceNameKey),
-		Value: &otlpCommon.AnyValue{Value: &otlpCommon.AnyValue_StringValue{StringValue: "loadtest"}},
+		Value: anyvalue.String(cfg.ServiceName),
 	})
 
 	r.Attributes = append(r.Attributes, &otlpCommon.KeyValue{
 		Key:   string(semconv.ServiceInstanceIDKey),
-		Value: &otlpCommon.AnyValue{Value: &otlpCommon.AnyValue_IntValue{IntValue: int64(idx)}},
+		Value: anyvalue.Int(int64(idx)),
 	})
 
 	r.Attributes = append(r.Attributes, &otlpCommon.KeyValue{
 		Key:   string(semconv.K8SPodNameKey),
-		Value: &otlpCommon.AnyValue{Value: &otlpCommon.AnyValue_StringValue{StringValue: fmt.Sprintf("pod-%d", i)}},
+		Value: anyvalue.String(fmt.Sprintf("pod-%d", i)),
 	})
 
 	r.Attributes = append(r.Attributes, &otlpCommon.KeyValue{
 		Key:   string(semconv.HostNameKey),
-		Value: &otlpCommon.AnyValue{Value: &otlpCommon.AnyValue_StringValue{StringValue: host}},
+		Value: anyvalue.String(host),
 	})
 
+	if len(cfg.Attributes) > 0 {
+		r.Attributes = MergeAttrs(r.Attributes, cfg.Attributes)
+	}
+
 	return r
 }
 
-func NewScope() *otlpCommon.InstrumentationScope {
+// ParseAttrs parses attributes in the format 'key=value', the value
+// may end in :string, :int, :double or :bool to set its type. Values without
+// a known type suffix are strings, so 'url=http://host:8080' works as is.
+func ParseAttrs(values []string) ([]*otlpCommon.KeyValue, error) {
+	attrs := make([]*otlpCommon.KeyValue, 0, len(values))
+	for _, a := range values {
+		key, value, ok := strings.Cut(a, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid attribute format: %q (expected 'key=value' or 'key=value:type')", a)
+		}
+
+		typ := "string"
+		if i := strings.LastIndex(value, ":"); i >= 0 {
+			switch suffix := value[i+1:]; suffix {
+			case "string", "int", "double", "bool":
+				typ, value = suffix, value[:i]
+			}
+		}
+
+		var anyValue *otlpCommon.AnyValue
+		switch typ {
+		case "int":
+			v, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid int attribute %q: %w", key, err)
+			}
+			anyValue = anyvalue.Int(v)
+		case "double":
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid double attribute %q: %w", key, err)
+			}
+			anyValue = anyvalue.Double(v)
+		case "bool":
+			v, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid bool attribute %q: %w", key, err)
+			}
+			anyValue = anyvalue.Bool(v)
+		default:
+			anyValue = anyvalue.String(value)
+		}
+
+		attrs = MergeAttrs(attrs, []*otlpCommon.KeyValue{{Key: key, Value: anyValue}})
+	}
+	return attrs, nil
+}
+
+// Default scope identity of the generated telemetry
+const (
+	DefaultScopeName    = "otlp_worker"
+	DefaultScopeVersion = "1.2.3"
+)
+
+// ScopeConfig sets the instrumentation scope identity shared by all signals,
+// empty fields use the defaults
+type ScopeConfig struct {
+	Name    string
+	Version string
+	// Attributes are merged into the scope attributes, replacing the
+	// built-in attributes with the same key
+	Attributes []*otlpCommon.KeyValue
+}
+
+func NewScope(cfg ScopeConfig) *otlpCommon.InstrumentationScope {
+	if cfg.Name == "" {
+		cfg.Name = DefaultScopeName
+	}
+	if cfg.Version == "" {
+		cfg.Version = DefaultScopeVersion
+	}
+
 	s := &otlpCommon.InstrumentationScope{
-		Name:                   "otlp_worker",
-		Version:                "1.2.3",
+		Name:                   cfg.Name,
+		Version:                cfg.Version,
 		Attributes:             nil,
 		DroppedAttributesCount: 0,
 	}
 
 	s.Attributes = append(s.Attributes, &otlpCommon.KeyValue{
 		Key:   string(semconv.TelemetrySDKNameKey),
-		Value: &otlpCommon.AnyValue{Value: &otlpCommon.AnyValue_StringValue{StringValue: "go"}},
+		Value: anyvalue.String("go"),
 	})
 
+	if len(cfg.Attributes) > 0 {
+		s.Attributes = MergeAttrs(s.Attributes, cfg.Attributes)
+	}
+
 	return s
 }
@@ -0,0 +1,98 @@
+package otlp
+
+import (
+	"testing"
+
+	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+)
+
+func TestParseAttrs(t *testing.T) {
+	attrs, err := ParseAttrs([]string{
+		"k8s.namespace.name=tenant-a",
+		"tenant.id=42:int",
+		"sample.ratio=0.5:double",
+		"canary=true:bool",
+		"endpoint=http://host:8080",
+		"label=a:b:string",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]*otlpCommon.AnyValue)
+	for _, kv := range attrs {
+		got[kv.Key] = kv.Value
+	}
+	if got["k8s.namespace.name"].GetStringValue() != "tenant-a" {
+		t.Errorf("Expected string value, got %v", got["k8s.namespace.name"])
+	}
+	if got["tenant.id"].GetIntValue() != 42 {
+		t.Errorf("Expected int value, got %v", got["tenant.id"])
+	}
+	if got["sample.ratio"].GetDoubleValue() != 0.5 {
+		t.Errorf("Expected double value, got %v", got["sample.ratio"])
+	}
+	if !got["canary"].GetBoolValue() {
+		t.Errorf("Expected bool value, got %v", got["canary"])
+	}
+	if got["endpoint"].GetStringValue() != "http://host:8080" {
+		t.Errorf("Expected colons without a type suffix to be kept, got %v", got["endpoint"])
+	}
+	if got["label"].GetStringValue() != "a:b" {
+		t.Errorf("Expected the :string suffix to be stripped, got %v", got["label"])
+	}
+
+	for _, a := range []string{"tenant", "=x", "n=x:int", "b=maybe:bool", "d=x:double"} {
+		if _, err := ParseAttrs([]string{a}); err == nil {
+			t.Errorf("Expected error parsing %q", a)
+		}
+	}
+}
+
+func TestNewResource_Config(t *testing.T) {
+	attrs, _ := ParseAttrs([]string{"k8s.pod.name=checkout-0", "tenant.id=7:int"})
+	res := NewResource(ResourceConfig{ServiceName: "checkout", Attributes: attrs}, 1, 0)
+
+	got := make(map[string]*otlpCommon.AnyValue)
+	for _, kv := range res.Attributes {
+		if _, ok := got[kv.Key]; ok {
+			t.Errorf("Duplicate resource attribute %s", kv.Key)
+		}
+		got[kv.Key] = kv.Value
+	}
+	if got["service.name"].GetStringValue() != "checkout" {
+		t.Errorf("Expected service.name checkout, got %v", got["service.name"])
+	}
+	if got["k8s.pod.name"].GetStringValue() != "checkout-0" {
+		t.Errorf("Expected the user attribute to replace k8s.pod.name, got %v", got["k8s.pod.name"])
+	}
+	if got["tenant.id"].GetIntValue() != 7 {
+		t.Errorf("Expected tenant.id 7, got %v", got["tenant.id"])
+	}
+
+	def := NewResource(ResourceConfig{}, 1, 0)
+	if def.Attributes[0].GetValue().GetStringValue() != DefaultServiceName {
+		t.Errorf("Expected the default service name, got %v", def.Attributes[0])
+	}
+}
+
+func TestNewScope_Config(t *testing.T) {
+	attrs, _ := ParseAttrs([]string{"telemetry.sdk.name=java", "library.team=mobile"})
+	s := NewScope(ScopeConfig{Name: "io.opentelemetry.okhttp", Version: "4.12.0", Attributes: attrs})
+
+	if s.Name != "io.opentelemetry.okhttp" || s.Version != "4.12.0" {
+		t.Errorf("Expected the configured scope identity, got %s %s", s.Name, s.Version)
+	}
+	got := make(map[string]string)
+	for _, kv := range s.Attributes {
+		got[kv.Key] = kv.GetValue().GetStringValue()
+	}
+	if len(s.Attributes) != 2 || got["telemetry.sdk.name"] != "java" || got["library.team"] != "mobile" {
+		t.Errorf("Expected the scope attributes to be merged, got %v", got)
+	}
+
+	def := NewScope(ScopeConfig{})
+	if def.Name != DefaultScopeName || def.Version != DefaultScopeVersion || len(def.Attributes) != 1 {
+		t.Errorf("Expected the default scope, got %v", def)
+	}
+}
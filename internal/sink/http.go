@@ -0,0 +1,109 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/streamfold/otel-loadgen/internal/compression"
+	v1 "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	v1_metrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	v1_trace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	contentTypeJSON     = "application/json"
+	contentTypeProtobuf = "application/x-protobuf"
+)
+
+// newHTTPHandler serves OTLP/HTTP exports, feeding them through the same
+// services as the gRPC server so both transports are acked identically
+func newHTTPHandler(log *zap.Logger, logs *otlpLogsRPCService, traces *otlpTracesRPCService, metrics *otlpMetricsRPCService) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/v1/traces", func(w http.ResponseWriter, r *http.Request) {
+		handleExport(log, w, r, &v1_trace.ExportTraceServiceRequest{}, func(ctx context.Context, req *v1_trace.ExportTraceServiceRequest) (proto.Message, error) {
+			return traces.Export(ctx, req)
+		})
+	})
+	mux.HandleFunc("/v1/metrics", func(w http.ResponseWriter, r *http.Request) {
+		handleExport(log, w, r, &v1_metrics.ExportMetricsServiceRequest{}, func(ctx context.Context, req *v1_metrics.ExportMetricsServiceRequest) (proto.Message, error) {
+			return metrics.Export(ctx, req)
+		})
+	})
+	mux.HandleFunc("/v1/logs", func(w http.ResponseWriter, r *http.Request) {
+		handleExport(log, w, r, &v1.ExportLogsServiceRequest{}, func(ctx context.Context, req *v1.ExportLogsServiceRequest) (proto.Message, error) {
+			return logs.Export(ctx, req)
+		})
+	})
+
+	return mux
+}
+
+func handleExport[T proto.Message](log *zap.Logger, w http.ResponseWriter, r *http.Request, req T, export func(context.Context, T) (proto.Message, error)) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (contentType != contentTypeJSON && contentType != contentTypeProtobuf) {
+		http.Error(w, fmt.Sprintf("Unsupported content type: %q", r.Header.Get("Content-Type")), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	codec, err := compression.ParseContentEncoding(r.Header.Get("Content-Encoding"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	body, err = codec.Decompress(body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to decompress body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if contentType == contentTypeJSON {
+		err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(body, req)
+	} else {
+		err = proto.Unmarshal(body, req)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to unmarshal request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := export(r.Context(), req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var out []byte
+	if contentType == contentTypeJSON {
+		out, err = protojson.Marshal(resp)
+	} else {
+		out, err = proto.Marshal(resp)
+	}
+	if err != nil {
+		log.Error("failed to marshal export response", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(out)
+}
@@ -0,0 +1,143 @@
+package sink
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/compression"
+	"github.com/streamfold/otel-loadgen/internal/msg_tracker"
+	"github.com/streamfold/otel-loadgen/internal/otlp"
+	v1 "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	v1_trace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	otlpLogs "go.opentelemetry.io/proto/otlp/logs/v1"
+	otlpTraces "go.opentelemetry.io/proto/otlp/trace/v1"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func newTestHTTPServer(mt *msg_tracker.Tracker) *httptest.Server {
+	log := zap.NewNop()
+	return httptest.NewServer(newHTTPHandler(log,
+		&otlpLogsRPCService{log: log, mt: mt},
+		&otlpTracesRPCService{log: log, mt: mt},
+		&otlpMetricsRPCService{log: log, mt: mt},
+	))
+}
+
+func postExport(t *testing.T, url string, contentType string, codec compression.Type, body []byte) *http.Response {
+	t.Helper()
+
+	body, err := codec.Compress(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if encoding := codec.ContentEncoding(); encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	return resp
+}
+
+func TestHTTPExport_TracesProtobufGzip(t *testing.T) {
+	mt := msg_tracker.NewTracker(zap.NewNop())
+	srv := newTestHTTPServer(mt)
+	defer srv.Close()
+
+	gen := newTestMsgIdGenerator("gen-a")
+	res := otlp.NewResource(otlp.ResourceConfig{}, 1, 0)
+	res.Attributes = gen.AddResourceAttrs(res.Attributes)
+
+	spans := make([]*otlpTraces.Span, 0, 4)
+	for i := 0; i < 4; i++ {
+		spans = append(spans, &otlpTraces.Span{Name: "span", Attributes: gen.AddElementAttrs(nil)})
+	}
+	body, err := proto.Marshal(&v1_trace.ExportTraceServiceRequest{
+		ResourceSpans: []*otlpTraces.ResourceSpans{
+			{Resource: res, ScopeSpans: []*otlpTraces.ScopeSpans{{Spans: spans}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := postExport(t, srv.URL+"/v1/traces", contentTypeProtobuf, compression.Gzip, body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != contentTypeProtobuf {
+		t.Errorf("Expected protobuf response, got %q", ct)
+	}
+
+	if report := mt.GeneratorReport(time.Now())["gen-a"]; report.TotalAcked != 4 {
+		t.Errorf("Expected 4 acked spans, got %d", report.TotalAcked)
+	}
+}
+
+func TestHTTPExport_LogsJSON(t *testing.T) {
+	mt := msg_tracker.NewTracker(zap.NewNop())
+	srv := newTestHTTPServer(mt)
+	defer srv.Close()
+
+	gen := newTestMsgIdGenerator("gen-a")
+	res := otlp.NewResource(otlp.ResourceConfig{}, 1, 0)
+	res.Attributes = gen.AddResourceAttrs(res.Attributes)
+
+	body, err := protojson.Marshal(&v1.ExportLogsServiceRequest{
+		ResourceLogs: []*otlpLogs.ResourceLogs{
+			{
+				Resource: res,
+				ScopeLogs: []*otlpLogs.ScopeLogs{{LogRecords: []*otlpLogs.LogRecord{
+					{Attributes: gen.AddElementAttrs(nil)},
+					{Attributes: gen.AddElementAttrs(nil)},
+				}}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := postExport(t, srv.URL+"/v1/logs", "application/json; charset=utf-8", compression.None, body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+
+	if report := mt.GeneratorReport(time.Now())["gen-a"]; report.TotalAcked != 2 {
+		t.Errorf("Expected 2 acked log records, got %d", report.TotalAcked)
+	}
+}
+
+func TestHTTPExport_Rejects(t *testing.T) {
+	srv := newTestHTTPServer(msg_tracker.NewTracker(zap.NewNop()))
+	defer srv.Close()
+
+	if resp := postExport(t, srv.URL+"/v1/metrics", "text/plain", compression.None, nil); resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected 415 for unsupported content type, got %d", resp.StatusCode)
+	}
+	if resp := postExport(t, srv.URL+"/v1/metrics", contentTypeProtobuf, compression.None, []byte("garbage")); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid body, got %d", resp.StatusCode)
+	}
+
+	resp, err := http.Get(srv.URL + "/v1/traces")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for GET, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,55 @@
+package sink
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics counts the telemetry received by the sink for each generator
+type Metrics struct {
+	spans      *prometheus.CounterVec
+	logRecords *prometheus.CounterVec
+	dataPoints *prometheus.CounterVec
+}
+
+// NewMetrics creates the received telemetry counters and registers them with reg
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	newCounter := func(name, help string) *prometheus.CounterVec {
+		c := prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loadgen",
+			Subsystem: "sink",
+			Name:      name,
+			Help:      help,
+		}, []string{"generator_id"})
+		reg.MustRegister(c)
+		return c
+	}
+
+	return &Metrics{
+		spans:      newCounter("received_spans_total", "Total number of spans received by the sink."),
+		logRecords: newCounter("received_log_records_total", "Total number of log records received by the sink."),
+		dataPoints: newCounter("received_data_points_total", "Total number of metric data points received by the sink."),
+	}
+}
+
+// The add methods are no-ops on a nil Metrics, so services work without metrics
+
+func (m *Metrics) addSpans(genID string, n int) {
+	if m == nil || n == 0 {
+		return
+	}
+	m.spans.WithLabelValues(genID).Add(float64(n))
+}
+
+func (m *Metrics) addLogRecords(genID string, n int) {
+	if m == nil || n == 0 {
+		return
+	}
+	m.logRecords.WithLabelValues(genID).Add(float64(n))
+}
+
+func (m *Metrics) addDataPoints(genID string, n int) {
+	if m == nil || n == 0 {
+		return
+	}
+	m.dataPoints.WithLabelValues(genID).Add(float64(n))
+}
@@ -0,0 +1,68 @@
+package sink
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/streamfold/otel-loadgen/internal/msg_tracker"
+	"github.com/streamfold/otel-loadgen/internal/otlp"
+	v1_trace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	otlpTraces "go.opentelemetry.io/proto/otlp/trace/v1"
+	"go.uber.org/zap"
+)
+
+func TestMetrics_CountsReceivedSpans(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	svc := &otlpTracesRPCService{log: zap.NewNop(), mt: msg_tracker.NewTracker(zap.NewNop()), metrics: NewMetrics(reg)}
+
+	gen := newTestMsgIdGenerator("gen-a")
+	res := otlp.NewResource(otlp.ResourceConfig{}, 1, 0)
+	res.Attributes = gen.AddResourceAttrs(res.Attributes)
+
+	spans := make([]*otlpTraces.Span, 0, 3)
+	for i := 0; i < 3; i++ {
+		spans = append(spans, &otlpTraces.Span{Attributes: gen.AddElementAttrs(nil)})
+	}
+	req := &v1_trace.ExportTraceServiceRequest{
+		ResourceSpans: []*otlpTraces.ResourceSpans{
+			{Resource: res, ScopeSpans: []*otlpTraces.ScopeSpans{{Spans: spans}}},
+		},
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := svc.Export(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, mf := range families {
+		if mf.GetName() != "loadgen_sink_received_spans_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			if len(m.GetLabel()) != 1 || m.GetLabel()[0].GetName() != "generator_id" || m.GetLabel()[0].GetValue() != "gen-a" {
+				t.Errorf("Unexpected labels: %v", m.GetLabel())
+			}
+			if v := m.GetCounter().GetValue(); v != 6 {
+				t.Errorf("Expected 6 received spans, got %v", v)
+			}
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected loadgen_sink_received_spans_total to be exported")
+	}
+}
+
+func TestMetrics_NilIsNoop(t *testing.T) {
+	var m *Metrics
+	m.addSpans("gen-a", 1)
+	m.addLogRecords("gen-a", 1)
+	m.addDataPoints("gen-a", 1)
+}
@@ -10,55 +10,90 @@ import (
 	v1 "go.opentelemetry.io/proto/otlp/collector/logs/v1"
 	v1_metrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
 	v1_trace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+	otlpMetrics "go.opentelemetry.io/proto/otlp/metrics/v1"
 	"go.uber.org/zap"
 )
 
 type otlpLogsRPCService struct {
-	log *zap.Logger
-	mt  *msg_tracker.Tracker
+	log     *zap.Logger
+	mt      *msg_tracker.Tracker
+	metrics *Metrics
 	v1.UnimplementedLogsServiceServer
 }
 
 type otlpTracesRPCService struct {
-	log *zap.Logger
-	mt  *msg_tracker.Tracker
+	log     *zap.Logger
+	mt      *msg_tracker.Tracker
+	metrics *Metrics
 	v1_trace.UnimplementedTraceServiceServer
 	count atomic.Int64
 }
 
 type otlpMetricsRPCService struct {
-	log *zap.Logger
-	mt  *msg_tracker.Tracker
+	log     *zap.Logger
+	mt      *msg_tracker.Tracker
+	metrics *Metrics
 	v1_metrics.UnimplementedMetricsServiceServer
 	count atomic.Int64
 }
 
-func (o otlpLogsRPCService) Export(ctx context.Context, request *v1.ExportLogsServiceRequest) (*v1.ExportLogsServiceResponse, error) {
+func (o *otlpLogsRPCService) Export(ctx context.Context, request *v1.ExportLogsServiceRequest) (*v1.ExportLogsServiceResponse, error) {
+	for _, rl := range request.ResourceLogs {
+		genID := worker.ExtractGeneratorId(rl.GetResource().GetAttributes())
+
+		for _, sl := range rl.ScopeLogs {
+			if genID != "" {
+				o.metrics.addLogRecords(genID, len(sl.LogRecords))
+			}
+			for _, record := range sl.LogRecords {
+				recordGenID := genID
+				if recordGenID == "" {
+					if recordGenID = elementGeneratorId(o.log, record.Attributes); recordGenID == "" {
+						continue
+					}
+					o.metrics.addLogRecords(recordGenID, 1)
+				}
+
+				msgID, got := worker.ExtractMsgIdParams(record.Attributes)
+				if !got {
+					fmt.Printf("failed to extract msg id params\n")
+					continue
+				}
+
+				o.mt.Ack(recordGenID, msgID.StartID, msgID.Len, msgID.ID)
+			}
+		}
+	}
+
 	return &v1.ExportLogsServiceResponse{}, nil
 }
 
 func (o *otlpTracesRPCService) Export(ctx context.Context, request *v1_trace.ExportTraceServiceRequest) (*v1_trace.ExportTraceServiceResponse, error) {
 	for _, rs := range request.ResourceSpans {
-		if rs.Resource == nil {
-			continue
-		}
-		
-		genID := worker.ExtractGeneratorId(rs.Resource.Attributes)
-		if genID == "" {
-			fmt.Printf("failed to extract generator id param\n")
-			continue
-		}
+		genID := worker.ExtractGeneratorId(rs.GetResource().GetAttributes())
 		
 		for _, ss := range rs.ScopeSpans {
+			if genID != "" {
+				o.metrics.addSpans(genID, len(ss.Spans))
+			}
 			for _, span := range ss.Spans {
+				spanGenID := genID
+				if spanGenID == "" {
+					if spanGenID = elementGeneratorId(o.log, span.Attributes); spanGenID == "" {
+						continue
+					}
+					o.metrics.addSpans(spanGenID, 1)
+				}
+
 				msgID, got := worker.ExtractMsgIdParams(span.Attributes)
 				if !got {
 					fmt.Printf("failed to extract msg id params\n")
 					continue
 				}
 				
-				//fmt.Printf("acking: %s, %v\n", genID, msgID)
-				o.mt.Ack(genID, msgID.StartID, msgID.Len, msgID.ID)
+				//fmt.Printf("acking: %s, %v\n", spanGenID, msgID)
+				o.mt.Ack(spanGenID, msgID.StartID, msgID.Len, msgID.ID)
 			}
 		}
 		
@@ -68,5 +103,77 @@ func (o *otlpTracesRPCService) Export(ctx context.Context, request *v1_trace.Exp
 }
 
 func (o *otlpMetricsRPCService) Export(ctx context.Context, request *v1_metrics.ExportMetricsServiceRequest) (*v1_metrics.ExportMetricsServiceResponse, error) {
+	for _, rm := range request.ResourceMetrics {
+		genID := worker.ExtractGeneratorId(rm.GetResource().GetAttributes())
+
+		for _, sm := range rm.ScopeMetrics {
+			for _, metric := range sm.Metrics {
+				switch data := metric.Data.(type) {
+				case *otlpMetrics.Metric_Gauge:
+					o.countDataPoints(genID, len(data.Gauge.DataPoints))
+					for _, dp := range data.Gauge.DataPoints {
+						o.ackDataPoint(genID, dp)
+					}
+				case *otlpMetrics.Metric_Sum:
+					o.countDataPoints(genID, len(data.Sum.DataPoints))
+					for _, dp := range data.Sum.DataPoints {
+						o.ackDataPoint(genID, dp)
+					}
+				case *otlpMetrics.Metric_Histogram:
+					o.countDataPoints(genID, len(data.Histogram.DataPoints))
+					for _, dp := range data.Histogram.DataPoints {
+						o.ackDataPoint(genID, dp)
+					}
+				case *otlpMetrics.Metric_ExponentialHistogram:
+					o.countDataPoints(genID, len(data.ExponentialHistogram.DataPoints))
+					for _, dp := range data.ExponentialHistogram.DataPoints {
+						o.ackDataPoint(genID, dp)
+					}
+				case *otlpMetrics.Metric_Summary:
+					o.countDataPoints(genID, len(data.Summary.DataPoints))
+					for _, dp := range data.Summary.DataPoints {
+						o.ackDataPoint(genID, dp)
+					}
+				}
+			}
+		}
+	}
+
 	return &v1_metrics.ExportMetricsServiceResponse{}, nil
 }
+
+// countDataPoints counts the data points of a metric when the resource carries
+// the generator ID, otherwise they are counted as they are acked
+func (o *otlpMetricsRPCService) countDataPoints(genID string, n int) {
+	if genID != "" {
+		o.metrics.addDataPoints(genID, n)
+	}
+}
+
+func (o *otlpMetricsRPCService) ackDataPoint(genID string, dp any) {
+	if genID == "" {
+		if genID = elementGeneratorId(o.log, worker.DataPointAttributes(dp)); genID == "" {
+			return
+		}
+		o.metrics.addDataPoints(genID, 1)
+	}
+
+	msgID, got := worker.ExtractMsgIdParamsFromDataPoint(dp)
+	if !got {
+		fmt.Printf("failed to extract msg id params\n")
+		return
+	}
+
+	o.mt.Ack(genID, msgID.StartID, msgID.Len, msgID.ID)
+}
+
+// elementGeneratorId returns the generator ID of an element whose resource
+// lost it, e.g. to a processor that rewrote resources. Elements only carry it
+// when the generator stamps it on every element, other elements are skipped.
+func elementGeneratorId(log *zap.Logger, attrs []*otlpCommon.KeyValue) string {
+	genID := worker.ExtractGeneratorId(attrs)
+	if genID == "" {
+		log.Debug("skipping element without a generator id")
+	}
+	return genID
+}
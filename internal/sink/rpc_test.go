@@ -0,0 +1,180 @@
+package sink
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/control"
+	"github.com/streamfold/otel-loadgen/internal/msg_tracker"
+	"github.com/streamfold/otel-loadgen/internal/otlp"
+	"github.com/streamfold/otel-loadgen/internal/worker"
+	v1 "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	v1_metrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	v1_trace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	otlpLogs "go.opentelemetry.io/proto/otlp/logs/v1"
+	otlpMetrics "go.opentelemetry.io/proto/otlp/metrics/v1"
+	otlpTraces "go.opentelemetry.io/proto/otlp/trace/v1"
+	"go.uber.org/zap"
+)
+
+// newTestMsgIdGenerator returns a generator whose ranges are discarded
+func newTestMsgIdGenerator(generatorID string) worker.MsgIdGenerator {
+	ctrlChan := make(chan control.Control, 10)
+	return worker.NewMsgIdGenerator(generatorID, worker.ALLOC_SIZE, control.ChanSender(ctrlChan))
+}
+
+func TestLogsExport_AcksRecords(t *testing.T) {
+	mt := msg_tracker.NewTracker(zap.NewNop())
+	svc := &otlpLogsRPCService{log: zap.NewNop(), mt: mt}
+
+	gen := newTestMsgIdGenerator("gen-a")
+	res := otlp.NewResource(otlp.ResourceConfig{}, 1, 0)
+	res.Attributes = gen.AddResourceAttrs(res.Attributes)
+
+	records := make([]*otlpLogs.LogRecord, 0, 5)
+	for i := 0; i < 5; i++ {
+		records = append(records, &otlpLogs.LogRecord{Attributes: gen.AddElementAttrs(nil)})
+	}
+	req := &v1.ExportLogsServiceRequest{
+		ResourceLogs: []*otlpLogs.ResourceLogs{
+			{
+				Resource:  res,
+				ScopeLogs: []*otlpLogs.ScopeLogs{{LogRecords: records}},
+			},
+		},
+	}
+
+	// The second export is a redelivery of the same records
+	for i := 0; i < 2; i++ {
+		if _, err := svc.Export(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, ok := mt.GeneratorReport(time.Now())["gen-a"]
+	if !ok {
+		t.Fatal("Expected generator to be tracked")
+	}
+	if report.TotalAcked != 5 {
+		t.Errorf("Expected 5 acked log records, got %d", report.TotalAcked)
+	}
+	if report.TotalDuped != 5 {
+		t.Errorf("Expected 5 duplicated log records, got %d", report.TotalDuped)
+	}
+}
+
+func TestMetricsExport_AcksDataPoints(t *testing.T) {
+	mt := msg_tracker.NewTracker(zap.NewNop())
+	svc := &otlpMetricsRPCService{log: zap.NewNop(), mt: mt}
+
+	gen := newTestMsgIdGenerator("gen-a")
+	res := otlp.NewResource(otlp.ResourceConfig{}, 1, 0)
+	res.Attributes = gen.AddResourceAttrs(res.Attributes)
+
+	metrics := []*otlpMetrics.Metric{
+		{Data: &otlpMetrics.Metric_Gauge{Gauge: &otlpMetrics.Gauge{
+			DataPoints: []*otlpMetrics.NumberDataPoint{{Attributes: gen.AddElementAttrs(nil)}},
+		}}},
+		{Data: &otlpMetrics.Metric_Sum{Sum: &otlpMetrics.Sum{
+			DataPoints: []*otlpMetrics.NumberDataPoint{{Attributes: gen.AddElementAttrs(nil)}},
+		}}},
+		{Data: &otlpMetrics.Metric_Histogram{Histogram: &otlpMetrics.Histogram{
+			DataPoints: []*otlpMetrics.HistogramDataPoint{{Attributes: gen.AddElementAttrs(nil)}},
+		}}},
+		{Data: &otlpMetrics.Metric_ExponentialHistogram{ExponentialHistogram: &otlpMetrics.ExponentialHistogram{
+			DataPoints: []*otlpMetrics.ExponentialHistogramDataPoint{{Attributes: gen.AddElementAttrs(nil)}},
+		}}},
+	}
+	req := &v1_metrics.ExportMetricsServiceRequest{
+		ResourceMetrics: []*otlpMetrics.ResourceMetrics{
+			{
+				Resource:     res,
+				ScopeMetrics: []*otlpMetrics.ScopeMetrics{{Metrics: metrics}},
+			},
+		},
+	}
+
+	if _, err := svc.Export(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+
+	report := mt.GeneratorReport(time.Now())["gen-a"]
+	if report.TotalAcked != uint(len(metrics)) {
+		t.Errorf("Expected %d acked data points, got %d", len(metrics), report.TotalAcked)
+	}
+}
+
+func TestTracesExport_ElementGeneratorId(t *testing.T) {
+	mt := msg_tracker.NewTracker(zap.NewNop())
+	svc := &otlpTracesRPCService{log: zap.NewNop(), mt: mt}
+
+	gen := worker.NewElementMsgIdGenerator("gen-a", worker.ALLOC_SIZE, control.ChanSender(make(chan control.Control, 10)))
+	spans := make([]*otlpTraces.Span, 0, 5)
+	for i := 0; i < 5; i++ {
+		spans = append(spans, &otlpTraces.Span{Attributes: gen.AddElementAttrs(nil)})
+	}
+
+	// A processor dropped the resource, only the spans carry the generator id
+	req := &v1_trace.ExportTraceServiceRequest{
+		ResourceSpans: []*otlpTraces.ResourceSpans{
+			{ScopeSpans: []*otlpTraces.ScopeSpans{{Spans: spans}}},
+		},
+	}
+	if _, err := svc.Export(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+
+	if report := mt.GeneratorReport(time.Now())["gen-a"]; report.TotalAcked != 5 {
+		t.Errorf("Expected 5 acked spans, got %d", report.TotalAcked)
+	}
+}
+
+func TestMetricsExport_ElementGeneratorId(t *testing.T) {
+	mt := msg_tracker.NewTracker(zap.NewNop())
+	svc := &otlpMetricsRPCService{log: zap.NewNop(), mt: mt}
+
+	gen := worker.NewElementMsgIdGenerator("gen-a", worker.ALLOC_SIZE, control.ChanSender(make(chan control.Control, 10)))
+	req := &v1_metrics.ExportMetricsServiceRequest{
+		ResourceMetrics: []*otlpMetrics.ResourceMetrics{
+			{
+				Resource: otlp.NewResource(otlp.ResourceConfig{}, 1, 0),
+				ScopeMetrics: []*otlpMetrics.ScopeMetrics{{Metrics: []*otlpMetrics.Metric{
+					{Data: &otlpMetrics.Metric_Histogram{Histogram: &otlpMetrics.Histogram{
+						DataPoints: []*otlpMetrics.HistogramDataPoint{
+							{Attributes: gen.AddElementAttrs(nil)}, {Attributes: gen.AddElementAttrs(nil)},
+						},
+					}}},
+				}}},
+			},
+		},
+	}
+	if _, err := svc.Export(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+
+	if report := mt.GeneratorReport(time.Now())["gen-a"]; report.TotalAcked != 2 {
+		t.Errorf("Expected 2 acked data points, got %d", report.TotalAcked)
+	}
+}
+
+func TestLogsExport_NoGeneratorId(t *testing.T) {
+	mt := msg_tracker.NewTracker(zap.NewNop())
+	svc := &otlpLogsRPCService{log: zap.NewNop(), mt: mt}
+
+	// Without element ids, records of a resource without the generator id
+	// can't be attributed
+	gen := newTestMsgIdGenerator("gen-a")
+	req := &v1.ExportLogsServiceRequest{
+		ResourceLogs: []*otlpLogs.ResourceLogs{
+			{ScopeLogs: []*otlpLogs.ScopeLogs{{LogRecords: []*otlpLogs.LogRecord{{Attributes: gen.AddElementAttrs(nil)}}}}},
+		},
+	}
+	if _, err := svc.Export(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+
+	if acked := mt.TotalAcked(); acked != 0 {
+		t.Errorf("Expected no acked records, got %d", acked)
+	}
+}
@@ -1,12 +1,17 @@
 package sink
 
 import (
+	"errors"
 	"fmt"
 	"net"
+	"net/http"
 	"net/url"
+	"os"
+	"strconv"
 	"strings"
 
 	"github.com/streamfold/otel-loadgen/internal/msg_tracker"
+	"github.com/streamfold/otel-loadgen/internal/util"
 	v1 "go.opentelemetry.io/proto/otlp/collector/logs/v1"
 	v1_metrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
 	v1_trace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
@@ -20,10 +25,16 @@ type Sink struct {
 	log  *zap.Logger
 	srv  *grpc.Server
 	mt *msg_tracker.Tracker
+
+	// httpAddr is the OTLP/HTTP listen address, empty disables the listener
+	httpAddr string
+	httpSrv  *http.Server
+
+	metrics *Metrics
 }
 
-func New(addr string, mt *msg_tracker.Tracker, log *zap.Logger) (*Sink, error) {
-	if !strings.HasPrefix(addr, "http://") && !strings.HasPrefix(addr, "https://") {
+func New(addr string, httpAddr string, mt *msg_tracker.Tracker, log *zap.Logger) (*Sink, error) {
+	if !util.IsUnixAddr(addr) && !strings.HasPrefix(addr, "http://") && !strings.HasPrefix(addr, "https://") {
 		addr = fmt.Sprintf("http://%s", addr)
 	}
 	u, err := url.Parse(addr)
@@ -36,23 +47,51 @@ func New(addr string, mt *msg_tracker.Tracker, log *zap.Logger) (*Sink, error) {
 		log:  log,
 		mt: mt,
 		srv:  grpc.NewServer(),
+		httpAddr: httpAddr,
 	}, nil
 }
 
+// Addr returns the sink address, once started a port of 0 is replaced with the
+// port listened on
 func (s *Sink) Addr() string {
 	return s.addr.String()
 }
 
+// SetMetrics enables counting received telemetry, it must be called before Start
+func (s *Sink) SetMetrics(m *Metrics) {
+	s.metrics = m
+}
+
 func (s *Sink) Start() error {
-	v1.RegisterLogsServiceServer(s.srv, &otlpLogsRPCService{log: s.log, mt: s.mt})
-	v1_trace.RegisterTraceServiceServer(s.srv, &otlpTracesRPCService{log: s.log, mt: s.mt})
-	v1_metrics.RegisterMetricsServiceServer(s.srv, &otlpMetricsRPCService{log: s.log, mt: s.mt})
+	logsSvc := &otlpLogsRPCService{log: s.log, mt: s.mt, metrics: s.metrics}
+	tracesSvc := &otlpTracesRPCService{log: s.log, mt: s.mt, metrics: s.metrics}
+	metricsSvc := &otlpMetricsRPCService{log: s.log, mt: s.mt, metrics: s.metrics}
+
+	v1.RegisterLogsServiceServer(s.srv, logsSvc)
+	v1_trace.RegisterTraceServiceServer(s.srv, tracesSvc)
+	v1_metrics.RegisterMetricsServiceServer(s.srv, metricsSvc)
 
-	s.log.Info("Starting sink", zap.String("addr", fmt.Sprintf(":%s", s.addr.Port())))
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", s.addr.Port()))
+	network, listenAddr := "tcp", fmt.Sprintf(":%s", s.addr.Port())
+	if path, ok := util.UnixSocketPath(s.addr); ok {
+		network, listenAddr = "unix", path
+		if err := removeStaleSocket(path); err != nil {
+			return err
+		}
+	}
+
+	s.log.Info("Starting sink", zap.String("addr", listenAddr))
+	lis, err := net.Listen(network, listenAddr)
 	if err != nil {
 		return err
 	}
+	if tcpAddr, ok := lis.Addr().(*net.TCPAddr); ok && s.addr.Port() == "0" {
+		// Report the port picked by the kernel
+		host := s.addr.Hostname()
+		if host == "" {
+			host = "localhost"
+		}
+		s.addr.Host = net.JoinHostPort(host, strconv.Itoa(tcpAddr.Port))
+	}
 
 	go func() {
 		if err := s.srv.Serve(lis); err != nil {
@@ -60,9 +99,41 @@ func (s *Sink) Start() error {
 		}
 	}()
 
+	if s.httpAddr != "" {
+		httpLis, err := net.Listen("tcp", s.httpAddr)
+		if err != nil {
+			s.srv.Stop()
+			return err
+		}
+
+		s.httpSrv = &http.Server{
+			Handler: newHTTPHandler(s.log, logsSvc, tracesSvc, metricsSvc),
+		}
+
+		s.log.Info("Starting OTLP/HTTP sink", zap.String("addr", s.httpAddr))
+		go func() {
+			if err := s.httpSrv.Serve(httpLis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				s.log.Error("http sink server error", zap.Error(err))
+			}
+		}()
+	}
+
 	return nil
 }
 
+// removeStaleSocket removes a socket left behind by a previous sink, any other
+// file at path is kept and the listen fails
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if err != nil || fi.Mode()&os.ModeSocket == 0 {
+		return nil
+	}
+	return os.Remove(path)
+}
+
 func (s *Sink) Stop() {
+	if s.httpSrv != nil {
+		_ = s.httpSrv.Close()
+	}
 	s.srv.GracefulStop()
 }
@@ -0,0 +1,95 @@
+package stats
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var csvHeader = []string{"timestamp", "domain", "stat", "delta", "rate", "value"}
+
+// CSVWriter appends stats reports to a CSV file, one row per stat. Rate stats
+// fill delta and rate, gauges fill value and latency stats add a row per
+// percentile with the value in milliseconds.
+type CSVWriter struct {
+	f *os.File
+	w *csv.Writer
+}
+
+// NewCSVWriter creates the file at path, truncating it, and writes the header
+func NewCSVWriter(path string) (*CSVWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stats csv: %w", err)
+	}
+
+	c := &CSVWriter{f: f, w: csv.NewWriter(f)}
+	if err := c.w.Write(csvHeader); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Write appends the rows of the reports, sorted by domain, and flushes them
+func (c *CSVWriter) Write(now time.Time, reports map[string][]StatReport) error {
+	domains := make([]string, 0, len(reports))
+	for domain := range reports {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+
+	ts := now.UTC().Format(time.RFC3339Nano)
+	for _, domain := range domains {
+		for _, r := range reports[domain] {
+			for _, row := range r.csvRows() {
+				if err := c.w.Write(append([]string{ts, domain}, row...)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// Close flushes any pending rows and closes the file
+func (c *CSVWriter) Close() error {
+	c.w.Flush()
+	if err := c.w.Error(); err != nil {
+		c.f.Close()
+		return err
+	}
+	return c.f.Close()
+}
+
+// csvRows returns the stat, delta, rate and value columns of the report
+func (s StatReport) csvRows() [][]string {
+	name := s.statType.String()
+
+	switch {
+	case s.statType.isLatency():
+		rows := make([][]string, 0, len(s.percentiles))
+		for i, p := range s.percentiles {
+			ms := float64(p) / float64(time.Millisecond)
+			rows = append(rows, []string{fmt.Sprintf("%s_p%g", name, ReportedPercentiles[i]), "", "", formatFloat(ms)})
+		}
+		return rows
+	case s.statType.isGauge():
+		return [][]string{{name, "", "", strconv.FormatUint(s.delta, 10)}}
+	default:
+		rate := 0.0
+		if secs := s.dur.Seconds(); secs > 0 {
+			rate = float64(s.delta) / secs
+		}
+		return [][]string{{name, strconv.FormatUint(s.delta, 10), formatFloat(rate), ""}}
+	}
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,62 @@
+package stats
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCSVWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.csv")
+	c, err := NewCSVWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	reports := map[string][]StatReport{
+		"OTLP Traces": {
+			{statType: StatSpansSent, delta: 3000, dur: 1500 * time.Millisecond},
+			{statType: StatQueueDepth, delta: 4},
+			{statType: StatExportLatency, percentiles: []time.Duration{time.Millisecond, 2 * time.Millisecond, 2500 * time.Microsecond}},
+		},
+		"OTLP Logs": {
+			{statType: StatLogsSent, delta: 10, dur: time.Second},
+		},
+	}
+	if err := c.Write(now, reports); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rows are flushed on each write
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := "2025-01-01T00:00:00Z"
+	expected := [][]string{
+		csvHeader,
+		{ts, "OTLP Logs", "logs_sent", "10", "10", ""},
+		{ts, "OTLP Traces", "spans_sent", "3000", "2000", ""},
+		{ts, "OTLP Traces", "queue_depth", "", "", "4"},
+		{ts, "OTLP Traces", "export_latency_p50", "", "", "1"},
+		{ts, "OTLP Traces", "export_latency_p90", "", "", "2"},
+		{ts, "OTLP Traces", "export_latency_p99", "", "", "2.5"},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("Unexpected rows:\n%v\nexpected:\n%v", rows, expected)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
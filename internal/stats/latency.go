@@ -0,0 +1,142 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Latency is a stat that records durations and reports their percentiles
+type Latency interface {
+	Record(d time.Duration)
+}
+
+// ReportedPercentiles are the percentiles printed for latency stats
+var ReportedPercentiles = []float64{50, 90, 99}
+
+// The histogram follows the HDR layout: values below subBucketCount are
+// recorded exactly, above that every power of two is split into
+// subBucketHalf linear buckets, bounding the relative error to 1/64.
+const (
+	subBucketBits  = 7
+	subBucketCount = 1 << subBucketBits
+	subBucketHalf  = subBucketCount / 2
+	// Microsecond values need at most 64 - subBucketBits + 1 magnitudes
+	numBuckets = subBucketCount + (64-subBucketBits)*subBucketHalf
+)
+
+// histogram counts microsecond values in log-linear buckets, it is safe for
+// concurrent use
+type histogram struct {
+	counts [numBuckets]atomic.Uint64
+}
+
+func bucketIndex(v uint64) int {
+	if v < subBucketCount {
+		return int(v)
+	}
+	shift := bits.Len64(v) - subBucketBits
+	top := v >> shift
+	return subBucketCount + (shift-1)*subBucketHalf + int(top-subBucketHalf)
+}
+
+// bucketValue returns the midpoint of the values counted in bucket idx
+func bucketValue(idx int) uint64 {
+	if idx < subBucketCount {
+		return uint64(idx)
+	}
+	shift := (idx-subBucketCount)/subBucketHalf + 1
+	top := uint64((idx-subBucketCount)%subBucketHalf + subBucketHalf)
+	return top<<shift + (uint64(1)<<shift)/2
+}
+
+func (h *histogram) record(v uint64) {
+	h.counts[bucketIndex(v)].Add(1)
+}
+
+// snapshot returns the current bucket counts
+func (h *histogram) snapshot() []uint64 {
+	out := make([]uint64, numBuckets)
+	for i := range h.counts {
+		out[i] = h.counts[i].Load()
+	}
+	return out
+}
+
+// percentiles returns the values at each percentile of the counts, ok is false
+// if counts is empty
+func percentiles(counts []uint64, ps []float64) ([]time.Duration, bool) {
+	var total uint64
+	for _, c := range counts {
+		total += c
+	}
+	if total == 0 {
+		return nil, false
+	}
+
+	out := make([]time.Duration, len(ps))
+	for i, p := range ps {
+		rank := max(uint64(math.Ceil(p/100*float64(total))), 1)
+
+		var seen uint64
+		for idx, c := range counts {
+			seen += c
+			if seen >= rank {
+				out[i] = time.Duration(bucketValue(idx)) * time.Microsecond
+				break
+			}
+		}
+	}
+	return out, true
+}
+
+type latency struct {
+	statType StatType
+	hist     histogram
+
+	lastReportMut    sync.Mutex
+	lastReportCounts []uint64
+}
+
+func (l *latency) Record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	l.hist.record(uint64(d / time.Microsecond))
+}
+
+// intervalPercentiles returns the percentiles of the values recorded since the
+// last call
+func (l *latency) intervalPercentiles() ([]time.Duration, bool) {
+	curr := l.hist.snapshot()
+
+	l.lastReportMut.Lock()
+	delta := make([]uint64, numBuckets)
+	for i := range curr {
+		delta[i] = curr[i]
+		if l.lastReportCounts != nil {
+			delta[i] -= l.lastReportCounts[i]
+		}
+	}
+	l.lastReportCounts = curr
+	l.lastReportMut.Unlock()
+
+	return percentiles(delta, ReportedPercentiles)
+}
+
+// totalPercentiles returns the percentiles of every recorded value
+func (l *latency) totalPercentiles() ([]time.Duration, bool) {
+	return percentiles(l.hist.snapshot(), ReportedPercentiles)
+}
+
+func formatPercentiles(desc string, values []time.Duration) string {
+	parts := make([]string, 0, len(values))
+	for i, v := range values {
+		parts = append(parts, fmt.Sprintf("p%g %s", ReportedPercentiles[i], v.Round(time.Microsecond)))
+	}
+	return fmt.Sprintf("%s (%s)", desc, strings.Join(parts, ", "))
+}
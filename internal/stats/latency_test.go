@@ -0,0 +1,78 @@
+package stats
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBucketIndex_RoundTrip(t *testing.T) {
+	for _, v := range []uint64{0, 1, 127, 128, 129, 1000, 12345, 1 << 20, 987654321, 1<<63 + 12345} {
+		got := bucketValue(bucketIndex(v))
+		// Values are bucketed with a relative error of at most 1/64
+		diff := float64(got) - float64(v)
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff > float64(v)/64+1 {
+			t.Errorf("value %d bucketed as %d", v, got)
+		}
+	}
+
+	if idx := bucketIndex(^uint64(0)); idx >= numBuckets {
+		t.Errorf("Expected max value to fit in %d buckets, got index %d", numBuckets, idx)
+	}
+}
+
+func TestLatency_Percentiles(t *testing.T) {
+	l := &latency{statType: StatExportLatency}
+	// 1ms..100ms, one sample each
+	for i := 1; i <= 100; i++ {
+		l.Record(time.Duration(i) * time.Millisecond)
+	}
+
+	ps, ok := l.intervalPercentiles()
+	if !ok {
+		t.Fatal("Expected percentiles")
+	}
+	for i, want := range []time.Duration{50 * time.Millisecond, 90 * time.Millisecond, 99 * time.Millisecond} {
+		if diff := ps[i] - want; diff < -want/64 || diff > want/64 {
+			t.Errorf("p%g: expected about %s, got %s", ReportedPercentiles[i], want, ps[i])
+		}
+	}
+
+	// The next interval only covers values recorded since the last report
+	if _, ok := l.intervalPercentiles(); ok {
+		t.Error("Expected no percentiles for an empty interval")
+	}
+	l.Record(5 * time.Second)
+	ps, _ = l.intervalPercentiles()
+	if ps[0] < 4900*time.Millisecond {
+		t.Errorf("Expected interval p50 of about 5s, got %s", ps[0])
+	}
+
+	// Totals cover every recorded value
+	ps, _ = l.totalPercentiles()
+	if ps[0] > 60*time.Millisecond {
+		t.Errorf("Expected total p50 of about 50ms, got %s", ps[0])
+	}
+}
+
+func TestLatency_Report(t *testing.T) {
+	tracker := NewStatTracker()
+	l := tracker.NewDomain("OTLP Traces").NewLatency(StatExportLatency)
+	l.Record(2 * time.Millisecond)
+
+	reports := tracker.Report(time.Now())["OTLP Traces"]
+	if len(reports) != 1 {
+		t.Fatalf("Expected 1 report, got %d", len(reports))
+	}
+	out := reports[0].Report()
+	if !strings.HasPrefix(out, "export latency (p50 2.0") || !strings.Contains(out, "p99") {
+		t.Errorf("Unexpected report: %s", out)
+	}
+
+	if p := tracker.Percentiles()["OTLP Traces"]["p99"]; p < 1.9 || p > 2.1 {
+		t.Errorf("Expected p99 of about 2ms, got %v", p)
+	}
+}
@@ -10,8 +10,16 @@ type Stat interface {
 	Incr(delta uint64)
 }
 
+// Gauge is a stat that reports its current value rather than a rate
+type Gauge interface {
+	Stat
+	Decr(delta uint64)
+}
+
 type stat struct {
 	statType StatType
+	// target is the expected rate per second, zero if there is none
+	target float64
 
 	value atomic.Uint64
 
@@ -24,6 +32,10 @@ func (s *stat) Incr(delta uint64) {
 	s.value.Add(delta)
 }
 
+func (s *stat) Decr(delta uint64) {
+	s.value.Add(^(delta - 1))
+}
+
 type StatType int
 
 const (
@@ -33,6 +45,11 @@ const (
 	StatMetricsSent
 	StatLogsSent
 	StatSpansSent
+	StatSpansInvalid
+	StatQueueDepth
+	StatExportErrors
+	StatRejected
+	StatExportLatency
 )
 
 func (s StatType) String() string {
@@ -49,6 +66,16 @@ func (s StatType) String() string {
 		return "logs_sent"
 	case StatSpansSent:
 		return "spans_sent"
+	case StatSpansInvalid:
+		return "spans_invalid"
+	case StatQueueDepth:
+		return "queue_depth"
+	case StatExportErrors:
+		return "export_errors"
+	case StatRejected:
+		return "rejected"
+	case StatExportLatency:
+		return "export_latency"
 	default:
 		return "unknown"
 	}
@@ -68,6 +95,16 @@ func (s StatType) desc() string {
 		return "logs"
 	case StatSpansSent:
 		return "spans"
+	case StatSpansInvalid:
+		return "invalid spans"
+	case StatQueueDepth:
+		return "queued batches"
+	case StatExportErrors:
+		return "export errors"
+	case StatRejected:
+		return "rejected"
+	case StatExportLatency:
+		return "export latency"
 	default:
 		return ""
 	}
@@ -87,6 +124,14 @@ func (s StatType) unit() string {
 		return "logs"
 	case StatSpansSent:
 		return "spans"
+	case StatSpansInvalid:
+		return "spans"
+	case StatQueueDepth:
+		return "batches"
+	case StatExportErrors:
+		return "errors"
+	case StatRejected:
+		return "items"
 	default:
 		return ""
 	}
@@ -106,7 +151,25 @@ func (s StatType) factor() float64 {
 		return 1.0
 	case StatSpansSent:
 		return 1.0
+	case StatSpansInvalid:
+		return 1.0
+	case StatQueueDepth:
+		return 1.0
+	case StatExportErrors:
+		return 1.0
+	case StatRejected:
+		return 1.0
 	default:
 		return 0.0
 	}
 }
+
+// isGauge returns true for stats reported as their current value
+func (s StatType) isGauge() bool {
+	return s == StatQueueDepth
+}
+
+// isLatency returns true for stats reported as percentiles
+func (s StatType) isLatency() bool {
+	return s == StatExportLatency
+}
@@ -1,6 +1,7 @@
 package stats
 
 import (
+	"encoding/json"
 	"fmt"
 	"sort"
 	"strings"
@@ -11,17 +12,33 @@ import (
 type Tracker interface {
 	NewDomain(pusher string) Builder
 	Report(now time.Time) map[string][]StatReport
+	// Totals returns the cumulative value of every rate stat, by domain and
+	// stat name. Gauges are excluded.
+	Totals() map[string]map[string]uint64
+	// Percentiles returns the percentiles of every value recorded by the
+	// latency stats in milliseconds, by domain and percentile name, e.g. p99
+	Percentiles() map[string]map[string]float64
 }
 
 type Builder interface {
 	NewStat(statType StatType) Stat
+	NewGauge(statType StatType) Gauge
+	// NewTargetStat creates a rate stat that is reported next to its target
+	// rate per second
+	NewTargetStat(statType StatType, target float64) Stat
+	// NewLatency creates a stat that reports the percentiles of the recorded
+	// durations
+	NewLatency(statType StatType) Latency
 }
 
 type StatReport struct {
 	statType StatType
 
-	delta uint64
-	dur   time.Duration
+	delta  uint64
+	dur    time.Duration
+	target float64
+	// percentiles of a latency stat, matching ReportedPercentiles
+	percentiles []time.Duration
 }
 
 type statTracker struct {
@@ -31,7 +48,8 @@ type statTracker struct {
 
 type statDomain struct {
 	sync.Mutex
-	stats map[int]*stat
+	stats     map[int]*stat
+	latencies map[int]*latency
 }
 
 type statBuilder struct {
@@ -54,19 +72,43 @@ func (s *statTracker) NewDomain(domain string) Builder {
 	}
 
 	d = &statDomain{
-		stats: make(map[int]*stat),
+		stats:     make(map[int]*stat),
+		latencies: make(map[int]*latency),
 	}
 	s.domains[domain] = d
 
 	return &statBuilder{domain: d}
 }
 
+func (s *statBuilder) NewGauge(statType StatType) Gauge {
+	return s.newStat(statType, 0)
+}
+
 func (s *statBuilder) NewStat(statType StatType) Stat {
+	return s.newStat(statType, 0)
+}
+
+func (s *statBuilder) NewTargetStat(statType StatType, target float64) Stat {
+	return s.newStat(statType, target)
+}
+
+func (s *statBuilder) NewLatency(statType StatType) Latency {
+	s.domain.Lock()
+	defer s.domain.Unlock()
+
+	l := &latency{statType: statType}
+	s.domain.latencies[int(statType)] = l
+
+	return l
+}
+
+func (s *statBuilder) newStat(statType StatType, target float64) *stat {
 	s.domain.Lock()
 	defer s.domain.Unlock()
 
 	newStat := &stat{
 		statType: statType,
+		target:   target,
 	}
 	s.domain.stats[int(statType)] = newStat
 
@@ -75,14 +117,32 @@ func (s *statBuilder) NewStat(statType StatType) Stat {
 
 func (d *statDomain) report(now time.Time) []StatReport {
 	stats := make(map[int]*stat, len(d.stats))
+	latencies := make([]*latency, 0, len(d.latencies))
 	d.Lock()
 	for k, v := range d.stats {
 		stats[k] = v
 	}
+	for _, l := range d.latencies {
+		latencies = append(latencies, l)
+	}
 	d.Unlock()
 
-	reports := make([]StatReport, 0, len(stats))
+	reports := make([]StatReport, 0, len(stats)+len(latencies))
+	for _, l := range latencies {
+		if ps, ok := l.intervalPercentiles(); ok {
+			reports = append(reports, StatReport{statType: l.statType, percentiles: ps})
+		}
+	}
+
 	for _, s := range stats {
+		if s.statType.isGauge() {
+			reports = append(reports, StatReport{
+				statType: s.statType,
+				delta:    s.value.Load(),
+			})
+			continue
+		}
+
 		s.lastReportMut.Lock()
 
 		if s.lastReportTime.IsZero() {
@@ -100,6 +160,7 @@ func (d *statDomain) report(now time.Time) []StatReport {
 			statType: s.statType,
 			delta:  currValue - s.lastReportValue,
 			dur:    now.Sub(lastReportTime),
+			target: s.target,
 		})
 
 		s.lastReportTime = now
@@ -133,8 +194,143 @@ func (s *statTracker) Report(now time.Time) map[string][]StatReport {
 }
 
 func (s *StatReport) Report() string {
+	if s.statType.isLatency() {
+		return formatPercentiles(s.statType.desc(), s.percentiles)
+	}
+
+	if s.statType.isGauge() {
+		return fmt.Sprintf("%d %s", s.delta, s.statType.desc())
+	}
+
+	if s.target > 0 {
+		return fmt.Sprintf("%d %s (%4.2f %s/sec, target %4.2f %s/sec)",
+			s.delta, s.statType.desc(),
+			float64(s.delta)/s.dur.Seconds()/float64(s.statType.factor()), s.statType.unit(),
+			s.target/float64(s.statType.factor()), s.statType.unit(),
+		)
+	}
+
 	return fmt.Sprintf("%d %s (%4.2f %s/sec)",
 		s.delta, s.statType.desc(),
 		float64(s.delta)/s.dur.Seconds()/float64(s.statType.factor()), s.statType.unit(),
 	)
 }
+
+func (s *statTracker) Totals() map[string]map[string]uint64 {
+	s.RLock()
+	defer s.RUnlock()
+
+	totals := make(map[string]map[string]uint64, len(s.domains))
+	for domain, d := range s.domains {
+		d.Lock()
+		values := make(map[string]uint64, len(d.stats))
+		for _, st := range d.stats {
+			if st.statType.isGauge() {
+				continue
+			}
+			values[st.statType.String()] = st.value.Load()
+		}
+		d.Unlock()
+
+		totals[domain] = values
+	}
+
+	return totals
+}
+
+func (s *statTracker) Percentiles() map[string]map[string]float64 {
+	s.RLock()
+	defer s.RUnlock()
+
+	out := make(map[string]map[string]float64)
+	for domain, d := range s.domains {
+		d.Lock()
+		for _, l := range d.latencies {
+			ps, ok := l.totalPercentiles()
+			if !ok {
+				continue
+			}
+			values := make(map[string]float64, len(ps))
+			for i, p := range ps {
+				values[fmt.Sprintf("p%g", ReportedPercentiles[i])] = float64(p) / float64(time.Millisecond)
+			}
+			out[domain] = values
+		}
+		d.Unlock()
+	}
+
+	return out
+}
+
+// Format is the output format of the periodic stats report
+type Format int
+
+const (
+	FormatText Format = iota
+	FormatJSON
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatText:
+		return "text"
+	case FormatJSON:
+		return "json"
+	default:
+		return "unknown"
+	}
+}
+
+func ParseFormat(s string) (Format, error) {
+	switch s {
+	case "text":
+		return FormatText, nil
+	case "json":
+		return FormatJSON, nil
+	default:
+		return 0, fmt.Errorf("invalid stats format: %q (expected text or json)", s)
+	}
+}
+
+// statReportJSON is the JSON form of a StatReport, keeping the raw numbers
+// rather than the scaled units of the text report
+type statReportJSON struct {
+	Type string `json:"type"`
+	// Delta, DurationSeconds and Rate are set for rate stats
+	Delta           *uint64  `json:"delta,omitempty"`
+	DurationSeconds *float64 `json:"duration_seconds,omitempty"`
+	Rate            *float64 `json:"rate,omitempty"`
+	Target          float64  `json:"target,omitempty"`
+	// Value is set for gauges
+	Value *uint64 `json:"value,omitempty"`
+	// PercentilesMs is set for latency stats
+	PercentilesMs map[string]float64 `json:"percentiles_ms,omitempty"`
+}
+
+func (s StatReport) MarshalJSON() ([]byte, error) {
+	out := statReportJSON{Type: s.statType.String()}
+
+	switch {
+	case s.statType.isLatency():
+		out.PercentilesMs = make(map[string]float64, len(s.percentiles))
+		for i, p := range s.percentiles {
+			out.PercentilesMs[fmt.Sprintf("p%g", ReportedPercentiles[i])] = float64(p) / float64(time.Millisecond)
+		}
+	case s.statType.isGauge():
+		value := s.delta
+		out.Value = &value
+	default:
+		delta := s.delta
+		secs := s.dur.Seconds()
+		rate := 0.0
+		if secs > 0 {
+			rate = float64(delta) / secs
+		}
+		out.Delta = &delta
+		out.DurationSeconds = &secs
+		out.Rate = &rate
+		out.Target = s.target
+	}
+
+	return json.Marshal(out)
+}
@@ -0,0 +1,58 @@
+package stats
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestStatReport_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		report StatReport
+		want   string
+	}{
+		{
+			StatReport{statType: StatSpansSent, delta: 3000, dur: 1500 * time.Millisecond, target: 2000},
+			`{"type":"spans_sent","delta":3000,"duration_seconds":1.5,"rate":2000,"target":2000}`,
+		},
+		{
+			// rates keep the raw unit rather than the MiB of the text report
+			StatReport{statType: StatBytesSent, delta: 2 * 1024 * 1024, dur: time.Second},
+			`{"type":"bytes_sent","delta":2097152,"duration_seconds":1,"rate":2097152}`,
+		},
+		{
+			StatReport{statType: StatExportErrors, dur: time.Second},
+			`{"type":"export_errors","delta":0,"duration_seconds":1,"rate":0}`,
+		},
+		{
+			StatReport{statType: StatQueueDepth, delta: 4},
+			`{"type":"queue_depth","value":4}`,
+		},
+		{
+			StatReport{statType: StatExportLatency, percentiles: []time.Duration{time.Millisecond, 2 * time.Millisecond, 2500 * time.Microsecond}},
+			`{"type":"export_latency","percentiles_ms":{"p50":1,"p90":2,"p99":2.5}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		buf, err := json.Marshal(tt.report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf) != tt.want {
+			t.Errorf("Expected %s, got %s", tt.want, buf)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, f := range []Format{FormatText, FormatJSON} {
+		got, err := ParseFormat(f.String())
+		if err != nil || got != f {
+			t.Errorf("Expected %s to round trip, got %v, %v", f, got, err)
+		}
+	}
+	if _, err := ParseFormat("yaml"); err == nil {
+		t.Error("Expected an invalid format to fail")
+	}
+}
@@ -0,0 +1,126 @@
+package summary
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Delta is the change of a single value between a base and a current run
+type Delta struct {
+	Signal  string
+	Name    string
+	Base    float64
+	Current float64
+	// Change is the change relative to Base, 0.1 is 10% higher. It is +Inf when
+	// Base is zero and Current isn't.
+	Change float64
+	// Regression is set when the value got worse by more than the threshold
+	Regression bool
+}
+
+// Diff compares the signals present in both runs: the throughput of the sent
+// stats, the export error rate and the latency percentiles. A value regresses
+// when it changes for the worse by more than threshold, relative to the base.
+func Diff(base Summary, current Summary, threshold float64) []Delta {
+	var deltas []Delta
+
+	for _, signal := range sortedKeys(base.Signals) {
+		cur, ok := current.Signals[signal]
+		if !ok {
+			continue
+		}
+		b := base.Signals[signal]
+
+		for _, name := range sortedKeys(b.Rates) {
+			if !strings.HasSuffix(name, "_sent") {
+				continue
+			}
+			if c, ok := cur.Rates[name]; ok {
+				deltas = append(deltas, newDelta(signal, name+"/sec", b.Rates[name], c, threshold, true))
+			}
+		}
+
+		deltas = append(deltas, newDelta(signal, "error_rate", b.ErrorRate, cur.ErrorRate, threshold, false))
+
+		for _, name := range sortedKeys(b.LatencyMs) {
+			if c, ok := cur.LatencyMs[name]; ok {
+				deltas = append(deltas, newDelta(signal, "latency_"+name+"_ms", b.LatencyMs[name], c, threshold, false))
+			}
+		}
+	}
+
+	return deltas
+}
+
+func newDelta(signal, name string, base, current, threshold float64, higherIsBetter bool) Delta {
+	d := Delta{Signal: signal, Name: name, Base: base, Current: current}
+
+	switch {
+	case base == current:
+		d.Change = 0
+	case base == 0:
+		d.Change = math.Inf(1)
+	default:
+		d.Change = (current - base) / base
+	}
+
+	if higherIsBetter {
+		d.Regression = d.Change < -threshold
+	} else {
+		d.Regression = d.Change > threshold
+	}
+
+	return d
+}
+
+// Regressions returns the number of deltas flagged as a regression
+func Regressions(deltas []Delta) int {
+	n := 0
+	for _, d := range deltas {
+		if d.Regression {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteDiff prints the deltas as a table
+func WriteDiff(w io.Writer, deltas []Delta) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SIGNAL\tVALUE\tBASE\tCURRENT\tCHANGE\t")
+
+	for _, d := range deltas {
+		change := fmt.Sprintf("%+.2f%%", d.Change*100)
+		if math.IsInf(d.Change, 1) {
+			change = "new"
+		}
+		if d.Regression {
+			change += "  REGRESSION"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t\n", d.Signal, d.Name, formatValue(d.Base), formatValue(d.Current), change)
+	}
+
+	return tw.Flush()
+}
+
+// formatValue keeps small values, like error rates, readable without printing
+// large rates in exponent form
+func formatValue(v float64) string {
+	if math.Abs(v) >= 1000 {
+		return fmt.Sprintf("%.0f", v)
+	}
+	return fmt.Sprintf("%.4g", v)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
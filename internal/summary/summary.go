@@ -0,0 +1,86 @@
+package summary
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Summary is the JSON record of a load generator run, written at shutdown so
+// runs can be compared
+type Summary struct {
+	StartTime time.Time `json:"start_time"`
+	// DurationSeconds is the wall clock time the generators ran for
+	DurationSeconds float64 `json:"duration_seconds"`
+	// Signals holds a summary per stats domain, e.g. "OTLP Traces"
+	Signals map[string]Signal `json:"signals"`
+}
+
+// Signal summarizes the stats of a single domain
+type Signal struct {
+	// Totals are the cumulative stat values by stat name, e.g. spans_sent
+	Totals map[string]uint64 `json:"totals"`
+	// Rates are the totals divided by the run duration, per second
+	Rates map[string]float64 `json:"rates"`
+	// ErrorRate is the fraction of export attempts that failed
+	ErrorRate float64 `json:"error_rate"`
+	// LatencyMs holds export latency percentiles in milliseconds, keyed by
+	// percentile name, e.g. p99. It is omitted when latency isn't tracked.
+	LatencyMs map[string]float64 `json:"latency_ms,omitempty"`
+}
+
+// New builds a summary from the cumulative stat totals of each domain
+func New(start time.Time, end time.Time, totals map[string]map[string]uint64) Summary {
+	dur := end.Sub(start).Seconds()
+
+	s := Summary{
+		StartTime:       start,
+		DurationSeconds: dur,
+		Signals:         make(map[string]Signal, len(totals)),
+	}
+
+	for domain, values := range totals {
+		sig := Signal{
+			Totals: values,
+			Rates:  make(map[string]float64, len(values)),
+		}
+		for name, v := range values {
+			if dur > 0 {
+				sig.Rates[name] = float64(v) / dur
+			}
+		}
+
+		errors := values["export_errors"]
+		if attempts := values["batches_sent"] + errors; attempts > 0 {
+			sig.ErrorRate = float64(errors) / float64(attempts)
+		}
+
+		s.Signals[domain] = sig
+	}
+
+	return s
+}
+
+// Write stores the summary as indented JSON at path
+func (s Summary) Write(path string) error {
+	buf, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(buf, '\n'), 0o644)
+}
+
+// Load reads a summary written by Write
+func Load(path string) (Summary, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	var s Summary
+	if err := json.Unmarshal(buf, &s); err != nil {
+		return Summary{}, fmt.Errorf("failed to parse summary %s: %w", path, err)
+	}
+	return s, nil
+}
@@ -0,0 +1,131 @@
+package summary
+
+import (
+	"bytes"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	start := time.Now()
+	s := New(start, start.Add(10*time.Second), map[string]map[string]uint64{
+		"OTLP Traces": {"spans_sent": 50000, "batches_sent": 90, "export_errors": 10},
+	})
+
+	sig := s.Signals["OTLP Traces"]
+	if sig.Rates["spans_sent"] != 5000 {
+		t.Errorf("Expected 5000 spans/sec, got %v", sig.Rates["spans_sent"])
+	}
+	if sig.ErrorRate != 0.1 {
+		t.Errorf("Expected an error rate of 0.1, got %v", sig.ErrorRate)
+	}
+}
+
+func TestWriteLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.json")
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := New(start, start.Add(time.Minute), map[string]map[string]uint64{
+		"OTLP Logs": {"logs_sent": 600},
+	})
+	if err := s.Write(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.DurationSeconds != 60 || loaded.Signals["OTLP Logs"].Rates["logs_sent"] != 10 {
+		t.Errorf("Unexpected loaded summary: %+v", loaded)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	base := Summary{Signals: map[string]Signal{
+		"OTLP Traces": {
+			Rates:     map[string]float64{"spans_sent": 10000, "bytes_sent": 2000000, "export_errors": 0},
+			ErrorRate: 0.01,
+			LatencyMs: map[string]float64{"p50": 2, "p99": 10},
+		},
+		"OTLP Metrics": {
+			Rates: map[string]float64{"metrics_sent": 5000},
+		},
+	}}
+	current := Summary{Signals: map[string]Signal{
+		"OTLP Traces": {
+			// spans drop 20%, bytes drop 2%, within the threshold
+			Rates:     map[string]float64{"spans_sent": 8000, "bytes_sent": 1960000, "export_errors": 5},
+			ErrorRate: 0.02,
+			LatencyMs: map[string]float64{"p50": 2, "p99": 12},
+		},
+		"OTLP Metrics": {
+			Rates:     map[string]float64{"metrics_sent": 6000},
+			ErrorRate: 0.001,
+		},
+	}}
+
+	deltas := Diff(base, current, 0.05)
+
+	byName := make(map[string]Delta)
+	for _, d := range deltas {
+		byName[d.Signal+"/"+d.Name] = d
+	}
+
+	expected := []struct {
+		key        string
+		change     float64
+		regression bool
+	}{
+		{"OTLP Traces/spans_sent/sec", -0.2, true},
+		{"OTLP Traces/bytes_sent/sec", -0.02, false},
+		{"OTLP Traces/error_rate", 1.0, true},
+		{"OTLP Traces/latency_p50_ms", 0, false},
+		{"OTLP Traces/latency_p99_ms", 0.2, true},
+		{"OTLP Metrics/metrics_sent/sec", 0.2, false},
+		{"OTLP Metrics/error_rate", math.Inf(1), true},
+	}
+	for _, e := range expected {
+		d, ok := byName[e.key]
+		if !ok {
+			t.Errorf("Missing delta %s", e.key)
+			continue
+		}
+		if math.Abs(d.Change-e.change) > 1e-9 && !(math.IsInf(e.change, 1) && math.IsInf(d.Change, 1)) {
+			t.Errorf("%s: expected change %v, got %v", e.key, e.change, d.Change)
+		}
+		if d.Regression != e.regression {
+			t.Errorf("%s: expected regression=%v, got %v", e.key, e.regression, d.Regression)
+		}
+	}
+
+	// export_errors isn't a throughput stat
+	if _, ok := byName["OTLP Traces/export_errors/sec"]; ok {
+		t.Error("Expected export_errors not to be compared as throughput")
+	}
+	if len(deltas) != len(expected) {
+		t.Errorf("Expected %d deltas, got %d", len(expected), len(deltas))
+	}
+	if n := Regressions(deltas); n != 4 {
+		t.Errorf("Expected 4 regressions, got %d", n)
+	}
+
+	var out bytes.Buffer
+	if err := WriteDiff(&out, deltas); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(out.String(), "REGRESSION"); got != 4 {
+		t.Errorf("Expected 4 flagged rows, got %d:\n%s", got, out.String())
+	}
+}
+
+func TestDiff_SignalOnlyInOneRun(t *testing.T) {
+	base := Summary{Signals: map[string]Signal{"OTLP Logs": {Rates: map[string]float64{"logs_sent": 100}}}}
+	current := Summary{Signals: map[string]Signal{"OTLP Traces": {Rates: map[string]float64{"spans_sent": 100}}}}
+
+	if deltas := Diff(base, current, 0.05); len(deltas) != 0 {
+		t.Errorf("Expected no deltas, got %v", deltas)
+	}
+}
@@ -0,0 +1,94 @@
+package telemetry
+
+import (
+	"fmt"
+	"time"
+)
+
+// AlertPattern drives gauge values through a repeating shape, so alerting
+// rules can be checked to fire and resolve
+type AlertPattern int
+
+const (
+	// AlertPatternNone emits random values
+	AlertPatternNone AlertPattern = iota
+	// AlertPatternSpike holds the baseline, jumps to the peak for a fifth of
+	// the period and drops back
+	AlertPatternSpike
+	// AlertPatternRamp climbs linearly to the peak over half the period,
+	// plateaus for a quarter and recovers linearly to the baseline
+	AlertPatternRamp
+	// AlertPatternFlap alternates between the baseline and the peak every
+	// tenth of the period
+	AlertPatternFlap
+)
+
+// Values the alert patterns move between, the random gauge values are in [0, 100)
+const (
+	alertBaseline = 10.0
+	alertPeak     = 90.0
+)
+
+func (p AlertPattern) String() string {
+	switch p {
+	case AlertPatternNone:
+		return "none"
+	case AlertPatternSpike:
+		return "spike"
+	case AlertPatternRamp:
+		return "ramp"
+	case AlertPatternFlap:
+		return "flap"
+	default:
+		return "unknown"
+	}
+}
+
+func ParseAlertPattern(s string) (AlertPattern, error) {
+	switch s {
+	case "none":
+		return AlertPatternNone, nil
+	case "spike":
+		return AlertPatternSpike, nil
+	case "ramp":
+		return AlertPatternRamp, nil
+	case "flap":
+		return AlertPatternFlap, nil
+	default:
+		return 0, fmt.Errorf("invalid alert pattern: %q (expected none, spike, ramp or flap)", s)
+	}
+}
+
+// value returns the value of the pattern elapsed into the run, the shape
+// repeats every period
+func (p AlertPattern) value(elapsed time.Duration, period time.Duration) float64 {
+	if period <= 0 {
+		return alertBaseline
+	}
+	// Position in the current cycle, in [0, 1)
+	phase := float64(elapsed%period) / float64(period)
+
+	switch p {
+	case AlertPatternSpike:
+		if phase >= 0.4 && phase < 0.6 {
+			return alertPeak
+		}
+		return alertBaseline
+	case AlertPatternRamp:
+		switch {
+		case phase < 0.5:
+			return alertBaseline + (alertPeak-alertBaseline)*phase/0.5
+		case phase < 0.75:
+			return alertPeak
+		default:
+			return alertPeak - (alertPeak-alertBaseline)*(phase-0.75)/0.25
+		}
+	case AlertPatternFlap:
+		if int(phase*10)%2 == 1 {
+			return alertPeak
+		}
+		return alertBaseline
+	default:
+		return alertBaseline
+	}
+}
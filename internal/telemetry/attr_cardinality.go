@@ -0,0 +1,84 @@
+package telemetry
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/otlp/anyvalue"
+	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+)
+
+// Keys of the extra attributes, typical high cardinality identifiers. Keys
+// past the end of the list are numbered.
+var cardinalityAttrKeys = []string{
+	"user.id",
+	"request.id",
+	"session.id",
+	"tenant.id",
+	"order.id",
+	"device.id",
+	"customer.id",
+	"transaction.id",
+}
+
+// AttrCardinality adds extra attributes with random values to stress the
+// attribute indexing of backends. The number of attributes and the number of
+// distinct values of each are set independently.
+type AttrCardinality struct {
+	keys []string
+	// values is the number of distinct values of each attribute, zero makes
+	// every value a random 64-bit id
+	values int
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewAttrCardinality returns nil when count is zero, a zero seed picks a
+// random seed
+func NewAttrCardinality(count, values int, seed int64) (*AttrCardinality, error) {
+	if count < 0 {
+		return nil, fmt.Errorf("invalid attribute count: %d (must be >= 0)", count)
+	}
+	if values < 0 {
+		return nil, fmt.Errorf("invalid attribute value cardinality: %d (must be >= 0)", values)
+	}
+	if count == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		if i < len(cardinalityAttrKeys) {
+			keys = append(keys, cardinalityAttrKeys[i])
+		} else {
+			keys = append(keys, fmt.Sprintf("loadgen.attr.%d", i))
+		}
+	}
+
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &AttrCardinality{keys: keys, values: values, rng: rand.New(rand.NewSource(seed))}, nil
+}
+
+// attrs returns the extra attributes with newly drawn values
+func (c *AttrCardinality) attrs() []*otlpCommon.KeyValue {
+	kvs := make([]*otlpCommon.KeyValue, 0, len(c.keys))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range c.keys {
+		var value string
+		if c.values > 0 {
+			value = fmt.Sprintf("%s-%d", key, c.rng.Intn(c.values))
+		} else {
+			value = fmt.Sprintf("%016x", c.rng.Uint64())
+		}
+		kvs = append(kvs, anyvalue.KV(key, anyvalue.String(value)))
+	}
+
+	return kvs
+}
@@ -0,0 +1,61 @@
+package telemetry
+
+import (
+	"testing"
+
+	"github.com/streamfold/otel-loadgen/internal/worker"
+)
+
+func TestNewAttrCardinality(t *testing.T) {
+	if c, err := NewAttrCardinality(0, 10, 1); err != nil || c != nil {
+		t.Errorf("Expected nil for no attributes, got %v, %v", c, err)
+	}
+	if _, err := NewAttrCardinality(-1, 0, 1); err == nil {
+		t.Error("Expected error for a negative count")
+	}
+	if _, err := NewAttrCardinality(1, -1, 1); err == nil {
+		t.Error("Expected error for a negative value cardinality")
+	}
+}
+
+func TestTracesBuildBatch_AttrCardinality(t *testing.T) {
+	for _, values := range []int{0, 3} {
+		card, err := NewAttrCardinality(10, values, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := newTestTracesWorker(t, TracesConfig{
+			ResourcesPerBatch:   1,
+			SpansPerResource:    100,
+			SpanAttrCardinality: card,
+		})
+
+		distinct := make(map[string]map[string]bool)
+		for _, span := range w.buildBatch(newTestResources(1), worker.NopMsgIdGenerator())[0].ScopeSpans[0].Spans {
+			// index is followed by the extra attributes
+			extra := span.Attributes[1:]
+			if len(extra) != 10 {
+				t.Fatalf("Expected 10 extra attributes, got %d", len(extra))
+			}
+			if extra[0].Key != "user.id" || extra[9].Key != "loadgen.attr.9" {
+				t.Errorf("Unexpected keys %s and %s", extra[0].Key, extra[9].Key)
+			}
+
+			for _, attr := range extra {
+				if distinct[attr.Key] == nil {
+					distinct[attr.Key] = make(map[string]bool)
+				}
+				distinct[attr.Key][attr.Value.GetStringValue()] = true
+			}
+		}
+
+		for key, seen := range distinct {
+			if values > 0 && len(seen) != values {
+				t.Errorf("Expected %d values of %s, got %d", values, key, len(seen))
+			}
+			if values == 0 && len(seen) != 100 {
+				t.Errorf("Expected a new value of %s per span, got %d", key, len(seen))
+			}
+		}
+	}
+}
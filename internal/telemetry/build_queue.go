@@ -0,0 +1,60 @@
+package telemetry
+
+import (
+	"sync"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/stats"
+)
+
+// runBuildQueue builds a batch on every tick and sends it. With a queue size of
+// zero the batch is sent from the tick loop. Otherwise batches are handed from
+// the builder to a separate sender over a buffered channel of that size, and its
+// fill level is tracked by depth: a full queue means the exporter can't keep up,
+// an empty one means generation is the limit. Queued batches are sent before
+// returning once stopChan is closed.
+func runBuildQueue[T any](stopChan <-chan bool, tick <-chan time.Time, queueSize int, depth stats.Gauge, build func() T, send func(T)) {
+	if queueSize <= 0 {
+		for {
+			select {
+			case <-stopChan:
+				return
+			case <-tick:
+				send(build())
+			}
+		}
+	}
+
+	queue := make(chan T, queueSize)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for batch := range queue {
+			depth.Decr(1)
+			send(batch)
+		}
+	}()
+
+	defer func() {
+		close(queue)
+		wg.Wait()
+	}()
+
+	for {
+		select {
+		case <-stopChan:
+			return
+		case <-tick:
+			batch := build()
+
+			// Count the batch before handing it off so the sender can't
+			// decrement first. This blocks while the queue is full, the sender
+			// keeps draining it so a built batch is never dropped.
+			depth.Incr(1)
+			queue <- batch
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package telemetry
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testGauge struct {
+	testStat
+	max atomic.Uint64
+}
+
+func (g *testGauge) Incr(delta uint64) {
+	v := g.value.Add(delta)
+	for {
+		m := g.max.Load()
+		if v <= m || g.max.CompareAndSwap(m, v) {
+			return
+		}
+	}
+}
+
+func (g *testGauge) Decr(delta uint64) {
+	g.value.Add(^(delta - 1))
+}
+
+func TestRunBuildQueue_SlowExporterFillsQueue(t *testing.T) {
+	const queueSize = 4
+
+	stopChan := make(chan bool)
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+
+	depth := &testGauge{}
+	release := make(chan struct{})
+	var built, sent atomic.Int64
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runBuildQueue(stopChan, ticker.C, queueSize, depth,
+			func() int { return int(built.Add(1)) },
+			func(int) {
+				<-release
+				sent.Add(1)
+			})
+	}()
+
+	// The sender is blocked on the first batch, so the queue fills up behind it
+	deadline := time.Now().Add(5 * time.Second)
+	for depth.value.Load() < queueSize {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected queue depth to reach %d, got %d", queueSize, depth.value.Load())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The builder may be blocked handing one more batch to the full queue
+	if max := depth.max.Load(); max > queueSize+1 {
+		t.Errorf("Expected queue depth to be bounded by %d, got %d", queueSize+1, max)
+	}
+
+	close(stopChan)
+	close(release)
+	<-done
+
+	if depth.value.Load() != 0 {
+		t.Errorf("Expected queue to be drained on stop, got depth %d", depth.value.Load())
+	}
+	if sent.Load() != built.Load() {
+		t.Errorf("Expected all %d built batches to be sent, got %d", built.Load(), sent.Load())
+	}
+}
+
+func TestRunBuildQueue_Synchronous(t *testing.T) {
+	stopChan := make(chan bool)
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+
+	var sent atomic.Int64
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runBuildQueue(stopChan, ticker.C, 0, &testGauge{},
+			func() int { return 1 },
+			func(int) {
+				if sent.Add(1) == 3 {
+					close(stopChan)
+				}
+			})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected synchronous queue to stop")
+	}
+}
@@ -0,0 +1,16 @@
+package telemetry
+
+import "time"
+
+// clock returns the time generated telemetry is timestamped from
+type clock func() time.Time
+
+// newClock returns a clock pinned to baseTime, or the wall clock if baseTime is zero
+func newClock(baseTime time.Time) clock {
+	if baseTime.IsZero() {
+		return time.Now
+	}
+	return func() time.Time {
+		return baseTime
+	}
+}
@@ -0,0 +1,133 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// probeTimeout bounds the startup compression probe, so an unreachable
+// endpoint doesn't hold up the workers
+const probeTimeout = 2 * time.Second
+
+// probeCompression sends an empty export request with the configured
+// compression and warns if the endpoint rejects the encoding, rather than
+// failing every export later on. Other failures are only logged at debug
+// level, the endpoint may not be up yet.
+func (e *exporter) probeCompression() {
+	if e.cfg.Compression.ContentEncoding() == "" {
+		return
+	}
+
+	var supported string
+	var err error
+	if e.cfg.UseGRPC {
+		supported, err = e.probeGRPC()
+	} else {
+		supported, err = e.probeHTTP()
+	}
+
+	switch {
+	case err == nil:
+		e.log.Debug("compression probe succeeded", zap.Stringer("compression", e.cfg.Compression))
+	case isUnsupportedCompression(err):
+		fields := []zap.Field{
+			zap.Stringer("compression", e.cfg.Compression),
+			zap.String("endpoint", e.endpoint.String()),
+			zap.Error(err),
+		}
+		if supported != "" {
+			fields = append(fields, zap.String("supported", supported))
+		}
+		e.log.Warn("endpoint does not support the configured compression, exports will fail (see --compression)", fields...)
+	default:
+		e.log.Debug("compression probe failed", zap.Error(err))
+	}
+}
+
+// probeGRPC returns the encodings the server advertised in its response
+// headers, if any
+func (e *exporter) probeGRPC() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	ctx = metadata.NewOutgoingContext(ctx, metadata.New(e.cfg.CustomHeaders))
+
+	// An empty message is a valid export request for every signal
+	var header metadata.MD
+	err := e.conn.Invoke(ctx, e.grpcMethod, &emptypb.Empty{}, &emptypb.Empty{}, grpc.Header(&header))
+	return strings.Join(header.Get("grpc-accept-encoding"), ","), err
+}
+
+// probeHTTP returns the encodings listed in the Accept-Encoding response
+// header, if any
+func (e *exporter) probeHTTP() (string, error) {
+	buf, err := e.marshal(&emptypb.Empty{})
+	if err != nil {
+		return "", err
+	}
+	body, err := e.cfg.Compression.Compress(buf)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", e.cfg.HTTPEncoding.contentType())
+	req.Header.Set("Content-Encoding", e.cfg.Compression.ContentEncoding())
+	for k, v := range e.cfg.CustomHeaders {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	respBody, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return resp.Header.Get("Accept-Encoding"), &httpStatusError{status: resp.StatusCode, body: string(respBody)}
+	}
+	return "", nil
+}
+
+// isUnsupportedCompression returns true if err is the endpoint rejecting the
+// request encoding. gRPC servers answer Unimplemented for an unknown
+// grpc-encoding, HTTP servers either 415 or, like the collector, 400 naming
+// the Content-Encoding.
+func isUnsupportedCompression(err error) bool {
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.status {
+		case http.StatusUnsupportedMediaType:
+			return true
+		case http.StatusBadRequest:
+			return strings.Contains(strings.ToLower(statusErr.body), "content-encoding")
+		default:
+			return false
+		}
+	}
+
+	if st, ok := status.FromError(err); ok {
+		return st.Code() == codes.Unimplemented && strings.Contains(st.Message(), "grpc-encoding")
+	}
+
+	return false
+}
@@ -0,0 +1,102 @@
+package telemetry
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/streamfold/otel-loadgen/internal/compression"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// gzipOnlyHandler rejects any encoding other than gzip the way the collector does
+func gzipOnlyHandler(w http.ResponseWriter, r *http.Request) {
+	if ce := r.Header.Get("Content-Encoding"); ce != "" && ce != "gzip" {
+		http.Error(w, fmt.Sprintf("unsupported Content-Encoding: %s", ce), http.StatusBadRequest)
+	}
+}
+
+func probeWarnings(t *testing.T, cfg ExportConfig, client *http.Client) *observer.ObservedLogs {
+	t.Helper()
+
+	core, logs := observer.New(zap.WarnLevel)
+	cfg.ProbeCompression = true
+	e := newExporter(zap.New(core), cfg, tracesHTTPPath, tracesGRPCMethod)
+	if err := e.init(newTestStatsBuilder(), client); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(e.close)
+	return logs
+}
+
+func TestProbeCompression_HTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(gzipOnlyHandler))
+	defer srv.Close()
+	endpoint, _ := url.Parse(srv.URL)
+
+	logs := probeWarnings(t, ExportConfig{Endpoint: endpoint, Compression: compression.Zstd}, srv.Client())
+	if logs.Len() != 1 {
+		t.Fatalf("Expected a warning for zstd against a gzip-only endpoint, got %d logs", logs.Len())
+	}
+	if got := logs.All()[0].ContextMap()["compression"]; got != "zstd" {
+		t.Errorf("Expected the warning to name the compression, got %v", got)
+	}
+
+	logs = probeWarnings(t, ExportConfig{Endpoint: endpoint, Compression: compression.Gzip}, srv.Client())
+	if logs.Len() != 0 {
+		t.Errorf("Expected no warning for a supported compression, got %v", logs.All())
+	}
+}
+
+func TestProbeCompression_GRPC(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer(grpc.UnknownServiceHandler(func(any, grpc.ServerStream) error {
+		return status.Error(codes.Unimplemented, `grpc: Decompressor is not installed for grpc-encoding "zstd"`)
+	}))
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	endpoint, _ := url.Parse("http://" + lis.Addr().String())
+	logs := probeWarnings(t, ExportConfig{Endpoint: endpoint, UseGRPC: true, Compression: compression.Zstd}, nil)
+	if logs.Len() != 1 {
+		t.Fatalf("Expected a warning for an unsupported grpc-encoding, got %d logs", logs.Len())
+	}
+
+	// Any other failure, e.g. an unreachable endpoint, isn't reported as a
+	// compression problem
+	srv.Stop()
+	logs = probeWarnings(t, ExportConfig{Endpoint: endpoint, UseGRPC: true, Compression: compression.Zstd}, nil)
+	if logs.Len() != 0 {
+		t.Errorf("Expected no warning for an unreachable endpoint, got %v", logs.All())
+	}
+}
+
+func TestIsUnsupportedCompression(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&httpStatusError{status: http.StatusUnsupportedMediaType}, true},
+		{&httpStatusError{status: http.StatusBadRequest, body: "unsupported Content-Encoding: zstd"}, true},
+		{&httpStatusError{status: http.StatusBadRequest, body: "invalid request"}, false},
+		{&httpStatusError{status: http.StatusServiceUnavailable}, false},
+		{status.Error(codes.Unimplemented, `grpc: Decompressor is not installed for grpc-encoding "zstd"`), true},
+		{status.Error(codes.Unimplemented, "unknown service"), false},
+		{status.Error(codes.Unavailable, "connection refused"), false},
+	}
+	for _, tt := range tests {
+		if got := isUnsupportedCompression(tt.err); got != tt.want {
+			t.Errorf("isUnsupportedCompression(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// ConnMaxAgeTransport wraps transport so its idle connections are closed
+// every maxAge, the following requests dial again and DNS is re-resolved.
+// Connections in use finish their request and are closed once idle, so no
+// request fails because of its connection's age. A maxAge of zero returns
+// transport unchanged.
+func ConnMaxAgeTransport(transport *http.Transport, maxAge time.Duration) http.RoundTripper {
+	if maxAge <= 0 {
+		return transport
+	}
+
+	t := &maxAgeTransport{Transport: transport, maxAge: maxAge}
+	t.nextRecycle.Store(time.Now().Add(maxAge).UnixNano())
+	return t
+}
+
+type maxAgeTransport struct {
+	*http.Transport
+	maxAge time.Duration
+	// nextRecycle is when idle connections are next closed, in unix nanos
+	nextRecycle atomic.Int64
+}
+
+func (t *maxAgeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	now := time.Now()
+	next := t.nextRecycle.Load()
+	if now.UnixNano() >= next && t.nextRecycle.CompareAndSwap(next, now.Add(t.maxAge).UnixNano()) {
+		t.CloseIdleConnections()
+	}
+	return t.Transport.RoundTrip(req)
+}
+
+// grpcConn is a gRPC client connection that is replaced once it is older
+// than maxAge, so the endpoint is re-resolved and load re-spreads. A replaced
+// connection is closed once its in-flight calls complete. A maxAge of zero
+// keeps the first connection.
+type grpcConn struct {
+	dial   func() (*grpc.ClientConn, error)
+	maxAge time.Duration
+
+	mu   sync.Mutex
+	curr *agedConn
+}
+
+type agedConn struct {
+	*grpc.ClientConn
+	created time.Time
+	// active is the number of in-flight calls
+	active int
+	// retired connections are closed once they have no in-flight calls
+	retired bool
+}
+
+func newGRPCConn(dial func() (*grpc.ClientConn, error), maxAge time.Duration) (*grpcConn, error) {
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+
+	return &grpcConn{
+		dial:   dial,
+		maxAge: maxAge,
+		curr:   &agedConn{ClientConn: conn, created: time.Now()},
+	}, nil
+}
+
+// Invoke performs a unary call on the current connection
+func (c *grpcConn) Invoke(ctx context.Context, method string, args, reply any, opts ...grpc.CallOption) error {
+	conn := c.acquire()
+	defer c.release(conn)
+
+	return conn.Invoke(ctx, method, args, reply, opts...)
+}
+
+func (c *grpcConn) acquire() *agedConn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.maxAge > 0 && time.Since(c.curr.created) >= c.maxAge {
+		// The client connects lazily, so this doesn't block on the endpoint.
+		// If it fails the old connection is kept until the next call.
+		if conn, err := c.dial(); err == nil {
+			old := c.curr
+			old.retired = true
+			if old.active == 0 {
+				_ = old.Close()
+			}
+			c.curr = &agedConn{ClientConn: conn, created: time.Now()}
+		}
+	}
+
+	c.curr.active++
+	return c.curr
+}
+
+func (c *grpcConn) release(conn *agedConn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	conn.active--
+	if conn.retired && conn.active == 0 {
+		_ = conn.Close()
+	}
+}
+
+// Close closes the current connection, retired connections are closed by
+// their last in-flight call
+func (c *grpcConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.curr.Close()
+}
@@ -0,0 +1,155 @@
+package telemetry
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/stats"
+	otlpTraceColl "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// countingListener counts the connections it accepts
+type countingListener struct {
+	net.Listener
+	accepted atomic.Int64
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.accepted.Add(1)
+	}
+	return conn, err
+}
+
+// exportEvery exports n empty trace requests, interval apart, it may be
+// called from other goroutines
+func exportEvery(t *testing.T, e *exporter, n int, interval time.Duration) {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		if !e.export(1, &otlpTraceColl.ExportTraceServiceRequest{}, &otlpTraceColl.ExportTraceServiceResponse{}) {
+			t.Errorf("export %d failed", i)
+			return
+		}
+		time.Sleep(interval)
+	}
+}
+
+func newTestHTTPExporter(t *testing.T, maxAge time.Duration) (*exporter, *testStatsBuilder, *countingListener) {
+	t.Helper()
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	lis := &countingListener{Listener: srv.Listener}
+	srv.Listener = lis
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	endpoint, _ := url.Parse(srv.URL)
+	e := newExporter(zap.NewNop(), ExportConfig{Endpoint: endpoint}, tracesHTTPPath, tracesGRPCMethod)
+	sb := newTestStatsBuilder()
+	client := &http.Client{Transport: ConnMaxAgeTransport(&http.Transport{}, maxAge)}
+	if err := e.init(sb, client); err != nil {
+		t.Fatal(err)
+	}
+	return e, sb, lis
+}
+
+func TestConnMaxAgeTransport_RecyclesConnections(t *testing.T) {
+	e, sb, lis := newTestHTTPExporter(t, 50*time.Millisecond)
+
+	// 20 exports over ~200ms, none may fail because its connection was recycled
+	exportEvery(t, e, 20, 10*time.Millisecond)
+
+	if got := lis.accepted.Load(); got < 3 || got > 6 {
+		t.Errorf("Expected connections to be recycled about every 50ms, got %d connections", got)
+	}
+	if errs := sb.value(stats.StatExportErrors); errs != 0 {
+		t.Errorf("Expected no export errors, got %d", errs)
+	}
+}
+
+func TestConnMaxAgeTransport_Disabled(t *testing.T) {
+	e, _, lis := newTestHTTPExporter(t, 0)
+
+	exportEvery(t, e, 10, 5*time.Millisecond)
+
+	if got := lis.accepted.Load(); got != 1 {
+		t.Errorf("Expected a single reused connection, got %d connections", got)
+	}
+}
+
+func TestGRPCConn_RecyclesConnections(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis := &countingListener{Listener: l}
+	srv := grpc.NewServer()
+	otlpTraceColl.RegisterTraceServiceServer(srv, testTraceServer{})
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	e, sb := newTestGRPCExporter(t, l.Addr().String(), ExportConfig{ConnMaxAge: 50 * time.Millisecond})
+
+	// Concurrent exports keep calls in flight while connections are replaced
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		exportEvery(t, e, 20, 10*time.Millisecond)
+	}()
+	exportEvery(t, e, 20, 10*time.Millisecond)
+	<-done
+
+	if got := lis.accepted.Load(); got < 3 || got > 6 {
+		t.Errorf("Expected connections to be recycled about every 50ms, got %d connections", got)
+	}
+	if errs := sb.value(stats.StatExportErrors); errs != 0 {
+		t.Errorf("Expected no export errors, got %d", errs)
+	}
+}
+
+func TestGRPCConn_ClosesRetiredConnOnceIdle(t *testing.T) {
+	var dials int
+	c, err := newGRPCConn(func() (*grpc.ClientConn, error) {
+		dials++
+		return grpc.NewClient("passthrough:///unused", grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return nil, net.ErrClosed
+		}), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	inflight := c.acquire()
+	time.Sleep(2 * time.Millisecond)
+	next := c.acquire()
+
+	if dials != 2 || next == inflight {
+		t.Fatalf("Expected the connection to be replaced, got %d dials", dials)
+	}
+	if !inflight.retired || inflight.GetState() == connectivity.Shutdown {
+		t.Errorf("Expected the retired connection to stay open during its call, got %s", inflight.GetState())
+	}
+
+	c.release(inflight)
+	if inflight.GetState() != connectivity.Shutdown {
+		t.Errorf("Expected the retired connection to be closed once idle, got %s", inflight.GetState())
+	}
+	c.release(next)
+}
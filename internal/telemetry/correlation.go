@@ -0,0 +1,83 @@
+package telemetry
+
+import (
+	"math/rand"
+	"sync"
+
+	otlpTraces "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// SpanRef identifies an emitted span that other signals can reference
+type SpanRef struct {
+	TraceId           []byte
+	SpanId            []byte
+	StartTimeUnixNano uint64
+}
+
+type correlationKey struct {
+	pusher   uint64
+	resource int
+}
+
+// Correlator shares the spans emitted by the traces worker with the logs and
+// metrics workers, so their records reference spans of the same resource. The
+// workers' pushers are numbered the same way, so pusher N of every signal
+// generates the same resources.
+type Correlator struct {
+	mu    sync.RWMutex
+	spans map[correlationKey][]SpanRef
+}
+
+func NewCorrelator() *Correlator {
+	return &Correlator{
+		spans: make(map[correlationKey][]SpanRef),
+	}
+}
+
+// recordTraces stores the spans of the latest batch of a pusher, replacing the
+// spans of the previous batch
+func (c *Correlator) recordTraces(pusher uint64, batch []*otlpTraces.ResourceSpans) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, rs := range batch {
+		refs := make([]SpanRef, 0)
+		for _, ss := range rs.ScopeSpans {
+			for _, span := range ss.Spans {
+				refs = append(refs, SpanRef{
+					TraceId:           span.TraceId,
+					SpanId:            span.SpanId,
+					StartTimeUnixNano: span.StartTimeUnixNano,
+				})
+			}
+		}
+		c.spans[correlationKey{pusher: pusher, resource: i}] = refs
+	}
+}
+
+// pick returns a random span emitted for the resource, if there is one yet
+func (c *Correlator) pick(pusher uint64, resource int) (SpanRef, bool) {
+	refs := c.pickN(pusher, resource, 1)
+	if len(refs) == 0 {
+		return SpanRef{}, false
+	}
+	return refs[0], true
+}
+
+// pickN returns up to n distinct random spans emitted for the resource
+func (c *Correlator) pickN(pusher uint64, resource int, n int) []SpanRef {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	refs := c.spans[correlationKey{pusher: pusher, resource: resource}]
+	n = min(n, len(refs))
+	if n == 0 {
+		return nil
+	}
+
+	picked := make([]SpanRef, 0, n)
+	for _, i := range rand.Perm(len(refs))[:n] {
+		picked = append(picked, refs[i])
+	}
+	return picked
+}
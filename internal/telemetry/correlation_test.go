@@ -0,0 +1,234 @@
+package telemetry
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/compression"
+	"github.com/streamfold/otel-loadgen/internal/worker"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	otlpLogsColl "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	otlpTraceColl "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	otlpRes "go.opentelemetry.io/proto/otlp/resource/v1"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+// resourceKey identifies a generated resource by its instance id and pod name
+func resourceKey(res *otlpRes.Resource) string {
+	var instance, pod string
+	for _, kv := range res.Attributes {
+		switch kv.Key {
+		case string(semconv.ServiceInstanceIDKey):
+			instance = fmt.Sprint(kv.Value.GetIntValue())
+		case string(semconv.K8SPodNameKey):
+			pod = kv.Value.GetStringValue()
+		}
+	}
+	return instance + "/" + pod
+}
+
+func TestCorrelateSignals_LogsReferenceSpans(t *testing.T) {
+	var mu sync.Mutex
+	var traceReqs []*otlpTraceColl.ExportTraceServiceRequest
+	var logReqs []*otlpLogsColl.ExportLogsServiceRequest
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		var resp proto.Message
+		switch r.URL.Path {
+		case tracesHTTPPath:
+			req := &otlpTraceColl.ExportTraceServiceRequest{}
+			if err := proto.Unmarshal(body, req); err != nil {
+				t.Error(err)
+			}
+			traceReqs = append(traceReqs, req)
+			resp = &otlpTraceColl.ExportTraceServiceResponse{}
+		case logsHTTPPath:
+			req := &otlpLogsColl.ExportLogsServiceRequest{}
+			if err := proto.Unmarshal(body, req); err != nil {
+				t.Error(err)
+			}
+			logReqs = append(logReqs, req)
+			resp = &otlpLogsColl.ExportLogsServiceResponse{}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		out, _ := proto.Marshal(resp)
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		_, _ = w.Write(out)
+	}))
+	defer srv.Close()
+
+	endpoint, _ := url.Parse(srv.URL)
+	exportCfg := ExportConfig{Endpoint: endpoint, Compression: compression.None}
+	correlator := NewCorrelator()
+
+	traces := NewTracesWorker(zap.NewNop(), exportCfg, TracesConfig{
+		ResourcesPerBatch: 3,
+		SpansPerResource:  5,
+		Correlator:        correlator,
+	})
+	logs := NewLogsWorker(zap.NewNop(), exportCfg, LogsConfig{
+		ResourcesPerBatch: 3,
+		LogsPerResource:   5,
+		Correlator:        correlator,
+	})
+
+	for _, w := range []worker.Worker{traces, logs} {
+		if err := w.Init(newTestStatsBuilder(), srv.Client()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Two pushers per signal, the second pushers generate different resources
+	for i := 0; i < 2; i++ {
+		traces.Start(5*time.Millisecond, worker.NopMsgIdGenerator())
+		logs.Start(5*time.Millisecond, worker.NopMsgIdGenerator())
+	}
+	time.Sleep(200 * time.Millisecond)
+	traces.StopAll()
+	logs.StopAll()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// trace ids emitted per resource
+	spanTraces := make(map[string]map[string]bool)
+	for _, req := range traceReqs {
+		for _, rs := range req.ResourceSpans {
+			key := resourceKey(rs.Resource)
+			if spanTraces[key] == nil {
+				spanTraces[key] = make(map[string]bool)
+			}
+			for _, ss := range rs.ScopeSpans {
+				for _, span := range ss.Spans {
+					spanTraces[key][hex.EncodeToString(span.TraceId)] = true
+				}
+			}
+		}
+	}
+
+	correlated := 0
+	for _, req := range logReqs {
+		for _, rl := range req.ResourceLogs {
+			key := resourceKey(rl.Resource)
+			for _, sl := range rl.ScopeLogs {
+				for _, record := range sl.LogRecords {
+					if len(record.TraceId) == 0 {
+						// logs built before the first trace batch aren't correlated
+						continue
+					}
+					if len(record.SpanId) != 8 {
+						t.Errorf("Expected 8 byte span id, got %d", len(record.SpanId))
+					}
+					traceId := hex.EncodeToString(record.TraceId)
+					if !spanTraces[key][traceId] {
+						t.Errorf("Log trace id %s not emitted by a span of resource %s", traceId, key)
+					}
+					correlated++
+				}
+			}
+		}
+	}
+
+	if correlated == 0 {
+		t.Fatal("Expected correlated log records")
+	}
+}
+
+func TestMetricsBuildBatch_Exemplars(t *testing.T) {
+	correlator := NewCorrelator()
+	tw := newTestTracesWorker(t, TracesConfig{ResourcesPerBatch: 1, SpansPerResource: 4})
+	spans := tw.buildBatch(newTestResources(1), worker.NopMsgIdGenerator())
+	correlator.recordTraces(1, spans)
+
+	mw := newTestMetricsWorker(t, MetricsConfig{
+		ResourcesPerBatch:  1,
+		MetricsPerResource: 10,
+		MetricType:         MetricTypeGauge,
+		Correlator:         correlator,
+	})
+	batch := mw.buildBatch(1, newTestResources(1), newTestMetricSeries(1, 10), worker.NopMsgIdGenerator())
+
+	traceId := spans[0].ScopeSpans[0].Spans[0].TraceId
+	for _, dp := range gaugeDataPoints(batch) {
+		if len(dp.Exemplars) != 1 {
+			t.Fatalf("Expected 1 exemplar, got %d", len(dp.Exemplars))
+		}
+		if !bytes.Equal(dp.Exemplars[0].TraceId, traceId) {
+			t.Errorf("Expected exemplar trace id %x, got %x", traceId, dp.Exemplars[0].TraceId)
+		}
+	}
+
+	// Resources without emitted spans get no exemplars
+	batch = mw.buildBatch(2, newTestResources(1), newTestMetricSeries(1, 10), worker.NopMsgIdGenerator())
+	for _, dp := range gaugeDataPoints(batch) {
+		if len(dp.Exemplars) != 0 {
+			t.Errorf("Expected no exemplars, got %d", len(dp.Exemplars))
+		}
+	}
+}
+
+func TestMetricsBuildBatch_ExemplarsPerPoint(t *testing.T) {
+	correlator := NewCorrelator()
+	tw := newTestTracesWorker(t, TracesConfig{ResourcesPerBatch: 1, SpansPerResource: 4})
+	spans := tw.buildBatch(newTestResources(1), worker.NopMsgIdGenerator())
+	correlator.recordTraces(1, spans)
+
+	emitted := make(map[string]bool)
+	for _, span := range spans[0].ScopeSpans[0].Spans {
+		emitted[string(span.SpanId)] = true
+	}
+
+	for _, tt := range []struct {
+		perPoint, want int
+	}{
+		{3, 3},
+		// Capped at the number of spans of the resource
+		{10, 4},
+	} {
+		mw := newTestMetricsWorker(t, MetricsConfig{
+			ResourcesPerBatch:  1,
+			MetricsPerResource: 10,
+			MetricType:         MetricTypeSum,
+			Correlator:         correlator,
+			ExemplarsPerPoint:  tt.perPoint,
+		})
+		series := newTestMetricSeries(1, 10)
+		series.startTime = uint64(time.Now().Add(-time.Minute).UnixNano())
+		batch := mw.buildBatch(1, newTestResources(1), series, worker.NopMsgIdGenerator())
+
+		for _, dp := range batch[0].ScopeMetrics[0].Metrics[0].GetSum().DataPoints {
+			if len(dp.Exemplars) != tt.want {
+				t.Fatalf("Expected %d exemplars, got %d", tt.want, len(dp.Exemplars))
+			}
+			distinct := make(map[string]bool)
+			for _, ex := range dp.Exemplars {
+				if !emitted[string(ex.SpanId)] {
+					t.Errorf("Expected exemplar span id %x to be an emitted span", ex.SpanId)
+				}
+				distinct[string(ex.SpanId)] = true
+				if ex.TimeUnixNano < dp.StartTimeUnixNano || ex.TimeUnixNano > dp.TimeUnixNano {
+					t.Errorf("Expected exemplar time %d within [%d, %d]", ex.TimeUnixNano, dp.StartTimeUnixNano, dp.TimeUnixNano)
+				}
+			}
+			if len(distinct) != tt.want {
+				t.Errorf("Expected exemplars to reference distinct spans, got %d", len(distinct))
+			}
+		}
+	}
+}
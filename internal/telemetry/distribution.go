@@ -0,0 +1,102 @@
+package telemetry
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// Distribution determines how a total number of elements is split across buckets,
+// for example how the spans of a batch are split across its resources
+type Distribution int
+
+const (
+	// DistributionUniform splits elements evenly
+	DistributionUniform Distribution = iota
+	// DistributionSkewed splits elements following a Zipf-like curve, so the
+	// first buckets are hot and the long tail is cold
+	DistributionSkewed
+	// DistributionRandom splits elements with random weights drawn each time
+	DistributionRandom
+)
+
+func (d Distribution) String() string {
+	switch d {
+	case DistributionUniform:
+		return "uniform"
+	case DistributionSkewed:
+		return "skewed"
+	case DistributionRandom:
+		return "random"
+	default:
+		return "unknown"
+	}
+}
+
+func ParseDistribution(s string) (Distribution, error) {
+	switch s {
+	case "uniform":
+		return DistributionUniform, nil
+	case "skewed":
+		return DistributionSkewed, nil
+	case "random":
+		return DistributionRandom, nil
+	default:
+		return 0, fmt.Errorf("invalid distribution: %q (expected uniform, skewed or random)", s)
+	}
+}
+
+// weights returns the relative weight of each of the n buckets
+func (d Distribution) weights(n int) []float64 {
+	weights := make([]float64, n)
+	for i := range weights {
+		switch d {
+		case DistributionSkewed:
+			weights[i] = 1.0 / float64(i+1)
+		case DistributionRandom:
+			weights[i] = rand.ExpFloat64()
+		default:
+			weights[i] = 1.0
+		}
+	}
+	return weights
+}
+
+// split divides total elements across n buckets. The counts always sum to total,
+// remainders are assigned to the buckets with the largest fractional share.
+func (d Distribution) split(total int, n int) []int {
+	counts := make([]int, n)
+	if n == 0 {
+		return counts
+	}
+
+	weights := d.weights(n)
+	sum := 0.0
+	for _, w := range weights {
+		sum += w
+	}
+
+	type remainder struct {
+		idx  int
+		frac float64
+	}
+	remainders := make([]remainder, n)
+
+	assigned := 0
+	for i, w := range weights {
+		share := float64(total) * w / sum
+		counts[i] = int(share)
+		assigned += counts[i]
+		remainders[i] = remainder{idx: i, frac: share - float64(counts[i])}
+	}
+
+	sort.SliceStable(remainders, func(a, b int) bool {
+		return remainders[a].frac > remainders[b].frac
+	})
+	for k := 0; assigned < total; k++ {
+		counts[remainders[k%n].idx]++
+		assigned++
+	}
+
+	return counts
+}
@@ -0,0 +1,85 @@
+package telemetry
+
+import (
+	"math"
+	"testing"
+)
+
+func sum(counts []int) int {
+	total := 0
+	for _, c := range counts {
+		total += c
+	}
+	return total
+}
+
+func TestDistribution_Uniform(t *testing.T) {
+	counts := DistributionUniform.split(100, 4)
+	for i, c := range counts {
+		if c != 25 {
+			t.Errorf("Expected bucket %d to have 25, got %d", i, c)
+		}
+	}
+
+	// Remainders are spread so the total is preserved
+	counts = DistributionUniform.split(10, 3)
+	if sum(counts) != 10 {
+		t.Errorf("Expected total 10, got %d (%v)", sum(counts), counts)
+	}
+	for _, c := range counts {
+		if c < 3 || c > 4 {
+			t.Errorf("Expected uniform counts of 3 or 4, got %v", counts)
+		}
+	}
+}
+
+func TestDistribution_Skewed(t *testing.T) {
+	const total = 1000
+	const n = 5
+
+	counts := DistributionSkewed.split(total, n)
+	if sum(counts) != total {
+		t.Fatalf("Expected total %d, got %d (%v)", total, sum(counts), counts)
+	}
+
+	// Counts follow 1/(i+1), normalized
+	harmonic := 0.0
+	for i := 0; i < n; i++ {
+		harmonic += 1.0 / float64(i+1)
+	}
+	for i, c := range counts {
+		want := total / (float64(i+1) * harmonic)
+		if math.Abs(float64(c)-want) > 1 {
+			t.Errorf("Expected bucket %d to have ~%.1f, got %d", i, want, c)
+		}
+		if i > 0 && c > counts[i-1] {
+			t.Errorf("Expected non-increasing counts, got %v", counts)
+		}
+	}
+}
+
+func TestDistribution_Random(t *testing.T) {
+	for iter := 0; iter < 100; iter++ {
+		counts := DistributionRandom.split(500, 7)
+		if sum(counts) != 500 {
+			t.Fatalf("Expected total 500, got %d (%v)", sum(counts), counts)
+		}
+		for _, c := range counts {
+			if c < 0 {
+				t.Fatalf("Expected non-negative counts, got %v", counts)
+			}
+		}
+	}
+}
+
+func TestParseDistribution(t *testing.T) {
+	for _, d := range []Distribution{DistributionUniform, DistributionSkewed, DistributionRandom} {
+		parsed, err := ParseDistribution(d.String())
+		if err != nil || parsed != d {
+			t.Errorf("Expected %s to round trip, got %v, %v", d, parsed, err)
+		}
+	}
+	if _, err := ParseDistribution("normal"); err == nil {
+		t.Error("Expected error for unknown distribution")
+	}
+}
@@ -0,0 +1,436 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/compression"
+	"github.com/streamfold/otel-loadgen/internal/stats"
+	"github.com/streamfold/otel-loadgen/internal/util"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	grpcstats "google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// ExportConfig holds the export settings shared by all signal workers
+type ExportConfig struct {
+	Endpoint      *url.URL
+	UseGRPC       bool
+	HTTPEncoding  HTTPEncoding
+	Compression   compression.Type
+	CustomHeaders map[string]string
+	// TLS is used for https endpoints, the system defaults are used if nil
+	TLS *tls.Config
+	// MaxRetries is the number of times a failed export is retried before
+	// the batch is dropped
+	MaxRetries int
+	// ConnMaxAge replaces the gRPC connection once it is older than this, so
+	// the endpoint is re-resolved, zero keeps the connection open
+	ConnMaxAge time.Duration
+	// GRPCWaitForReady queues gRPC exports until the connection is ready,
+	// bounded by the export timeout, instead of failing them while the
+	// endpoint is unreachable
+	GRPCWaitForReady bool
+	// ProbeCompression sends an empty export request on startup and warns
+	// if the endpoint doesn't support the configured compression
+	ProbeCompression bool
+}
+
+// HTTPEncoding is the payload encoding used for OTLP/HTTP export
+type HTTPEncoding int
+
+const (
+	HTTPEncodingProtobuf HTTPEncoding = iota
+	HTTPEncodingJSON
+)
+
+func (e HTTPEncoding) String() string {
+	switch e {
+	case HTTPEncodingProtobuf:
+		return "protobuf"
+	case HTTPEncodingJSON:
+		return "json"
+	default:
+		return "unknown"
+	}
+}
+
+func (e HTTPEncoding) contentType() string {
+	if e == HTTPEncodingJSON {
+		return "application/json"
+	}
+	return "application/x-protobuf"
+}
+
+func ParseHTTPEncoding(s string) (HTTPEncoding, error) {
+	switch s {
+	case "protobuf":
+		return HTTPEncodingProtobuf, nil
+	case "json":
+		return HTTPEncodingJSON, nil
+	default:
+		return 0, fmt.Errorf("invalid http encoding: %q (expected json or protobuf)", s)
+	}
+}
+
+// OTLP HTTP paths and gRPC methods for each signal
+const (
+	tracesHTTPPath    = "/v1/traces"
+	tracesGRPCMethod  = "/opentelemetry.proto.collector.trace.v1.TraceService/Export"
+	metricsHTTPPath   = "/v1/metrics"
+	metricsGRPCMethod = "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export"
+	logsHTTPPath      = "/v1/logs"
+	logsGRPCMethod    = "/opentelemetry.proto.collector.logs.v1.LogsService/Export"
+)
+
+// Retry backoff bounds, the backoff doubles after each failed attempt
+const (
+	retryInitialBackoff = 100 * time.Millisecond
+	retryMaxBackoff     = 5 * time.Second
+)
+
+// exporter pushes OTLP export requests for a single signal over gRPC or HTTP
+type exporter struct {
+	log              *zap.Logger
+	cfg              ExportConfig
+	endpoint         *url.URL
+	grpcMethod       string
+	conn             *grpcConn
+	client           *http.Client
+	statBytesSent    stats.Stat
+	statBytesSentZ   stats.Stat
+	statBatchesSent  stats.Stat
+	statExportErrors stats.Stat
+	// statExportLatency is the round trip of each export attempt
+	statExportLatency stats.Latency
+	retryInitial      time.Duration
+	retryMax          time.Duration
+	done              chan struct{}
+	doneOnce          sync.Once
+}
+
+// httpStatusError is returned when the server responds with a non-2xx status
+type httpStatusError struct {
+	status int
+	body   string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d: %s", e.status, e.body)
+}
+
+func newExporter(log *zap.Logger, cfg ExportConfig, httpPath string, grpcMethod string) *exporter {
+	// Copy the endpoint, it is shared between signals
+	endpoint := *cfg.Endpoint
+
+	// For HTTP mode, ensure the endpoint has the signal path
+	if !cfg.UseGRPC && (endpoint.Path == "" || endpoint.Path == "/") {
+		endpoint.Path = httpPath
+	}
+
+	return &exporter{
+		log:          log,
+		cfg:          cfg,
+		endpoint:     &endpoint,
+		grpcMethod:   grpcMethod,
+		retryInitial: retryInitialBackoff,
+		retryMax:     retryMaxBackoff,
+		done:         make(chan struct{}),
+	}
+}
+
+func (e *exporter) init(statsBuilder stats.Builder, client *http.Client) error {
+	e.client = client
+
+	e.statBytesSent = statsBuilder.NewStat(stats.StatBytesSent)
+	e.statBytesSentZ = statsBuilder.NewStat(stats.StatBytesSentZ)
+	e.statBatchesSent = statsBuilder.NewStat(stats.StatBatchesSent)
+	e.statExportErrors = statsBuilder.NewStat(stats.StatExportErrors)
+	e.statExportLatency = statsBuilder.NewLatency(stats.StatExportLatency)
+
+	if e.cfg.UseGRPC {
+		opts := []grpc.DialOption{
+			grpc.WithStatsHandler(&wireStatsHandler{statBytesSentZ: e.statBytesSentZ}),
+		}
+		target := "dns:///" + net.JoinHostPort(e.endpoint.Hostname(), e.endpoint.Port())
+		socketPath, isUnix := util.UnixSocketPath(e.endpoint)
+		if isUnix {
+			target = "unix:" + socketPath
+		}
+
+		if name := e.cfg.Compression.GRPCCompressor(); name != "" {
+			opts = append(opts, grpc.WithDefaultCallOptions(grpc.UseCompressor(name)))
+		}
+		if e.cfg.GRPCWaitForReady {
+			opts = append(opts, grpc.WithDefaultCallOptions(grpc.WaitForReady(true)))
+		}
+
+		if e.endpoint.Scheme == "http" || isUnix {
+			opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		} else {
+			tlsCfg := e.cfg.TLS
+			if tlsCfg == nil {
+				tlsCfg = &tls.Config{}
+			}
+			opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsCfg)))
+		}
+
+		// The client connects lazily on the first export, connection errors
+		// surface as export errors
+		conn, err := newGRPCConn(func() (*grpc.ClientConn, error) {
+			return grpc.NewClient(target, opts...)
+		}, e.cfg.ConnMaxAge)
+		if err != nil {
+			return err
+		}
+
+		e.conn = conn
+	}
+
+	if e.cfg.ProbeCompression {
+		e.probeCompression()
+	}
+
+	return nil
+}
+
+// stop aborts any pending retries, it must be called before waiting for the
+// pushers to exit
+func (e *exporter) stop() {
+	e.doneOnce.Do(func() {
+		close(e.done)
+	})
+}
+
+func (e *exporter) close() {
+	e.stop()
+	if e.conn != nil {
+		_ = e.conn.Close()
+	}
+}
+
+// export sends msg and decodes the response into resp, returning true if
+// the request was accepted. Retryable failures are retried with exponential
+// backoff up to MaxRetries times, after which the batch is dropped.
+func (e *exporter) export(idx uint64, msg proto.Message, resp proto.Message) bool {
+	backoff := e.retryInitial
+
+	for attempt := 0; ; attempt++ {
+		var err error
+		if e.cfg.UseGRPC {
+			err = e.exportGRPC(idx, msg, resp)
+		} else {
+			err = e.exportHTTP(idx, msg, resp)
+		}
+		if err == nil {
+			return true
+		}
+
+		e.statExportErrors.Incr(1)
+
+		if !isRetryable(err) || attempt >= e.cfg.MaxRetries {
+			e.log.Error("failed to export batch, dropping it",
+				zap.Int("attempts", attempt+1), zap.Error(err))
+			return false
+		}
+
+		e.log.Debug("failed to export batch, retrying",
+			zap.Int("attempt", attempt+1), zap.Duration("backoff", backoff), zap.Error(err))
+
+		select {
+		case <-time.After(withJitter(backoff)):
+		case <-e.done:
+			e.log.Error("failed to export batch, dropping it on shutdown", zap.Error(err))
+			return false
+		}
+
+		backoff = min(backoff*2, e.retryMax)
+	}
+}
+
+// withJitter spreads d uniformly over [d/2, 3d/2) so retrying pushers don't
+// hit the endpoint in lockstep
+func withJitter(d time.Duration) time.Duration {
+	return d/2 + time.Duration(rand.Int63n(int64(d)+1))
+}
+
+// isRetryable returns true for transient failures, following the OTLP
+// exporter retry guidelines
+func isRetryable(err error) bool {
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.status {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		default:
+			return false
+		}
+	}
+
+	if st, ok := status.FromError(err); ok {
+		switch st.Code() {
+		case codes.Canceled, codes.DeadlineExceeded, codes.Aborted, codes.OutOfRange,
+			codes.Unavailable, codes.DataLoss, codes.ResourceExhausted:
+			return true
+		default:
+			return false
+		}
+	}
+
+	// Transport errors, e.g. connection refused or timeouts
+	return true
+}
+
+func (e *exporter) exportGRPC(idx uint64, msg proto.Message, resp proto.Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	mdMap := map[string]string{
+		"x-forwarded-for": fmt.Sprintf("127.0.0.%d", idx),
+	}
+	for k, v := range e.cfg.CustomHeaders {
+		mdMap[k] = v
+	}
+	md := metadata.New(mdMap)
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	start := time.Now()
+	err := e.conn.Invoke(ctx, e.grpcMethod, msg, resp)
+	e.statExportLatency.Record(time.Since(start))
+	if err != nil {
+		return err
+	}
+
+	e.statBytesSent.Incr(uint64(proto.Size(msg)))
+	e.statBatchesSent.Incr(1)
+
+	return nil
+}
+
+func (e *exporter) marshal(msg proto.Message) ([]byte, error) {
+	if e.cfg.HTTPEncoding == HTTPEncodingJSON {
+		return protojson.Marshal(msg)
+	}
+	return proto.Marshal(msg)
+}
+
+// unmarshal decodes a response body, using the response content type if the
+// server returned a different encoding than requested
+func (e *exporter) unmarshal(contentType string, buf []byte, resp proto.Message) error {
+	if len(buf) == 0 {
+		return nil
+	}
+
+	encoding := e.cfg.HTTPEncoding
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		switch mediaType {
+		case "application/json":
+			encoding = HTTPEncodingJSON
+		case "application/x-protobuf":
+			encoding = HTTPEncodingProtobuf
+		}
+	}
+
+	if encoding == HTTPEncodingJSON {
+		return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(buf, resp)
+	}
+	return proto.Unmarshal(buf, resp)
+}
+
+func (e *exporter) exportHTTP(idx uint64, msg proto.Message, resp proto.Message) error {
+	buf, err := e.marshal(msg)
+	if err != nil {
+		panic(err)
+	}
+
+	body, err := e.cfg.Compression.Compress(buf)
+	if err != nil {
+		panic(err)
+	}
+
+	compressedLen := len(body)
+
+	// Force a fake address to ensure we distribute across partitions
+	remoteAddr := fmt.Sprintf("127.0.0.%d", idx)
+
+	req, err := http.NewRequest(http.MethodPost, e.endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		panic(err)
+	}
+
+	req.Header.Set("X-Forwarded-For", remoteAddr)
+	req.Header.Set("Content-Type", e.cfg.HTTPEncoding.contentType())
+	// Ask for the response in the same encoding so partial success bodies can be decoded
+	req.Header.Set("Accept", e.cfg.HTTPEncoding.contentType())
+	if encoding := e.cfg.Compression.ContentEncoding(); encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+
+	for k, v := range e.cfg.CustomHeaders {
+		req.Header.Set(k, v)
+	}
+
+	start := time.Now()
+	httpResp, err := e.client.Do(req)
+	if err != nil {
+		e.statExportLatency.Record(time.Since(start))
+		return err
+	}
+
+	respBody, _ := io.ReadAll(httpResp.Body)
+	_ = httpResp.Body.Close()
+	e.statExportLatency.Record(time.Since(start))
+
+	if httpResp.StatusCode/100 != 2 {
+		return &httpStatusError{status: httpResp.StatusCode, body: string(respBody)}
+	}
+
+	if err := e.unmarshal(httpResp.Header.Get("Content-Type"), respBody, resp); err != nil {
+		e.log.Warn("failed to decode export response", zap.Error(err))
+	}
+
+	e.statBytesSent.Incr(uint64(len(buf)))
+	e.statBytesSentZ.Incr(uint64(compressedLen))
+	e.statBatchesSent.Incr(1)
+
+	return nil
+}
+
+// wireStatsHandler records the on-the-wire (compressed) size of outgoing gRPC messages
+type wireStatsHandler struct {
+	statBytesSentZ stats.Stat
+}
+
+func (h *wireStatsHandler) TagRPC(ctx context.Context, _ *grpcstats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (h *wireStatsHandler) HandleRPC(_ context.Context, s grpcstats.RPCStats) {
+	if out, ok := s.(*grpcstats.OutPayload); ok {
+		h.statBytesSentZ.Incr(uint64(out.WireLength))
+	}
+}
+
+func (h *wireStatsHandler) TagConn(ctx context.Context, _ *grpcstats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (h *wireStatsHandler) HandleConn(context.Context, grpcstats.ConnStats) {}
@@ -0,0 +1,471 @@
+package telemetry
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/compression"
+	"github.com/streamfold/otel-loadgen/internal/stats"
+	otlpTraceColl "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	otlpTraces "go.opentelemetry.io/proto/otlp/trace/v1"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestExporterHTTPEncoding(t *testing.T) {
+	tests := []struct {
+		encoding    HTTPEncoding
+		contentType string
+		unmarshal   func([]byte, proto.Message) error
+		marshal     func(proto.Message) ([]byte, error)
+	}{
+		{HTTPEncodingProtobuf, "application/x-protobuf", proto.Unmarshal, proto.Marshal},
+		{HTTPEncodingJSON, "application/json", protojson.Unmarshal, protojson.Marshal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.encoding.String(), func(t *testing.T) {
+			var gotPath, gotContentType, gotAccept string
+			var gotReq otlpTraceColl.ExportTraceServiceRequest
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.URL.Path
+				gotContentType = r.Header.Get("Content-Type")
+				gotAccept = r.Header.Get("Accept")
+
+				gr, err := gzip.NewReader(r.Body)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				body, _ := io.ReadAll(gr)
+				if err := tt.unmarshal(body, &gotReq); err != nil {
+					t.Error(err)
+				}
+
+				resp, _ := tt.marshal(&otlpTraceColl.ExportTraceServiceResponse{
+					PartialSuccess: &otlpTraceColl.ExportTracePartialSuccess{RejectedSpans: 2},
+				})
+				w.Header().Set("Content-Type", gotAccept)
+				_, _ = w.Write(resp)
+			}))
+			defer srv.Close()
+
+			endpoint, _ := url.Parse(srv.URL)
+			e := newExporter(zap.NewNop(), ExportConfig{Endpoint: endpoint, HTTPEncoding: tt.encoding}, tracesHTTPPath, tracesGRPCMethod)
+			if err := e.init(stats.NewStatTracker().NewDomain("test"), srv.Client()); err != nil {
+				t.Fatal(err)
+			}
+
+			msg := &otlpTraceColl.ExportTraceServiceRequest{
+				ResourceSpans: []*otlpTraces.ResourceSpans{{SchemaUrl: "test"}},
+			}
+			resp := &otlpTraceColl.ExportTraceServiceResponse{}
+			if !e.export(1, msg, resp) {
+				t.Fatal("Expected export to succeed")
+			}
+
+			if gotPath != tracesHTTPPath {
+				t.Errorf("Expected path %s, got %s", tracesHTTPPath, gotPath)
+			}
+			if gotContentType != tt.contentType {
+				t.Errorf("Expected content type %s, got %s", tt.contentType, gotContentType)
+			}
+			if gotAccept != tt.contentType {
+				t.Errorf("Expected accept %s, got %s", tt.contentType, gotAccept)
+			}
+			if len(gotReq.ResourceSpans) != 1 || gotReq.ResourceSpans[0].SchemaUrl != "test" {
+				t.Errorf("Unexpected request received: %v", &gotReq)
+			}
+			if resp.GetPartialSuccess().GetRejectedSpans() != 2 {
+				t.Errorf("Expected response to be decoded, got %v", resp)
+			}
+		})
+	}
+}
+
+func TestParseHTTPEncoding(t *testing.T) {
+	for _, s := range []string{"json", "protobuf"} {
+		enc, err := ParseHTTPEncoding(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if enc.String() != s {
+			t.Errorf("Expected %s, got %s", s, enc)
+		}
+	}
+
+	if _, err := ParseHTTPEncoding("xml"); err == nil {
+		t.Error("Expected error for invalid encoding")
+	}
+}
+
+func TestExporterHTTPResponseContentType(t *testing.T) {
+	// The response is decoded using its content type, even if the server
+	// ignored the requested encoding
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, _ := protojson.Marshal(&otlpTraceColl.ExportTraceServiceResponse{
+			PartialSuccess: &otlpTraceColl.ExportTracePartialSuccess{RejectedSpans: 3},
+		})
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write(resp)
+	}))
+	defer srv.Close()
+
+	endpoint, _ := url.Parse(srv.URL)
+	e := newExporter(zap.NewNop(), ExportConfig{Endpoint: endpoint, HTTPEncoding: HTTPEncodingProtobuf}, tracesHTTPPath, tracesGRPCMethod)
+	if err := e.init(stats.NewStatTracker().NewDomain("test"), srv.Client()); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := &otlpTraceColl.ExportTraceServiceResponse{}
+	if !e.export(1, &otlpTraceColl.ExportTraceServiceRequest{}, resp) {
+		t.Fatal("Expected export to succeed")
+	}
+	if resp.GetPartialSuccess().GetRejectedSpans() != 3 {
+		t.Errorf("Expected JSON response to be decoded, got %v", resp)
+	}
+}
+
+type testStat struct {
+	value atomic.Uint64
+}
+
+func (s *testStat) Incr(delta uint64) {
+	s.value.Add(delta)
+}
+
+// testStatsBuilder records the stats created by a worker so tests can inspect them
+type testStatsBuilder struct {
+	stats     map[stats.StatType]*testStat
+	latencies []*testLatency
+}
+
+func newTestStatsBuilder() *testStatsBuilder {
+	return &testStatsBuilder{stats: make(map[stats.StatType]*testStat)}
+}
+
+func (b *testStatsBuilder) NewStat(statType stats.StatType) stats.Stat {
+	s := &testStat{}
+	b.stats[statType] = s
+	return s
+}
+
+func (b *testStatsBuilder) NewGauge(statType stats.StatType) stats.Gauge {
+	g := &testGauge{}
+	b.stats[statType] = &g.testStat
+	return g
+}
+
+func (b *testStatsBuilder) NewTargetStat(statType stats.StatType, target float64) stats.Stat {
+	return b.NewStat(statType)
+}
+
+func (b *testStatsBuilder) NewLatency(statType stats.StatType) stats.Latency {
+	l := &testLatency{}
+	b.latencies = append(b.latencies, l)
+	return l
+}
+
+// testLatency counts the recorded durations
+type testLatency struct {
+	count atomic.Uint64
+}
+
+func (l *testLatency) Record(d time.Duration) {
+	l.count.Add(1)
+}
+
+func (b *testStatsBuilder) value(statType stats.StatType) uint64 {
+	if s, ok := b.stats[statType]; ok {
+		return s.value.Load()
+	}
+	return 0
+}
+
+func TestExporterHTTPCompression(t *testing.T) {
+	tests := []struct {
+		compression     compression.Type
+		contentEncoding string
+	}{
+		{compression.Gzip, "gzip"},
+		{compression.Zstd, "zstd"},
+		{compression.None, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.compression.String(), func(t *testing.T) {
+			var gotEncoding string
+			var gotLen int
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotEncoding = r.Header.Get("Content-Encoding")
+				body, _ := io.ReadAll(r.Body)
+				gotLen = len(body)
+			}))
+			defer srv.Close()
+
+			endpoint, _ := url.Parse(srv.URL)
+			e := newExporter(zap.NewNop(), ExportConfig{Endpoint: endpoint, Compression: tt.compression}, tracesHTTPPath, tracesGRPCMethod)
+			sb := newTestStatsBuilder()
+			if err := e.init(sb, srv.Client()); err != nil {
+				t.Fatal(err)
+			}
+
+			spans := make([]*otlpTraces.Span, 100)
+			for i := range spans {
+				spans[i] = &otlpTraces.Span{Name: "http_request"}
+			}
+			msg := &otlpTraceColl.ExportTraceServiceRequest{
+				ResourceSpans: []*otlpTraces.ResourceSpans{{ScopeSpans: []*otlpTraces.ScopeSpans{{Spans: spans}}}},
+			}
+			if !e.export(1, msg, &otlpTraceColl.ExportTraceServiceResponse{}) {
+				t.Fatal("Expected export to succeed")
+			}
+
+			if gotEncoding != tt.contentEncoding {
+				t.Errorf("Expected Content-Encoding %q, got %q", tt.contentEncoding, gotEncoding)
+			}
+			if sb.value(stats.StatBytesSent) != uint64(proto.Size(msg)) {
+				t.Errorf("Expected bytes sent to be the uncompressed size %d, got %d", proto.Size(msg), sb.value(stats.StatBytesSent))
+			}
+			if sb.value(stats.StatBytesSentZ) != uint64(gotLen) {
+				t.Errorf("Expected compressed bytes sent to be the body size %d, got %d", gotLen, sb.value(stats.StatBytesSentZ))
+			}
+		})
+	}
+}
+
+func newTestRetryExporter(t *testing.T, srv *httptest.Server, maxRetries int) (*exporter, *testStatsBuilder) {
+	t.Helper()
+
+	endpoint, _ := url.Parse(srv.URL)
+	e := newExporter(zap.NewNop(), ExportConfig{Endpoint: endpoint, MaxRetries: maxRetries}, tracesHTTPPath, tracesGRPCMethod)
+	e.retryInitial = time.Millisecond
+	e.retryMax = 4 * time.Millisecond
+
+	sb := newTestStatsBuilder()
+	if err := e.init(sb, srv.Client()); err != nil {
+		t.Fatal(err)
+	}
+	return e, sb
+}
+
+func TestExporterRetry_TransientFailure(t *testing.T) {
+	var attempts atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) <= 2 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	e, sb := newTestRetryExporter(t, srv, 3)
+	if !e.export(1, &otlpTraceColl.ExportTraceServiceRequest{}, &otlpTraceColl.ExportTraceServiceResponse{}) {
+		t.Fatal("Expected export to succeed after retries")
+	}
+
+	if attempts.Load() != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts.Load())
+	}
+	if sb.value(stats.StatExportErrors) != 2 {
+		t.Errorf("Expected 2 export errors, got %d", sb.value(stats.StatExportErrors))
+	}
+	if sb.value(stats.StatBatchesSent) != 1 {
+		t.Errorf("Expected 1 batch sent, got %d", sb.value(stats.StatBatchesSent))
+	}
+	if len(sb.latencies) != 1 || sb.latencies[0].count.Load() != 3 {
+		t.Errorf("Expected the latency of each of the 3 attempts to be recorded")
+	}
+}
+
+func TestExporterRetry_Exhausted(t *testing.T) {
+	var attempts atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	e, sb := newTestRetryExporter(t, srv, 2)
+	if e.export(1, &otlpTraceColl.ExportTraceServiceRequest{}, &otlpTraceColl.ExportTraceServiceResponse{}) {
+		t.Fatal("Expected export to fail once retries are exhausted")
+	}
+
+	if attempts.Load() != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts.Load())
+	}
+	if sb.value(stats.StatExportErrors) != 3 {
+		t.Errorf("Expected 3 export errors, got %d", sb.value(stats.StatExportErrors))
+	}
+}
+
+func TestExporterRetry_NonRetryableStatus(t *testing.T) {
+	var attempts atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		http.Error(w, "bad request", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	e, _ := newTestRetryExporter(t, srv, 3)
+	if e.export(1, &otlpTraceColl.ExportTraceServiceRequest{}, &otlpTraceColl.ExportTraceServiceResponse{}) {
+		t.Fatal("Expected export to fail")
+	}
+	if attempts.Load() != 1 {
+		t.Errorf("Expected a non-retryable status to not be retried, got %d attempts", attempts.Load())
+	}
+}
+
+func TestExporterRetry_TransportError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	e, sb := newTestRetryExporter(t, srv, 1)
+	srv.Close()
+
+	if e.export(1, &otlpTraceColl.ExportTraceServiceRequest{}, &otlpTraceColl.ExportTraceServiceResponse{}) {
+		t.Fatal("Expected export to a closed server to fail")
+	}
+	if sb.value(stats.StatExportErrors) != 2 {
+		t.Errorf("Expected 2 export errors, got %d", sb.value(stats.StatExportErrors))
+	}
+}
+
+func TestExporterRetry_StopAbortsBackoff(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	e, _ := newTestRetryExporter(t, srv, 100)
+	e.retryInitial = time.Hour
+	e.retryMax = time.Hour
+
+	done := make(chan bool)
+	go func() {
+		done <- e.export(1, &otlpTraceColl.ExportTraceServiceRequest{}, &otlpTraceColl.ExportTraceServiceResponse{})
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	e.stop()
+
+	select {
+	case ok := <-done:
+		if ok {
+			t.Error("Expected export to be dropped on stop")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected stop to abort the retry backoff")
+	}
+}
+
+type testTraceServer struct {
+	otlpTraceColl.UnimplementedTraceServiceServer
+}
+
+func (testTraceServer) Export(context.Context, *otlpTraceColl.ExportTraceServiceRequest) (*otlpTraceColl.ExportTraceServiceResponse, error) {
+	return &otlpTraceColl.ExportTraceServiceResponse{}, nil
+}
+
+// startTestTraceServer serves the OTLP trace service on a local port
+func startTestTraceServer(t *testing.T) string {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	otlpTraceColl.RegisterTraceServiceServer(srv, testTraceServer{})
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	return lis.Addr().String()
+}
+
+func newTestGRPCExporter(t *testing.T, addr string, cfg ExportConfig) (*exporter, *testStatsBuilder) {
+	t.Helper()
+
+	cfg.Endpoint, _ = url.Parse("http://" + addr)
+	cfg.UseGRPC = true
+	e := newExporter(zap.NewNop(), cfg, tracesHTTPPath, tracesGRPCMethod)
+	e.retryInitial = time.Millisecond
+	e.retryMax = 4 * time.Millisecond
+
+	sb := newTestStatsBuilder()
+	if err := e.init(sb, nil); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(e.close)
+	return e, sb
+}
+
+func TestExporterGRPC(t *testing.T) {
+	e, sb := newTestGRPCExporter(t, startTestTraceServer(t), ExportConfig{})
+
+	if !e.export(1, &otlpTraceColl.ExportTraceServiceRequest{}, &otlpTraceColl.ExportTraceServiceResponse{}) {
+		t.Fatal("Expected export to succeed")
+	}
+	if sb.value(stats.StatBatchesSent) != 1 {
+		t.Errorf("Expected 1 batch sent, got %d", sb.value(stats.StatBatchesSent))
+	}
+}
+
+func TestExporterGRPC_Unreachable(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+
+	e, sb := newTestGRPCExporter(t, addr, ExportConfig{MaxRetries: 1})
+	if e.export(1, &otlpTraceColl.ExportTraceServiceRequest{}, &otlpTraceColl.ExportTraceServiceResponse{}) {
+		t.Fatal("Expected export to an unreachable endpoint to fail")
+	}
+	if sb.value(stats.StatExportErrors) != 2 {
+		t.Errorf("Expected 2 export errors, got %d", sb.value(stats.StatExportErrors))
+	}
+}
+
+func TestExporterGRPCWaitForReady(t *testing.T) {
+	// Reserve a port, the server only starts listening after the export
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+
+	e, sb := newTestGRPCExporter(t, addr, ExportConfig{GRPCWaitForReady: true})
+
+	srv := grpc.NewServer()
+	otlpTraceColl.RegisterTraceServiceServer(srv, testTraceServer{})
+	defer srv.Stop()
+
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		lis, err := net.Listen("tcp", addr)
+		if err != nil {
+			t.Errorf("Failed to listen on %s: %v", addr, err)
+			return
+		}
+		srv.Serve(lis)
+	}()
+
+	if !e.export(1, &otlpTraceColl.ExportTraceServiceRequest{}, &otlpTraceColl.ExportTraceServiceResponse{}) {
+		t.Fatal("Expected export to succeed once the server is up")
+	}
+	if sb.value(stats.StatExportErrors) != 0 {
+		t.Errorf("Expected no export errors, got %d", sb.value(stats.StatExportErrors))
+	}
+}
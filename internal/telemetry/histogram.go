@@ -0,0 +1,173 @@
+package telemetry
+
+import (
+	"math"
+	"math/rand"
+
+	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+	otlpMetrics "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+// Generated histogram values are clamped to this range (in ms)
+const (
+	histogramMinValue = 1.0
+	histogramMaxValue = 10_000.0
+)
+
+// Observed values follow a log-normal distribution around the median, which
+// resembles typical request latencies with a long tail
+const (
+	histogramMedian = 100.0
+	histogramSigma  = 1.0
+)
+
+// histogramState accumulates observations of a single cumulative histogram series
+type histogramState struct {
+	count uint64
+	sum   float64
+	min   float64
+	max   float64
+
+	// explicit bucket histograms
+	bounds []float64
+
+	// exponential histograms
+	exponential bool
+	scale       int32
+	offset      int32
+
+	counts []uint64
+}
+
+// newExplicitHistogramState creates a histogram with numBuckets buckets whose
+// bounds grow geometrically across the value range
+func newExplicitHistogramState(numBuckets int) *histogramState {
+	numBounds := max(numBuckets-1, 0)
+	bounds := make([]float64, numBounds)
+	prev := histogramMinValue
+	for k := 0; k < numBounds; k++ {
+		frac := float64(k+1) / float64(numBounds+1)
+		v := histogramMinValue * math.Pow(histogramMaxValue/histogramMinValue, frac)
+		bounds[k] = roundBound(v, v-prev)
+		prev = v
+	}
+
+	return &histogramState{
+		bounds: bounds,
+		counts: make([]uint64, numBounds+1),
+	}
+}
+
+// roundBound rounds a bucket bound to whole numbers, or to a tenth of the gap
+// to the previous bound when bounds are closer, so they stay increasing
+func roundBound(v, gap float64) float64 {
+	// Dividing by a power of ten gives the closest float to the decimal
+	scale := max(math.Pow(10, 1-math.Floor(math.Log10(gap))), 1)
+	return math.Round(v*scale) / scale
+}
+
+// minExpHistogramBuckets is the smallest bucket budget of an exponential
+// histogram. The minimum value of 1 is a bucket boundary at every scale, so
+// the value range always spans at least two buckets.
+const minExpHistogramBuckets = 2
+
+// newExpHistogramState creates an exponential histogram using the largest scale
+// that fits the value range into maxBuckets positive buckets
+func newExpHistogramState(maxBuckets int) *histogramState {
+	maxBuckets = max(maxBuckets, minExpHistogramBuckets)
+
+	scale := int32(20)
+	for ; scale > -10; scale-- {
+		if expBucketIndex(histogramMaxValue, scale)-expBucketIndex(histogramMinValue, scale)+1 <= int32(maxBuckets) {
+			break
+		}
+	}
+
+	lo := expBucketIndex(histogramMinValue, scale)
+	hi := expBucketIndex(histogramMaxValue, scale)
+
+	return &histogramState{
+		exponential: true,
+		scale:       scale,
+		offset:      lo,
+		counts:      make([]uint64, hi-lo+1),
+	}
+}
+
+// expBucketIndex returns the index of the bucket (base^index, base^(index+1)]
+// containing value, where base = 2^(2^-scale)
+func expBucketIndex(value float64, scale int32) int32 {
+	return int32(math.Ceil(math.Log2(value)*math.Exp2(float64(scale)))) - 1
+}
+
+func histogramSamplesPerBatch() int {
+	return 10 + rand.Intn(90)
+}
+
+func (h *histogramState) observe(numSamples int) {
+	for n := 0; n < numSamples; n++ {
+		v := math.Exp(math.Log(histogramMedian) + rand.NormFloat64()*histogramSigma)
+		v = min(max(v, histogramMinValue), histogramMaxValue)
+
+		if h.count == 0 || v < h.min {
+			h.min = v
+		}
+		if h.count == 0 || v > h.max {
+			h.max = v
+		}
+		h.count++
+		h.sum += v
+
+		if h.exponential {
+			h.counts[expBucketIndex(v, h.scale)-h.offset]++
+		} else {
+			h.counts[h.explicitBucket(v)]++
+		}
+	}
+}
+
+func (h *histogramState) explicitBucket(v float64) int {
+	// Buckets are upper-bound inclusive
+	for k, bound := range h.bounds {
+		if v <= bound {
+			return k
+		}
+	}
+	return len(h.bounds)
+}
+
+func (h *histogramState) histogramDataPoint(attrs []*otlpCommon.KeyValue, startTime uint64, ts uint64) *otlpMetrics.HistogramDataPoint {
+	sum, minV, maxV := h.sum, h.min, h.max
+
+	return &otlpMetrics.HistogramDataPoint{
+		Attributes:        attrs,
+		StartTimeUnixNano: startTime,
+		TimeUnixNano:      ts,
+		Count:             h.count,
+		Sum:               &sum,
+		BucketCounts:      append([]uint64(nil), h.counts...),
+		ExplicitBounds:    h.bounds,
+		Min:               &minV,
+		Max:               &maxV,
+	}
+}
+
+func (h *histogramState) expHistogramDataPoint(attrs []*otlpCommon.KeyValue, startTime uint64, ts uint64) *otlpMetrics.ExponentialHistogramDataPoint {
+	sum, minV, maxV := h.sum, h.min, h.max
+
+	return &otlpMetrics.ExponentialHistogramDataPoint{
+		Attributes:        attrs,
+		StartTimeUnixNano: startTime,
+		TimeUnixNano:      ts,
+		Count:             h.count,
+		Sum:               &sum,
+		Scale:             h.scale,
+		ZeroCount:         0,
+		Positive: &otlpMetrics.ExponentialHistogramDataPoint_Buckets{
+			Offset:       h.offset,
+			BucketCounts: append([]uint64(nil), h.counts...),
+		},
+		Min: &minV,
+		Max: &maxV,
+	}
+}
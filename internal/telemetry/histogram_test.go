@@ -0,0 +1,96 @@
+package telemetry
+
+import (
+	"math"
+	"testing"
+)
+
+func sumCounts(counts []uint64) uint64 {
+	var total uint64
+	for _, c := range counts {
+		total += c
+	}
+	return total
+}
+
+func TestExplicitHistogram_Bounds(t *testing.T) {
+	for _, buckets := range []int{1, 2, 5, 20, 100} {
+		h := newExplicitHistogramState(buckets)
+		if len(h.counts) != buckets || len(h.bounds) != buckets-1 {
+			t.Errorf("Expected %d buckets and %d bounds, got %d and %d", buckets, buckets-1, len(h.counts), len(h.bounds))
+		}
+		for k := 1; k < len(h.bounds); k++ {
+			if h.bounds[k] <= h.bounds[k-1] {
+				t.Errorf("Expected increasing bounds with %d buckets, got %v", buckets, h.bounds)
+				break
+			}
+		}
+	}
+}
+
+func TestExplicitHistogram_Observe(t *testing.T) {
+	h := newExplicitHistogramState(20)
+	for i := 0; i < 10; i++ {
+		h.observe(histogramSamplesPerBatch())
+	}
+
+	dp := h.histogramDataPoint(nil, 1, 2)
+	if dp.Count != h.count || sumCounts(dp.BucketCounts) != dp.Count {
+		t.Errorf("Expected bucket counts to sum to %d, got %d", dp.Count, sumCounts(dp.BucketCounts))
+	}
+	if len(dp.BucketCounts) != len(dp.ExplicitBounds)+1 {
+		t.Errorf("Expected one more bucket than bounds, got %d buckets and %d bounds", len(dp.BucketCounts), len(dp.ExplicitBounds))
+	}
+	if *dp.Min > *dp.Max || *dp.Min < histogramMinValue || *dp.Max > histogramMaxValue {
+		t.Errorf("Expected %v <= min %v <= max %v <= %v", histogramMinValue, *dp.Min, *dp.Max, histogramMaxValue)
+	}
+	if *dp.Sum < *dp.Min*float64(dp.Count) || *dp.Sum > *dp.Max*float64(dp.Count) {
+		t.Errorf("Expected sum %v between count * min and count * max", *dp.Sum)
+	}
+}
+
+func TestExpHistogram_BucketBudget(t *testing.T) {
+	for buckets := minExpHistogramBuckets; buckets <= 160; buckets++ {
+		h := newExpHistogramState(buckets)
+		if len(h.counts) > buckets {
+			t.Errorf("Expected at most %d buckets, got %d at scale %d", buckets, len(h.counts), h.scale)
+		}
+
+		// The scale is the largest that fits the budget
+		if h.scale < 20 {
+			finer := expBucketIndex(histogramMaxValue, h.scale+1) - expBucketIndex(histogramMinValue, h.scale+1) + 1
+			if finer <= int32(buckets) {
+				t.Errorf("Expected scale %d to fit %d buckets, only got scale %d", h.scale+1, buckets, h.scale)
+			}
+		}
+	}
+
+	// Smaller budgets are clamped to the smallest that covers the value range
+	if h := newExpHistogramState(1); len(h.counts) != minExpHistogramBuckets {
+		t.Errorf("Expected %d buckets for a budget of 1, got %d", minExpHistogramBuckets, len(h.counts))
+	}
+}
+
+func TestExpHistogram_Observe(t *testing.T) {
+	h := newExpHistogramState(20)
+	for i := 0; i < 10; i++ {
+		h.observe(histogramSamplesPerBatch())
+	}
+
+	dp := h.expHistogramDataPoint(nil, 1, 2)
+	if sumCounts(dp.Positive.BucketCounts)+dp.ZeroCount != dp.Count {
+		t.Errorf("Expected bucket counts to sum to %d, got %d", dp.Count, sumCounts(dp.Positive.BucketCounts))
+	}
+	if *dp.Min > *dp.Max {
+		t.Errorf("Expected min %v <= max %v", *dp.Min, *dp.Max)
+	}
+
+	// Bucket i covers (base^(offset+i), base^(offset+i+1)], together the
+	// buckets must cover the whole value range
+	base := math.Exp2(math.Exp2(-float64(dp.Scale)))
+	lower := math.Pow(base, float64(dp.Positive.Offset))
+	upper := math.Pow(base, float64(dp.Positive.Offset)+float64(len(dp.Positive.BucketCounts)))
+	if lower >= histogramMinValue || upper < histogramMaxValue {
+		t.Errorf("Expected buckets (%v, %v] to cover [%v, %v]", lower, upper, histogramMinValue, histogramMaxValue)
+	}
+}
@@ -0,0 +1,107 @@
+package telemetry
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/streamfold/otel-loadgen/internal/otlp/anyvalue"
+	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+	otlpLogs "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+// LogBody determines the shape of generated log records
+type LogBody int
+
+const (
+	// LogBodyString is a plaintext message
+	LogBodyString LogBody = iota
+	// LogBodyJSON is a structured log line as a nested kvlist body, with the
+	// message, level and request details as fields
+	LogBodyJSON
+	// LogBodyKVList is a plaintext message with the level and request details
+	// as attributes
+	LogBodyKVList
+)
+
+func ParseLogBody(s string) (LogBody, error) {
+	switch s {
+	case "string":
+		return LogBodyString, nil
+	case "json":
+		return LogBodyJSON, nil
+	case "kvlist":
+		return LogBodyKVList, nil
+	default:
+		return 0, fmt.Errorf("invalid log body: %q (expected string, json or kvlist)", s)
+	}
+}
+
+// logRequest is the request a generated log line is about
+type logRequest struct {
+	method string
+	route  string
+}
+
+var logRequests = []logRequest{
+	{"GET", "/api/users/{id}"},
+	{"POST", "/api/orders"},
+	{"GET", "/api/products"},
+	{"PUT", "/api/carts/{id}"},
+	{"DELETE", "/api/sessions/{id}"},
+}
+
+// logStatusCode is the HTTP status of a request logged with severity
+func logStatusCode(severity otlpLogs.SeverityNumber) int {
+	switch severity {
+	case otlpLogs.SeverityNumber_SEVERITY_NUMBER_ERROR:
+		return 503
+	case otlpLogs.SeverityNumber_SEVERITY_NUMBER_WARN:
+		return 429
+	default:
+		return 200
+	}
+}
+
+// newLogRecord returns the j-th log record of a resource with the body shape
+func (b LogBody) newLogRecord(j int, msg logMessage) *otlpLogs.LogRecord {
+	record := &otlpLogs.LogRecord{
+		SeverityNumber: msg.severity,
+		SeverityText:   severityText(msg.severity),
+	}
+
+	req := logRequests[j%len(logRequests)]
+	level := strings.ToLower(record.SeverityText)
+	status := logStatusCode(msg.severity)
+	// Vary the duration so parsed values aren't constant
+	durationMs := float64(5+(j*37)%500) + 0.25
+
+	switch b {
+	case LogBodyJSON:
+		record.Body = anyvalue.FromJSON(map[string]any{
+			"level":   level,
+			"message": msg.body,
+			"logger":  "app.http",
+			"http": map[string]any{
+				"method":      req.method,
+				"route":       req.route,
+				"status_code": status,
+				"duration_ms": durationMs,
+			},
+			"request_id": fmt.Sprintf("req-%08x", j),
+			"tags":       []any{"loadgen", level},
+		})
+	case LogBodyKVList:
+		record.Body = anyvalue.FromJSON(msg.body)
+		record.Attributes = []*otlpCommon.KeyValue{
+			{Key: "log.level", Value: anyvalue.FromJSON(level)},
+			{Key: "http.method", Value: anyvalue.FromJSON(req.method)},
+			{Key: "http.route", Value: anyvalue.FromJSON(req.route)},
+			{Key: "http.status_code", Value: anyvalue.FromJSON(status)},
+			{Key: "http.duration_ms", Value: anyvalue.FromJSON(durationMs)},
+		}
+	default:
+		record.Body = anyvalue.FromJSON(msg.body)
+	}
+
+	return record
+}
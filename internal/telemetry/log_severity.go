@@ -0,0 +1,82 @@
+package telemetry
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/streamfold/otel-loadgen/internal/util"
+	otlpLogs "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+var severityNames = map[string]otlpLogs.SeverityNumber{
+	"trace": otlpLogs.SeverityNumber_SEVERITY_NUMBER_TRACE,
+	"debug": otlpLogs.SeverityNumber_SEVERITY_NUMBER_DEBUG,
+	"info":  otlpLogs.SeverityNumber_SEVERITY_NUMBER_INFO,
+	"warn":  otlpLogs.SeverityNumber_SEVERITY_NUMBER_WARN,
+	"error": otlpLogs.SeverityNumber_SEVERITY_NUMBER_ERROR,
+	"fatal": otlpLogs.SeverityNumber_SEVERITY_NUMBER_FATAL,
+}
+
+// severityLogMessages are the messages of each severity, the common messages
+// plus some for the severities they don't cover
+var severityLogMessages = func() map[otlpLogs.SeverityNumber][]logMessage {
+	messages := make(map[otlpLogs.SeverityNumber][]logMessage)
+	for _, msg := range append(commonLogMessages,
+		logMessage{otlpLogs.SeverityNumber_SEVERITY_NUMBER_TRACE, "entering request handler"},
+		logMessage{otlpLogs.SeverityNumber_SEVERITY_NUMBER_TRACE, "acquired connection from pool"},
+		logMessage{otlpLogs.SeverityNumber_SEVERITY_NUMBER_FATAL, "out of memory, shutting down"},
+		logMessage{otlpLogs.SeverityNumber_SEVERITY_NUMBER_FATAL, "unrecoverable storage corruption detected"},
+	) {
+		messages[msg.severity] = append(messages[msg.severity], msg)
+	}
+	return messages
+}()
+
+// SeverityMix assigns log severities by record index following relative
+// weights. The severity of an index only depends on the seed, so every batch
+// has the same mix and runs with the same seed generate the same logs.
+type SeverityMix struct {
+	severities *util.WeightedChoice[otlpLogs.SeverityNumber]
+	seed       uint64
+}
+
+// ParseSeverityMix parses a weight list of the format 'debug:10,info:70,warn:15,error:5'
+func ParseSeverityMix(s string, seed int64) (*SeverityMix, error) {
+	names, weights, err := util.ParseWeights(s)
+	if err != nil {
+		return nil, err
+	}
+
+	severities := make([]otlpLogs.SeverityNumber, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		severity, ok := severityNames[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("invalid severity: %q (expected trace, debug, info, warn, error or fatal)", name)
+		}
+		if seen[strings.ToLower(name)] {
+			return nil, fmt.Errorf("duplicate severity: %q", name)
+		}
+		seen[strings.ToLower(name)] = true
+		severities = append(severities, severity)
+	}
+
+	wc, err := util.NewWeightedChoice(severities, weights)
+	if err != nil {
+		return nil, fmt.Errorf("invalid severity weights: %w", err)
+	}
+
+	return &SeverityMix{severities: wc, seed: uint64(seed)}, nil
+}
+
+// message returns the message of the record at index within its batch, the
+// j-th message of its severity. A nil SeverityMix cycles through the common
+// messages.
+func (m *SeverityMix) message(index, j int) logMessage {
+	if m == nil {
+		return commonLogMessages[j%len(commonLogMessages)]
+	}
+
+	messages := severityLogMessages[m.severities.Pick(seededUnit(m.seed, index))]
+	return messages[j%len(messages)]
+}
@@ -0,0 +1,90 @@
+package telemetry
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/streamfold/otel-loadgen/internal/worker"
+	otlpLogs "go.opentelemetry.io/proto/otlp/logs/v1"
+	"go.uber.org/zap"
+)
+
+func TestParseSeverityMix(t *testing.T) {
+	if _, err := ParseSeverityMix("trace:1,debug:10,info:70,warn:15,error:4,fatal:0", 0); err != nil {
+		t.Fatalf("Expected a valid severity mix, got %v", err)
+	}
+	for _, s := range []string{"", "info", "info:x", "info:1,INFO:2", "info:0", "notice:1"} {
+		if _, err := ParseSeverityMix(s, 0); err == nil {
+			t.Errorf("Expected error parsing %q", s)
+		}
+	}
+}
+
+func TestSeverityMix_Distribution(t *testing.T) {
+	mix, err := ParseSeverityMix("debug:1,info:2,error:1,fatal:0", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[otlpLogs.SeverityNumber]int)
+	const n = 10000
+	for i := 0; i < n; i++ {
+		msg := mix.message(i, i)
+		if severityText(msg.severity) == "" || msg.body == "" {
+			t.Fatalf("Expected a message with a severity, got %+v", msg)
+		}
+		counts[msg.severity]++
+	}
+
+	want := map[otlpLogs.SeverityNumber]float64{
+		otlpLogs.SeverityNumber_SEVERITY_NUMBER_DEBUG: 0.25,
+		otlpLogs.SeverityNumber_SEVERITY_NUMBER_INFO:  0.5,
+		otlpLogs.SeverityNumber_SEVERITY_NUMBER_ERROR: 0.25,
+	}
+	for severity, frac := range want {
+		got := float64(counts[severity]) / n
+		if got < frac-0.03 || got > frac+0.03 {
+			t.Errorf("Expected %v to be ~%.2f of records, got %.3f", severity, frac, got)
+		}
+	}
+	if len(counts) != len(want) {
+		t.Errorf("Expected only weighted severities, got %v", counts)
+	}
+}
+
+func TestLogsBuildBatch_SeverityMix(t *testing.T) {
+	endpoint, err := url.Parse("http://localhost:4317")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exportCfg := ExportConfig{Endpoint: endpoint, UseGRPC: true}
+	newRecords := func(seed int64) []*otlpLogs.LogRecord {
+		mix, err := ParseSeverityMix("trace:1,info:1,fatal:1", seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logs := NewLogsWorker(zap.NewNop(), exportCfg, LogsConfig{ResourcesPerBatch: 1, LogsPerResource: 50, Severities: mix, Body: LogBodyJSON}).(*logsWorker)
+		return logs.buildBatch(1, newTestResources(1), worker.NopMsgIdGenerator())[0].ScopeLogs[0].LogRecords
+	}
+
+	a, b := newRecords(7), newRecords(7)
+	seen := make(map[string]bool)
+	for i, record := range a {
+		if record.SeverityNumber != b[i].SeverityNumber {
+			t.Fatalf("Expected the same severity for record %d with the same seed", i)
+		}
+		if record.SeverityText != severityText(record.SeverityNumber) {
+			t.Errorf("Expected severity text %s, got %s", severityText(record.SeverityNumber), record.SeverityText)
+		}
+		// The structured body follows the picked severity
+		level := findAttrValue(record.Body.GetKvlistValue().GetValues(), "level").GetStringValue()
+		if level != strings.ToLower(record.SeverityText) {
+			t.Errorf("Expected body level %s, got %s", strings.ToLower(record.SeverityText), level)
+		}
+		seen[record.SeverityText] = true
+	}
+	if !seen["TRACE"] || !seen["INFO"] || !seen["FATAL"] {
+		t.Errorf("Expected trace, info and fatal records, got %v", seen)
+	}
+}
@@ -0,0 +1,265 @@
+package telemetry
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/genai"
+	"github.com/streamfold/otel-loadgen/internal/otlp"
+	"github.com/streamfold/otel-loadgen/internal/otlp/anyvalue"
+	"github.com/streamfold/otel-loadgen/internal/stats"
+	"github.com/streamfold/otel-loadgen/internal/worker"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	otlpLogsColl "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+	otlpLogs "go.opentelemetry.io/proto/otlp/logs/v1"
+	otlpRes "go.opentelemetry.io/proto/otlp/resource/v1"
+	"go.uber.org/zap"
+)
+
+// LogsConfig holds the settings for generating log records
+type LogsConfig struct {
+	ResourcesPerBatch int
+	// ResourceCounts varies the number of resources of each batch around
+	// ResourcesPerBatch, the default gives every batch ResourcesPerBatch
+	ResourceCounts  ResourceCountDistribution
+	LogsPerResource int
+	// Body is the shape of the generated log records
+	Body LogBody
+	// Severities, if set, picks the severity of each record, otherwise the
+	// records cycle through a fixed set of messages
+	Severities     *SeverityMix
+	BaseTime       time.Time
+	BuildQueueSize int
+	// Correlator, if set, is used to reference spans emitted by the traces worker
+	Correlator *Correlator
+	// GenAICorpus, if set, replaces the log records with gen_ai events of the
+	// corpus conversations
+	GenAICorpus genai.EntrySource
+	// ResourceAttrs are added to every generated resource, e.g. the detected
+	// host and process attributes
+	ResourceAttrs []*otlpCommon.KeyValue
+	// Scope is the instrumentation scope of the generated logs
+	Scope otlp.ScopeConfig
+	// Resource sets the service name and user attributes of the resources
+	Resource otlp.ResourceConfig
+}
+
+type logsWorker struct {
+	log               *zap.Logger
+	resourcesPerBatch int
+	resourceCounts    ResourceCountDistribution
+	logsPerResource   int
+	body              LogBody
+	severities        *SeverityMix
+	exp               *exporter
+	scope             *otlpCommon.InstrumentationScope
+	wg                sync.WaitGroup
+	nextWorkerId      atomic.Uint64
+	stopChan          chan bool
+	statLogsSent      stats.Stat
+	statRejected      stats.Stat
+	statQueueDepth    stats.Gauge
+	now               clock
+	buildQueueSize    int
+	correlator        *Correlator
+	genAICorpus       genai.EntrySource
+	resourceAttrs     []*otlpCommon.KeyValue
+	resource          otlp.ResourceConfig
+}
+
+func NewLogsWorker(log *zap.Logger, exportCfg ExportConfig, cfg LogsConfig) worker.Worker {
+	return &logsWorker{
+		log:               log,
+		exp:               newExporter(log, exportCfg, logsHTTPPath, logsGRPCMethod),
+		resourcesPerBatch: cfg.ResourcesPerBatch,
+		resourceCounts:    cfg.ResourceCounts,
+		logsPerResource:   cfg.LogsPerResource,
+		body:              cfg.Body,
+		severities:        cfg.Severities,
+		scope:             otlp.NewScope(cfg.Scope),
+		now:               newClock(cfg.BaseTime),
+		buildQueueSize:    cfg.BuildQueueSize,
+		correlator:        cfg.Correlator,
+		genAICorpus:       cfg.GenAICorpus,
+		resourceAttrs:     cfg.ResourceAttrs,
+		resource:          cfg.Resource,
+	}
+}
+
+func (o *logsWorker) Init(statsBuilder stats.Builder, client *http.Client) error {
+	o.wg = sync.WaitGroup{}
+	o.stopChan = make(chan bool)
+
+	o.statLogsSent = statsBuilder.NewStat(stats.StatLogsSent)
+	o.statRejected = statsBuilder.NewStat(stats.StatRejected)
+	if o.buildQueueSize > 0 {
+		o.statQueueDepth = statsBuilder.NewGauge(stats.StatQueueDepth)
+	}
+
+	return o.exp.init(statsBuilder, client)
+}
+
+func (o *logsWorker) Start(pushInterval time.Duration, msgIdGen worker.MsgIdGenerator) {
+	pusherIdx := o.nextWorkerId.Add(1)
+	ticker := time.NewTicker(pushInterval)
+
+	o.wg.Add(1)
+	go func() {
+		defer func() {
+			ticker.Stop()
+			o.wg.Done()
+		}()
+
+		o.pushWait(ticker.C, pusherIdx, msgIdGen)
+	}()
+}
+
+func (o *logsWorker) StopAll() {
+	close(o.stopChan)
+	o.exp.stop()
+	o.wg.Wait()
+	o.exp.close()
+}
+
+func (o *logsWorker) pushWait(tick <-chan time.Time, idx uint64, msgIdGen worker.MsgIdGenerator) {
+	resources := make([]*otlpRes.Resource, 0)
+	for i := 0; i < o.resourceCounts.max(o.resourcesPerBatch); i++ {
+		res := otlp.NewResource(o.resource, idx, i)
+		res.Attributes = append(res.Attributes, o.resourceAttrs...)
+		res.Attributes = msgIdGen.AddResourceAttrs(res.Attributes)
+		resources = append(resources, res)
+	}
+
+	rng := newResourceCountRand()
+	runBuildQueue(o.stopChan, tick, o.buildQueueSize, o.statQueueDepth,
+		func() []*otlpLogs.ResourceLogs {
+			return o.buildBatch(idx, o.resourceCounts.resources(rng, resources, o.resourcesPerBatch), msgIdGen)
+		},
+		func(batch []*otlpLogs.ResourceLogs) {
+			o.pushIt(idx, batch)
+		})
+}
+
+func (o *logsWorker) pushIt(idx uint64, batch []*otlpLogs.ResourceLogs) {
+	numLogs := len(batch) * o.logsPerResource
+	if numLogs == 0 {
+		return
+	}
+
+	msg := &otlpLogsColl.ExportLogsServiceRequest{ResourceLogs: batch}
+	resp := &otlpLogsColl.ExportLogsServiceResponse{}
+	if !o.exp.export(idx, msg, resp) {
+		return
+	}
+
+	if ps := resp.GetPartialSuccess(); ps != nil && ps.GetRejectedLogRecords() != 0 {
+		o.log.Warn("export partially rejected",
+			zap.Int64("rejected", ps.GetRejectedLogRecords()), zap.String("message", ps.GetErrorMessage()))
+		o.statRejected.Incr(uint64(ps.GetRejectedLogRecords()))
+	}
+
+	o.statLogsSent.Incr(uint64(numLogs))
+}
+
+func (o *logsWorker) buildBatch(idx uint64, resources []*otlpRes.Resource, msgIdGen worker.MsgIdGenerator) []*otlpLogs.ResourceLogs {
+	resLogs := make([]*otlpLogs.ResourceLogs, 0, len(resources))
+
+	for i, res := range resources {
+		records := make([]*otlpLogs.LogRecord, 0, o.logsPerResource)
+		nowNano := o.now().UnixNano()
+
+		// gen_ai events of the current corpus conversation
+		var events []*otlpLogs.LogRecord
+
+		for j := 0; j < o.logsPerResource; j++ {
+			ts := uint64(nowNano + int64(j)*int64(1_000_000))
+
+			attrs := []*otlpCommon.KeyValue{
+				{
+					Key:   "index",
+					Value: anyvalue.Int(int64(j)),
+				},
+			}
+			attrs = msgIdGen.AddElementAttrs(attrs)
+
+			if o.genAICorpus != nil && len(events) == 0 {
+				events = o.genAICorpus.LogRecords()
+			}
+
+			var record *otlpLogs.LogRecord
+			if len(events) > 0 {
+				record, events = events[0], events[1:]
+			} else {
+				record = o.body.newLogRecord(j, o.severities.message(i*o.logsPerResource+j, j))
+			}
+			record.TimeUnixNano = ts
+			record.ObservedTimeUnixNano = ts
+			record.Attributes = append(record.Attributes, attrs...)
+
+			if o.correlator != nil {
+				if ref, ok := o.correlator.pick(idx, i); ok {
+					record.TraceId = ref.TraceId
+					record.SpanId = ref.SpanId
+					// sampled
+					record.Flags = 0x01
+				}
+			}
+
+			records = append(records, record)
+		}
+
+		resLogs = append(resLogs, &otlpLogs.ResourceLogs{
+			Resource: res,
+			ScopeLogs: []*otlpLogs.ScopeLogs{
+				{
+					Scope:      o.scope,
+					LogRecords: records,
+					SchemaUrl:  semconv.SchemaURL,
+				},
+			},
+			SchemaUrl: semconv.SchemaURL,
+		})
+	}
+
+	return resLogs
+}
+
+func severityText(severity otlpLogs.SeverityNumber) string {
+	switch severity {
+	case otlpLogs.SeverityNumber_SEVERITY_NUMBER_TRACE:
+		return "TRACE"
+	case otlpLogs.SeverityNumber_SEVERITY_NUMBER_DEBUG:
+		return "DEBUG"
+	case otlpLogs.SeverityNumber_SEVERITY_NUMBER_WARN:
+		return "WARN"
+	case otlpLogs.SeverityNumber_SEVERITY_NUMBER_ERROR:
+		return "ERROR"
+	case otlpLogs.SeverityNumber_SEVERITY_NUMBER_FATAL:
+		return "FATAL"
+	default:
+		return "INFO"
+	}
+}
+
+type logMessage struct {
+	severity otlpLogs.SeverityNumber
+	body     string
+}
+
+// Common log messages for realistic telemetry data
+var commonLogMessages = []logMessage{
+	{otlpLogs.SeverityNumber_SEVERITY_NUMBER_INFO, "request completed successfully"},
+	{otlpLogs.SeverityNumber_SEVERITY_NUMBER_INFO, "user session started"},
+	{otlpLogs.SeverityNumber_SEVERITY_NUMBER_DEBUG, "cache lookup miss, fetching from database"},
+	{otlpLogs.SeverityNumber_SEVERITY_NUMBER_INFO, "message published to queue"},
+	{otlpLogs.SeverityNumber_SEVERITY_NUMBER_WARN, "slow database query detected"},
+	{otlpLogs.SeverityNumber_SEVERITY_NUMBER_INFO, "configuration reloaded"},
+	{otlpLogs.SeverityNumber_SEVERITY_NUMBER_ERROR, "failed to connect to upstream service"},
+	{otlpLogs.SeverityNumber_SEVERITY_NUMBER_DEBUG, "parsed request payload"},
+	{otlpLogs.SeverityNumber_SEVERITY_NUMBER_WARN, "retrying request after timeout"},
+	{otlpLogs.SeverityNumber_SEVERITY_NUMBER_INFO, "background job finished"},
+}
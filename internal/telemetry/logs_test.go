@@ -0,0 +1,182 @@
+package telemetry
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/streamfold/otel-loadgen/internal/genai"
+	"github.com/streamfold/otel-loadgen/internal/otlp"
+	"github.com/streamfold/otel-loadgen/internal/worker"
+	otlpLogs "go.opentelemetry.io/proto/otlp/logs/v1"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestLogsBuildBatch_Scope(t *testing.T) {
+	endpoint, err := url.Parse("http://localhost:4317")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scopeAttrs, err := otlp.ParseAttrs([]string{"library.team=mobile"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scope := otlp.ScopeConfig{Name: "io.opentelemetry.okhttp", Version: "4.12.0", Attributes: scopeAttrs}
+	exportCfg := ExportConfig{Endpoint: endpoint, UseGRPC: true}
+	logs := NewLogsWorker(zap.NewNop(), exportCfg, LogsConfig{ResourcesPerBatch: 2, LogsPerResource: 3, Scope: scope}).(*logsWorker)
+	traces := newTestTracesWorker(t, TracesConfig{Scope: scope})
+
+	batch := logs.buildBatch(1, newTestResources(2), worker.NopMsgIdGenerator())
+	if len(batch) != 2 {
+		t.Fatalf("Expected 2 resources, got %d", len(batch))
+	}
+	for _, rl := range batch {
+		s := rl.ScopeLogs[0].Scope
+		if s.Name != scope.Name || s.Version != scope.Version {
+			t.Errorf("Expected scope %s %s, got %s %s", scope.Name, scope.Version, s.Name, s.Version)
+		}
+		if v := findAttrValue(s.Attributes, "library.team"); v.GetStringValue() != "mobile" {
+			t.Errorf("Expected the library.team scope attribute, got %v", s.Attributes)
+		}
+		// Logs and traces of a run share the scope identity
+		if !proto.Equal(s, traces.scope) {
+			t.Errorf("Expected logs scope %v to match traces scope %v", s, traces.scope)
+		}
+	}
+}
+
+// writeTestCorpus writes a corpus of n single turn conversations whose text is
+// prefixed with name
+func writeTestCorpus(t *testing.T, name string, n int) *genai.Corpus {
+	t.Helper()
+
+	entries := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		entries = append(entries, fmt.Sprintf(`{"system": "%[1]s system %[2]d", "tools": "[]", "conversations": [
+			{"from": "human", "value": "%[1]s question %[2]d"}, {"from": "gpt", "value": "%[1]s answer %[2]d"}]}`, name, i))
+	}
+
+	path := filepath.Join(t.TempDir(), name+".json")
+	if err := os.WriteFile(path, []byte("["+strings.Join(entries, ",")+"]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	corpus, err := genai.LoadCorpus(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return corpus
+}
+
+func TestLogsBuildBatch_GenAICorpus(t *testing.T) {
+	endpoint, err := url.Parse("http://localhost:4317")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracesCorpus := writeTestCorpus(t, "traces", 3)
+	logsCorpus := writeTestCorpus(t, "logs", 2)
+
+	traces := newTestTracesWorker(t, TracesConfig{ResourcesPerBatch: 1, SpansPerResource: 5, GenAICorpus: tracesCorpus})
+	logs := NewLogsWorker(zap.NewNop(), ExportConfig{Endpoint: endpoint, UseGRPC: true},
+		LogsConfig{ResourcesPerBatch: 1, LogsPerResource: 6, GenAICorpus: logsCorpus}).(*logsWorker)
+
+	// Drawing spans doesn't advance the logs corpus
+	spans := traces.buildBatch(newTestResources(1), worker.NopMsgIdGenerator())
+	batch := logs.buildBatch(1, newTestResources(1), worker.NopMsgIdGenerator())
+
+	records := batch[0].ScopeLogs[0].LogRecords
+	if len(records) != 6 {
+		t.Fatalf("Expected 6 log records, got %d", len(records))
+	}
+
+	// Each conversation is a system, user and choice event
+	expected := []struct {
+		event string
+		text  string
+	}{
+		{genai.EventSystemMessage, "logs system 0"},
+		{genai.EventUserMessage, "logs question 0"},
+		{genai.EventChoice, "logs answer 0"},
+		{genai.EventSystemMessage, "logs system 1"},
+		{genai.EventUserMessage, "logs question 1"},
+		{genai.EventChoice, "logs answer 1"},
+	}
+	for i, e := range expected {
+		body, _ := protojson.Marshal(records[i].Body)
+		if records[i].EventName != e.event || !strings.Contains(string(body), e.text) {
+			t.Errorf("Record %d: expected %s with %q, got %s %s", i, e.event, e.text, records[i].EventName, body)
+		}
+		if records[i].TimeUnixNano == 0 || len(records[i].Attributes) == 0 {
+			t.Errorf("Record %d: expected timestamp and attributes to be set", i)
+		}
+	}
+
+	spansJSON, _ := protojson.Marshal(spans[0])
+	if !strings.Contains(string(spansJSON), "traces question") || strings.Contains(string(spansJSON), "logs question") {
+		t.Error("Expected spans to draw from the traces corpus only")
+	}
+}
+
+func TestLogsBuildBatch_Body(t *testing.T) {
+	endpoint, err := url.Parse("http://localhost:4317")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exportCfg := ExportConfig{Endpoint: endpoint, UseGRPC: true}
+	newRecords := func(body LogBody) []*otlpLogs.LogRecord {
+		logs := NewLogsWorker(zap.NewNop(), exportCfg, LogsConfig{ResourcesPerBatch: 1, LogsPerResource: 10, Body: body}).(*logsWorker)
+		return logs.buildBatch(1, newTestResources(1), worker.NopMsgIdGenerator())[0].ScopeLogs[0].LogRecords
+	}
+
+	for _, record := range newRecords(LogBodyString) {
+		if record.Body.GetStringValue() == "" || findAttrValue(record.Attributes, "log.level") != nil {
+			t.Fatalf("Expected a plaintext body without request attributes, got %v", record)
+		}
+	}
+
+	for _, record := range newRecords(LogBodyJSON) {
+		fields := record.Body.GetKvlistValue().GetValues()
+		if v := findAttrValue(fields, "level"); v.GetStringValue() != strings.ToLower(record.SeverityText) {
+			t.Fatalf("Expected the level field to match severity %s, got %v", record.SeverityText, v)
+		}
+		if findAttrValue(fields, "message").GetStringValue() == "" {
+			t.Errorf("Expected a message field, got %v", fields)
+		}
+		http := findAttrValue(fields, "http").GetKvlistValue().GetValues()
+		if status := findAttrValue(http, "status_code").GetIntValue(); status != int64(logStatusCode(record.SeverityNumber)) {
+			t.Errorf("Expected a nested http.status_code matching severity, got %d", status)
+		}
+	}
+
+	for _, record := range newRecords(LogBodyKVList) {
+		if record.Body.GetStringValue() == "" {
+			t.Fatalf("Expected a plaintext body, got %v", record.Body)
+		}
+		if v := findAttrValue(record.Attributes, "log.level"); v.GetStringValue() != strings.ToLower(record.SeverityText) {
+			t.Errorf("Expected the log.level attribute to match severity %s, got %v", record.SeverityText, v)
+		}
+		if findAttrValue(record.Attributes, "http.status_code").GetIntValue() == 0 {
+			t.Errorf("Expected an http.status_code attribute, got %v", record.Attributes)
+		}
+		if findAttrValue(record.Attributes, "index") == nil {
+			t.Errorf("Expected the record attributes to be kept, got %v", record.Attributes)
+		}
+	}
+}
+
+func TestParseLogBody(t *testing.T) {
+	for s, want := range map[string]LogBody{"string": LogBodyString, "json": LogBodyJSON, "kvlist": LogBodyKVList} {
+		if got, err := ParseLogBody(s); err != nil || got != want {
+			t.Errorf("ParseLogBody(%q) = %d, %v, expected %d", s, got, err, want)
+		}
+	}
+	if _, err := ParseLogBody("xml"); err == nil {
+		t.Error("Expected an error for an invalid log body")
+	}
+}
@@ -0,0 +1,72 @@
+package telemetry
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/streamfold/otel-loadgen/internal/otlp/anyvalue"
+	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+)
+
+// MetricAttr is a data point attribute key with a bounded number of distinct values
+type MetricAttr struct {
+	Key         string
+	Cardinality int
+}
+
+// ParseMetricAttrs parses a list of attributes in the format 'key:cardinality,...'
+func ParseMetricAttrs(s string) ([]MetricAttr, error) {
+	attrs := make([]MetricAttr, 0)
+
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		parts := strings.SplitN(item, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid metric attribute format: %q (expected 'key:cardinality')", item)
+		}
+
+		key := strings.TrimSpace(parts[0])
+		if key == "" {
+			return nil, fmt.Errorf("invalid metric attribute format: %q (empty key)", item)
+		}
+
+		cardinality, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid cardinality for %q: %w", key, err)
+		}
+		if cardinality < 1 {
+			return nil, fmt.Errorf("invalid cardinality for %q: must be > 0", key)
+		}
+
+		attrs = append(attrs, MetricAttr{Key: key, Cardinality: cardinality})
+	}
+
+	return attrs, nil
+}
+
+// metricAttrValues returns the configured attributes for the data point with the
+// given series index. Values are derived from the index, so each series keeps the
+// same labels across batches and every key stays within its cardinality.
+func metricAttrValues(attrs []MetricAttr, seriesIdx int) []*otlpCommon.KeyValue {
+	kvs := make([]*otlpCommon.KeyValue, 0, len(attrs))
+
+	// Treat the series index as a mixed-radix number, one digit per attribute,
+	// so the combinations are spread across all keys
+	n := seriesIdx
+	for _, attr := range attrs {
+		value := n % attr.Cardinality
+		n /= attr.Cardinality
+
+		kvs = append(kvs, &otlpCommon.KeyValue{
+			Key:   attr.Key,
+			Value: anyvalue.String(fmt.Sprintf("%s-%d", attr.Key, value)),
+		})
+	}
+
+	return kvs
+}
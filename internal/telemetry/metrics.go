@@ -0,0 +1,475 @@
+package telemetry
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/otlp"
+	"github.com/streamfold/otel-loadgen/internal/otlp/anyvalue"
+	"github.com/streamfold/otel-loadgen/internal/stats"
+	"github.com/streamfold/otel-loadgen/internal/worker"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	otlpMetricsColl "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+	otlpMetrics "go.opentelemetry.io/proto/otlp/metrics/v1"
+	otlpRes "go.opentelemetry.io/proto/otlp/resource/v1"
+	"go.uber.org/zap"
+)
+
+type MetricType int
+
+const (
+	MetricTypeGauge MetricType = iota
+	MetricTypeSum
+	MetricTypeHistogram
+	MetricTypeExpHistogram
+)
+
+func (m MetricType) String() string {
+	switch m {
+	case MetricTypeGauge:
+		return "gauge"
+	case MetricTypeSum:
+		return "sum"
+	case MetricTypeHistogram:
+		return "histogram"
+	case MetricTypeExpHistogram:
+		return "exp-histogram"
+	default:
+		return "unknown"
+	}
+}
+
+func ParseMetricType(s string) (MetricType, error) {
+	switch s {
+	case "gauge":
+		return MetricTypeGauge, nil
+	case "sum":
+		return MetricTypeSum, nil
+	case "histogram":
+		return MetricTypeHistogram, nil
+	case "exp-histogram":
+		return MetricTypeExpHistogram, nil
+	default:
+		return 0, fmt.Errorf("invalid metric type: %q (expected gauge, sum, histogram or exp-histogram)", s)
+	}
+}
+
+// MetricsConfig holds the settings for generating metric data points
+type MetricsConfig struct {
+	ResourcesPerBatch int
+	// ResourceCounts varies the number of resources of each batch around
+	// ResourcesPerBatch, the default gives every batch ResourcesPerBatch
+	ResourceCounts     ResourceCountDistribution
+	MetricsPerResource int
+	MetricType         MetricType
+	// HistogramBuckets is the number of buckets for histogram types, for
+	// exponential histograms it's the maximum number of positive buckets
+	HistogramBuckets int
+	// BaseTime pins data point timestamps to a fixed time instead of the wall clock
+	BaseTime time.Time
+	// BuildQueueSize is the number of built batches that can wait for export,
+	// zero builds and exports each batch in turn
+	BuildQueueSize int
+	// Attrs are additional data point attributes with bounded cardinality
+	Attrs []MetricAttr
+	// Correlator, if set, is used to attach exemplars referencing spans emitted
+	// by the traces worker
+	Correlator *Correlator
+	// ExemplarsPerPoint is the number of exemplars attached to each data point
+	// when correlated, each referencing a different span. Defaults to 1.
+	ExemplarsPerPoint int
+	// StalenessRate is the fraction of data points emitted as staleness markers,
+	// flagged with NoRecordedValue and carrying no value
+	StalenessRate float64
+	// ResourceAttrs are added to every generated resource, e.g. the detected
+	// host and process attributes
+	ResourceAttrs []*otlpCommon.KeyValue
+	// Scope is the instrumentation scope of the generated metrics
+	Scope otlp.ScopeConfig
+	// Resource sets the service name and user attributes of the resources
+	Resource otlp.ResourceConfig
+	// AlertPattern drives gauge values through a repeating shape instead of
+	// random values, each cycle lasts AlertPeriod
+	AlertPattern AlertPattern
+	AlertPeriod  time.Duration
+}
+
+type metricsWorker struct {
+	log                *zap.Logger
+	resourcesPerBatch  int
+	resourceCounts     ResourceCountDistribution
+	metricsPerResource int
+	metricType         MetricType
+	histogramBuckets   int
+	exp                *exporter
+	scope              *otlpCommon.InstrumentationScope
+	wg                 sync.WaitGroup
+	nextWorkerId       atomic.Uint64
+	stopChan           chan bool
+	statMetricsSent    stats.Stat
+	statRejected       stats.Stat
+	statQueueDepth     stats.Gauge
+	now                clock
+	attrs              []MetricAttr
+	buildQueueSize     int
+	correlator         *Correlator
+	exemplarsPerPoint  int
+	stalenessRate      float64
+	resourceAttrs      []*otlpCommon.KeyValue
+	resource           otlp.ResourceConfig
+	alertPattern       AlertPattern
+	alertPeriod        time.Duration
+}
+
+// metricSeries holds the per-pusher state of the generated series, cumulative
+// sums and histograms must keep increasing from a fixed start time across batches
+type metricSeries struct {
+	startTime  uint64
+	counters   [][]int64
+	histograms [][]*histogramState
+}
+
+func NewMetricsWorker(log *zap.Logger, exportCfg ExportConfig, cfg MetricsConfig) worker.Worker {
+	return &metricsWorker{
+		log:                log,
+		exp:                newExporter(log, exportCfg, metricsHTTPPath, metricsGRPCMethod),
+		resourcesPerBatch:  cfg.ResourcesPerBatch,
+		resourceCounts:     cfg.ResourceCounts,
+		metricsPerResource: cfg.MetricsPerResource,
+		metricType:         cfg.MetricType,
+		histogramBuckets:   cfg.HistogramBuckets,
+		scope:              otlp.NewScope(cfg.Scope),
+		now:                newClock(cfg.BaseTime),
+		attrs:              cfg.Attrs,
+		buildQueueSize:     cfg.BuildQueueSize,
+		correlator:         cfg.Correlator,
+		exemplarsPerPoint:  max(cfg.ExemplarsPerPoint, 1),
+		stalenessRate:      cfg.StalenessRate,
+		resourceAttrs:      cfg.ResourceAttrs,
+		resource:           cfg.Resource,
+		alertPattern:       cfg.AlertPattern,
+		alertPeriod:        cfg.AlertPeriod,
+	}
+}
+
+func (o *metricsWorker) Init(statsBuilder stats.Builder, client *http.Client) error {
+	o.wg = sync.WaitGroup{}
+	o.stopChan = make(chan bool)
+
+	o.statMetricsSent = statsBuilder.NewStat(stats.StatMetricsSent)
+	o.statRejected = statsBuilder.NewStat(stats.StatRejected)
+	if o.buildQueueSize > 0 {
+		o.statQueueDepth = statsBuilder.NewGauge(stats.StatQueueDepth)
+	}
+
+	return o.exp.init(statsBuilder, client)
+}
+
+func (o *metricsWorker) Start(pushInterval time.Duration, msgIdGen worker.MsgIdGenerator) {
+	pusherIdx := o.nextWorkerId.Add(1)
+	ticker := time.NewTicker(pushInterval)
+
+	o.wg.Add(1)
+	go func() {
+		defer func() {
+			ticker.Stop()
+			o.wg.Done()
+		}()
+
+		o.pushWait(ticker.C, pusherIdx, msgIdGen)
+	}()
+}
+
+func (o *metricsWorker) StopAll() {
+	close(o.stopChan)
+	o.exp.stop()
+	o.wg.Wait()
+	o.exp.close()
+}
+
+func (o *metricsWorker) pushWait(tick <-chan time.Time, idx uint64, msgIdGen worker.MsgIdGenerator) {
+	resources := make([]*otlpRes.Resource, 0)
+	for i := 0; i < o.resourceCounts.max(o.resourcesPerBatch); i++ {
+		res := otlp.NewResource(o.resource, idx, i)
+		res.Attributes = append(res.Attributes, o.resourceAttrs...)
+		res.Attributes = msgIdGen.AddResourceAttrs(res.Attributes)
+		resources = append(resources, res)
+	}
+
+	series := o.newSeries(len(resources))
+
+	rng := newResourceCountRand()
+	runBuildQueue(o.stopChan, tick, o.buildQueueSize, o.statQueueDepth,
+		func() []*otlpMetrics.ResourceMetrics {
+			return o.buildBatch(idx, o.resourceCounts.resources(rng, resources, o.resourcesPerBatch), series, msgIdGen)
+		},
+		func(batch []*otlpMetrics.ResourceMetrics) {
+			o.pushIt(idx, batch)
+		})
+}
+
+// newSeries starts the series of numResources resources at the current time
+func (o *metricsWorker) newSeries(numResources int) *metricSeries {
+	series := &metricSeries{
+		startTime:  uint64(o.now().UnixNano()),
+		counters:   make([][]int64, numResources),
+		histograms: make([][]*histogramState, numResources),
+	}
+	for i := range series.counters {
+		series.counters[i] = make([]int64, o.metricsPerResource)
+		series.histograms[i] = make([]*histogramState, o.metricsPerResource)
+		for j := range series.histograms[i] {
+			switch o.metricType {
+			case MetricTypeHistogram:
+				series.histograms[i][j] = newExplicitHistogramState(o.histogramBuckets)
+			case MetricTypeExpHistogram:
+				series.histograms[i][j] = newExpHistogramState(o.histogramBuckets)
+			}
+		}
+	}
+	return series
+}
+
+func (o *metricsWorker) pushIt(idx uint64, batch []*otlpMetrics.ResourceMetrics) {
+	numMetrics := len(batch) * o.metricsPerResource
+	if numMetrics == 0 {
+		return
+	}
+
+	msg := &otlpMetricsColl.ExportMetricsServiceRequest{ResourceMetrics: batch}
+	resp := &otlpMetricsColl.ExportMetricsServiceResponse{}
+	if !o.exp.export(idx, msg, resp) {
+		return
+	}
+
+	if ps := resp.GetPartialSuccess(); ps != nil && ps.GetRejectedDataPoints() != 0 {
+		o.log.Warn("export partially rejected",
+			zap.Int64("rejected", ps.GetRejectedDataPoints()), zap.String("message", ps.GetErrorMessage()))
+		o.statRejected.Incr(uint64(ps.GetRejectedDataPoints()))
+	}
+
+	o.statMetricsSent.Incr(uint64(numMetrics))
+}
+
+func (o *metricsWorker) buildBatch(idx uint64, resources []*otlpRes.Resource, series *metricSeries, msgIdGen worker.MsgIdGenerator) []*otlpMetrics.ResourceMetrics {
+	resMetrics := make([]*otlpMetrics.ResourceMetrics, 0, len(resources))
+
+	defs := commonMetrics
+	if o.metricType == MetricTypeHistogram || o.metricType == MetricTypeExpHistogram {
+		defs = commonHistograms
+	}
+	numMetrics := min(o.metricsPerResource, len(defs))
+
+	for i, res := range resources {
+		metrics := make([]*otlpMetrics.Metric, numMetrics)
+		for m := 0; m < numMetrics; m++ {
+			metrics[m] = o.newMetric(defs[m])
+		}
+
+		nowNano := o.now().UnixNano()
+
+		// Data points are spread across the metrics, each data point is a distinct series
+		for j := 0; j < o.metricsPerResource; j++ {
+			ts := uint64(nowNano + int64(j)*int64(10_000_000))
+
+			attrs := []*otlpCommon.KeyValue{
+				{
+					Key:   "index",
+					Value: anyvalue.Int(int64(j)),
+				},
+			}
+			attrs = append(attrs, metricAttrValues(o.attrs, j)...)
+			attrs = msgIdGen.AddElementAttrs(attrs)
+
+			metric := metrics[j%numMetrics]
+
+			// Staleness markers keep their message id so delivery tracking still
+			// accounts for them, the series resumes with the next batch
+			if o.stalenessRate > 0 && rand.Float64() < o.stalenessRate {
+				o.appendStaleDataPoint(metric, attrs, series.startTime, ts)
+				continue
+			}
+
+			switch o.metricType {
+			case MetricTypeGauge:
+				gauge := metric.GetGauge()
+				value := rand.Float64() * 100
+				if o.alertPattern != AlertPatternNone {
+					value = o.alertPattern.value(time.Duration(ts-series.startTime), o.alertPeriod)
+				}
+				gauge.DataPoints = append(gauge.DataPoints, &otlpMetrics.NumberDataPoint{
+					Attributes:   attrs,
+					TimeUnixNano: ts,
+					Value:        &otlpMetrics.NumberDataPoint_AsDouble{AsDouble: value},
+					Exemplars:    o.exemplars(idx, i, ts, ts, value),
+				})
+			case MetricTypeSum:
+				series.counters[i][j] += 1 + rand.Int63n(10)
+				sum := metric.GetSum()
+				sum.DataPoints = append(sum.DataPoints, &otlpMetrics.NumberDataPoint{
+					Attributes:        attrs,
+					StartTimeUnixNano: series.startTime,
+					TimeUnixNano:      ts,
+					Value:             &otlpMetrics.NumberDataPoint_AsInt{AsInt: series.counters[i][j]},
+					Exemplars:         o.exemplars(idx, i, series.startTime, ts, float64(series.counters[i][j])),
+				})
+			case MetricTypeHistogram:
+				state := series.histograms[i][j]
+				state.observe(histogramSamplesPerBatch())
+				dp := state.histogramDataPoint(attrs, series.startTime, ts)
+				dp.Exemplars = o.exemplars(idx, i, series.startTime, ts, state.max)
+				hist := metric.GetHistogram()
+				hist.DataPoints = append(hist.DataPoints, dp)
+			case MetricTypeExpHistogram:
+				state := series.histograms[i][j]
+				state.observe(histogramSamplesPerBatch())
+				dp := state.expHistogramDataPoint(attrs, series.startTime, ts)
+				dp.Exemplars = o.exemplars(idx, i, series.startTime, ts, state.max)
+				hist := metric.GetExponentialHistogram()
+				hist.DataPoints = append(hist.DataPoints, dp)
+			}
+		}
+
+		resMetrics = append(resMetrics, &otlpMetrics.ResourceMetrics{
+			Resource: res,
+			ScopeMetrics: []*otlpMetrics.ScopeMetrics{
+				{
+					Scope:     o.scope,
+					Metrics:   metrics,
+					SchemaUrl: semconv.SchemaURL,
+				},
+			},
+			SchemaUrl: semconv.SchemaURL,
+		})
+	}
+
+	return resMetrics
+}
+
+// appendStaleDataPoint adds a data point flagged with NoRecordedValue, marking
+// the series as stale at ts. Cumulative state isn't advanced.
+func (o *metricsWorker) appendStaleDataPoint(metric *otlpMetrics.Metric, attrs []*otlpCommon.KeyValue, startTime, ts uint64) {
+	flags := uint32(otlpMetrics.DataPointFlags_DATA_POINT_FLAGS_NO_RECORDED_VALUE_MASK)
+
+	switch o.metricType {
+	case MetricTypeGauge:
+		gauge := metric.GetGauge()
+		gauge.DataPoints = append(gauge.DataPoints, &otlpMetrics.NumberDataPoint{
+			Attributes:   attrs,
+			TimeUnixNano: ts,
+			Flags:        flags,
+		})
+	case MetricTypeSum:
+		sum := metric.GetSum()
+		sum.DataPoints = append(sum.DataPoints, &otlpMetrics.NumberDataPoint{
+			Attributes:        attrs,
+			StartTimeUnixNano: startTime,
+			TimeUnixNano:      ts,
+			Flags:             flags,
+		})
+	case MetricTypeHistogram:
+		hist := metric.GetHistogram()
+		hist.DataPoints = append(hist.DataPoints, &otlpMetrics.HistogramDataPoint{
+			Attributes:        attrs,
+			StartTimeUnixNano: startTime,
+			TimeUnixNano:      ts,
+			Flags:             flags,
+		})
+	case MetricTypeExpHistogram:
+		hist := metric.GetExponentialHistogram()
+		hist.DataPoints = append(hist.DataPoints, &otlpMetrics.ExponentialHistogramDataPoint{
+			Attributes:        attrs,
+			StartTimeUnixNano: startTime,
+			TimeUnixNano:      ts,
+			Flags:             flags,
+		})
+	}
+}
+
+// exemplars returns exemplars referencing spans of the same resource, or nil
+// when signals aren't correlated or no spans have been emitted yet. Exemplars
+// are timestamped at the start of their span, clamped to the [start, ts]
+// window the data point covers.
+func (o *metricsWorker) exemplars(idx uint64, resource int, start, ts uint64, value float64) []*otlpMetrics.Exemplar {
+	if o.correlator == nil {
+		return nil
+	}
+	refs := o.correlator.pickN(idx, resource, o.exemplarsPerPoint)
+	if len(refs) == 0 {
+		return nil
+	}
+
+	exemplars := make([]*otlpMetrics.Exemplar, 0, len(refs))
+	for _, ref := range refs {
+		exemplars = append(exemplars, &otlpMetrics.Exemplar{
+			TimeUnixNano: min(max(ref.StartTimeUnixNano, start), ts),
+			Value:        &otlpMetrics.Exemplar_AsDouble{AsDouble: value},
+			TraceId:      ref.TraceId,
+			SpanId:       ref.SpanId,
+		})
+	}
+	return exemplars
+}
+
+func (o *metricsWorker) newMetric(def metricDef) *otlpMetrics.Metric {
+	metric := &otlpMetrics.Metric{
+		Name:        def.name,
+		Description: def.description,
+		Unit:        def.unit,
+	}
+
+	switch o.metricType {
+	case MetricTypeGauge:
+		metric.Data = &otlpMetrics.Metric_Gauge{Gauge: &otlpMetrics.Gauge{}}
+	case MetricTypeSum:
+		metric.Data = &otlpMetrics.Metric_Sum{Sum: &otlpMetrics.Sum{
+			AggregationTemporality: otlpMetrics.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+			IsMonotonic:            true,
+		}}
+	case MetricTypeHistogram:
+		metric.Data = &otlpMetrics.Metric_Histogram{Histogram: &otlpMetrics.Histogram{
+			AggregationTemporality: otlpMetrics.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+		}}
+	case MetricTypeExpHistogram:
+		metric.Data = &otlpMetrics.Metric_ExponentialHistogram{ExponentialHistogram: &otlpMetrics.ExponentialHistogram{
+			AggregationTemporality: otlpMetrics.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+		}}
+	}
+
+	return metric
+}
+
+type metricDef struct {
+	name        string
+	description string
+	unit        string
+}
+
+// Common metric names for realistic telemetry data
+var commonMetrics = []metricDef{
+	{"http.server.request.count", "Number of HTTP requests", "{request}"},
+	{"http.server.active_requests", "Number of active HTTP requests", "{request}"},
+	{"db.client.operation.count", "Number of database operations", "{operation}"},
+	{"messaging.client.sent.messages", "Number of messages sent", "{message}"},
+	{"process.cpu.time", "CPU time used by the process", "s"},
+	{"process.memory.usage", "Memory used by the process", "By"},
+	{"system.network.io", "Bytes transmitted over the network", "By"},
+	{"system.disk.io", "Disk bytes transferred", "By"},
+	{"rpc.server.request.count", "Number of RPC requests", "{request}"},
+	{"cache.operation.count", "Number of cache operations", "{operation}"},
+}
+
+// Common histogram metric names, values are generated in milliseconds
+var commonHistograms = []metricDef{
+	{"http.server.request.duration", "Duration of HTTP server requests", "ms"},
+	{"http.client.request.duration", "Duration of HTTP client requests", "ms"},
+	{"db.client.operation.duration", "Duration of database operations", "ms"},
+	{"rpc.server.duration", "Duration of inbound RPCs", "ms"},
+	{"messaging.process.duration", "Duration of message processing", "ms"},
+}
@@ -0,0 +1,258 @@
+package telemetry
+
+import (
+	"math"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/worker"
+	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+	otlpMetrics "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"go.uber.org/zap"
+)
+
+func newTestMetricsWorker(t *testing.T, cfg MetricsConfig) *metricsWorker {
+	t.Helper()
+
+	endpoint, err := url.Parse("http://localhost:4317")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewMetricsWorker(zap.NewNop(), ExportConfig{Endpoint: endpoint, UseGRPC: true}, cfg).(*metricsWorker)
+}
+
+func newTestMetricSeries(numResources, numMetrics int) *metricSeries {
+	series := &metricSeries{
+		startTime:  uint64(time.Now().UnixNano()),
+		counters:   make([][]int64, numResources),
+		histograms: make([][]*histogramState, numResources),
+	}
+	for i := range series.counters {
+		series.counters[i] = make([]int64, numMetrics)
+		series.histograms[i] = make([]*histogramState, numMetrics)
+	}
+	return series
+}
+
+func gaugeDataPoints(batch []*otlpMetrics.ResourceMetrics) []*otlpMetrics.NumberDataPoint {
+	var dps []*otlpMetrics.NumberDataPoint
+	for _, rm := range batch {
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				dps = append(dps, m.GetGauge().GetDataPoints()...)
+			}
+		}
+	}
+	return dps
+}
+
+func TestMetricsBuildBatch_Attrs(t *testing.T) {
+	attrs, err := ParseMetricAttrs("host:10, region:3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := newTestMetricsWorker(t, MetricsConfig{
+		ResourcesPerBatch:  1,
+		MetricsPerResource: 100,
+		MetricType:         MetricTypeGauge,
+		Attrs:              attrs,
+	})
+
+	batch := w.buildBatch(1, newTestResources(1), newTestMetricSeries(1, 100), worker.NopMsgIdGenerator())
+	dps := gaugeDataPoints(batch)
+	if len(dps) != 100 {
+		t.Fatalf("Expected 100 data points, got %d", len(dps))
+	}
+
+	distinct := map[string]map[string]bool{"host": {}, "region": {}}
+	for _, dp := range dps {
+		for key := range distinct {
+			value := findAttrValue(dp.Attributes, key)
+			if value == nil {
+				t.Fatalf("Expected data point to carry attribute %q", key)
+			}
+			distinct[key][value.GetStringValue()] = true
+		}
+	}
+
+	if len(distinct["host"]) != 10 {
+		t.Errorf("Expected 10 distinct host values, got %d", len(distinct["host"]))
+	}
+	if len(distinct["region"]) != 3 {
+		t.Errorf("Expected 3 distinct region values, got %d", len(distinct["region"]))
+	}
+}
+
+func TestMetricAttrValues_Stable(t *testing.T) {
+	attrs := []MetricAttr{{Key: "host", Cardinality: 4}}
+
+	first := metricAttrValues(attrs, 7)
+	second := metricAttrValues(attrs, 7)
+	if first[0].GetValue().GetStringValue() != second[0].GetValue().GetStringValue() {
+		t.Error("Expected the same series index to produce the same attribute values")
+	}
+}
+
+func TestParseMetricAttrs(t *testing.T) {
+	attrs, err := ParseMetricAttrs("")
+	if err != nil || len(attrs) != 0 {
+		t.Errorf("Expected no attributes for an empty string, got %v (%v)", attrs, err)
+	}
+
+	for _, s := range []string{"host", "host:abc", "host:0", ":5"} {
+		if _, err := ParseMetricAttrs(s); err == nil {
+			t.Errorf("Expected error for %q", s)
+		}
+	}
+}
+
+func findAttrValue(attrs []*otlpCommon.KeyValue, key string) *otlpCommon.AnyValue {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return attr.Value
+		}
+	}
+	return nil
+}
+
+func TestMetricsBuildBatch_StalenessRate(t *testing.T) {
+	noRecordedValue := uint32(otlpMetrics.DataPointFlags_DATA_POINT_FLAGS_NO_RECORDED_VALUE_MASK)
+
+	for _, metricType := range []MetricType{MetricTypeGauge, MetricTypeSum, MetricTypeHistogram, MetricTypeExpHistogram} {
+		t.Run(metricType.String(), func(t *testing.T) {
+			o := newTestMetricsWorker(t, MetricsConfig{
+				ResourcesPerBatch:  10,
+				MetricsPerResource: 200,
+				MetricType:         metricType,
+				HistogramBuckets:   10,
+				StalenessRate:      0.25,
+			})
+			series := newTestMetricSeries(10, 200)
+			for i := range series.histograms {
+				for j := range series.histograms[i] {
+					if metricType == MetricTypeHistogram {
+						series.histograms[i][j] = newExplicitHistogramState(10)
+					} else if metricType == MetricTypeExpHistogram {
+						series.histograms[i][j] = newExpHistogramState(10)
+					}
+				}
+			}
+
+			var total, stale int
+			batch := o.buildBatch(1, newTestResources(10), series, worker.NopMsgIdGenerator())
+			for _, rm := range batch {
+				for _, sm := range rm.ScopeMetrics {
+					for _, m := range sm.Metrics {
+						var dps []interface{ GetFlags() uint32 }
+						for _, dp := range m.GetGauge().GetDataPoints() {
+							dps = append(dps, dp)
+						}
+						for _, dp := range m.GetSum().GetDataPoints() {
+							dps = append(dps, dp)
+						}
+						for _, dp := range m.GetHistogram().GetDataPoints() {
+							dps = append(dps, dp)
+						}
+						for _, dp := range m.GetExponentialHistogram().GetDataPoints() {
+							dps = append(dps, dp)
+						}
+
+						for _, dp := range dps {
+							total++
+							if dp.GetFlags()&noRecordedValue != 0 {
+								stale++
+							}
+						}
+					}
+				}
+			}
+
+			if total != 2000 {
+				t.Fatalf("Expected 2000 data points, got %d", total)
+			}
+			// 25% of 2000 is 500, allow for sampling noise
+			if stale < 400 || stale > 600 {
+				t.Errorf("Expected about 500 stale data points, got %d", stale)
+			}
+		})
+	}
+}
+
+func TestMetricsBuildBatch_StaleDataPointHasNoValue(t *testing.T) {
+	o := newTestMetricsWorker(t, MetricsConfig{
+		ResourcesPerBatch:  1,
+		MetricsPerResource: 5,
+		MetricType:         MetricTypeGauge,
+		StalenessRate:      1,
+	})
+
+	batch := o.buildBatch(1, newTestResources(1), newTestMetricSeries(1, 5), worker.NopMsgIdGenerator())
+	for _, dp := range gaugeDataPoints(batch) {
+		if dp.Flags != uint32(otlpMetrics.DataPointFlags_DATA_POINT_FLAGS_NO_RECORDED_VALUE_MASK) {
+			t.Errorf("Expected NoRecordedValue flag, got %d", dp.Flags)
+		}
+		if dp.Value != nil {
+			t.Errorf("Expected stale data point to have no value, got %v", dp.Value)
+		}
+	}
+}
+
+func TestAlertPattern_Value(t *testing.T) {
+	period := 100 * time.Second
+	tests := []struct {
+		pattern AlertPattern
+		at      time.Duration
+		want    float64
+	}{
+		{AlertPatternSpike, 10 * time.Second, alertBaseline},
+		{AlertPatternSpike, 45 * time.Second, alertPeak},
+		{AlertPatternSpike, 70 * time.Second, alertBaseline},
+		{AlertPatternSpike, 145 * time.Second, alertPeak},
+		{AlertPatternRamp, 0, alertBaseline},
+		{AlertPatternRamp, 25 * time.Second, (alertBaseline + alertPeak) / 2},
+		{AlertPatternRamp, 60 * time.Second, alertPeak},
+		{AlertPatternRamp, 87500 * time.Millisecond, (alertBaseline + alertPeak) / 2},
+		{AlertPatternFlap, 5 * time.Second, alertBaseline},
+		{AlertPatternFlap, 15 * time.Second, alertPeak},
+		{AlertPatternFlap, 25 * time.Second, alertBaseline},
+		{AlertPatternFlap, 95 * time.Second, alertPeak},
+	}
+	for _, tt := range tests {
+		if got := tt.pattern.value(tt.at, period); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%v at %v: expected %v, got %v", tt.pattern, tt.at, tt.want, got)
+		}
+	}
+
+	if _, err := ParseAlertPattern("sawtooth"); err == nil {
+		t.Error("Expected error for an unknown alert pattern")
+	}
+}
+
+func TestMetricsBuildBatch_AlertPattern(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := newTestMetricsWorker(t, MetricsConfig{
+		ResourcesPerBatch:  1,
+		MetricsPerResource: 1,
+		MetricType:         MetricTypeGauge,
+		AlertPattern:       AlertPatternSpike,
+		AlertPeriod:        time.Minute,
+		BaseTime:           base,
+	})
+
+	// Sample the series at times into the run, before, during and after the spike
+	for at, want := range map[time.Duration]float64{
+		10 * time.Second: alertBaseline,
+		30 * time.Second: alertPeak,
+		50 * time.Second: alertBaseline,
+	} {
+		series := newTestMetricSeries(1, 1)
+		series.startTime = uint64(base.Add(-at).UnixNano())
+		dps := gaugeDataPoints(w.buildBatch(1, newTestResources(1), series, worker.NopMsgIdGenerator()))
+		if len(dps) != 1 || dps[0].GetAsDouble() != want {
+			t.Errorf("Expected %v at %v into the run, got %v", want, at, dps)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package telemetry
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// newTargetLimiter returns a limiter allowing targetRate elements per second,
+// with a burst of one batch so a whole batch can always be admitted at once
+func newTargetLimiter(targetRate float64, batchSize int) *rate.Limiter {
+	return rate.NewLimiter(rate.Limit(targetRate), max(batchSize, 1))
+}
+
+// rateTicks returns a channel that ticks each time the limiter admits a batch
+// of n elements. Pushers sharing the limiter split the target rate between
+// them, so the aggregate rate converges to the target however many there are.
+// The channel stops ticking once stopChan is closed.
+func rateTicks(stopChan <-chan bool, limiter *rate.Limiter, n int) <-chan time.Time {
+	ticks := make(chan time.Time)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stopChan
+		cancel()
+	}()
+
+	go func() {
+		for {
+			if err := limiter.WaitN(ctx, n); err != nil {
+				return
+			}
+
+			select {
+			case <-stopChan:
+				return
+			case ticks <- time.Now():
+			}
+		}
+	}()
+
+	return ticks
+}
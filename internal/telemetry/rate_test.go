@@ -0,0 +1,69 @@
+package telemetry
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateTicks_SharedLimiterConvergesToTarget(t *testing.T) {
+	const (
+		targetRate = 2000.0
+		batchSize  = 100
+		pushers    = 4
+		runFor     = 500 * time.Millisecond
+	)
+
+	stopChan := make(chan bool)
+	limiter := newTargetLimiter(targetRate, batchSize)
+
+	var sent atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < pushers; i++ {
+		ticks := rateTicks(stopChan, limiter, batchSize)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stopChan:
+					return
+				case <-ticks:
+					sent.Add(batchSize)
+				}
+			}
+		}()
+	}
+
+	time.Sleep(runFor)
+	close(stopChan)
+	wg.Wait()
+
+	// One burst of a batch plus the target rate over the run, regardless of
+	// the number of pushers
+	expected := float64(batchSize) + targetRate*runFor.Seconds()
+	if got := float64(sent.Load()); got < expected*0.7 || got > expected*1.1 {
+		t.Errorf("Expected about %.0f elements, got %.0f", expected, got)
+	}
+}
+
+func TestRateTicks_StopsOnClose(t *testing.T) {
+	stopChan := make(chan bool)
+	// Slow enough that the second tick never comes before stop
+	ticks := rateTicks(stopChan, newTargetLimiter(1, 10), 10)
+
+	select {
+	case <-ticks:
+	case <-time.After(time.Second):
+		t.Fatal("Expected first batch to be admitted by the burst")
+	}
+
+	close(stopChan)
+	select {
+	case <-ticks:
+		t.Fatal("Expected no tick after stop")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,109 @@
+package telemetry
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/streamfold/otel-loadgen/internal/otlp"
+	"github.com/streamfold/otel-loadgen/internal/stats"
+	"github.com/streamfold/otel-loadgen/internal/worker"
+
+	otlpLogsColl "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	otlpMetricsColl "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	otlpTraceColl "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+	otlpRes "go.opentelemetry.io/proto/otlp/resource/v1"
+	"go.uber.org/zap"
+)
+
+// ReplayTraces exports count spans built like those of a traces worker, each
+// tagged by msgIdGen, e.g. to re-send messages the sink never acked. All spans
+// share a single resource and are sent in batches of the configured resources
+// per batch times spans per resource. Returns the number of spans exported.
+func ReplayTraces(log *zap.Logger, exportCfg ExportConfig, cfg TracesConfig, client *http.Client, msgIdGen worker.MsgIdGenerator, count int) (int, error) {
+	w := NewTracesWorker(log, exportCfg, cfg).(*tracesWorker)
+	if err := w.Init(stats.NewStatTracker().NewDomain("Replay"), client); err != nil {
+		return 0, err
+	}
+	defer w.exp.close()
+
+	resources := []*otlpRes.Resource{replayResource(w.resource, w.resourceAttrs, msgIdGen)}
+
+	batchSize := max(w.resourcesPerBatch*w.spansPerResource, 1)
+	sent := 0
+	for sent < count {
+		n := min(batchSize, count-sent)
+		w.spansPerResource = n
+
+		msg := &otlpTraceColl.ExportTraceServiceRequest{ResourceSpans: w.buildBatch(resources, msgIdGen)}
+		if !w.exp.export(0, msg, &otlpTraceColl.ExportTraceServiceResponse{}) {
+			return sent, fmt.Errorf("failed to export replayed spans")
+		}
+		sent += n
+	}
+
+	return sent, nil
+}
+
+// ReplayMetrics is like ReplayTraces for metric data points
+func ReplayMetrics(log *zap.Logger, exportCfg ExportConfig, cfg MetricsConfig, client *http.Client, msgIdGen worker.MsgIdGenerator, count int) (int, error) {
+	w := NewMetricsWorker(log, exportCfg, cfg).(*metricsWorker)
+	if err := w.Init(stats.NewStatTracker().NewDomain("Replay"), client); err != nil {
+		return 0, err
+	}
+	defer w.exp.close()
+
+	resources := []*otlpRes.Resource{replayResource(w.resource, w.resourceAttrs, msgIdGen)}
+
+	batchSize := max(w.resourcesPerBatch*w.metricsPerResource, 1)
+	w.metricsPerResource = batchSize
+	series := w.newSeries(len(resources))
+
+	sent := 0
+	for sent < count {
+		n := min(batchSize, count-sent)
+		w.metricsPerResource = n
+
+		msg := &otlpMetricsColl.ExportMetricsServiceRequest{ResourceMetrics: w.buildBatch(0, resources, series, msgIdGen)}
+		if !w.exp.export(0, msg, &otlpMetricsColl.ExportMetricsServiceResponse{}) {
+			return sent, fmt.Errorf("failed to export replayed data points")
+		}
+		sent += n
+	}
+
+	return sent, nil
+}
+
+// ReplayLogs is like ReplayTraces for log records
+func ReplayLogs(log *zap.Logger, exportCfg ExportConfig, cfg LogsConfig, client *http.Client, msgIdGen worker.MsgIdGenerator, count int) (int, error) {
+	w := NewLogsWorker(log, exportCfg, cfg).(*logsWorker)
+	if err := w.Init(stats.NewStatTracker().NewDomain("Replay"), client); err != nil {
+		return 0, err
+	}
+	defer w.exp.close()
+
+	resources := []*otlpRes.Resource{replayResource(w.resource, w.resourceAttrs, msgIdGen)}
+
+	batchSize := max(w.resourcesPerBatch*w.logsPerResource, 1)
+	sent := 0
+	for sent < count {
+		n := min(batchSize, count-sent)
+		w.logsPerResource = n
+
+		msg := &otlpLogsColl.ExportLogsServiceRequest{ResourceLogs: w.buildBatch(0, resources, msgIdGen)}
+		if !w.exp.export(0, msg, &otlpLogsColl.ExportLogsServiceResponse{}) {
+			return sent, fmt.Errorf("failed to export replayed log records")
+		}
+		sent += n
+	}
+
+	return sent, nil
+}
+
+// replayResource is the single resource replayed elements share
+func replayResource(cfg otlp.ResourceConfig, attrs []*otlpCommon.KeyValue, msgIdGen worker.MsgIdGenerator) *otlpRes.Resource {
+	res := otlp.NewResource(cfg, 0, 0)
+	res.Attributes = append(res.Attributes, attrs...)
+	res.Attributes = msgIdGen.AddResourceAttrs(res.Attributes)
+	return res
+}
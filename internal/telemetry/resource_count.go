@@ -0,0 +1,100 @@
+package telemetry
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	otlpRes "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+// ResourceCountDistribution determines how many resources each batch carries,
+// modeling exporters that batch opportunistically rather than in fixed sizes
+type ResourceCountDistribution int
+
+const (
+	// ResourceCountFixed gives every batch the configured number of resources
+	ResourceCountFixed ResourceCountDistribution = iota
+	// ResourceCountUniform draws the count uniformly from 1 to twice the
+	// configured number minus one, so the mean is the configured number
+	ResourceCountUniform
+	// ResourceCountSkewed draws the count from a geometric distribution with
+	// the configured number as mean, so most batches are small and a few carry
+	// many resources. Counts are capped at skewedResourceCountCap times the
+	// configured number.
+	ResourceCountSkewed
+)
+
+// skewedResourceCountCap bounds the largest batch of ResourceCountSkewed
+const skewedResourceCountCap = 4
+
+func (d ResourceCountDistribution) String() string {
+	switch d {
+	case ResourceCountFixed:
+		return "fixed"
+	case ResourceCountUniform:
+		return "uniform"
+	case ResourceCountSkewed:
+		return "skewed"
+	default:
+		return "unknown"
+	}
+}
+
+func ParseResourceCountDistribution(s string) (ResourceCountDistribution, error) {
+	switch s {
+	case "fixed":
+		return ResourceCountFixed, nil
+	case "uniform":
+		return ResourceCountUniform, nil
+	case "skewed":
+		return ResourceCountSkewed, nil
+	default:
+		return 0, fmt.Errorf("invalid resource count distribution: %q (expected fixed, uniform or skewed)", s)
+	}
+}
+
+// max returns the largest number of resources a batch can carry when n is the
+// configured number
+func (d ResourceCountDistribution) max(n int) int {
+	switch d {
+	case ResourceCountUniform:
+		return max(2*n-1, 1)
+	case ResourceCountSkewed:
+		return skewedResourceCountCap * n
+	default:
+		return n
+	}
+}
+
+// pick returns the number of resources of the next batch, from 1 to max(n),
+// drawn from rng
+func (d ResourceCountDistribution) pick(rng *rand.Rand, n int) int {
+	if n <= 1 {
+		return n
+	}
+
+	switch d {
+	case ResourceCountUniform:
+		return 1 + rng.Intn(2*n-1)
+	case ResourceCountSkewed:
+		// Inverse transform sampling of a geometric distribution on 1, 2, ...
+		// with success probability 1/n
+		k := 1 + int(math.Log(1-rng.Float64())/math.Log(1-1/float64(n)))
+		return min(k, d.max(n))
+	default:
+		return n
+	}
+}
+
+// resources returns the resources of the next batch from the pool of
+// d.max(n) resources of a pusher
+func (d ResourceCountDistribution) resources(rng *rand.Rand, pool []*otlpRes.Resource, n int) []*otlpRes.Resource {
+	return pool[:min(d.pick(rng, n), len(pool))]
+}
+
+// newResourceCountRand returns the source a pusher draws its batch sizes
+// from. Each pusher owns one, a *rand.Rand isn't safe for concurrent use.
+func newResourceCountRand() *rand.Rand {
+	return rand.New(rand.NewSource(rand.Int63()))
+}
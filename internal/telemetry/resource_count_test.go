@@ -0,0 +1,137 @@
+package telemetry
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/streamfold/otel-loadgen/internal/stats"
+	"github.com/streamfold/otel-loadgen/internal/worker"
+)
+
+// resourceCountSamples returns the resource counts of 10k batches and their
+// mean, drawn from a fixed seed so the bounds checked against them hold on
+// every run
+func resourceCountSamples(d ResourceCountDistribution, n int) ([]int, float64) {
+	rng := rand.New(rand.NewSource(1))
+	counts := make([]int, 10_000)
+	total := 0
+	for i := range counts {
+		counts[i] = d.pick(rng, n)
+		total += counts[i]
+	}
+	return counts, float64(total) / float64(len(counts))
+}
+
+func TestResourceCount_Fixed(t *testing.T) {
+	counts, _ := resourceCountSamples(ResourceCountFixed, 5)
+	for _, c := range counts {
+		if c != 5 {
+			t.Fatalf("Expected every batch to have 5 resources, got %d", c)
+		}
+	}
+}
+
+func TestResourceCount_Uniform(t *testing.T) {
+	const n = 5
+
+	counts, mean := resourceCountSamples(ResourceCountUniform, n)
+	seen := make(map[int]int)
+	for _, c := range counts {
+		if c < 1 || c > 2*n-1 {
+			t.Fatalf("Expected counts from 1 to %d, got %d", 2*n-1, c)
+		}
+		seen[c]++
+	}
+
+	if len(seen) != 2*n-1 {
+		t.Errorf("Expected every count from 1 to %d, got %v", 2*n-1, seen)
+	}
+	for c, k := range seen {
+		if k < 900 || k > 1400 {
+			t.Errorf("Expected each count in about 1 of %d batches, %d occurred %d times", 2*n-1, c, k)
+		}
+	}
+	if math.Abs(mean-n) > 0.2 {
+		t.Errorf("Expected a mean of %d resources, got %.2f", n, mean)
+	}
+}
+
+func TestResourceCount_Skewed(t *testing.T) {
+	const n = 5
+
+	counts, mean := resourceCountSamples(ResourceCountSkewed, n)
+	small, large := 0, 0
+	for _, c := range counts {
+		if c < 1 || c > skewedResourceCountCap*n {
+			t.Fatalf("Expected counts from 1 to %d, got %d", skewedResourceCountCap*n, c)
+		}
+		if c < n {
+			small++
+		}
+		if c >= 2*n {
+			large++
+		}
+	}
+
+	// A geometric distribution with mean n has most batches below the mean,
+	// and about (1-1/n)^(2n-1) at or above twice the mean
+	if small < len(counts)/2 {
+		t.Errorf("Expected most batches to be smaller than %d, got %d of %d", n, small, len(counts))
+	}
+	if large < len(counts)/20 {
+		t.Errorf("Expected some batches with at least %d resources, got %d of %d", 2*n, large, len(counts))
+	}
+	if mean < 0.85*n || mean > n {
+		t.Errorf("Expected a mean just under %d resources, got %.2f", n, mean)
+	}
+}
+
+func TestResourceCount_SingleResource(t *testing.T) {
+	for _, d := range []ResourceCountDistribution{ResourceCountFixed, ResourceCountUniform, ResourceCountSkewed} {
+		if c := d.pick(newResourceCountRand(), 1); c != 1 {
+			t.Errorf("Expected %s to always pick 1 resource of 1, got %d", d, c)
+		}
+	}
+}
+
+func TestParseResourceCountDistribution(t *testing.T) {
+	for _, d := range []ResourceCountDistribution{ResourceCountFixed, ResourceCountUniform, ResourceCountSkewed} {
+		got, err := ParseResourceCountDistribution(d.String())
+		if err != nil || got != d {
+			t.Errorf("ParseResourceCountDistribution(%q) = %v, %v", d.String(), got, err)
+		}
+	}
+	if _, err := ParseResourceCountDistribution("zipf"); err == nil {
+		t.Error("Expected an error for an unknown distribution")
+	}
+}
+
+func TestTracesPushIt_CountsVaryingResources(t *testing.T) {
+	addr := startTestTraceServer(t)
+	w := newTestTracesWorker(t, TracesConfig{
+		ResourcesPerBatch: 4,
+		ResourceCounts:    ResourceCountUniform,
+		SpansPerResource:  3,
+	})
+	exp, sb := newTestGRPCExporter(t, addr, ExportConfig{})
+	w.exp = exp
+	w.statTracesSent = sb.NewStat(stats.StatSpansSent)
+
+	pool := newTestResources(ResourceCountUniform.max(4))
+	rng := rand.New(rand.NewSource(1))
+	want := 0
+	for i := 0; i < 20; i++ {
+		resources := w.resourceCounts.resources(rng, pool, w.resourcesPerBatch)
+		batch := w.buildBatch(resources, worker.NopMsgIdGenerator())
+		if len(batch) != len(resources) {
+			t.Fatalf("Expected a resource per batch entry, got %d for %d", len(batch), len(resources))
+		}
+		want += len(resources) * 3
+		w.pushIt(1, batch)
+	}
+
+	if got := sb.value(stats.StatSpansSent); got != uint64(want) {
+		t.Errorf("Expected %d spans sent, got %d", want, got)
+	}
+}
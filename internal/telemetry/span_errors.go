@@ -0,0 +1,90 @@
+package telemetry
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/otlp/anyvalue"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+	otlpTraces "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// spanException is an exception recorded on error spans
+type spanException struct {
+	exceptionType string
+	message       string
+}
+
+// Exceptions error spans are generated with
+var spanExceptions = []spanException{
+	{"java.net.SocketTimeoutException", "Read timed out"},
+	{"java.lang.NullPointerException", "Cannot invoke \"String.length()\" because \"name\" is null"},
+	{"ConnectionRefusedError", "[Errno 111] Connection refused"},
+	{"KeyError", "'user_id'"},
+	{"*net.OpError", "dial tcp 10.0.0.12:5432: connect: connection refused"},
+	{"context.deadlineExceededError", "context deadline exceeded"},
+	{"System.InvalidOperationException", "Sequence contains no elements"},
+	{"Error", "ECONNRESET: socket hang up"},
+}
+
+// spanErrorInjector marks a fraction of the spans as errors. Spans are picked
+// with a seeded source so runs with the same seed pick the same spans.
+type spanErrorInjector struct {
+	rate float64
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// newSpanErrorInjector returns nil when rate is zero, a zero seed picks a
+// random seed
+func newSpanErrorInjector(rate float64, seed int64) *spanErrorInjector {
+	if rate <= 0 {
+		return nil
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	return &spanErrorInjector{
+		rate: rate,
+		rng:  rand.New(rand.NewSource(seed)),
+	}
+}
+
+// pick returns the exception of the next span, if it should be an error
+func (e *spanErrorInjector) pick() (spanException, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.rng.Float64() >= e.rate {
+		return spanException{}, false
+	}
+	return spanExceptions[e.rng.Intn(len(spanExceptions))], true
+}
+
+// inject sets an error status on the span and records the exception as an
+// exception span event at time ts
+func (exc spanException) inject(span *otlpTraces.Span, ts uint64) {
+	span.Status = &otlpTraces.Status{
+		Code:    otlpTraces.Status_STATUS_CODE_ERROR,
+		Message: exc.message,
+	}
+
+	span.Events = append(span.Events, &otlpTraces.Span_Event{
+		TimeUnixNano: ts,
+		Name:         "exception",
+		Attributes: []*otlpCommon.KeyValue{
+			{
+				Key:   string(semconv.ExceptionTypeKey),
+				Value: anyvalue.String(exc.exceptionType),
+			},
+			{
+				Key:   string(semconv.ExceptionMessageKey),
+				Value: anyvalue.String(exc.message),
+			},
+		},
+	})
+}
@@ -0,0 +1,95 @@
+package telemetry
+
+import (
+	"fmt"
+
+	"github.com/streamfold/otel-loadgen/internal/otlp/anyvalue"
+	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+	otlpTraces "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// spanEventTemplate builds the attributes of an event from the span index and
+// the event's index within the span, so payloads vary without randomness
+type spanEventTemplate struct {
+	name  string
+	attrs func(j, k int) []*otlpCommon.KeyValue
+}
+
+// spanEventPool are the events spans are given in turn
+var spanEventPool = []spanEventTemplate{
+	{
+		name: "db-connect",
+		attrs: func(j, k int) []*otlpCommon.KeyValue {
+			return []*otlpCommon.KeyValue{
+				anyvalue.KV("db.system", anyvalue.String("postgresql")),
+				anyvalue.KV("server.address", anyvalue.String(fmt.Sprintf("db-%d.internal", j%4))),
+				anyvalue.KV("server.port", anyvalue.Int(5432)),
+			}
+		},
+	},
+	{
+		name: "cache.miss",
+		attrs: func(j, k int) []*otlpCommon.KeyValue {
+			return []*otlpCommon.KeyValue{
+				anyvalue.KV("cache.key", anyvalue.String(fmt.Sprintf("session:%d:%d", j, k))),
+				anyvalue.KV("cache.ttl_ms", anyvalue.Int(int64(1000*(1+j%30)))),
+			}
+		},
+	},
+	{
+		name: "message.sent",
+		attrs: func(j, k int) []*otlpCommon.KeyValue {
+			return []*otlpCommon.KeyValue{
+				anyvalue.KV("messaging.system", anyvalue.String("kafka")),
+				anyvalue.KV("messaging.destination.name", anyvalue.String(fmt.Sprintf("orders-%d", k%3))),
+				anyvalue.KV("messaging.message.body.size", anyvalue.Int(int64(256+(j*37+k*11)%4096))),
+			}
+		},
+	},
+	{
+		name: "retry",
+		attrs: func(j, k int) []*otlpCommon.KeyValue {
+			return []*otlpCommon.KeyValue{
+				anyvalue.KV("retry.attempt", anyvalue.Int(int64(1+k%5))),
+				anyvalue.KV("retry.backoff_ms", anyvalue.Double(float64(int64(50)<<(k%5)))),
+				anyvalue.KV("retry.reason", anyvalue.String("connection reset by peer")),
+			}
+		},
+	},
+	{
+		name: "log",
+		attrs: func(j, k int) []*otlpCommon.KeyValue {
+			msg := commonLogMessages[(j+k)%len(commonLogMessages)]
+			return []*otlpCommon.KeyValue{
+				anyvalue.KV("log.severity", anyvalue.String(severityText(msg.severity))),
+				anyvalue.KV("log.message", anyvalue.String(msg.body)),
+			}
+		},
+	},
+	{
+		name: "http.response",
+		attrs: func(j, k int) []*otlpCommon.KeyValue {
+			return []*otlpCommon.KeyValue{
+				anyvalue.KV("http.response.status_code", anyvalue.Int(200)),
+				anyvalue.KV("http.response.body.size", anyvalue.Int(int64(512+(j*131+k*17)%65536))),
+				anyvalue.KV("http.response.cached", anyvalue.Bool(j%5 == 0)),
+			}
+		},
+	},
+}
+
+// spanEvents returns perSpan events for the span at index j, taken from the
+// pool in turn and spread evenly between the span's start and end
+func spanEvents(perSpan, j int, start, end int64) []*otlpTraces.Span_Event {
+	events := make([]*otlpTraces.Span_Event, 0, perSpan)
+	step := (end - start) / int64(perSpan+1)
+	for k := 0; k < perSpan; k++ {
+		tmpl := spanEventPool[(j+k)%len(spanEventPool)]
+		events = append(events, &otlpTraces.Span_Event{
+			TimeUnixNano: uint64(start + int64(k+1)*step),
+			Name:         tmpl.name,
+			Attributes:   tmpl.attrs(j, k),
+		})
+	}
+	return events
+}
@@ -0,0 +1,42 @@
+package telemetry
+
+import (
+	"testing"
+
+	"github.com/streamfold/otel-loadgen/internal/worker"
+)
+
+func TestTracesBuildBatch_EventsPerSpan(t *testing.T) {
+	for _, perSpan := range []int{0, 1, 8} {
+		w := newTestTracesWorker(t, TracesConfig{
+			ResourcesPerBatch: 1,
+			SpansPerResource:  10,
+			EventsPerSpan:     perSpan,
+		})
+
+		names := make(map[string]bool)
+		for _, span := range w.buildBatch(newTestResources(1), worker.NopMsgIdGenerator())[0].ScopeSpans[0].Spans {
+			if len(span.Events) != perSpan {
+				t.Fatalf("Expected %d events, got %d", perSpan, len(span.Events))
+			}
+
+			last := span.StartTimeUnixNano
+			for _, event := range span.Events {
+				if event.TimeUnixNano <= last || event.TimeUnixNano >= span.EndTimeUnixNano {
+					t.Errorf("Expected ordered event times within %d-%d, got %d after %d",
+						span.StartTimeUnixNano, span.EndTimeUnixNano, event.TimeUnixNano, last)
+				}
+				last = event.TimeUnixNano
+
+				if len(event.Attributes) == 0 {
+					t.Errorf("Expected attributes on %s event", event.Name)
+				}
+				names[event.Name] = true
+			}
+		}
+
+		if perSpan > 1 && len(names) != len(spanEventPool) {
+			t.Errorf("Expected every pooled event name, got %v", names)
+		}
+	}
+}
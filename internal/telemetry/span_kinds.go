@@ -0,0 +1,75 @@
+package telemetry
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/streamfold/otel-loadgen/internal/util"
+	otlpTraces "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+var spanKindNames = map[string]otlpTraces.Span_SpanKind{
+	"server":   otlpTraces.Span_SPAN_KIND_SERVER,
+	"client":   otlpTraces.Span_SPAN_KIND_CLIENT,
+	"internal": otlpTraces.Span_SPAN_KIND_INTERNAL,
+	"producer": otlpTraces.Span_SPAN_KIND_PRODUCER,
+	"consumer": otlpTraces.Span_SPAN_KIND_CONSUMER,
+}
+
+// SpanKinds assigns span kinds by span index following relative weights. The
+// kind of an index only depends on the seed, so every trace has the same shape
+// and runs with the same seed generate the same topology.
+type SpanKinds struct {
+	kinds *util.WeightedChoice[otlpTraces.Span_SpanKind]
+	seed  uint64
+}
+
+// ParseSpanKinds parses a weight list of the format 'server:2,client:2,internal:5'
+func ParseSpanKinds(s string, seed int64) (*SpanKinds, error) {
+	names, weights, err := util.ParseWeights(s)
+	if err != nil {
+		return nil, err
+	}
+
+	kinds := make([]otlpTraces.Span_SpanKind, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		kind, ok := spanKindNames[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("invalid span kind: %q (expected server, client, internal, producer or consumer)", name)
+		}
+		if seen[strings.ToLower(name)] {
+			return nil, fmt.Errorf("duplicate span kind: %q", name)
+		}
+		seen[strings.ToLower(name)] = true
+		kinds = append(kinds, kind)
+	}
+
+	wc, err := util.NewWeightedChoice(kinds, weights)
+	if err != nil {
+		return nil, fmt.Errorf("invalid span kind weights: %w", err)
+	}
+
+	return &SpanKinds{kinds: wc, seed: uint64(seed)}, nil
+}
+
+// kind returns the span kind of the span at index within its trace, a nil
+// SpanKinds makes every span a server span
+func (k *SpanKinds) kind(index int) otlpTraces.Span_SpanKind {
+	if k == nil {
+		return otlpTraces.Span_SPAN_KIND_SERVER
+	}
+
+	return k.kinds.Pick(seededUnit(k.seed, index))
+}
+
+// seededUnit returns a value in [0, 1) that only depends on the seed and index,
+// the splitmix64 of both scaled down
+func seededUnit(seed uint64, index int) float64 {
+	z := seed + uint64(index+1)*0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	z ^= z >> 31
+
+	return float64(z>>11) / (1 << 53)
+}
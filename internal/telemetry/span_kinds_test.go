@@ -0,0 +1,88 @@
+package telemetry
+
+import (
+	"testing"
+
+	"github.com/streamfold/otel-loadgen/internal/worker"
+	otlpTraces "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func TestParseSpanKinds(t *testing.T) {
+	if _, err := ParseSpanKinds("server:2,client:1", 0); err != nil {
+		t.Fatalf("Expected valid span kinds, got %v", err)
+	}
+	for _, s := range []string{"", "server", "server:x", "server:1,server:2", "server:0", "rpc:1"} {
+		if _, err := ParseSpanKinds(s, 0); err == nil {
+			t.Errorf("Expected error parsing %q", s)
+		}
+	}
+}
+
+func TestSpanKinds_Distribution(t *testing.T) {
+	kinds, err := ParseSpanKinds("server:1,client:1,internal:2", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[otlpTraces.Span_SpanKind]int)
+	const n = 10000
+	for i := 0; i < n; i++ {
+		counts[kinds.kind(i)]++
+	}
+
+	want := map[otlpTraces.Span_SpanKind]float64{
+		otlpTraces.Span_SPAN_KIND_SERVER:   0.25,
+		otlpTraces.Span_SPAN_KIND_CLIENT:   0.25,
+		otlpTraces.Span_SPAN_KIND_INTERNAL: 0.5,
+	}
+	for kind, frac := range want {
+		got := float64(counts[kind]) / n
+		if got < frac-0.03 || got > frac+0.03 {
+			t.Errorf("Expected %v to be ~%.2f of spans, got %.3f", kind, frac, got)
+		}
+	}
+	if len(counts) != len(want) {
+		t.Errorf("Expected only weighted kinds, got %v", counts)
+	}
+}
+
+func TestSpanKinds_Deterministic(t *testing.T) {
+	a, _ := ParseSpanKinds("server:1,client:1,internal:1,producer:1,consumer:1", 7)
+	b, _ := ParseSpanKinds("server:1,client:1,internal:1,producer:1,consumer:1", 7)
+	c, _ := ParseSpanKinds("server:1,client:1,internal:1,producer:1,consumer:1", 8)
+
+	differs := false
+	for i := 0; i < 100; i++ {
+		if a.kind(i) != b.kind(i) {
+			t.Fatalf("Expected the same kind for index %d with the same seed", i)
+		}
+		if a.kind(i) != c.kind(i) {
+			differs = true
+		}
+	}
+	if !differs {
+		t.Error("Expected a different seed to assign different kinds")
+	}
+
+	var none *SpanKinds
+	if none.kind(3) != otlpTraces.Span_SPAN_KIND_SERVER {
+		t.Error("Expected nil span kinds to assign server")
+	}
+}
+
+func TestTracesBuildBatch_SpanKinds(t *testing.T) {
+	kinds, _ := ParseSpanKinds("client:1,consumer:1", 1)
+	w := newTestTracesWorker(t, TracesConfig{
+		ResourcesPerBatch: 2,
+		SpansPerResource:  8,
+		SpanKinds:         kinds,
+	})
+
+	for _, rs := range w.buildBatch(newTestResources(2), worker.NopMsgIdGenerator()) {
+		for j, span := range rs.ScopeSpans[0].Spans {
+			if span.Kind != kinds.kind(j) {
+				t.Errorf("Expected span %d to be %v, got %v", j, kinds.kind(j), span.Kind)
+			}
+		}
+	}
+}
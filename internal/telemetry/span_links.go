@@ -0,0 +1,99 @@
+package telemetry
+
+import (
+	"bytes"
+	"math/rand"
+	"sync"
+
+	"github.com/streamfold/otel-loadgen/internal/otlp/anyvalue"
+	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+	otlpTraces "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// spanLinkRingSize is the number of recent spans links can point at
+const spanLinkRingSize = 256
+
+// Values of the link.type attribute
+var spanLinkTypes = []string{
+	"follows_from",
+	"batch",
+	"retry",
+}
+
+type linkTarget struct {
+	traceId []byte
+	spanId  []byte
+}
+
+// spanLinker links spans to spans of previously generated traces, kept in a
+// ring buffer shared by the worker's pushers
+type spanLinker struct {
+	perSpan int
+
+	mu      sync.Mutex
+	targets []linkTarget
+	next    int
+}
+
+// newSpanLinker returns nil when perSpan is zero
+func newSpanLinker(perSpan int) *spanLinker {
+	if perSpan <= 0 {
+		return nil
+	}
+
+	return &spanLinker{
+		perSpan: perSpan,
+		targets: make([]linkTarget, 0, spanLinkRingSize),
+	}
+}
+
+// links returns up to perSpan links to spans of other traces than traceId,
+// there may be fewer until enough traces were generated
+func (l *spanLinker) links(traceId []byte) []*otlpTraces.Span_Link {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.targets) == 0 {
+		return nil
+	}
+
+	// Walk the ring from a random offset so a span never links the same
+	// target twice
+	n := len(l.targets)
+	start := rand.Intn(n)
+
+	links := make([]*otlpTraces.Span_Link, 0, min(l.perSpan, n))
+	for i := 0; i < n && len(links) < l.perSpan; i++ {
+		target := l.targets[(start+i)%n]
+		if bytes.Equal(target.traceId, traceId) {
+			continue
+		}
+
+		links = append(links, &otlpTraces.Span_Link{
+			TraceId: target.traceId,
+			SpanId:  target.spanId,
+			Attributes: []*otlpCommon.KeyValue{
+				{
+					Key:   "link.type",
+					Value: anyvalue.String(spanLinkTypes[rand.Intn(len(spanLinkTypes))]),
+				},
+			},
+		})
+	}
+	return links
+}
+
+// record adds a span that later spans can link to, replacing the oldest once
+// the ring is full
+func (l *spanLinker) record(traceId, spanId []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	target := linkTarget{traceId: traceId, spanId: spanId}
+	if len(l.targets) < cap(l.targets) {
+		l.targets = append(l.targets, target)
+		return
+	}
+	l.targets[l.next] = target
+	l.next = (l.next + 1) % len(l.targets)
+}
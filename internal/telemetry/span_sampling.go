@@ -0,0 +1,85 @@
+package telemetry
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/otlp/anyvalue"
+	"github.com/streamfold/otel-loadgen/internal/util"
+	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+)
+
+// Attributes carrying the sampling decision of a span
+const (
+	samplingPriorityKey    = "sampling.priority"
+	samplingProbabilityKey = "sampling.probability"
+)
+
+// SpanSampling adds sampling decision attributes to spans, for backends that
+// sample on attributes rather than trace flags. The sampling.probability of
+// each span follows relative weights and sampling.priority is 1 if a draw with
+// that probability kept the span, 0 otherwise.
+type SpanSampling struct {
+	probabilities *util.WeightedChoice[float64]
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// ParseSpanSampling parses a weight list of sampling probabilities of the
+// format '1:1,0.1:4,0.01:5', a zero seed picks a random seed
+func ParseSpanSampling(s string, seed int64) (*SpanSampling, error) {
+	names, weights, err := util.ParseWeights(s)
+	if err != nil {
+		return nil, err
+	}
+
+	probabilities := make([]float64, 0, len(names))
+	seen := make(map[float64]bool, len(names))
+	for _, name := range names {
+		p, err := strconv.ParseFloat(name, 64)
+		if err != nil || p < 0 || p > 1 {
+			return nil, fmt.Errorf("invalid sampling probability: %q (expected a number from 0 to 1)", name)
+		}
+		if seen[p] {
+			return nil, fmt.Errorf("duplicate sampling probability: %q", name)
+		}
+		seen[p] = true
+		probabilities = append(probabilities, p)
+	}
+
+	wc, err := util.NewWeightedChoice(probabilities, weights)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sampling probability weights: %w", err)
+	}
+
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &SpanSampling{probabilities: wc, rng: rand.New(rand.NewSource(seed))}, nil
+}
+
+// attrs returns the sampling attributes of the next span
+func (s *SpanSampling) attrs() []*otlpCommon.KeyValue {
+	s.mu.Lock()
+	p := s.probabilities.Pick(s.rng.Float64())
+	var priority int64
+	if s.rng.Float64() < p {
+		priority = 1
+	}
+	s.mu.Unlock()
+
+	return []*otlpCommon.KeyValue{
+		{
+			Key:   samplingPriorityKey,
+			Value: anyvalue.Int(priority),
+		},
+		{
+			Key:   samplingProbabilityKey,
+			Value: anyvalue.Double(p),
+		},
+	}
+}
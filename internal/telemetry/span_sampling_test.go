@@ -0,0 +1,69 @@
+package telemetry
+
+import (
+	"testing"
+
+	"github.com/streamfold/otel-loadgen/internal/worker"
+)
+
+func TestParseSpanSampling(t *testing.T) {
+	if _, err := ParseSpanSampling("1:1,0.1:4,0:2", 0); err != nil {
+		t.Fatalf("Expected valid sampling probabilities, got %v", err)
+	}
+	for _, s := range []string{"", "1", "x:1", "1.5:1", "-0.1:1", "0.1:1,0.10:2", "1:0"} {
+		if _, err := ParseSpanSampling(s, 0); err == nil {
+			t.Errorf("Expected error parsing %q", s)
+		}
+	}
+}
+
+func TestSpanSampling_Attributes(t *testing.T) {
+	sampling, err := ParseSpanSampling("1:1,0.5:2,0.1:1", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := newTestTracesWorker(t, TracesConfig{
+		ResourcesPerBatch: 10,
+		SpansPerResource:  1000,
+		Sampling:          sampling,
+	})
+	batch := w.buildBatch(newTestResources(10), worker.NopMsgIdGenerator())
+
+	counts := make(map[float64]int)
+	kept := make(map[float64]int)
+	n := 0
+	for _, rs := range batch {
+		for _, span := range rs.ScopeSpans[0].Spans {
+			var p float64
+			var priority int64 = -1
+			for _, attr := range span.Attributes {
+				switch attr.Key {
+				case samplingProbabilityKey:
+					p = attr.GetValue().GetDoubleValue()
+				case samplingPriorityKey:
+					priority = attr.GetValue().GetIntValue()
+				}
+			}
+			if priority != 0 && priority != 1 {
+				t.Fatalf("Expected a sampling priority of 0 or 1 on span, got %d", priority)
+			}
+			counts[p]++
+			kept[p] += int(priority)
+			n++
+		}
+	}
+
+	want := map[float64]float64{1: 0.25, 0.5: 0.5, 0.1: 0.25}
+	for p, frac := range want {
+		if got := float64(counts[p]) / float64(n); got < frac-0.03 || got > frac+0.03 {
+			t.Errorf("Expected probability %v on ~%.2f of spans, got %.3f", p, frac, got)
+		}
+		if got := float64(kept[p]) / float64(counts[p]); got < p-0.05 || got > p+0.05 {
+			t.Errorf("Expected ~%.2f of spans with probability %v to be kept, got %.3f", p, p, got)
+		}
+	}
+	if len(counts) != len(want) {
+		t.Errorf("Expected only weighted probabilities, got %v", counts)
+	}
+}
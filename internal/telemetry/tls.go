@@ -0,0 +1,58 @@
+package telemetry
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TLSOptions configures TLS for the gRPC and HTTP export paths
+type TLSOptions struct {
+	// CAFile is a PEM bundle trusted in addition to the system cert pool
+	CAFile string
+	// CertFile and KeyFile are the PEM client certificate and key for mutual TLS
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+	ServerName         string
+}
+
+// Config builds the tls.Config for the options
+func (o TLSOptions) Config() (*tls.Config, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file: %s", o.CAFile)
+		}
+	}
+
+	if (o.CertFile == "") != (o.KeyFile == "") {
+		return nil, errors.New("both a client certificate and key must be provided for mutual TLS")
+	}
+
+	var certs []tls.Certificate
+	if o.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	return &tls.Config{
+		RootCAs:            pool,
+		Certificates:       certs,
+		InsecureSkipVerify: o.InsecureSkipVerify,
+		ServerName:         o.ServerName,
+	}, nil
+}
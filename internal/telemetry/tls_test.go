@@ -0,0 +1,155 @@
+package telemetry
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTLSOptions_CAFile(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(opts TLSOptions) error {
+		cfg, err := opts.Config()
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+		resp, err := client.Get(srv.URL)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		return err
+	}
+
+	if err := get(TLSOptions{}); err == nil {
+		t.Error("Expected untrusted certificate to fail verification")
+	}
+	if err := get(TLSOptions{CAFile: caFile}); err != nil {
+		t.Errorf("Expected certificate to be trusted with CA file: %v", err)
+	}
+	if err := get(TLSOptions{InsecureSkipVerify: true}); err != nil {
+		t.Errorf("Expected verification to be skipped: %v", err)
+	}
+	if err := get(TLSOptions{CAFile: caFile, ServerName: "collector.invalid"}); err == nil {
+		t.Error("Expected mismatched server name to fail verification")
+	}
+}
+
+func TestTLSOptions_InvalidCAFile(t *testing.T) {
+	if _, err := (TLSOptions{CAFile: filepath.Join(t.TempDir(), "missing.pem")}).Config(); err == nil {
+		t.Error("Expected error for missing CA file")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(caFile, []byte("not a cert"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (TLSOptions{CAFile: caFile}).Config(); err == nil {
+		t.Error("Expected error for CA file without certificates")
+	}
+}
+
+// writeClientCert writes a self-signed client certificate and key, returning their paths
+func writeClientCert(t *testing.T) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "otel-loadgen"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestTLSOptions_ClientCertificate(t *testing.T) {
+	var gotCN string
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			gotCN = r.TLS.PeerCertificates[0].Subject.CommonName
+		}
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	certFile, keyFile := writeClientCert(t)
+
+	get := func(opts TLSOptions) error {
+		cfg, err := opts.Config()
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+		resp, err := client.Get(srv.URL)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		return err
+	}
+
+	if err := get(TLSOptions{InsecureSkipVerify: true}); err == nil {
+		t.Error("Expected server requiring a client certificate to reject the request")
+	}
+	if err := get(TLSOptions{InsecureSkipVerify: true, CertFile: certFile, KeyFile: keyFile}); err != nil {
+		t.Fatalf("Expected request with client certificate to succeed: %v", err)
+	}
+	if gotCN != "otel-loadgen" {
+		t.Errorf("Expected client certificate CN otel-loadgen, got %q", gotCN)
+	}
+}
+
+func TestTLSOptions_ClientCertificateRequiresKey(t *testing.T) {
+	certFile, keyFile := writeClientCert(t)
+
+	if _, err := (TLSOptions{CertFile: certFile}).Config(); err == nil {
+		t.Error("Expected error when only the certificate is provided")
+	}
+	if _, err := (TLSOptions{KeyFile: keyFile}).Config(); err == nil {
+		t.Error("Expected error when only the key is provided")
+	}
+}
@@ -0,0 +1,67 @@
+package telemetry
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// tracePoolSize bounds the number of recent traces a worker can extend
+const tracePoolSize = 1024
+
+// pooledTrace is a recent trace and the last span emitted for it, reused
+// traces continue below that span
+type pooledTrace struct {
+	traceId    []byte
+	lastSpanId []byte
+}
+
+// tracePool holds the most recent traces of a worker so batches can extend an
+// existing trace instead of starting a new one, mixing short traces with very
+// long ones
+type tracePool struct {
+	mu        sync.Mutex
+	reuseRate float64
+	traces    []pooledTrace
+	// next is the slot the next new trace is written to once the pool is full
+	next int
+}
+
+func newTracePool(reuseRate float64) *tracePool {
+	return &tracePool{
+		reuseRate: reuseRate,
+		traces:    make([]pooledTrace, 0, tracePoolSize),
+	}
+}
+
+// pick returns a recent trace to extend and its slot, with a probability of
+// the reuse rate. It returns false when a new trace should be started.
+func (p *tracePool) pick() (pooledTrace, int, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.traces) == 0 || rand.Float64() >= p.reuseRate {
+		return pooledTrace{}, -1, false
+	}
+
+	slot := rand.Intn(len(p.traces))
+	return p.traces[slot], slot, true
+}
+
+// record stores the last span of a trace. A slot from pick updates the reused
+// trace, -1 adds a new trace, replacing the oldest when the pool is full.
+func (p *tracePool) record(slot int, trace pooledTrace) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if slot >= 0 && slot < len(p.traces) {
+		p.traces[slot] = trace
+		return
+	}
+
+	if len(p.traces) < cap(p.traces) {
+		p.traces = append(p.traces, trace)
+		return
+	}
+	p.traces[p.next] = trace
+	p.next = (p.next + 1) % len(p.traces)
+}
@@ -1,19 +1,14 @@
 package telemetry
 
 import (
-	"bytes"
-	gzip2 "compress/gzip"
-	"context"
-	"fmt"
-	"io"
 	"net/http"
-	"net/url"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/streamfold/otel-loadgen/internal/genai"
 	"github.com/streamfold/otel-loadgen/internal/otlp"
+	"github.com/streamfold/otel-loadgen/internal/otlp/anyvalue"
 	"github.com/streamfold/otel-loadgen/internal/stats"
 	"github.com/streamfold/otel-loadgen/internal/util"
 	"github.com/streamfold/otel-loadgen/internal/worker"
@@ -24,87 +19,201 @@ import (
 	otlpRes "go.opentelemetry.io/proto/otlp/resource/v1"
 	otlpTraces "go.opentelemetry.io/proto/otlp/trace/v1"
 	"go.uber.org/zap"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
-	"google.golang.org/grpc/encoding/gzip"
-	"google.golang.org/grpc/metadata"
-	"google.golang.org/protobuf/proto"
+	"golang.org/x/time/rate"
 )
 
+// TracesConfig holds the settings for generating trace spans
+type TracesConfig struct {
+	ResourcesPerBatch int
+	// ResourceCounts varies the number of resources of each batch around
+	// ResourcesPerBatch, the default gives every batch ResourcesPerBatch
+	ResourceCounts   ResourceCountDistribution
+	SpansPerResource int
+	// SpansDistribution controls how the spans of a batch are split across
+	// its resources, the batch total is always the number of resources times
+	// SpansPerResource
+	SpansDistribution Distribution
+	GenAICorpus       genai.EntrySource
+	// ValidateBeforeSend checks every span before export and counts invalid ones
+	ValidateBeforeSend bool
+	// DropInvalidSpans removes invalid spans from the batch, requires ValidateBeforeSend
+	DropInvalidSpans bool
+	// BuildQueueSize is the number of built batches that can wait for export,
+	// zero builds and exports each batch in turn
+	BuildQueueSize int
+	// BaseTime pins span timestamps to a fixed time instead of the wall clock,
+	// making generated batches reproducible
+	BaseTime time.Time
+	// Correlator, if set, records the generated spans so logs and metrics can
+	// reference them
+	Correlator *Correlator
+	// TargetRate is the aggregate spans per second across all pushers, when
+	// set it paces the pushers instead of the push interval
+	TargetRate float64
+	// PartitionAttr adds a loadgen.partition resource attribute with the pusher
+	// index, matching the partition used for the X-Forwarded-For header
+	PartitionAttr bool
+	// ResourceAttrs are added to every generated resource, e.g. the detected
+	// host and process attributes
+	ResourceAttrs []*otlpCommon.KeyValue
+	// Scope is the instrumentation scope of the generated spans
+	Scope otlp.ScopeConfig
+	// Resource sets the service name and user attributes of the resources
+	Resource otlp.ResourceConfig
+	// TraceReuseRate is the probability that a resource's spans extend a recent
+	// trace rather than starting a new one, zero always starts new traces
+	TraceReuseRate float64
+	// ErrorRate is the fraction of spans given an error status and an
+	// exception event
+	ErrorRate float64
+	// ErrorSeed seeds the selection of error spans, zero picks a random seed
+	ErrorSeed int64
+	// LinksPerSpan is the number of links each span gets to spans of
+	// previously generated traces
+	LinksPerSpan int
+	// EventsPerSpan is the number of events each span gets, spread between
+	// its start and end. Gen_ai tool and exception events are added on top.
+	EventsPerSpan int
+	// SpanAttrCardinality and ResourceAttrCardinality add extra high
+	// cardinality attributes to every span and every resource, nil adds none
+	SpanAttrCardinality     *AttrCardinality
+	ResourceAttrCardinality *AttrCardinality
+	// SpanKinds assigns the span kinds, nil makes every span a server span
+	SpanKinds *SpanKinds
+	// Sampling adds sampling.priority and sampling.probability attributes to
+	// every span, nil adds none
+	Sampling *SpanSampling
+	// TreeShape controls how the spans of a trace are parented
+	TreeShape TreeShape
+	// TreeFanout is the number of children of each span with TreeShapeFanout
+	TreeFanout int
+	// IDSeed seeds the trace and span ids for reproducible runs, zero seeds
+	// them randomly so separate runs don't generate the same ids
+	IDSeed int64
+}
+
+// partitionAttrKey is the resource attribute carrying the pusher partition
+const partitionAttrKey = "loadgen.partition"
+
 type tracesWorker struct {
 	log               *zap.Logger
 	resourcesPerBatch int
+	resourceCounts    ResourceCountDistribution
 	spansPerResource  int
-	endpoint          *url.URL
-	useGRPC           bool
+	spansDistribution Distribution
+	exp               *exporter
 	scope             *otlpCommon.InstrumentationScope
 	idGen             *util.ByteGen
 	wg                sync.WaitGroup
 	nextWorkerId      atomic.Uint64
 	stopChan          chan bool
-	client            *http.Client
-	statBytesSent     stats.Stat
-	statBytesSentZ    stats.Stat
-	statBatchesSent   stats.Stat
 	statTracesSent    stats.Stat
-	tracesClient      otlpTraceColl.TraceServiceClient
-	genAICorpus       *genai.Corpus
-	customHeaders     map[string]string
+	statRejected      stats.Stat
+	statSpansInvalid  stats.Stat
+	statQueueDepth    stats.Gauge
+	genAICorpus       genai.EntrySource
+	validate          bool
+	dropInvalid       bool
+	now               clock
+	buildQueueSize    int
+	correlator        *Correlator
+	targetRate        float64
+	limiter           *rate.Limiter
+	partitionAttr     bool
+	resourceAttrs     []*otlpCommon.KeyValue
+	resource          otlp.ResourceConfig
+	tracePool         *tracePool
+	spanErrors        *spanErrorInjector
+	spanLinker        *spanLinker
+	eventsPerSpan     int
+	spanAttrCard      *AttrCardinality
+	resourceAttrCard  *AttrCardinality
+	spanKinds         *SpanKinds
+	sampling          *SpanSampling
+	treeShape         TreeShape
+	treeFanout        int
 }
 
-func NewTracesWorker(log *zap.Logger, endpoint *url.URL, useGRPC bool, resourcesPerBatch int, spansPerResource int, genAICorpus *genai.Corpus, customHeaders map[string]string) worker.Worker {
-	// For HTTP mode, ensure the endpoint has the /v1/traces path
-	if !useGRPC && (endpoint.Path == "" || endpoint.Path == "/") {
-		endpoint.Path = "/v1/traces"
+func NewTracesWorker(log *zap.Logger, exportCfg ExportConfig, cfg TracesConfig) worker.Worker {
+	var limiter *rate.Limiter
+	if cfg.TargetRate > 0 {
+		limiter = newTargetLimiter(cfg.TargetRate, cfg.ResourcesPerBatch*cfg.SpansPerResource)
+	}
+
+	var pool *tracePool
+	if cfg.TraceReuseRate > 0 {
+		pool = newTracePool(cfg.TraceReuseRate)
+	}
+
+	idGen := util.NewByteGen()
+	if cfg.IDSeed != 0 {
+		idGen = util.NewByteGenSeed(util.IdSeed(cfg.IDSeed))
 	}
 
 	return &tracesWorker{
 		log:               log,
-		useGRPC:           useGRPC,
-		endpoint:          endpoint,
-		resourcesPerBatch: resourcesPerBatch,
-		spansPerResource:  spansPerResource,
-		scope:             otlp.NewScope(),
-		idGen:             util.NewByteGen(),
-		genAICorpus:       genAICorpus,
-		customHeaders:     customHeaders,
+		exp:               newExporter(log, exportCfg, tracesHTTPPath, tracesGRPCMethod),
+		resourcesPerBatch: cfg.ResourcesPerBatch,
+		resourceCounts:    cfg.ResourceCounts,
+		spansPerResource:  cfg.SpansPerResource,
+		spansDistribution: cfg.SpansDistribution,
+		scope:             otlp.NewScope(cfg.Scope),
+		idGen:             idGen,
+		genAICorpus:       cfg.GenAICorpus,
+		validate:          cfg.ValidateBeforeSend,
+		dropInvalid:       cfg.DropInvalidSpans,
+		now:               newClock(cfg.BaseTime),
+		buildQueueSize:    cfg.BuildQueueSize,
+		correlator:        cfg.Correlator,
+		targetRate:        cfg.TargetRate,
+		limiter:           limiter,
+		partitionAttr:     cfg.PartitionAttr,
+		resourceAttrs:     cfg.ResourceAttrs,
+		resource:          cfg.Resource,
+		tracePool:         pool,
+		spanErrors:        newSpanErrorInjector(cfg.ErrorRate, cfg.ErrorSeed),
+		spanLinker:        newSpanLinker(cfg.LinksPerSpan),
+		eventsPerSpan:     cfg.EventsPerSpan,
+		spanAttrCard:      cfg.SpanAttrCardinality,
+		resourceAttrCard:  cfg.ResourceAttrCardinality,
+		spanKinds:         cfg.SpanKinds,
+		sampling:          cfg.Sampling,
+		treeShape:         cfg.TreeShape,
+		treeFanout:        cfg.TreeFanout,
 	}
 }
 
 func (o *tracesWorker) Init(statsBuilder stats.Builder, client *http.Client) error {
 	o.wg = sync.WaitGroup{}
 	o.stopChan = make(chan bool)
-	o.client = client
-
-	o.statBytesSent = statsBuilder.NewStat(stats.StatBytesSent)
-	o.statBytesSentZ = statsBuilder.NewStat(stats.StatBytesSentZ)
-	o.statBatchesSent = statsBuilder.NewStat(stats.StatBatchesSent)
-	o.statTracesSent = statsBuilder.NewStat(stats.StatSpansSent)
-
-	if o.useGRPC {
-		opts := []grpc.DialOption{
-			grpc.WithDefaultCallOptions(grpc.UseCompressor(gzip.Name)),
-		}
 
-		if o.endpoint.Scheme == "http" {
-			opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
-		}
-
-		conn, err := grpc.Dial(fmt.Sprintf("%s:%s", o.endpoint.Hostname(), o.endpoint.Port()), opts...)
-		if err != nil {
-			return err
-		}
-
-		o.tracesClient = otlpTraceColl.NewTraceServiceClient(conn)
+	if o.targetRate > 0 {
+		o.statTracesSent = statsBuilder.NewTargetStat(stats.StatSpansSent, o.targetRate)
+	} else {
+		o.statTracesSent = statsBuilder.NewStat(stats.StatSpansSent)
+	}
+	o.statRejected = statsBuilder.NewStat(stats.StatRejected)
+	if o.validate {
+		o.statSpansInvalid = statsBuilder.NewStat(stats.StatSpansInvalid)
+	}
+	if o.buildQueueSize > 0 {
+		o.statQueueDepth = statsBuilder.NewGauge(stats.StatQueueDepth)
 	}
 
-	return nil
+	return o.exp.init(statsBuilder, client)
 }
 
 func (o *tracesWorker) Start(pushInterval time.Duration, msgIdGen worker.MsgIdGenerator) {
 	pusherIdx := o.nextWorkerId.Add(1)
 	ticker := time.NewTicker(pushInterval)
 
+	// A target rate replaces the push interval
+	tick := ticker.C
+	if o.limiter != nil {
+		ticker.Stop()
+		tick = rateTicks(o.stopChan, o.limiter, o.resourcesPerBatch*o.spansPerResource)
+	}
+
 	o.wg.Add(1)
 	go func() {
 		defer func() {
@@ -112,204 +221,213 @@ func (o *tracesWorker) Start(pushInterval time.Duration, msgIdGen worker.MsgIdGe
 			o.wg.Done()
 		}()
 
-		o.pushWait(ticker, pusherIdx, msgIdGen)
+		o.pushWait(tick, pusherIdx, msgIdGen)
 	}()
 }
 
 func (o *tracesWorker) StopAll() {
 	close(o.stopChan)
+	o.exp.stop()
 	o.wg.Wait()
+	o.exp.close()
 }
 
-func (o *tracesWorker) pushWait(ticker *time.Ticker, idx uint64, msgIdGen worker.MsgIdGenerator) {
+func (o *tracesWorker) pushWait(tick <-chan time.Time, idx uint64, msgIdGen worker.MsgIdGenerator) {
 	resources := make([]*otlpRes.Resource, 0)
-	for i := 0; i < o.resourcesPerBatch; i++ {
-		res := otlp.NewResource(idx, i)
+	for i := 0; i < o.resourceCounts.max(o.resourcesPerBatch); i++ {
+		res := otlp.NewResource(o.resource, idx, i)
+		res.Attributes = append(res.Attributes, o.resourceAttrs...)
 		res.Attributes = msgIdGen.AddResourceAttrs(res.Attributes)
-		resources = append(resources, res)
-	}
-
-	for {
-		select {
-		case <-o.stopChan:
-			return
-		case <-ticker.C:
-			o.pushIt(idx, resources, msgIdGen)
+		if o.partitionAttr {
+			res.Attributes = append(res.Attributes, &otlpCommon.KeyValue{
+				Key:   partitionAttrKey,
+				Value: anyvalue.Int(int64(idx)),
+			})
 		}
-	}
-}
-
-func (o *tracesWorker) pushIt(idx uint64, resources []*otlpRes.Resource, msgIdGen worker.MsgIdGenerator) {
-	batch := o.buildBatch(resources, msgIdGen)
-
-	if o.useGRPC {
-		o.pushBatchGRPC(idx, batch)
-		return
+		if o.resourceAttrCard != nil {
+			res.Attributes = append(res.Attributes, o.resourceAttrCard.attrs()...)
+		}
+		resources = append(resources, res)
 	}
 
-	o.pushBatchHTTP(idx, batch)
+	rng := newResourceCountRand()
+	runBuildQueue(o.stopChan, tick, o.buildQueueSize, o.statQueueDepth,
+		func() []*otlpTraces.ResourceSpans {
+			batch := o.buildBatch(o.resourceCounts.resources(rng, resources, o.resourcesPerBatch), msgIdGen)
+			if o.correlator != nil {
+				o.correlator.recordTraces(idx, batch)
+			}
+			return batch
+		},
+		func(batch []*otlpTraces.ResourceSpans) {
+			o.pushIt(idx, batch)
+		})
 }
 
-func (o *tracesWorker) pushBatchGRPC(idx uint64, batch []*otlpTraces.ResourceSpans) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	mdMap := map[string]string{
-		"x-forwarded-for": fmt.Sprintf("127.0.0.%d", idx),
-	}
-	for k, v := range o.customHeaders {
-		mdMap[k] = v
+func (o *tracesWorker) pushIt(idx uint64, batch []*otlpTraces.ResourceSpans) {
+	numSpans := 0
+	for _, rs := range batch {
+		for _, ss := range rs.ScopeSpans {
+			numSpans += len(ss.Spans)
+		}
 	}
-	md := metadata.New(mdMap)
-	ctx = metadata.NewOutgoingContext(ctx, md)
-
-	msg := &otlpTraceColl.ExportTraceServiceRequest{ResourceSpans: batch}
-	resp, err := o.tracesClient.Export(ctx, msg)
-	if err != nil {
-		panic(err)
+	if o.validate {
+		invalid, err := validateTraces(batch, o.dropInvalid)
+		if invalid > 0 {
+			o.log.Warn("generated invalid spans",
+				zap.Int("invalid", invalid), zap.Bool("dropped", o.dropInvalid), zap.Error(err))
+			o.statSpansInvalid.Incr(uint64(invalid))
+			if o.dropInvalid {
+				numSpans -= invalid
+			}
+		}
 	}
 
-	if ps := resp.GetPartialSuccess(); ps != nil && ps.GetRejectedSpans() != 0 {
-		panic(fmt.Sprintf("got rejected traces spans: %d", ps.GetRejectedSpans()))
+	// Nothing left to send when every span was dropped
+	if numSpans == 0 {
+		return
 	}
 
-	o.statBytesSent.Incr(uint64(proto.Size(msg)))
-	o.statTracesSent.Incr(uint64(o.resourcesPerBatch * o.spansPerResource))
-	o.statBatchesSent.Incr(1)
-}
-
-func (o *tracesWorker) pushBatchHTTP(idx uint64, batch []*otlpTraces.ResourceSpans) {
-	// Use the ExportTraceServiceRequest for proper OTLP HTTP format
 	msg := &otlpTraceColl.ExportTraceServiceRequest{ResourceSpans: batch}
-
-	buf, err := proto.Marshal(msg)
-	if err != nil {
-		panic(err)
-	}
-
-	bufIn := bytes.NewReader(buf)
-	bufOut := bytes.NewBuffer(nil)
-
-	gr := gzip2.NewWriter(bufOut)
-
-	_, err = io.Copy(gr, bufIn)
-	if err != nil {
-		panic(err)
-	}
-
-	err = gr.Close()
-	if err != nil {
-		panic(err)
-	}
-
-	compressedLen := bufOut.Len()
-
-	// Force a fake address to ensure we distribute across partitions
-	remoteAddr := fmt.Sprintf("127.0.0.%d", idx)
-
-	req, err := http.NewRequest(http.MethodPost, o.endpoint.String(), bufOut)
-	if err != nil {
-		panic(err)
-	}
-
-	req.Header.Set("X-Forwarded-For", remoteAddr)
-	req.Header.Set("Content-Type", "application/x-protobuf")
-	req.Header.Set("Content-Encoding", "gzip")
-
-	for k, v := range o.customHeaders {
-		req.Header.Set(k, v)
-	}
-
-	resp, err := o.client.Do(req)
-	if err != nil {
-		panic(err)
-	}
-
-	if resp.StatusCode/100 != 2 {
-		body, _ := io.ReadAll(resp.Body)
-		o.log.Error("unexpected status code received", 
-			zap.Int("status", resp.StatusCode),
-			zap.String("body", string(body)))
-		_ = resp.Body.Close()
+	resp := &otlpTraceColl.ExportTraceServiceResponse{}
+	if !o.exp.export(idx, msg, resp) {
 		return
 	}
 
-	_, _ = io.ReadAll(resp.Body)
-	_ = resp.Body.Close()
+	if ps := resp.GetPartialSuccess(); ps != nil && ps.GetRejectedSpans() != 0 {
+		o.log.Warn("export partially rejected",
+			zap.Int64("rejected", ps.GetRejectedSpans()), zap.String("message", ps.GetErrorMessage()))
+		o.statRejected.Incr(uint64(ps.GetRejectedSpans()))
+	}
 
-	o.statBytesSent.Incr(uint64(len(buf)))
-	o.statBytesSentZ.Incr(uint64(compressedLen))
-	o.statBatchesSent.Incr(1)
-	o.statTracesSent.Incr(uint64(o.spansPerResource))
+	o.statTracesSent.Incr(uint64(numSpans))
 }
 
+// buildBatch builds a span per element for each resource. Span attributes are
+// in a fixed order, so output can be compared against golden files: index,
+// the gen_ai attributes in corpus order, the sampling and extra attributes,
+// then the message id attributes.
+// Nothing is built from map iteration.
 func (o *tracesWorker) buildBatch(resources []*otlpRes.Resource, msgIdGen worker.MsgIdGenerator) []*otlpTraces.ResourceSpans {
-	resSpanPtrs := make([]*otlpTraces.ResourceSpans, 0, o.resourcesPerBatch)
-	resSpans := make([]otlpTraces.ResourceSpans, o.resourcesPerBatch)
+	resSpanPtrs := make([]*otlpTraces.ResourceSpans, 0, len(resources))
+	resSpans := make([]otlpTraces.ResourceSpans, len(resources))
+
+	spanCounts := o.spansDistribution.split(len(resources)*o.spansPerResource, len(resources))
 
 	for i, res := range resources {
+		numSpans := spanCounts[i]
+
 		rs := &resSpans[i]
 		rs.Resource = res
 		rs.ScopeSpans = []*otlpTraces.ScopeSpans{
 			{
 				Scope:     o.scope,
-				Spans:     make([]*otlpTraces.Span, 0, o.spansPerResource),
+				Spans:     make([]*otlpTraces.Span, 0, numSpans),
 				SchemaUrl: semconv.SchemaURL,
 			},
 		}
 		rs.SchemaUrl = semconv.SchemaURL
 
 		traceId := o.idGen.OtelId(16)
-		nowNano := time.Now().UnixNano()
+		// Spans of a reused trace continue below its last span
+		var parentSpanId []byte
+		poolSlot := -1
+		if o.tracePool != nil && numSpans > 0 {
+			if trace, slot, ok := o.tracePool.pick(); ok {
+				traceId, parentSpanId, poolSlot = trace.traceId, trace.lastSpanId, slot
+			}
+		}
+		nowNano := o.now().UnixNano()
 
-		spans := make([]otlpTraces.Span, o.spansPerResource)
+		spans := make([]otlpTraces.Span, numSpans)
 
-		for j := 0; j < o.spansPerResource; j++ {
+		for j := 0; j < numSpans; j++ {
 			startTime := nowNano + int64(j)*int64(10_000_000)
 
 			span := &spans[j]
 			span.TraceId = traceId
 			span.TraceState = "active"
 			span.Name = getSpanName(j)
-			span.Kind = otlpTraces.Span_SPAN_KIND_SERVER
+			span.Kind = o.spanKinds.kind(j)
+			endTime := nowNano + int64(numSpans)*int64(10_000_000)
 			span.StartTimeUnixNano = uint64(startTime)
-			span.EndTimeUnixNano = uint64(nowNano + int64(o.spansPerResource)*int64(10_000_000))
+			span.EndTimeUnixNano = uint64(endTime)
 			span.Attributes = []*otlpCommon.KeyValue{
 				{
 					Key:   "index",
-					Value: &otlpCommon.AnyValue{Value: &otlpCommon.AnyValue_IntValue{IntValue: int64(j)}},
+					Value: anyvalue.Int(int64(j)),
 				},
 			}
 
 			// Add gen_ai attributes if corpus is loaded
+			var toolEvents []*otlpTraces.Span_Event
+			refused := false
 			if o.genAICorpus != nil {
-				span.Attributes = append(span.Attributes, o.genAICorpus.GenAIAttributes()...)
+				var genAIAttrs []*otlpCommon.KeyValue
+				genAIAttrs, toolEvents = o.genAICorpus.GenAISpan()
+				span.Attributes = append(span.Attributes, genAIAttrs...)
+				if name, ok := genAISpanName(genAIAttrs); ok {
+					span.Name = name
+				}
+				refused = genai.IsRefusal(genAIAttrs)
+			}
+			if o.sampling != nil {
+				span.Attributes = append(span.Attributes, o.sampling.attrs()...)
+			}
+			if o.spanAttrCard != nil {
+				span.Attributes = append(span.Attributes, o.spanAttrCard.attrs()...)
 			}
 
 			span.DroppedAttributesCount = 0
-			span.Events = make([]*otlpTraces.Span_Event, 0, 1)
+			span.Events = make([]*otlpTraces.Span_Event, 0, o.eventsPerSpan+len(toolEvents))
 			span.DroppedEventsCount = 0
 			span.Links = nil
+			if o.spanLinker != nil {
+				span.Links = o.spanLinker.links(traceId)
+			}
 			span.DroppedLinksCount = 0
 			span.Status = nil
+			if refused {
+				span.Status = &otlpTraces.Status{
+					Code:    otlpTraces.Status_STATUS_CODE_ERROR,
+					Message: genai.FinishReasonContentFilter,
+				}
+			}
 			span.Attributes = msgIdGen.AddElementAttrs(span.Attributes)
 
 			span.SpanId = o.idGen.OtelId(8)
 			if j > 0 {
-				span.ParentSpanId = rs.ScopeSpans[0].Spans[j-1].SpanId
+				span.ParentSpanId = spans[o.treeShape.parent(j, o.treeFanout)].SpanId
+			} else {
+				span.ParentSpanId = parentSpanId
+			}
+
+			if o.eventsPerSpan > 0 {
+				span.Events = append(span.Events, spanEvents(o.eventsPerSpan, j, startTime, endTime)...)
 			}
 
-			event := &otlpTraces.Span_Event{
-				TimeUnixNano:           uint64(startTime + 5_000_000),
-				Name:                   "db-connect",
-				Attributes:             nil,
-				DroppedAttributesCount: 0,
+			// Tool calls follow each other within the span
+			for k, toolEvent := range toolEvents {
+				toolEvent.TimeUnixNano = uint64(startTime + int64(k+1)*100_000)
+				span.Events = append(span.Events, toolEvent)
+			}
+
+			if o.spanErrors != nil {
+				if exc, ok := o.spanErrors.pick(); ok {
+					exc.inject(span, uint64(startTime+8_000_000))
+				}
 			}
-			span.Events = append(span.Events, event)
 
 			rs.ScopeSpans[0].Spans = append(rs.ScopeSpans[0].Spans, span)
 		}
 
+		if o.spanLinker != nil && numSpans > 0 {
+			o.spanLinker.record(traceId, spans[0].SpanId)
+		}
+		if o.tracePool != nil && numSpans > 0 {
+			o.tracePool.record(poolSlot, pooledTrace{traceId: traceId, lastSpanId: spans[numSpans-1].SpanId})
+		}
+
 		resSpanPtrs = append(resSpanPtrs, rs)
 	}
 
@@ -334,3 +452,14 @@ var commonSpanNames = []string{
 func getSpanName(index int) string {
 	return commonSpanNames[index%len(commonSpanNames)]
 }
+
+// genAISpanName returns a gen_ai.<operation> span name from the operation
+// attribute, if present
+func genAISpanName(attrs []*otlpCommon.KeyValue) (string, bool) {
+	for _, attr := range attrs {
+		if attr.Key == "gen_ai.operation.name" {
+			return "gen_ai." + attr.GetValue().GetStringValue(), true
+		}
+	}
+	return "", false
+}
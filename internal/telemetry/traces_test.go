@@ -0,0 +1,739 @@
+package telemetry
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/compression"
+	"github.com/streamfold/otel-loadgen/internal/control"
+	"github.com/streamfold/otel-loadgen/internal/genai"
+	"github.com/streamfold/otel-loadgen/internal/msg_tracker"
+	"github.com/streamfold/otel-loadgen/internal/otlp"
+	"github.com/streamfold/otel-loadgen/internal/sink"
+	"github.com/streamfold/otel-loadgen/internal/stats"
+	"github.com/streamfold/otel-loadgen/internal/worker"
+	otlpTraceColl "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+	otlpRes "go.opentelemetry.io/proto/otlp/resource/v1"
+	otlpTraces "go.opentelemetry.io/proto/otlp/trace/v1"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+func newTestTracesWorker(t *testing.T, cfg TracesConfig) *tracesWorker {
+	t.Helper()
+
+	endpoint, err := url.Parse("http://localhost:4317")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewTracesWorker(zap.NewNop(), ExportConfig{Endpoint: endpoint, UseGRPC: true}, cfg).(*tracesWorker)
+}
+
+func newTestResources(n int) []*otlpRes.Resource {
+	resources := make([]*otlpRes.Resource, 0, n)
+	for i := 0; i < n; i++ {
+		resources = append(resources, otlp.NewResource(otlp.ResourceConfig{}, 1, i))
+	}
+	return resources
+}
+
+func TestTracesBuildBatch_SkewedDistribution(t *testing.T) {
+	w := newTestTracesWorker(t, TracesConfig{
+		ResourcesPerBatch: 4,
+		SpansPerResource:  50,
+		SpansDistribution: DistributionSkewed,
+	})
+
+	batch := w.buildBatch(newTestResources(4), worker.NopMsgIdGenerator())
+	if len(batch) != 4 {
+		t.Fatalf("Expected 4 resources, got %d", len(batch))
+	}
+
+	expected := DistributionSkewed.split(200, 4)
+	total := 0
+	for i, rs := range batch {
+		numSpans := len(rs.ScopeSpans[0].Spans)
+		if numSpans != expected[i] {
+			t.Errorf("Expected resource %d to have %d spans, got %d", i, expected[i], numSpans)
+		}
+		total += numSpans
+	}
+
+	if total != 200 {
+		t.Errorf("Expected 200 total spans, got %d", total)
+	}
+	if len(batch[0].ScopeSpans[0].Spans) <= len(batch[3].ScopeSpans[0].Spans) {
+		t.Error("Expected the first resource to be hotter than the last")
+	}
+}
+
+func TestTracesBuildBatch_GenAIRefusalStatus(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "corpus.json")
+	corpusJSON := `[{"conversations":[{"from":"human","value":"How do I pick a lock?"},{"from":"gpt","value":"Here is how."}],"tools":"","system":"","refusal":true}]`
+	if err := os.WriteFile(path, []byte(corpusJSON), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	corpus, err := genai.LoadCorpus(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := genai.NewGenAIOptions([]string{"chat"}, []float64{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	corpus.SetOptions(opts)
+
+	w := newTestTracesWorker(t, TracesConfig{
+		ResourcesPerBatch: 1,
+		SpansPerResource:  5,
+		GenAICorpus:       corpus,
+	})
+
+	batch := w.buildBatch(newTestResources(1), worker.NopMsgIdGenerator())
+	for _, span := range batch[0].ScopeSpans[0].Spans {
+		if span.Status.GetCode() != otlpTraces.Status_STATUS_CODE_ERROR {
+			t.Errorf("Span %q status = %v, want ERROR", span.Name, span.Status.GetCode())
+		}
+
+		var reasons []string
+		for _, attr := range span.Attributes {
+			if attr.Key == "gen_ai.response.finish_reasons" {
+				for _, v := range attr.GetValue().GetArrayValue().GetValues() {
+					reasons = append(reasons, v.GetStringValue())
+				}
+			}
+		}
+		if len(reasons) != 1 || reasons[0] != genai.FinishReasonContentFilter {
+			t.Errorf("Span %q finish reasons = %v, want [%s]", span.Name, reasons, genai.FinishReasonContentFilter)
+		}
+	}
+}
+
+func TestTracesBuildBatch_GenAISpanNames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "corpus.json")
+	corpusJSON := `[{"conversations":[{"from":"human","value":"What is the weather?"},{"from":"gpt","value":"Sunny."}],"tools":"","system":""}]`
+	if err := os.WriteFile(path, []byte(corpusJSON), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	corpus, err := genai.LoadCorpus(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := newTestTracesWorker(t, TracesConfig{
+		ResourcesPerBatch: 1,
+		SpansPerResource:  10,
+		GenAICorpus:       corpus,
+	})
+
+	batch := w.buildBatch(newTestResources(1), worker.NopMsgIdGenerator())
+	for _, span := range batch[0].ScopeSpans[0].Spans {
+		var op string
+		for _, attr := range span.Attributes {
+			if attr.Key == "gen_ai.operation.name" {
+				op = attr.GetValue().GetStringValue()
+			}
+		}
+		if op == "" {
+			t.Fatalf("Span %q is missing gen_ai.operation.name", span.Name)
+		}
+		if span.Name != "gen_ai."+op {
+			t.Errorf("Expected span name gen_ai.%s, got %q", op, span.Name)
+		}
+	}
+}
+
+func TestTracesBuildBatch_TraceReuseRate(t *testing.T) {
+	const reuseRate = 0.3
+
+	w := newTestTracesWorker(t, TracesConfig{
+		ResourcesPerBatch: 4,
+		SpansPerResource:  2,
+		TraceReuseRate:    reuseRate,
+	})
+
+	seen := make(map[string][]byte)
+	reused, total := 0, 0
+	for i := 0; i < 5000; i++ {
+		for _, rs := range w.buildBatch(newTestResources(4), worker.NopMsgIdGenerator()) {
+			spans := rs.ScopeSpans[0].Spans
+			traceId := string(spans[0].TraceId)
+
+			lastSpanId, ok := seen[traceId]
+			if ok {
+				reused++
+				if !bytes.Equal(spans[0].ParentSpanId, lastSpanId) {
+					t.Fatalf("Expected a reused trace to continue below its last span")
+				}
+			} else if len(spans[0].ParentSpanId) != 0 {
+				t.Fatalf("Expected a new trace to start with a root span")
+			}
+			seen[traceId] = spans[len(spans)-1].SpanId
+			total++
+		}
+	}
+
+	// The first trace can't be reused
+	got := float64(reused) / float64(total-1)
+	if got < reuseRate-0.02 || got > reuseRate+0.02 {
+		t.Errorf("Expected reuse rate ~%.2f, got %.3f", reuseRate, got)
+	}
+}
+
+func TestTracesBuildBatch_NoTraceReuse(t *testing.T) {
+	w := newTestTracesWorker(t, TracesConfig{
+		ResourcesPerBatch: 2,
+		SpansPerResource:  2,
+	})
+
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		for _, rs := range w.buildBatch(newTestResources(2), worker.NopMsgIdGenerator()) {
+			traceId := string(rs.ScopeSpans[0].Spans[0].TraceId)
+			if seen[traceId] {
+				t.Fatalf("Expected a new trace for every resource")
+			}
+			seen[traceId] = true
+		}
+	}
+}
+
+func TestTracesBuildBatch_ErrorRate(t *testing.T) {
+	const errorRate = 0.2
+
+	errorSpans := func(seed int64) []bool {
+		w := newTestTracesWorker(t, TracesConfig{
+			ResourcesPerBatch: 2,
+			SpansPerResource:  50,
+			ErrorRate:         errorRate,
+			ErrorSeed:         seed,
+		})
+
+		var errs []bool
+		for i := 0; i < 100; i++ {
+			for _, rs := range w.buildBatch(newTestResources(2), worker.NopMsgIdGenerator()) {
+				for _, span := range rs.ScopeSpans[0].Spans {
+					isErr := span.Status != nil
+					if isErr {
+						if span.Status.Code != otlpTraces.Status_STATUS_CODE_ERROR || span.Status.Message == "" {
+							t.Fatalf("Unexpected error status: %v", span.Status)
+						}
+
+						last := span.Events[len(span.Events)-1]
+						attrs := make(map[string]string)
+						for _, attr := range last.Attributes {
+							attrs[attr.Key] = attr.GetValue().GetStringValue()
+						}
+						if last.Name != "exception" || attrs["exception.type"] == "" || attrs["exception.message"] != span.Status.Message {
+							t.Fatalf("Unexpected exception event: %v", last)
+						}
+					}
+					errs = append(errs, isErr)
+				}
+			}
+		}
+		return errs
+	}
+
+	first := errorSpans(42)
+	count := 0
+	for _, isErr := range first {
+		if isErr {
+			count++
+		}
+	}
+	got := float64(count) / float64(len(first))
+	if got < errorRate-0.02 || got > errorRate+0.02 {
+		t.Errorf("Expected error rate ~%.2f, got %.3f", errorRate, got)
+	}
+
+	if !reflect.DeepEqual(first, errorSpans(42)) {
+		t.Error("Expected the same seed to pick the same error spans")
+	}
+	if reflect.DeepEqual(first, errorSpans(7)) {
+		t.Error("Expected a different seed to pick different error spans")
+	}
+}
+
+func TestTracesBuildBatch_NoErrors(t *testing.T) {
+	w := newTestTracesWorker(t, TracesConfig{
+		ResourcesPerBatch: 1,
+		SpansPerResource:  100,
+	})
+
+	for _, span := range w.buildBatch(newTestResources(1), worker.NopMsgIdGenerator())[0].ScopeSpans[0].Spans {
+		if span.Status != nil {
+			t.Fatalf("Expected no error spans, got %v", span.Status)
+		}
+	}
+}
+
+func TestTracesBuildBatch_LinksPerSpan(t *testing.T) {
+	w := newTestTracesWorker(t, TracesConfig{
+		ResourcesPerBatch: 2,
+		SpansPerResource:  3,
+		LinksPerSpan:      2,
+	})
+
+	// Spans of the first trace have nothing to link to yet
+	first := w.buildBatch(newTestResources(2), worker.NopMsgIdGenerator())
+	for _, span := range first[0].ScopeSpans[0].Spans {
+		if len(span.Links) != 0 {
+			t.Fatalf("Expected no links before any trace was generated, got %d", len(span.Links))
+		}
+	}
+
+	roots := make(map[string]string)
+	for _, rs := range first {
+		span := rs.ScopeSpans[0].Spans[0]
+		roots[string(span.TraceId)] = string(span.SpanId)
+	}
+
+	for _, rs := range w.buildBatch(newTestResources(2), worker.NopMsgIdGenerator()) {
+		for _, span := range rs.ScopeSpans[0].Spans {
+			if len(span.Links) != 2 {
+				t.Fatalf("Expected 2 links, got %d", len(span.Links))
+			}
+			for _, link := range span.Links {
+				if bytes.Equal(link.TraceId, span.TraceId) {
+					t.Error("Expected links to point at other traces")
+				}
+				if spanId, ok := roots[string(link.TraceId)]; ok && spanId != string(link.SpanId) {
+					t.Error("Expected links to point at generated spans")
+				}
+				if len(link.Attributes) != 1 || link.Attributes[0].Key != "link.type" || link.Attributes[0].GetValue().GetStringValue() == "" {
+					t.Errorf("Expected a link.type attribute, got %v", link.Attributes)
+				}
+			}
+		}
+	}
+}
+
+func TestTracesBuildBatch_NoCorpus(t *testing.T) {
+	w := newTestTracesWorker(t, TracesConfig{
+		ResourcesPerBatch: 1,
+		SpansPerResource:  3,
+	})
+
+	batch := w.buildBatch(newTestResources(1), worker.NopMsgIdGenerator())
+	for j, span := range batch[0].ScopeSpans[0].Spans {
+		if span.Name != getSpanName(j) {
+			t.Errorf("Expected span name %q, got %q", getSpanName(j), span.Name)
+		}
+	}
+}
+
+func TestTracesBuildBatch_BaseTimeReproducible(t *testing.T) {
+	baseTime := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	cfg := TracesConfig{
+		ResourcesPerBatch: 2,
+		SpansPerResource:  10,
+		BaseTime:          baseTime,
+		IDSeed:            1,
+	}
+
+	marshalBatch := func() []byte {
+		w := newTestTracesWorker(t, cfg)
+		batch := w.buildBatch(newTestResources(2), worker.NopMsgIdGenerator())
+		buf, err := proto.MarshalOptions{Deterministic: true}.Marshal(&otlpTraceColl.ExportTraceServiceRequest{ResourceSpans: batch})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return buf
+	}
+
+	first := marshalBatch()
+	second := marshalBatch()
+	if !bytes.Equal(first, second) {
+		t.Error("Expected batches generated with the same base time and id seed to be byte-identical")
+	}
+
+	w := newTestTracesWorker(t, cfg)
+	batch := w.buildBatch(newTestResources(1), worker.NopMsgIdGenerator())
+	if start := batch[0].ScopeSpans[0].Spans[0].StartTimeUnixNano; start != uint64(baseTime.UnixNano()) {
+		t.Errorf("Expected first span to start at the base time, got %d", start)
+	}
+}
+
+func TestTracesPushIt_PartialSuccessCounted(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, _ := proto.Marshal(&otlpTraceColl.ExportTraceServiceResponse{
+			PartialSuccess: &otlpTraceColl.ExportTracePartialSuccess{RejectedSpans: 4, ErrorMessage: "too old"},
+		})
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		_, _ = w.Write(resp)
+	}))
+	defer srv.Close()
+
+	endpoint, _ := url.Parse(srv.URL)
+	w := NewTracesWorker(zap.NewNop(), ExportConfig{Endpoint: endpoint}, TracesConfig{
+		ResourcesPerBatch: 1,
+		SpansPerResource:  10,
+	}).(*tracesWorker)
+
+	sb := newTestStatsBuilder()
+	if err := w.Init(sb, srv.Client()); err != nil {
+		t.Fatal(err)
+	}
+
+	w.pushIt(1, w.buildBatch(newTestResources(1), worker.NopMsgIdGenerator()))
+
+	if sb.value(stats.StatRejected) != 4 {
+		t.Errorf("Expected 4 rejected spans, got %d", sb.value(stats.StatRejected))
+	}
+	if sb.value(stats.StatSpansSent) != 10 {
+		t.Errorf("Expected 10 spans sent, got %d", sb.value(stats.StatSpansSent))
+	}
+}
+
+func TestTracesPushIt_SkipsEmptyBatch(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer srv.Close()
+
+	endpoint, _ := url.Parse(srv.URL)
+	w := NewTracesWorker(zap.NewNop(), ExportConfig{Endpoint: endpoint}, TracesConfig{
+		ResourcesPerBatch: 1,
+		SpansPerResource:  0,
+	}).(*tracesWorker)
+
+	sb := newTestStatsBuilder()
+	if err := w.Init(sb, srv.Client()); err != nil {
+		t.Fatal(err)
+	}
+
+	w.pushIt(1, w.buildBatch(newTestResources(1), worker.NopMsgIdGenerator()))
+
+	if requests.Load() != 0 {
+		t.Errorf("Expected no export requests, got %d", requests.Load())
+	}
+	if sb.value(stats.StatSpansSent) != 0 {
+		t.Errorf("Expected 0 spans sent, got %d", sb.value(stats.StatSpansSent))
+	}
+}
+
+func TestTracesExportGRPC_UnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "otlp.sock")
+
+	mt := msg_tracker.NewTracker(zap.NewNop())
+	s, err := sink.New("unix://"+socket, "", mt, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	endpoint, err := url.Parse("unix://" + socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewTracesWorker(zap.NewNop(), ExportConfig{Endpoint: endpoint, UseGRPC: true}, TracesConfig{
+		ResourcesPerBatch: 2,
+		SpansPerResource:  5,
+	}).(*tracesWorker)
+
+	sb := newTestStatsBuilder()
+	if err := w.Init(sb, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer w.exp.close()
+
+	msgIdGen := worker.NewMsgIdGenerator("gen-unix", worker.ALLOC_SIZE, control.ChanSender(make(chan control.Control, 10)))
+	resources := newTestResources(2)
+	for _, res := range resources {
+		res.Attributes = msgIdGen.AddResourceAttrs(res.Attributes)
+	}
+	w.pushIt(1, w.buildBatch(resources, msgIdGen))
+
+	if sb.value(stats.StatSpansSent) != 10 {
+		t.Fatalf("Expected 10 spans sent over the unix socket, got %d", sb.value(stats.StatSpansSent))
+	}
+	report, ok := mt.GeneratorReport(time.Now())["gen-unix"]
+	if !ok || report.TotalAcked != 10 {
+		t.Errorf("Expected the sink to ack 10 spans, got %+v", report)
+	}
+}
+
+func TestTracesPartitionAttr_PerWorker(t *testing.T) {
+	var mu sync.Mutex
+	// partitions seen per X-Forwarded-For address
+	partitions := make(map[string]map[int64]bool)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		req := &otlpTraceColl.ExportTraceServiceRequest{}
+		if err := proto.Unmarshal(body, req); err != nil {
+			t.Error(err)
+		}
+
+		mu.Lock()
+		addr := r.Header.Get("X-Forwarded-For")
+		if partitions[addr] == nil {
+			partitions[addr] = make(map[int64]bool)
+		}
+		for _, rs := range req.ResourceSpans {
+			value := findAttrValue(rs.Resource.Attributes, partitionAttrKey)
+			if value == nil {
+				t.Errorf("Expected %s resource attribute", partitionAttrKey)
+				continue
+			}
+			partitions[addr][value.GetIntValue()] = true
+		}
+		mu.Unlock()
+
+		out, _ := proto.Marshal(&otlpTraceColl.ExportTraceServiceResponse{})
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		_, _ = w.Write(out)
+	}))
+	defer srv.Close()
+
+	endpoint, _ := url.Parse(srv.URL)
+	traces := NewTracesWorker(zap.NewNop(), ExportConfig{Endpoint: endpoint, Compression: compression.None}, TracesConfig{
+		ResourcesPerBatch: 2,
+		SpansPerResource:  3,
+		PartitionAttr:     true,
+	})
+	if err := traces.Init(newTestStatsBuilder(), srv.Client()); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		traces.Start(5*time.Millisecond, worker.NopMsgIdGenerator())
+	}
+	time.Sleep(100 * time.Millisecond)
+	traces.StopAll()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(partitions) != 3 {
+		t.Fatalf("Expected exports from 3 workers, got %d", len(partitions))
+	}
+	for addr, seen := range partitions {
+		if len(seen) != 1 {
+			t.Errorf("Expected a single partition for %s, got %v", addr, seen)
+			continue
+		}
+		for partition := range seen {
+			if want := fmt.Sprintf("127.0.0.%d", partition); addr != want {
+				t.Errorf("Expected partition %d to be exported from %s, got %s", partition, want, addr)
+			}
+		}
+	}
+}
+
+func TestTracesResourceConfig_GeneratorIdLast(t *testing.T) {
+	exported := make(chan *otlpTraceColl.ExportTraceServiceRequest, 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		req := &otlpTraceColl.ExportTraceServiceRequest{}
+		if err := proto.Unmarshal(body, req); err != nil {
+			t.Error(err)
+		}
+		select {
+		case exported <- req:
+		default:
+		}
+	}))
+	defer srv.Close()
+
+	attrs, err := otlp.ParseAttrs([]string{"tenant.id=7:int"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint, _ := url.Parse(srv.URL)
+	traces := NewTracesWorker(zap.NewNop(), ExportConfig{Endpoint: endpoint, Compression: compression.None}, TracesConfig{
+		ResourcesPerBatch: 2,
+		SpansPerResource:  1,
+		Resource:          otlp.ResourceConfig{ServiceName: "checkout", Attributes: attrs},
+	})
+	if err := traces.Init(newTestStatsBuilder(), srv.Client()); err != nil {
+		t.Fatal(err)
+	}
+	traces.Start(5*time.Millisecond, worker.NewMsgIdGenerator("gen-1", worker.ALLOC_SIZE, control.ChanSender(make(chan control.Control, 100))))
+	req := <-exported
+	traces.StopAll()
+
+	for _, rs := range req.ResourceSpans {
+		keys := make(map[string]int)
+		for i, kv := range rs.Resource.Attributes {
+			keys[kv.Key] = i
+		}
+		if v := findAttrValue(rs.Resource.Attributes, "service.name"); v.GetStringValue() != "checkout" {
+			t.Errorf("Expected service.name checkout, got %v", v)
+		}
+		tenant, ok := keys["tenant.id"]
+		if !ok {
+			t.Fatal("Expected the tenant.id resource attribute")
+		}
+		if gen, ok := keys[worker.RES_ATTR_GENERATOR_ID]; !ok || gen < tenant {
+			t.Errorf("Expected the generator id after the user attributes, got %v", keys)
+		}
+	}
+}
+
+// attrKeyOrder flattens the attribute keys, including those nested in kvlists
+// and arrays, in the order they are emitted
+func attrKeyOrder(attrs []*otlpCommon.KeyValue) []string {
+	var keys []string
+	var walk func(prefix string, v *otlpCommon.AnyValue)
+	walk = func(prefix string, v *otlpCommon.AnyValue) {
+		for _, kv := range v.GetKvlistValue().GetValues() {
+			keys = append(keys, prefix+"."+kv.Key)
+			walk(prefix+"."+kv.Key, kv.Value)
+		}
+		for i, elem := range v.GetArrayValue().GetValues() {
+			walk(fmt.Sprintf("%s[%d]", prefix, i), elem)
+		}
+	}
+	for _, kv := range attrs {
+		keys = append(keys, kv.Key)
+		walk(kv.Key, kv.Value)
+	}
+	return keys
+}
+
+func TestTracesBuildBatch_AttributeOrderStable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "corpus.json")
+	corpusJSON := `[{"system":"Be brief.","tools":"[{\"name\":\"get_weather\",\"description\":\"Weather\",\"parameters\":{\"type\":\"object\",\"properties\":{\"city\":{\"type\":\"string\"},\"unit\":{\"type\":\"string\"}}}}]",
+		"conversations":[{"from":"human","value":"Weather in Paris?"},{"from":"function_call","value":"{\"name\":\"get_weather\",\"arguments\":{\"unit\":\"c\",\"city\":\"Paris\"}}"},{"from":"observation","value":"{\"temp\":20}"},{"from":"gpt","value":"20C."}]}]`
+	if err := os.WriteFile(path, []byte(corpusJSON), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	build := func() []*otlpTraces.ResourceSpans {
+		corpus, err := genai.LoadCorpus(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		kinds, _ := ParseSpanKinds("server:1,client:1", 3)
+		w := newTestTracesWorker(t, TracesConfig{
+			ResourcesPerBatch: 2,
+			SpansPerResource:  20,
+			GenAICorpus:       corpus,
+			ErrorRate:         0.5,
+			ErrorSeed:         3,
+			SpanKinds:         kinds,
+		})
+
+		msgIdGen := worker.NewMsgIdGenerator("gen-1", worker.ALLOC_SIZE, control.ChanSender(make(chan control.Control, 10)))
+		resources := newTestResources(2)
+		for _, res := range resources {
+			res.Attributes = msgIdGen.AddResourceAttrs(res.Attributes)
+		}
+		return w.buildBatch(resources, msgIdGen)
+	}
+
+	first, second := build(), build()
+	compared := 0
+	for i := range first {
+		if a, b := attrKeyOrder(first[i].Resource.Attributes), attrKeyOrder(second[i].Resource.Attributes); !reflect.DeepEqual(a, b) {
+			t.Errorf("Expected stable resource attribute order, got %v and %v", a, b)
+		}
+
+		spansA, spansB := first[i].ScopeSpans[0].Spans, second[i].ScopeSpans[0].Spans
+		for j := range spansA {
+			a, b := spansA[j], spansB[j]
+			keysA, keysB := attrKeyOrder(a.Attributes), attrKeyOrder(b.Attributes)
+			if keysA[0] != "index" || !reflect.DeepEqual(keysA[len(keysA)-3:], []string{
+				string(worker.ELEM_ATTR_START_RANGE), string(worker.ELEM_ATTR_RANGE_LEN), string(worker.ELEM_ATTR_MESSAGE_ID),
+			}) {
+				t.Errorf("Expected index first and the message id attributes last, got %v", keysA)
+			}
+
+			// The gen_ai operation is sampled, spans of the same operation
+			// must have the same attributes in the same order
+			if a.Name != b.Name {
+				continue
+			}
+			compared++
+			if !reflect.DeepEqual(keysA, keysB) {
+				t.Errorf("Expected stable attribute order for %s, got %v and %v", a.Name, keysA, keysB)
+			}
+			for k := range a.Events {
+				if ka, kb := attrKeyOrder(a.Events[k].Attributes), attrKeyOrder(b.Events[k].Attributes); !reflect.DeepEqual(ka, kb) {
+					t.Errorf("Expected stable event attribute order, got %v and %v", ka, kb)
+				}
+			}
+		}
+	}
+	if compared == 0 {
+		t.Fatal("Expected spans of the same operation to compare")
+	}
+}
+
+func TestTracesBuildBatch_IDSeed(t *testing.T) {
+	traceIds := func(seed int64) []byte {
+		w := newTestTracesWorker(t, TracesConfig{ResourcesPerBatch: 1, SpansPerResource: 1, IDSeed: seed})
+		return w.buildBatch(newTestResources(1), worker.NopMsgIdGenerator())[0].ScopeSpans[0].Spans[0].TraceId
+	}
+
+	if !bytes.Equal(traceIds(7), traceIds(7)) {
+		t.Error("Expected the same --id-seed to reproduce trace ids")
+	}
+	if bytes.Equal(traceIds(0), traceIds(0)) {
+		t.Error("Expected unseeded workers to generate different trace ids")
+	}
+}
+
+// Run with -race, the pushers of a worker build batches concurrently from the
+// shared id generator, trace pool and span linker
+func TestTracesBuildBatch_ConcurrentPushers(t *testing.T) {
+	w := newTestTracesWorker(t, TracesConfig{
+		ResourcesPerBatch: 2,
+		SpansPerResource:  5,
+		TraceReuseRate:    0.2,
+		LinksPerSpan:      1,
+		ErrorRate:         0.1,
+	})
+
+	const pushers, batches = 8, 200
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+
+	var wg sync.WaitGroup
+	for p := 0; p < pushers; p++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < batches; i++ {
+				for _, rs := range w.buildBatch(newTestResources(2), worker.NopMsgIdGenerator()) {
+					for _, span := range rs.ScopeSpans[0].Spans {
+						if len(span.SpanId) != 8 || bytes.Equal(span.SpanId, make([]byte, 8)) {
+							t.Errorf("Invalid span id %x", span.SpanId)
+						}
+						mu.Lock()
+						if seen[string(span.SpanId)] {
+							t.Errorf("Duplicate span id %x", span.SpanId)
+						}
+						seen[string(span.SpanId)] = true
+						mu.Unlock()
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) != pushers*batches*10 {
+		t.Errorf("Expected %d distinct span ids, got %d", pushers*batches*10, len(seen))
+	}
+}
@@ -0,0 +1,58 @@
+package telemetry
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// TreeShape determines how the spans of a trace are parented
+type TreeShape int
+
+const (
+	// TreeShapeChain parents each span to the previous span
+	TreeShapeChain TreeShape = iota
+	// TreeShapeRandom parents each span to a random earlier span
+	TreeShapeRandom
+	// TreeShapeFanout builds a tree where the root and every span below it
+	// have up to fanout children, filled breadth first
+	TreeShapeFanout
+)
+
+func (s TreeShape) String() string {
+	switch s {
+	case TreeShapeChain:
+		return "chain"
+	case TreeShapeRandom:
+		return "random"
+	case TreeShapeFanout:
+		return "fanout"
+	default:
+		return "unknown"
+	}
+}
+
+func ParseTreeShape(s string) (TreeShape, error) {
+	switch s {
+	case "chain":
+		return TreeShapeChain, nil
+	case "random":
+		return TreeShapeRandom, nil
+	case "fanout":
+		return TreeShapeFanout, nil
+	default:
+		return 0, fmt.Errorf("invalid tree shape: %q (expected chain, random or fanout)", s)
+	}
+}
+
+// parent returns the index of the parent of span j > 0, which is always an
+// earlier span so parents start before their children
+func (s TreeShape) parent(j int, fanout int) int {
+	switch s {
+	case TreeShapeRandom:
+		return rand.Intn(j)
+	case TreeShapeFanout:
+		return (j - 1) / max(fanout, 1)
+	default:
+		return j - 1
+	}
+}
@@ -0,0 +1,73 @@
+package telemetry
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/streamfold/otel-loadgen/internal/worker"
+)
+
+func TestParseTreeShape(t *testing.T) {
+	for _, shape := range []TreeShape{TreeShapeChain, TreeShapeRandom, TreeShapeFanout} {
+		got, err := ParseTreeShape(shape.String())
+		if err != nil || got != shape {
+			t.Errorf("Expected %v to round trip, got %v (%v)", shape, got, err)
+		}
+	}
+	if _, err := ParseTreeShape("star"); err == nil {
+		t.Error("Expected error for an unknown tree shape")
+	}
+}
+
+func TestTreeShape_Parent(t *testing.T) {
+	for j := 1; j < 100; j++ {
+		if p := TreeShapeChain.parent(j, 3); p != j-1 {
+			t.Errorf("Expected chain parent of %d to be %d, got %d", j, j-1, p)
+		}
+		if p := TreeShapeRandom.parent(j, 3); p < 0 || p >= j {
+			t.Errorf("Expected random parent of %d to be an earlier span, got %d", j, p)
+		}
+	}
+
+	// The root has three children, each of which has three children
+	children := make(map[int]int)
+	for j := 1; j <= 12; j++ {
+		children[TreeShapeFanout.parent(j, 3)]++
+	}
+	for p := 0; p <= 3; p++ {
+		if children[p] != 3 {
+			t.Errorf("Expected span %d to have 3 children, got %d", p, children[p])
+		}
+	}
+}
+
+func TestTracesBuildBatch_TreeShape(t *testing.T) {
+	for _, shape := range []TreeShape{TreeShapeChain, TreeShapeRandom, TreeShapeFanout} {
+		t.Run(shape.String(), func(t *testing.T) {
+			w := newTestTracesWorker(t, TracesConfig{
+				ResourcesPerBatch: 2,
+				SpansPerResource:  20,
+				TreeShape:         shape,
+				TreeFanout:        3,
+			})
+
+			for _, rs := range w.buildBatch(newTestResources(2), worker.NopMsgIdGenerator()) {
+				spans := rs.ScopeSpans[0].Spans
+				if len(spans[0].ParentSpanId) != 0 {
+					t.Error("Expected the root span to have no parent")
+				}
+
+				ids := make(map[string]bool)
+				for j, span := range spans {
+					if !bytes.Equal(span.TraceId, spans[0].TraceId) {
+						t.Errorf("Expected span %d to share the trace id", j)
+					}
+					if j > 0 && !ids[string(span.ParentSpanId)] {
+						t.Errorf("Expected span %d to be parented to an earlier span", j)
+					}
+					ids[string(span.SpanId)] = true
+				}
+			}
+		})
+	}
+}
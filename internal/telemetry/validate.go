@@ -0,0 +1,82 @@
+package telemetry
+
+import (
+	"errors"
+	"fmt"
+
+	otlpTraces "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+const (
+	traceIdLen = 16
+	spanIdLen  = 8
+)
+
+// validateSpan checks the span fields a collector expects to be well formed
+func validateSpan(span *otlpTraces.Span) error {
+	if err := validateId("trace_id", span.TraceId, traceIdLen); err != nil {
+		return err
+	}
+	if err := validateId("span_id", span.SpanId, spanIdLen); err != nil {
+		return err
+	}
+	if len(span.ParentSpanId) != 0 {
+		if err := validateId("parent_span_id", span.ParentSpanId, spanIdLen); err != nil {
+			return err
+		}
+	}
+	if span.Name == "" {
+		return errors.New("span name is empty")
+	}
+	if span.Kind == otlpTraces.Span_SPAN_KIND_UNSPECIFIED {
+		return errors.New("span kind is unspecified")
+	}
+	if span.StartTimeUnixNano == 0 {
+		return errors.New("start time is not set")
+	}
+	if span.EndTimeUnixNano < span.StartTimeUnixNano {
+		return fmt.Errorf("end time %d is before start time %d", span.EndTimeUnixNano, span.StartTimeUnixNano)
+	}
+	return nil
+}
+
+func validateId(field string, id []byte, expectedLen int) error {
+	if len(id) != expectedLen {
+		return fmt.Errorf("%s has length %d, expected %d", field, len(id), expectedLen)
+	}
+	for _, b := range id {
+		if b != 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is all zeros", field)
+}
+
+// validateTraces validates every span of the batch and returns the number of
+// invalid spans along with the first validation error. When drop is set the
+// invalid spans are removed from the batch.
+func validateTraces(batch []*otlpTraces.ResourceSpans, drop bool) (int, error) {
+	invalid := 0
+	var firstErr error
+
+	for _, rs := range batch {
+		for _, ss := range rs.ScopeSpans {
+			valid := ss.Spans[:0]
+			for _, span := range ss.Spans {
+				if err := validateSpan(span); err != nil {
+					invalid++
+					if firstErr == nil {
+						firstErr = err
+					}
+					if drop {
+						continue
+					}
+				}
+				valid = append(valid, span)
+			}
+			ss.Spans = valid
+		}
+	}
+
+	return invalid, firstErr
+}
@@ -0,0 +1,92 @@
+package telemetry
+
+import (
+	"testing"
+
+	"github.com/streamfold/otel-loadgen/internal/worker"
+	otlpTraces "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func validSpan() *otlpTraces.Span {
+	return &otlpTraces.Span{
+		TraceId:           []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		SpanId:            []byte{1, 2, 3, 4, 5, 6, 7, 8},
+		Name:              "http_request",
+		Kind:              otlpTraces.Span_SPAN_KIND_SERVER,
+		StartTimeUnixNano: 1000,
+		EndTimeUnixNano:   2000,
+	}
+}
+
+func TestValidateSpan(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(span *otlpTraces.Span)
+		wantErr bool
+	}{
+		{"valid", func(span *otlpTraces.Span) {}, false},
+		{"zero trace id", func(span *otlpTraces.Span) { span.TraceId = make([]byte, 16) }, true},
+		{"short trace id", func(span *otlpTraces.Span) { span.TraceId = []byte{1} }, true},
+		{"zero span id", func(span *otlpTraces.Span) { span.SpanId = make([]byte, 8) }, true},
+		{"missing span id", func(span *otlpTraces.Span) { span.SpanId = nil }, true},
+		{"zero parent span id", func(span *otlpTraces.Span) { span.ParentSpanId = make([]byte, 8) }, true},
+		{"valid parent span id", func(span *otlpTraces.Span) { span.ParentSpanId = []byte{8, 7, 6, 5, 4, 3, 2, 1} }, false},
+		{"empty name", func(span *otlpTraces.Span) { span.Name = "" }, true},
+		{"unspecified kind", func(span *otlpTraces.Span) { span.Kind = otlpTraces.Span_SPAN_KIND_UNSPECIFIED }, true},
+		{"missing start time", func(span *otlpTraces.Span) { span.StartTimeUnixNano = 0 }, true},
+		{"end before start", func(span *otlpTraces.Span) { span.EndTimeUnixNano = 500 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			span := validSpan()
+			tt.mutate(span)
+
+			err := validateSpan(span)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateSpan() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateTraces_Drop(t *testing.T) {
+	bad := validSpan()
+	bad.SpanId = make([]byte, 8)
+
+	newBatch := func() []*otlpTraces.ResourceSpans {
+		return []*otlpTraces.ResourceSpans{
+			{ScopeSpans: []*otlpTraces.ScopeSpans{{Spans: []*otlpTraces.Span{validSpan(), bad, validSpan()}}}},
+		}
+	}
+
+	batch := newBatch()
+	invalid, err := validateTraces(batch, false)
+	if invalid != 1 || err == nil {
+		t.Fatalf("Expected 1 invalid span with an error, got %d (%v)", invalid, err)
+	}
+	if len(batch[0].ScopeSpans[0].Spans) != 3 {
+		t.Errorf("Expected invalid span to be kept, got %d spans", len(batch[0].ScopeSpans[0].Spans))
+	}
+
+	batch = newBatch()
+	invalid, _ = validateTraces(batch, true)
+	if invalid != 1 {
+		t.Fatalf("Expected 1 invalid span, got %d", invalid)
+	}
+	if len(batch[0].ScopeSpans[0].Spans) != 2 {
+		t.Errorf("Expected invalid span to be dropped, got %d spans", len(batch[0].ScopeSpans[0].Spans))
+	}
+}
+
+func TestValidateTraces_GeneratedBatch(t *testing.T) {
+	w := newTestTracesWorker(t, TracesConfig{
+		ResourcesPerBatch: 2,
+		SpansPerResource:  20,
+	})
+
+	batch := w.buildBatch(newTestResources(2), worker.NopMsgIdGenerator())
+	if invalid, err := validateTraces(batch, false); invalid != 0 {
+		t.Errorf("Expected generated batch to be valid, got %d invalid spans: %v", invalid, err)
+	}
+}
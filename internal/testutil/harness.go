@@ -0,0 +1,146 @@
+// Package testutil wires a sink, control server and load generator together
+// in-process for end-to-end tests
+package testutil
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/control"
+	"github.com/streamfold/otel-loadgen/internal/msg_tracker"
+	"github.com/streamfold/otel-loadgen/internal/sink"
+	"github.com/streamfold/otel-loadgen/internal/summary"
+	"github.com/streamfold/otel-loadgen/internal/telemetry"
+	"github.com/streamfold/otel-loadgen/internal/worker"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+// Sink is an OTLP gRPC sink and its control server, listening on localhost
+// ports picked by the kernel
+type Sink struct {
+	// Endpoint is the OTLP gRPC endpoint, e.g. http://localhost:41234
+	Endpoint string
+	// ControlEndpoint is the control server URL generators register ranges with
+	ControlEndpoint string
+	// Tracker records the messages received by the sink
+	Tracker *msg_tracker.Tracker
+
+	sink    *sink.Sink
+	control *control.Server
+}
+
+// StartInProcessSink starts a sink and control server sharing a tracker, both
+// are stopped when the test finishes
+func StartInProcessSink(t testing.TB) *Sink {
+	t.Helper()
+
+	zl := zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel))
+	mt := msg_tracker.NewTracker(zl)
+
+	// Reporting is left to the test, it only has to outlive the test
+	c := control.New("localhost:0", mt, time.Hour, zl)
+	if err := c.Start(); err != nil {
+		t.Fatalf("failed to start control server: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Stop() })
+
+	s, err := sink.New("localhost:0", "", mt, zl)
+	if err != nil {
+		t.Fatalf("failed to create sink: %v", err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatalf("failed to start sink: %v", err)
+	}
+	t.Cleanup(s.Stop)
+
+	return &Sink{
+		Endpoint:        s.Addr(),
+		ControlEndpoint: "http://" + c.Addr(),
+		Tracker:         mt,
+		sink:            s,
+		control:         c,
+	}
+}
+
+// GeneratorConfig configures RunGenerator, each signal with a config is
+// generated
+type GeneratorConfig struct {
+	// Sink receives the telemetry and message ranges
+	Sink *Sink
+	// Traces, Metrics and Logs configure the signals to generate
+	Traces  *telemetry.TracesConfig
+	Metrics *telemetry.MetricsConfig
+	Logs    *telemetry.LogsConfig
+	// Workers is the number of pushers per signal, default 1
+	Workers int
+	// PushInterval is the delay between batches of each pusher, default 10ms
+	PushInterval time.Duration
+	// Duration is how long the generator runs for, default 500ms
+	Duration time.Duration
+	// GeneratorIDPrefix, if set, names generators <prefix>-<index>
+	GeneratorIDPrefix string
+}
+
+// RunGenerator runs a load generator against the sink for the configured
+// duration and returns its run summary. Message ranges are flushed to the
+// control server before it returns.
+func RunGenerator(t testing.TB, cfg GeneratorConfig) summary.Summary {
+	t.Helper()
+
+	if cfg.Sink == nil {
+		t.Fatal("generator config has no sink")
+	}
+	if cfg.Workers == 0 {
+		cfg.Workers = 1
+	}
+	if cfg.PushInterval == 0 {
+		cfg.PushInterval = 10 * time.Millisecond
+	}
+	if cfg.Duration == 0 {
+		cfg.Duration = 500 * time.Millisecond
+	}
+
+	zl := zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel))
+
+	endpoint, err := url.Parse(cfg.Sink.Endpoint)
+	if err != nil {
+		t.Fatalf("invalid sink endpoint: %v", err)
+	}
+	exportCfg := telemetry.ExportConfig{Endpoint: endpoint, UseGRPC: true}
+
+	workers, err := worker.New(worker.Config{
+		NumWorkers:        cfg.Workers,
+		ReportInterval:    time.Hour,
+		PushInterval:      cfg.PushInterval,
+		ControlEndpoint:   cfg.Sink.ControlEndpoint,
+		ControlPolicy:     worker.ControlPolicyRequired,
+		GeneratorIDPrefix: cfg.GeneratorIDPrefix,
+	}, zl, nil)
+	if err != nil {
+		t.Fatalf("failed to create workers: %v", err)
+	}
+
+	if cfg.Traces != nil {
+		if err := workers.Add("OTLP Traces", telemetry.NewTracesWorker(zl, exportCfg, *cfg.Traces)); err != nil {
+			t.Fatalf("failed to add traces worker: %v", err)
+		}
+	}
+	if cfg.Metrics != nil {
+		if err := workers.Add("OTLP Metrics", telemetry.NewMetricsWorker(zl, exportCfg, *cfg.Metrics)); err != nil {
+			t.Fatalf("failed to add metrics worker: %v", err)
+		}
+	}
+	if cfg.Logs != nil {
+		if err := workers.Add("OTLP Logs", telemetry.NewLogsWorker(zl, exportCfg, *cfg.Logs)); err != nil {
+			t.Fatalf("failed to add logs worker: %v", err)
+		}
+	}
+
+	workers.Start()
+	time.Sleep(cfg.Duration)
+	workers.Stop()
+
+	return workers.Summary(time.Now())
+}
@@ -0,0 +1,106 @@
+package testutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/telemetry"
+	"github.com/streamfold/otel-loadgen/internal/worker"
+)
+
+func TestRoundTrip_ZeroLoss(t *testing.T) {
+	s := StartInProcessSink(t)
+
+	sum := RunGenerator(t, GeneratorConfig{
+		Sink:    s,
+		Traces:  &telemetry.TracesConfig{ResourcesPerBatch: 2, SpansPerResource: 10},
+		Logs:    &telemetry.LogsConfig{ResourcesPerBatch: 1, LogsPerResource: 5},
+		Workers: 2,
+	})
+
+	spans := sum.Signals["OTLP Traces"].Totals["spans_sent"]
+	logs := sum.Signals["OTLP Logs"].Totals["logs_sent"]
+	if spans == 0 || logs == 0 {
+		t.Fatalf("Expected spans and logs to be sent, got %d spans and %d logs", spans, logs)
+	}
+
+	if acked := s.Tracker.TotalAcked(); acked != spans+logs {
+		t.Errorf("Expected %d messages acked, got %d", spans+logs, acked)
+	}
+
+	reports := s.Tracker.GeneratorReport(time.Now())
+	if len(reports) != 4 {
+		t.Errorf("Expected a generator per pusher, got %d", len(reports))
+	}
+	for id, report := range reports {
+		if report.Unacked != 0 || report.TotalDuped != 0 || report.LikelyLost != 0 {
+			t.Errorf("Generator %s lost messages: %+v", id, report)
+		}
+	}
+}
+
+func TestRoundTrip_TruncatedFinalRange(t *testing.T) {
+	s := StartInProcessSink(t)
+
+	// Far fewer spans are sent than a range holds, so the generator stops
+	// part way through its first range
+	sum := RunGenerator(t, GeneratorConfig{
+		Sink:     s,
+		Traces:   &telemetry.TracesConfig{ResourcesPerBatch: 1, SpansPerResource: 2},
+		Duration: 100 * time.Millisecond,
+	})
+
+	spans := sum.Signals["OTLP Traces"].Totals["spans_sent"]
+	if spans == 0 || spans >= worker.ALLOC_SIZE {
+		t.Fatalf("Expected part of a range to be sent, got %d spans", spans)
+	}
+
+	reports := s.Tracker.GeneratorReport(time.Now())
+	if len(reports) != 1 {
+		t.Fatalf("Expected a single generator, got %d", len(reports))
+	}
+	for id, report := range reports {
+		if report.Unacked != 0 {
+			t.Errorf("Expected no unacked messages, got %d", report.Unacked)
+		}
+
+		ranges := s.Tracker.Ranges(id)
+		if len(ranges) != 1 {
+			t.Fatalf("Expected a single range, got %d", len(ranges))
+		}
+		if uint64(ranges[0].RangeLen) != spans {
+			t.Errorf("Expected the final range to be truncated to %d, got %d", spans, ranges[0].RangeLen)
+		}
+	}
+}
+
+func TestRoundTrip_PrefixedRerun(t *testing.T) {
+	s := StartInProcessSink(t)
+
+	// Both runs name their generator bench-0, the second must not reuse the
+	// message IDs of the first
+	cfg := GeneratorConfig{
+		Sink:              s,
+		Traces:            &telemetry.TracesConfig{ResourcesPerBatch: 1, SpansPerResource: 10},
+		Duration:          100 * time.Millisecond,
+		GeneratorIDPrefix: "bench",
+	}
+	first := RunGenerator(t, cfg).Signals["OTLP Traces"].Totals["spans_sent"]
+	second := RunGenerator(t, cfg).Signals["OTLP Traces"].Totals["spans_sent"]
+	if first == 0 || second == 0 {
+		t.Fatalf("Expected spans to be sent by both runs, got %d and %d", first, second)
+	}
+
+	if acked := s.Tracker.TotalAcked(); acked != first+second {
+		t.Errorf("Expected %d messages acked, got %d", first+second, acked)
+	}
+
+	reports := s.Tracker.GeneratorReport(time.Now())
+	report, ok := reports["bench-0"]
+	if !ok || len(reports) != 1 {
+		t.Fatalf("Expected a single bench-0 generator, got %d", len(reports))
+	}
+	if report.Unacked != 0 || report.TotalDuped != 0 {
+		t.Errorf("Expected no unacked or duplicate messages, got %+v", report)
+	}
+}
@@ -1,34 +1,55 @@
 package util
 
 import (
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
 	"math/rand/v2"
 	"fmt"
+	"sync"
 )
 
+// ByteGen generates IDs, it is safe for concurrent use by the pushers of a
+// worker
 type ByteGen struct {
-	g *rand.ChaCha8
+	mu sync.Mutex
+	g  *rand.ChaCha8
 }
 
+// NewByteGen returns a generator with a random seed, so IDs of separate runs
+// and workers don't overlap. Use NewByteGenSeed for reproducible IDs.
 func NewByteGen() *ByteGen {
-	// Use a fixed seed
-	seed := [32]byte{
-		0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef,
-		0xfe, 0xdc, 0xba, 0x98, 0x76, 0x54, 0x32, 0x10,
-		0x0f, 0x1e, 0x2d, 0x3c, 0x4b, 0x5a, 0x69, 0x78,
-		0x87, 0x96, 0xa5, 0xb4, 0xc3, 0xd2, 0xe1, 0xf0,
+	var seed [32]byte
+	if _, err := crand.Read(seed[:]); err != nil {
+		panic(fmt.Errorf("failed to seed id generator: %w", err))
 	}
 
+	return NewByteGenSeed(seed)
+}
+
+// NewByteGenSeed returns a generator producing the same IDs for the same seed
+func NewByteGenSeed(seed [32]byte) *ByteGen {
 	return &ByteGen{
 		g: rand.NewChaCha8(seed),
 	}
 }
 
+// IdSeed expands a numeric seed, e.g. from a flag, to a generator seed
+func IdSeed(seed int64) [32]byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(seed))
+	return sha256.Sum256(buf[:])
+}
+
 // Generate an ID to represent either a trace, span or parent ID
 func (b *ByteGen) OtelId(numBytes uint) []byte {
 	byteSlice := make([]byte, numBytes)
 	
-	// Read random bytes from crypto/rand
+	// ChaCha8 isn't safe for concurrent use, a torn read can return
+	// repeated or partly zeroed IDs
+	b.mu.Lock()
 	_, err := b.g.Read(byteSlice)
+	b.mu.Unlock()
 	if err != nil {
 		panic(fmt.Errorf("failed to generate random bytes: %w", err))
 	}
@@ -0,0 +1,59 @@
+package util
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestByteGen_Seeded(t *testing.T) {
+	a, b := NewByteGenSeed(IdSeed(42)), NewByteGenSeed(IdSeed(42))
+	for i := 0; i < 100; i++ {
+		if !bytes.Equal(a.OtelId(16), b.OtelId(16)) {
+			t.Fatal("Expected generators with the same seed to produce the same ids")
+		}
+	}
+
+	if bytes.Equal(NewByteGenSeed(IdSeed(1)).OtelId(16), NewByteGenSeed(IdSeed(2)).OtelId(16)) {
+		t.Error("Expected different seeds to produce different ids")
+	}
+}
+
+func TestByteGen_RandomSeed(t *testing.T) {
+	// Separate runs must not repeat the same id sequence
+	if bytes.Equal(NewByteGen().OtelId(16), NewByteGen().OtelId(16)) {
+		t.Error("Expected randomly seeded generators to produce different ids")
+	}
+}
+
+func TestByteGen_Concurrent(t *testing.T) {
+	const goroutines, perGoroutine = 16, 2000
+
+	g := NewByteGen()
+	ids := make([][][]byte, goroutines)
+
+	var wg sync.WaitGroup
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < perGoroutine; j++ {
+				ids[i] = append(ids[i], g.OtelId(16))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool, goroutines*perGoroutine)
+	for _, batch := range ids {
+		for _, id := range batch {
+			if seen[string(id)] {
+				t.Fatalf("Duplicate id %x generated concurrently", id)
+			}
+			if bytes.Equal(id, make([]byte, len(id))) {
+				t.Fatal("Expected no all zero ids")
+			}
+			seen[string(id)] = true
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var envRefPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// ExpandEnvRefs replaces ${NAME} references with the value of the environment
+// variable NAME. Unlike os.ExpandEnv a bare $NAME is left as is, and referencing
+// an unset variable is an error so a missing secret isn't sent as an empty value.
+func ExpandEnvRefs(s string) (string, error) {
+	var missing []string
+
+	expanded := envRefPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		name := envRefPattern.FindStringSubmatch(ref)[1]
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return value
+	})
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable not set: %s", strings.Join(missing, ", "))
+	}
+
+	return expanded, nil
+}
+
+// ParseHeaders parses headers in the format 'Key=Value', expanding ${NAME}
+// environment references in the values
+func ParseHeaders(values []string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, h := range values {
+		parts := strings.SplitN(h, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid header format: %q (expected 'Key=Value')", h)
+		}
+
+		value, err := ExpandEnvRefs(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid header %q: %w", parts[0], err)
+		}
+		headers[parts[0]] = value
+	}
+	return headers, nil
+}
@@ -0,0 +1,41 @@
+package util
+
+import (
+	"testing"
+)
+
+func TestParseHeaders(t *testing.T) {
+	t.Setenv("OTLP_TEST_TOKEN", "s3cret")
+
+	headers, err := ParseHeaders([]string{
+		"Authorization=Bearer ${OTLP_TEST_TOKEN}",
+		"X-Api-Key=${OTLP_TEST_TOKEN}",
+		"X-Literal=$OTLP_TEST_TOKEN",
+		"X-Equals=a=b",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"Authorization": "Bearer s3cret",
+		"X-Api-Key":     "s3cret",
+		"X-Literal":     "$OTLP_TEST_TOKEN",
+		"X-Equals":      "a=b",
+	}
+	for k, v := range expected {
+		if headers[k] != v {
+			t.Errorf("Expected header %s=%q, got %q", k, v, headers[k])
+		}
+	}
+}
+
+func TestParseHeaders_Invalid(t *testing.T) {
+	if _, err := ParseHeaders([]string{"NoEquals"}); err == nil {
+		t.Error("Expected error for header without '='")
+	}
+
+	if _, err := ParseHeaders([]string{"Authorization=${OTLP_TEST_UNSET_VARIABLE}"}); err == nil {
+		t.Error("Expected error for unset environment variable")
+	}
+}
@@ -0,0 +1,23 @@
+package util
+
+import (
+	"net/url"
+	"strings"
+)
+
+// UnixSocketPath returns the socket path of a unix:// address, either
+// unix:///absolute/path or unix:relative/path
+func UnixSocketPath(u *url.URL) (string, bool) {
+	if u.Scheme != "unix" {
+		return "", false
+	}
+	if u.Opaque != "" {
+		return u.Opaque, true
+	}
+	return u.Host + u.Path, true
+}
+
+// IsUnixAddr reports whether addr is a unix socket address
+func IsUnixAddr(addr string) bool {
+	return strings.HasPrefix(addr, "unix:")
+}
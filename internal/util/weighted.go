@@ -0,0 +1,104 @@
+package util
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WeightedChoice picks values with a probability proportional to their weight
+type WeightedChoice[T any] struct {
+	values     []T
+	cumulative []float64
+	total      float64
+}
+
+func NewWeightedChoice[T any](values []T, weights []float64) (*WeightedChoice[T], error) {
+	if len(values) != len(weights) {
+		return nil, fmt.Errorf("mismatched values and weights: %d != %d", len(values), len(weights))
+	}
+
+	w := &WeightedChoice[T]{
+		values:     make([]T, 0, len(values)),
+		cumulative: make([]float64, 0, len(values)),
+	}
+
+	for i, weight := range weights {
+		if weight < 0 {
+			return nil, fmt.Errorf("negative weight: %v", weight)
+		}
+		if weight == 0 {
+			continue
+		}
+
+		w.total += weight
+		w.values = append(w.values, values[i])
+		w.cumulative = append(w.cumulative, w.total)
+	}
+
+	if w.total == 0 {
+		return nil, fmt.Errorf("at least one weight must be > 0")
+	}
+
+	return w, nil
+}
+
+// Pick returns a value given r, a uniformly distributed random number in [0, 1)
+func (w *WeightedChoice[T]) Pick(r float64) T {
+	target := r * w.total
+	idx := sort.Search(len(w.cumulative), func(i int) bool {
+		return w.cumulative[i] > target
+	})
+	if idx >= len(w.values) {
+		idx = len(w.values) - 1
+	}
+
+	return w.values[idx]
+}
+
+// Probability returns the probability that the value at index i is picked
+func (w *WeightedChoice[T]) Probability(i int) float64 {
+	prev := 0.0
+	if i > 0 {
+		prev = w.cumulative[i-1]
+	}
+	return (w.cumulative[i] - prev) / w.total
+}
+
+// Values returns the values with a non-zero weight
+func (w *WeightedChoice[T]) Values() []T {
+	return w.values
+}
+
+// ParseWeights parses a weight list of the format 'name:weight,name:weight'
+func ParseWeights(s string) ([]string, []float64, error) {
+	names := make([]string, 0)
+	weights := make([]float64, 0)
+
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		parts := strings.SplitN(item, ":", 2)
+		if len(parts) != 2 {
+			return nil, nil, fmt.Errorf("invalid weight format: %q (expected 'name:weight')", item)
+		}
+
+		weight, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid weight for %q: %w", parts[0], err)
+		}
+
+		names = append(names, strings.TrimSpace(parts[0]))
+		weights = append(weights, weight)
+	}
+
+	if len(names) == 0 {
+		return nil, nil, fmt.Errorf("empty weight list")
+	}
+
+	return names, weights, nil
+}
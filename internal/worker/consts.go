@@ -5,4 +5,8 @@ const RES_ATTR_GENERATOR_ID = "loadgen.generator_id"
 
 const ELEM_ATTR_START_RANGE = "loadgen.start_range"
 const ELEM_ATTR_RANGE_LEN = "loadgen.range_len"
-const ELEM_ATTR_MESSAGE_ID = "loadgen.message_id"
\ No newline at end of file
+const ELEM_ATTR_MESSAGE_ID = "loadgen.message_id"
+
+// ELEM_ATTR_GENERATOR_ID is the generator ID stamped on elements, the sink
+// falls back to it when the resource has none
+const ELEM_ATTR_GENERATOR_ID = RES_ATTR_GENERATOR_ID
\ No newline at end of file
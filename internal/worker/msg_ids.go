@@ -4,9 +4,17 @@ import (
 	"time"
 
 	"github.com/streamfold/otel-loadgen/internal/control"
+	"github.com/streamfold/otel-loadgen/internal/otlp/anyvalue"
 	otlpCommon "go.opentelemetry.io/proto/otlp/common/v1"
+	otlpMetrics "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"go.uber.org/zap"
 )
 
+// MsgIdGenerator tags generated telemetry so the sink can track delivery.
+// Attributes are always appended in a fixed order after the attributes passed
+// in: the generator ID on resources, and the range start, range length and
+// message ID on elements, followed by the generator ID if it's stamped on
+// elements too.
 type MsgIdGenerator interface {
 	Start()
 	Stop()
@@ -14,13 +22,18 @@ type MsgIdGenerator interface {
 	AddElementAttrs(attrs []*otlpCommon.KeyValue) []*otlpCommon.KeyValue
 }
 
+// ALLOC_SIZE is the default number of message IDs in each range
 const ALLOC_SIZE = 1000
 
 type msgIdGenerator struct {
 	generatorId string
+	// elementId also adds the generator ID to every element
+	elementId   bool
 	nextStartId uint64
-	ctrlChan    chan<- control.Control
-	currRange   *msgIdRange
+	// rangeSize is the number of message IDs allocated to each range
+	rangeSize uint
+	sender    control.Sender
+	currRange *msgIdRange
 }
 
 type msgIdRange struct {
@@ -36,38 +49,58 @@ type MsgID struct {
 	ID      uint64
 }
 
-func NewMsgIdGenerator(generatorId string, ctrlChan chan<- control.Control) MsgIdGenerator {
+// NewMsgIdGenerator creates a generator that allocates message IDs in ranges
+// of rangeSize, reporting each range to sender
+func NewMsgIdGenerator(generatorId string, rangeSize uint, sender control.Sender) MsgIdGenerator {
 	return &msgIdGenerator{
 		generatorId: generatorId,
 		nextStartId: 1,
-		ctrlChan:    ctrlChan,
+		rangeSize:   rangeSize,
+		sender:      sender,
 	}
 }
 
+// NewElementMsgIdGenerator is like NewMsgIdGenerator but also adds the
+// generator ID to every element, so the sink still tracks delivery when a
+// pipeline drops or rewrites resource attributes
+func NewElementMsgIdGenerator(generatorId string, rangeSize uint, sender control.Sender) MsgIdGenerator {
+	g := NewMsgIdGenerator(generatorId, rangeSize, sender).(*msgIdGenerator)
+	g.elementId = true
+	return g
+}
+
 // Add the generator ID to the resource attributes
 func (g *msgIdGenerator) AddResourceAttrs(attrs []*otlpCommon.KeyValue) []*otlpCommon.KeyValue {
 	return append(attrs, &otlpCommon.KeyValue{
 		Key:   string(RES_ATTR_GENERATOR_ID),
-		Value: &otlpCommon.AnyValue{Value: &otlpCommon.AnyValue_StringValue{StringValue: g.generatorId}},
+		Value: anyvalue.String(g.generatorId),
 	})
 }
 
 // Add the individual element attributes, will allocate a new message range as needed
 func (g *msgIdGenerator) AddElementAttrs(attrs []*otlpCommon.KeyValue) []*otlpCommon.KeyValue {
-	nextId := g.nextId()
+	return appendElementAttrs(attrs, g.nextId(), g.generatorId, g.elementId)
+}
 
+func appendElementAttrs(attrs []*otlpCommon.KeyValue, nextId MsgID, generatorId string, elementId bool) []*otlpCommon.KeyValue {
 	attrs = append(attrs, &otlpCommon.KeyValue{
 		Key:   string(ELEM_ATTR_START_RANGE),
-		Value: &otlpCommon.AnyValue{Value: &otlpCommon.AnyValue_IntValue{IntValue: int64(nextId.StartID)}},
+		Value: anyvalue.Int(int64(nextId.StartID)),
 	})
 	attrs = append(attrs, &otlpCommon.KeyValue{
 		Key:   string(ELEM_ATTR_RANGE_LEN),
-		Value: &otlpCommon.AnyValue{Value: &otlpCommon.AnyValue_IntValue{IntValue: int64(nextId.Len)}},
+		Value: anyvalue.Int(int64(nextId.Len)),
 	})
 	attrs = append(attrs, &otlpCommon.KeyValue{
 		Key:   string(ELEM_ATTR_MESSAGE_ID),
-		Value: &otlpCommon.AnyValue{Value: &otlpCommon.AnyValue_IntValue{IntValue: int64(nextId.ID)}},
+		Value: anyvalue.Int(int64(nextId.ID)),
 	})
+	if elementId {
+		attrs = append(attrs, &otlpCommon.KeyValue{
+			Key:   string(ELEM_ATTR_GENERATOR_ID),
+			Value: anyvalue.String(generatorId),
+		})
+	}
 
 	return attrs
 }
@@ -77,13 +110,13 @@ func (g *msgIdGenerator) Start() {
 }
 
 func (g *msgIdGenerator) Stop() {
-	if g.ctrlChan == nil || g.currRange == nil {
+	if g.sender == nil || g.currRange == nil {
 		return
 	}
 
 	// Entire range was not used, send update
 	if g.currRange.used < g.currRange.len {
-		g.ctrlChan <- control.Control{
+		g.sender.Send(control.Control{
 			Type: control.ControlTypeUpdate,
 			Range: control.MessageRange{
 				GeneratorID: g.generatorId,
@@ -91,7 +124,7 @@ func (g *msgIdGenerator) Stop() {
 				RangeLen:    g.currRange.used,
 				Timestamp:   g.currRange.timestamp,
 			},
-		}
+		})
 	}
 }
 
@@ -105,8 +138,8 @@ func (g *msgIdGenerator) nextRange(len uint) *msgIdRange {
 
 	g.nextStartId += uint64(len)
 
-	if g.ctrlChan != nil {
-		g.ctrlChan <- control.Control{
+	if g.sender != nil {
+		g.sender.Send(control.Control{
 			Type: control.ControlTypeNew,
 			Range: control.MessageRange{
 				GeneratorID: g.generatorId,
@@ -114,7 +147,7 @@ func (g *msgIdGenerator) nextRange(len uint) *msgIdRange {
 				RangeLen:    mid.len,
 				Timestamp:   mid.timestamp,
 			},
-		}
+		})
 	}
 
 	return mid
@@ -122,7 +155,7 @@ func (g *msgIdGenerator) nextRange(len uint) *msgIdRange {
 
 func (g *msgIdGenerator) nextId() MsgID {
 	if g.currRange == nil || g.currRange.isFull() {
-		g.currRange = g.nextRange(ALLOC_SIZE)
+		g.currRange = g.nextRange(g.rangeSize)
 	}
 
 	return g.currRange.nextId()
@@ -147,6 +180,77 @@ func (r *msgIdRange) nextId() MsgID {
 	}
 }
 
+type replayMsgIdGenerator struct {
+	log         *zap.Logger
+	generatorId string
+	elementId   bool
+	ids         []MsgID
+	next        int
+	// overrun is set once an element was left untagged
+	overrun bool
+}
+
+// NewReplayMsgIdGenerator tags elements with the given message IDs in order,
+// re-sending messages the sink hasn't acked. Their ranges were published by
+// the original run, so no controls are sent. With elementId the generator ID
+// is also added to every element.
+func NewReplayMsgIdGenerator(log *zap.Logger, generatorId string, ids []MsgID, elementId bool) MsgIdGenerator {
+	return &replayMsgIdGenerator{
+		log:         log,
+		generatorId: generatorId,
+		elementId:   elementId,
+		ids:         ids,
+	}
+}
+
+// AddResourceAttrs implements MsgIdGenerator.
+func (g *replayMsgIdGenerator) AddResourceAttrs(attrs []*otlpCommon.KeyValue) []*otlpCommon.KeyValue {
+	return append(attrs, &otlpCommon.KeyValue{
+		Key:   string(RES_ATTR_GENERATOR_ID),
+		Value: anyvalue.String(g.generatorId),
+	})
+}
+
+// AddElementAttrs implements MsgIdGenerator.
+func (g *replayMsgIdGenerator) AddElementAttrs(attrs []*otlpCommon.KeyValue) []*otlpCommon.KeyValue {
+	// Elements past the replayed IDs are sent untagged, the sink ignores them
+	if g.next >= len(g.ids) {
+		if !g.overrun {
+			g.overrun = true
+			g.log.Warn("replayed message IDs ran out, sending elements without message IDs",
+				zap.String("generator_id", g.generatorId), zap.Int("ids", len(g.ids)))
+		}
+		return attrs
+	}
+
+	id := g.ids[g.next]
+	g.next++
+	return appendElementAttrs(attrs, id, g.generatorId, g.elementId)
+}
+
+// Start implements MsgIdGenerator.
+func (g *replayMsgIdGenerator) Start() {
+}
+
+// Stop implements MsgIdGenerator.
+func (g *replayMsgIdGenerator) Stop() {
+}
+
+// ReplayMsgIDs finds the range of each message ID, IDs outside every range are
+// skipped
+func ReplayMsgIDs(ids []uint64, ranges []control.RangeDump) []MsgID {
+	msgIDs := make([]MsgID, 0, len(ids))
+	for _, id := range ids {
+		for _, r := range ranges {
+			if id >= r.StartID && id < r.StartID+uint64(r.RangeLen) {
+				msgIDs = append(msgIDs, MsgID{StartID: r.StartID, Len: r.RangeLen, ID: id})
+				break
+			}
+		}
+	}
+	return msgIDs
+}
+
 func ExtractGeneratorId(attrs []*otlpCommon.KeyValue) string {
 	for _, attr := range attrs {
 		if attr.Key == RES_ATTR_GENERATOR_ID && attr.Value != nil {
@@ -200,6 +304,28 @@ func ExtractMsgIdParams(attrs []*otlpCommon.KeyValue) (MsgID, bool) {
 	return msgID, haveID && haveLen && haveStartID
 }
 
+// ExtractMsgIdParamsFromDataPoint extracts the message ID params from the
+// attributes of a metric data point of any type
+func ExtractMsgIdParamsFromDataPoint(dp any) (MsgID, bool) {
+	return ExtractMsgIdParams(DataPointAttributes(dp))
+}
+
+// DataPointAttributes returns the attributes of a metric data point of any type
+func DataPointAttributes(dp any) []*otlpCommon.KeyValue {
+	switch v := dp.(type) {
+	case *otlpMetrics.NumberDataPoint:
+		return v.Attributes
+	case *otlpMetrics.HistogramDataPoint:
+		return v.Attributes
+	case *otlpMetrics.ExponentialHistogramDataPoint:
+		return v.Attributes
+	case *otlpMetrics.SummaryDataPoint:
+		return v.Attributes
+	default:
+		return nil
+	}
+}
+
 func getIntValue(value *otlpCommon.AnyValue) (int64, bool) {
 	if value == nil {
 		return 0, false
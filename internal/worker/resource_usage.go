@@ -0,0 +1,75 @@
+package worker
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/stats"
+	"go.uber.org/zap"
+)
+
+// elementStats are the stats counting generated elements, allocations are
+// reported per element
+var elementStats = []string{
+	stats.StatSpansSent.String(),
+	stats.StatLogsSent.String(),
+	stats.StatMetricsSent.String(),
+}
+
+// resourceUsage is a sample of the generator's cumulative resource usage
+type resourceUsage struct {
+	at         time.Time
+	cpu        time.Duration
+	cpuOK      bool
+	allocBytes uint64
+	elements   uint64
+}
+
+func sampleResourceUsage(now time.Time, totals map[string]map[string]uint64) resourceUsage {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	cpu, cpuOK := processCPUTime()
+
+	usage := resourceUsage{at: now, cpu: cpu, cpuOK: cpuOK, allocBytes: ms.TotalAlloc}
+	for _, values := range totals {
+		for _, name := range elementStats {
+			usage.elements += values[name]
+		}
+	}
+	return usage
+}
+
+// reportResourceUsage logs the CPU time and allocations of the generator since
+// the previous report, so the cost of generator features can be compared
+func (w *Workers) reportResourceUsage(now time.Time) {
+	usage := sampleResourceUsage(now, w.stats.Totals())
+	prev := w.lastUsage
+	w.lastUsage = usage
+
+	elapsed := usage.at.Sub(prev.at)
+	if elapsed <= 0 {
+		return
+	}
+
+	allocBytes := usage.allocBytes - prev.allocBytes
+	elements := usage.elements - prev.elements
+
+	fields := []zap.Field{
+		zap.Duration("interval", elapsed),
+		zap.Uint64("alloc_bytes", allocBytes),
+		zap.Uint64("elements_sent", elements),
+	}
+	if elements > 0 {
+		fields = append(fields, zap.Float64("bytes_per_element", float64(allocBytes)/float64(elements)))
+	}
+	if usage.cpuOK {
+		cpu := usage.cpu - prev.cpu
+		fields = append(fields,
+			zap.Duration("cpu_time", cpu),
+			// Percent of a single core, can exceed 100 with several cores busy
+			zap.Float64("cpu_percent", 100*cpu.Seconds()/elapsed.Seconds()))
+	}
+
+	w.log.Info("resource usage", fields...)
+}
@@ -1,15 +1,20 @@
 package worker
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/streamfold/otel-loadgen/internal/control"
 	"github.com/streamfold/otel-loadgen/internal/stats"
+	"github.com/streamfold/otel-loadgen/internal/summary"
 	"go.uber.org/zap"
 )
 
@@ -23,6 +28,20 @@ type Workers struct {
 	client      *http.Client
 	ctrl_client *control.Client
 	msgIdGens   []MsgIdGenerator
+	rampStop    chan bool
+	rampWg      sync.WaitGroup
+	// active is the number of pushers started per worker
+	active atomic.Int64
+	// nextGeneratorIdx is the index of the next prefixed generator ID
+	nextGeneratorIdx int
+	// startTime is when the workers were started, for the run summary
+	startTime time.Time
+	// firstMsgId, if set, is the first message ID of every generator
+	firstMsgId uint64
+	// statsCSV, if set, receives every stats report
+	statsCSV *stats.CSVWriter
+	// lastUsage is the resource usage at the previous resource report
+	lastUsage resourceUsage
 }
 
 type Config struct {
@@ -30,13 +49,101 @@ type Config struct {
 	ReportInterval  time.Duration
 	PushInterval    time.Duration
 	ControlEndpoint string
+	ControlPolicy   ControlPolicy
+	// DrainTimeout bounds how long the final message ranges are flushed to
+	// the control server on stop, zero waits until all are sent
+	DrainTimeout time.Duration
+	// ControlBuffer is the number of message ranges queued for the control
+	// server, default control.DefaultBufferSize
+	ControlBuffer int
+	// ControlOverflow determines whether generators wait for a full control
+	// queue or drop new range notifications
+	ControlOverflow control.Overflow
+	// ControlBatchSize and ControlBatchTimeout batch the message ranges posted
+	// to the control server, a batch size of 0 or 1 posts each on its own
+	ControlBatchSize    int
+	ControlBatchTimeout time.Duration
+	// ControlTransport is the protocol message ranges are published with
+	ControlTransport control.Transport
+	// RampUp starts the pushers of each worker one after the other, evenly
+	// spread across this window, instead of all at once
+	RampUp time.Duration
+	// GeneratorIDPrefix, if set, names generators <prefix>-<index> in start
+	// order instead of random UUIDs, so runs share the same generator namespace.
+	// Message IDs then start at the start time so runs don't share ranges.
+	GeneratorIDPrefix string
+	// StatsFormat is the output format of the periodic stats report
+	StatsFormat stats.Format
+	// StatsCSV, if set, is the path of a CSV file every stats report is
+	// appended to
+	StatsCSV string
+	// ResourceReport logs the generator's CPU time and allocations with every
+	// stats report
+	ResourceReport bool
+	// ElementGeneratorID adds the generator ID to every span, log record and
+	// data point as well as the resource
+	ElementGeneratorID bool
+	// RangeSize is the number of message IDs in each range reported to the
+	// control server, default ALLOC_SIZE. Larger ranges send fewer controls
+	// but the sink tracks more memory per range.
+	RangeSize uint
 }
 
+// ControlPolicy determines what happens when the control server can't be
+// reached at startup
+type ControlPolicy int
+
+const (
+	// ControlPolicyWarn logs a warning and keeps tracking, in case the control
+	// server comes up later
+	ControlPolicyWarn ControlPolicy = iota
+	// ControlPolicyRequired fails startup
+	ControlPolicyRequired
+	// ControlPolicyOptional logs a warning and disables message tracking
+	ControlPolicyOptional
+)
+
+const controlPingTimeout = 5 * time.Second
+
 func New(cfg Config, log *zap.Logger, client *http.Client) (*Workers, error) {
 	var ctrl_client *control.Client
 	if cfg.ControlEndpoint != "" {
 		var err error
-		ctrl_client, err = control.NewClient(cfg.ControlEndpoint, log)
+		ctrl_client, err = control.NewClient(cfg.ControlEndpoint, control.ClientConfig{
+			FlushTimeout: cfg.DrainTimeout,
+			BufferSize:   cfg.ControlBuffer,
+			Overflow:     cfg.ControlOverflow,
+			BatchSize:    cfg.ControlBatchSize,
+			BatchTimeout: cfg.ControlBatchTimeout,
+			Transport:    cfg.ControlTransport,
+		}, log)
+		if err != nil {
+			return nil, err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), controlPingTimeout)
+		err = ctrl_client.Ping(ctx)
+		cancel()
+
+		if err != nil {
+			switch cfg.ControlPolicy {
+			case ControlPolicyRequired:
+				return nil, fmt.Errorf("control server %s is unreachable: %w", cfg.ControlEndpoint, err)
+			case ControlPolicyOptional:
+				log.Warn("control server is unreachable, message tracking is disabled for this run",
+					zap.String("endpoint", cfg.ControlEndpoint), zap.Error(err))
+				ctrl_client = nil
+			default:
+				log.Warn("control server is unreachable, message ranges will be lost until it is available",
+					zap.String("endpoint", cfg.ControlEndpoint), zap.Error(err))
+			}
+		}
+	}
+
+	var statsCSV *stats.CSVWriter
+	if cfg.StatsCSV != "" {
+		var err error
+		statsCSV, err = stats.NewCSVWriter(cfg.StatsCSV)
 		if err != nil {
 			return nil, err
 		}
@@ -49,6 +156,7 @@ func New(cfg Config, log *zap.Logger, client *http.Client) (*Workers, error) {
 		client:      client,
 		ctrl_client: ctrl_client,
 		msgIdGens:   make([]MsgIdGenerator, 0),
+		statsCSV:    statsCSV,
 	}, nil
 }
 
@@ -63,16 +171,36 @@ func (w *Workers) Add(domain string, worker Worker) error {
 }
 
 func (w *Workers) Start() {
+	w.startTime = time.Now()
+	if w.cfg.GeneratorIDPrefix != "" {
+		// Prefixed generator IDs repeat across runs, so message IDs start at
+		// the start time instead of 1. A rerun then allocates ranges past
+		// those of the previous run, rather than reusing ranges the sink is
+		// still tracking or has already reaped.
+		w.firstMsgId = uint64(w.startTime.UnixNano())
+	}
+	if w.cfg.ResourceReport {
+		w.lastUsage = sampleResourceUsage(w.startTime, w.stats.Totals())
+	}
+
 	if w.ctrl_client != nil {
 		w.ctrl_client.Start()
 	}
-	for _, worker := range w.workers {
-		for i := 0; i < w.cfg.NumWorkers; i++ {
-			idGen := w.newIdGen()
-			w.msgIdGens = append(w.msgIdGens, idGen)
-			idGen.Start()
 
-			worker.Start(w.cfg.PushInterval, idGen)
+	w.rampStop = make(chan bool)
+	if w.cfg.RampUp > 0 {
+		// The first pushers start right away so load begins immediately
+		w.startPushers()
+
+		w.rampWg.Add(1)
+		go func() {
+			defer w.rampWg.Done()
+
+			w.rampUp()
+		}()
+	} else {
+		for i := 0; i < w.cfg.NumWorkers; i++ {
+			w.startPushers()
 		}
 	}
 
@@ -88,21 +216,131 @@ func (w *Workers) Start() {
 	}()
 }
 
+// rampUp linearly increases the number of active pushers after the first
+// ones, the last ones are started one step before the ramp window ends
+func (w *Workers) rampUp() {
+	start := time.Now()
+	step := w.cfg.RampUp / time.Duration(max(w.cfg.NumWorkers, 1))
+
+	for i := 1; i < w.cfg.NumWorkers; i++ {
+		timer := time.NewTimer(time.Until(start.Add(step * time.Duration(i))))
+		select {
+		case <-w.rampStop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		w.startPushers()
+		w.log.Info("ramping up workers",
+			zap.Int64("active", w.active.Load()), zap.Int("total", w.cfg.NumWorkers))
+	}
+}
+
+// startPushers starts one more pusher of each worker
+func (w *Workers) startPushers() {
+	for _, worker := range w.workers {
+		idGen := w.newIdGen()
+		w.msgIdGens = append(w.msgIdGens, idGen)
+		idGen.Start()
+
+		worker.Start(w.cfg.PushInterval, idGen)
+	}
+	w.active.Add(1)
+}
+
+// rampPhase describes the progress of the ramp up for the stats report
+func (w *Workers) rampPhase() string {
+	active := w.active.Load()
+	phase := "holding"
+	if active < int64(w.cfg.NumWorkers) {
+		phase = "ramping up"
+	}
+
+	return fmt.Sprintf("%s, %d/%d workers active", phase, active, w.cfg.NumWorkers)
+}
+
 func (w *Workers) Stop() {
 	close(w.statsStop)
 	w.statsWg.Wait()
 
+	if w.statsCSV != nil {
+		if err := w.statsCSV.Close(); err != nil {
+			w.log.Error("failed to close stats csv", zap.Error(err))
+		}
+	}
+
+	// No more pushers may be started once workers are stopped
+	close(w.rampStop)
+	w.rampWg.Wait()
+
 	for _, worker := range w.workers {
 		worker.StopAll()
 	}
 
-	for _, msg_id := range w.msgIdGens {
-		msg_id.Stop()
+	// Each generator reports its last, partly used range on stop. Those are
+	// drained to the control server before it's stopped, so the tracker
+	// doesn't wait on IDs that were never sent.
+	stopIdGens := func() {
+		for _, msg_id := range w.msgIdGens {
+			msg_id.Stop()
+		}
 	}
 
 	if w.ctrl_client != nil {
-		w.ctrl_client.Stop()
+		w.ctrl_client.Drain(stopIdGens)
+	} else {
+		stopIdGens()
+	}
+}
+
+// domainReportJSON is a line of the JSON stats report
+type domainReportJSON struct {
+	Time   time.Time          `json:"time"`
+	Domain string             `json:"domain"`
+	Stats  []stats.StatReport `json:"stats"`
+	// ActiveWorkers is set while ramping up
+	ActiveWorkers *int64 `json:"active_workers,omitempty"`
+}
+
+// printJSONStats prints a JSON line per domain
+func (w *Workers) printJSONStats(now time.Time, reports map[string][]stats.StatReport) {
+	domains := make([]string, 0, len(reports))
+	for domain := range reports {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+
+	for _, domain := range domains {
+		if len(reports[domain]) == 0 {
+			continue
+		}
+
+		line := domainReportJSON{Time: now, Domain: domain, Stats: reports[domain]}
+		if w.cfg.RampUp > 0 {
+			active := w.active.Load()
+			line.ActiveWorkers = &active
+		}
+
+		buf, err := json.Marshal(line)
+		if err != nil {
+			w.log.Error("failed to marshal stats report", zap.Error(err))
+			continue
+		}
+		fmt.Println(string(buf))
+	}
+}
+
+// Summary returns the run summary from the start of the workers until end
+func (w *Workers) Summary(end time.Time) summary.Summary {
+	s := summary.New(w.startTime, end, w.stats.Totals())
+	for domain, latency := range w.stats.Percentiles() {
+		if sig, ok := s.Signals[domain]; ok {
+			sig.LatencyMs = latency
+			s.Signals[domain] = sig
+		}
 	}
+	return s
 }
 
 func (w *Workers) newIdGen() MsgIdGenerator {
@@ -110,7 +348,33 @@ func (w *Workers) newIdGen() MsgIdGenerator {
 		return NopMsgIdGenerator()
 	}
 
-	return NewMsgIdGenerator(uuid.New().String(), w.ctrl_client.MessageChannel())
+	var gen MsgIdGenerator
+	if w.cfg.ElementGeneratorID {
+		gen = NewElementMsgIdGenerator(w.newGeneratorId(), w.rangeSize(), w.ctrl_client)
+	} else {
+		gen = NewMsgIdGenerator(w.newGeneratorId(), w.rangeSize(), w.ctrl_client)
+	}
+	if w.firstMsgId > 0 {
+		gen.(*msgIdGenerator).nextStartId = w.firstMsgId
+	}
+	return gen
+}
+
+func (w *Workers) rangeSize() uint {
+	if w.cfg.RangeSize == 0 {
+		return ALLOC_SIZE
+	}
+	return w.cfg.RangeSize
+}
+
+func (w *Workers) newGeneratorId() string {
+	if w.cfg.GeneratorIDPrefix == "" {
+		return uuid.New().String()
+	}
+
+	id := fmt.Sprintf("%s-%d", w.cfg.GeneratorIDPrefix, w.nextGeneratorIdx)
+	w.nextGeneratorIdx++
+	return id
 }
 
 func (w *Workers) printStats(ticker *time.Ticker) {
@@ -122,11 +386,30 @@ func (w *Workers) printStats(ticker *time.Ticker) {
 		case <-ticker.C:
 			now := time.Now()
 
+			if w.cfg.ResourceReport {
+				w.reportResourceUsage(now)
+			}
+
 			reports := w.stats.Report(now)
 			if len(reports) == 0 {
 				continue
 			}
 
+			if w.statsCSV != nil {
+				if err := w.statsCSV.Write(now, reports); err != nil {
+					w.log.Error("failed to write stats csv", zap.Error(err))
+				}
+			}
+
+			if w.cfg.StatsFormat == stats.FormatJSON {
+				w.printJSONStats(now, reports)
+				continue
+			}
+
+			if w.cfg.RampUp > 0 {
+				fmt.Printf("RAMP: %s\n", w.rampPhase())
+			}
+
 			for domain, domainReports := range reports {
 				reportOuts := make([]string, 0)
 				for _, r := range domainReports {
@@ -0,0 +1,334 @@
+package worker
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/streamfold/otel-loadgen/internal/control"
+	"github.com/streamfold/otel-loadgen/internal/stats"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// unreachableEndpoint returns the address of a port that nothing listens on
+func unreachableEndpoint(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+
+	return addr
+}
+
+func TestNew_ControlRequired(t *testing.T) {
+	_, err := New(Config{
+		ControlEndpoint: unreachableEndpoint(t),
+		ControlPolicy:   ControlPolicyRequired,
+	}, zap.NewNop(), http.DefaultClient)
+	if err == nil {
+		t.Fatal("Expected startup to fail when the control server is required and unreachable")
+	}
+}
+
+func TestNew_ControlOptional(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+
+	w, err := New(Config{
+		ControlEndpoint: unreachableEndpoint(t),
+		ControlPolicy:   ControlPolicyOptional,
+	}, zap.New(core), http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w.ctrl_client != nil {
+		t.Error("Expected control client to be disabled")
+	}
+	if _, ok := w.newIdGen().(nopMsgIdGenerator); !ok {
+		t.Errorf("Expected a nop message ID generator, got %T", w.newIdGen())
+	}
+	if logs.Len() != 1 {
+		t.Errorf("Expected a warning to be logged, got %d entries", logs.Len())
+	}
+}
+
+func TestNew_ControlReachable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/health" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	core, logs := observer.New(zap.WarnLevel)
+
+	w, err := New(Config{
+		ControlEndpoint: srv.URL,
+		ControlPolicy:   ControlPolicyRequired,
+	}, zap.New(core), http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w.ctrl_client == nil {
+		t.Error("Expected control client to be enabled")
+	}
+	if logs.Len() != 0 {
+		t.Errorf("Expected no warnings, got %d", logs.Len())
+	}
+}
+
+// countingWorker counts the pushers started
+type countingWorker struct {
+	started atomic.Int64
+}
+
+func (c *countingWorker) Init(stats.Builder, *http.Client) error { return nil }
+
+func (c *countingWorker) Start(time.Duration, MsgIdGenerator) {
+	c.started.Add(1)
+}
+
+func (c *countingWorker) StopAll() {}
+
+func TestWorkersStart_RampUp(t *testing.T) {
+	const rampUp = 200 * time.Millisecond
+
+	w, err := New(Config{NumWorkers: 4, ReportInterval: time.Hour, RampUp: rampUp}, zap.NewNop(), http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cw := &countingWorker{}
+	if err := w.Add("test", cw); err != nil {
+		t.Fatal(err)
+	}
+
+	w.Start()
+	if got := cw.started.Load(); got != 1 {
+		t.Errorf("Expected the first pusher to start right away, got %d", got)
+	}
+	if !strings.HasPrefix(w.rampPhase(), "ramping up") {
+		t.Errorf("Expected ramping up phase, got %q", w.rampPhase())
+	}
+
+	time.Sleep(rampUp + 100*time.Millisecond)
+	if got := cw.started.Load(); got != 4 {
+		t.Errorf("Expected all 4 pushers after the ramp, got %d", got)
+	}
+	if w.rampPhase() != "holding, 4/4 workers active" {
+		t.Errorf("Expected holding phase, got %q", w.rampPhase())
+	}
+
+	w.Stop()
+}
+
+func TestWorkersStop_DuringRampUp(t *testing.T) {
+	w, err := New(Config{NumWorkers: 4, ReportInterval: time.Hour, RampUp: time.Hour}, zap.NewNop(), http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cw := &countingWorker{}
+	if err := w.Add("test", cw); err != nil {
+		t.Fatal(err)
+	}
+
+	w.Start()
+	w.Stop()
+
+	if got := cw.started.Load(); got != 1 {
+		t.Errorf("Expected remaining pushers not to start after stop, got %d", got)
+	}
+}
+
+// recordingWorker records the message ID generators of its pushers
+type recordingWorker struct {
+	idGens []MsgIdGenerator
+}
+
+func (r *recordingWorker) Init(stats.Builder, *http.Client) error { return nil }
+
+func (r *recordingWorker) Start(_ time.Duration, msgIdGen MsgIdGenerator) {
+	r.idGens = append(r.idGens, msgIdGen)
+}
+
+func (r *recordingWorker) StopAll() {}
+
+func TestWorkersStart_GeneratorIDPrefix(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	const numWorkers = 4
+	w, err := New(Config{
+		NumWorkers:        numWorkers,
+		ReportInterval:    time.Hour,
+		ControlEndpoint:   srv.URL,
+		GeneratorIDPrefix: "bench",
+	}, zap.NewNop(), http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rw := &recordingWorker{}
+	if err := w.Add("test", rw); err != nil {
+		t.Fatal(err)
+	}
+
+	w.Start()
+	defer w.Stop()
+
+	if len(rw.idGens) != numWorkers {
+		t.Fatalf("Expected %d pushers, got %d", numWorkers, len(rw.idGens))
+	}
+	for i, idGen := range rw.idGens {
+		expected := fmt.Sprintf("bench-%d", i)
+		if id := ExtractGeneratorId(idGen.AddResourceAttrs(nil)); id != expected {
+			t.Errorf("Expected generator id %s, got %s", expected, id)
+		}
+	}
+}
+
+// spanWorker counts a fixed number of spans as sent when started
+type spanWorker struct {
+	spans stats.Stat
+}
+
+func (s *spanWorker) Init(sb stats.Builder, _ *http.Client) error {
+	s.spans = sb.NewStat(stats.StatSpansSent)
+	return nil
+}
+
+func (s *spanWorker) Start(time.Duration, MsgIdGenerator) {
+	s.spans.Incr(1000)
+}
+
+func (s *spanWorker) StopAll() {}
+
+func TestWorkers_ResourceReport(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	w, err := New(Config{NumWorkers: 1, ReportInterval: 50 * time.Millisecond, ResourceReport: true}, zap.New(core), http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Add("test", &spanWorker{}); err != nil {
+		t.Fatal(err)
+	}
+
+	w.Start()
+	time.Sleep(120 * time.Millisecond)
+	w.Stop()
+
+	entries := logs.FilterMessage("resource usage").All()
+	if len(entries) == 0 {
+		t.Fatal("Expected a resource usage log line")
+	}
+
+	fields := entries[0].ContextMap()
+	for _, key := range []string{"interval", "alloc_bytes", "elements_sent", "bytes_per_element", "cpu_time", "cpu_percent"} {
+		if _, ok := fields[key]; !ok {
+			t.Errorf("Expected field %q in %v", key, fields)
+		}
+	}
+	if fields["elements_sent"] != uint64(1000) {
+		t.Errorf("Expected 1000 elements sent, got %v", fields["elements_sent"])
+	}
+}
+
+func TestWorkers_NoResourceReport(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	w, err := New(Config{NumWorkers: 1, ReportInterval: 20 * time.Millisecond}, zap.New(core), http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Add("test", &spanWorker{}); err != nil {
+		t.Fatal(err)
+	}
+
+	w.Start()
+	time.Sleep(60 * time.Millisecond)
+	w.Stop()
+
+	if n := logs.FilterMessage("resource usage").Len(); n != 0 {
+		t.Errorf("Expected no resource usage log lines, got %d", n)
+	}
+}
+
+func TestElementMsgIdGenerator(t *testing.T) {
+	ctrlChan := make(chan control.Control, 10)
+
+	elem := NewElementMsgIdGenerator("gen-a", ALLOC_SIZE, control.ChanSender(ctrlChan)).AddElementAttrs(nil)
+	if id := ExtractGeneratorId(elem); id != "gen-a" {
+		t.Errorf("Expected the element generator id gen-a, got %q", id)
+	}
+	if _, ok := ExtractMsgIdParams(elem); !ok {
+		t.Error("Expected message id params alongside the element generator id")
+	}
+
+	if id := ExtractGeneratorId(NewMsgIdGenerator("gen-a", ALLOC_SIZE, control.ChanSender(ctrlChan)).AddElementAttrs(nil)); id != "" {
+		t.Errorf("Expected no element generator id by default, got %q", id)
+	}
+}
+
+func TestMsgIdGenerator_RangeSize(t *testing.T) {
+	ctrlChan := make(chan control.Control, 10)
+	gen := NewMsgIdGenerator("gen-a", 4, control.ChanSender(ctrlChan))
+
+	for i := 0; i < 10; i++ {
+		gen.AddElementAttrs(nil)
+	}
+	gen.Stop()
+	close(ctrlChan)
+
+	var got []control.Control
+	for ctrl := range ctrlChan {
+		got = append(got, ctrl)
+	}
+	want := []struct {
+		typ   control.ControlType
+		start uint64
+		len   uint
+	}{
+		{control.ControlTypeNew, 1, 4},
+		{control.ControlTypeNew, 5, 4},
+		{control.ControlTypeNew, 9, 4},
+		{control.ControlTypeUpdate, 9, 2},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d controls, got %d", len(want), len(got))
+	}
+	for i, w := range want {
+		r := got[i].Range
+		if got[i].Type != w.typ || r.StartID != w.start || r.RangeLen != w.len {
+			t.Errorf("Control %d: expected %v %d+%d, got %v %d+%d", i, w.typ, w.start, w.len, got[i].Type, r.StartID, r.RangeLen)
+		}
+	}
+}
+
+func TestReplayMsgIdGenerator_Overrun(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	gen := NewReplayMsgIdGenerator(zap.New(core), "gen-a", []MsgID{{StartID: 1, Len: 10, ID: 5}}, false)
+
+	if params, ok := ExtractMsgIdParams(gen.AddElementAttrs(nil)); !ok || params.ID != 5 {
+		t.Errorf("Expected the replayed message id 5, got %+v", params)
+	}
+
+	// Elements past the replayed IDs are left untagged rather than panicking
+	for i := 0; i < 3; i++ {
+		if attrs := gen.AddElementAttrs(nil); len(attrs) != 0 {
+			t.Errorf("Expected no attributes once the IDs ran out, got %d", len(attrs))
+		}
+	}
+	if n := logs.FilterMessageSnippet("ran out").Len(); n != 1 {
+		t.Errorf("Expected a single overrun warning, got %d", n)
+	}
+}